@@ -1,7 +1,10 @@
 package cmd
 
 import (
+	"fmt"
+	"strings"
 	"varanus/internal/app"
+	"varanus/internal/config"
 	"varanus/internal/util"
 
 	"github.com/spf13/cobra"
@@ -12,8 +15,8 @@ func makeConfigCmd(context *CmdContext) *cobra.Command {
 	// configCmd represents the config command
 	configCmd := &cobra.Command{
 		Use:   "config",
-		Short: "Operations for YAML configs",
-		Long:  `Operations for YAML configs`,
+		Short: "Operations for YAML and JSON configs",
+		Long:  `Operations for YAML and JSON configs`,
 	}
 	// Here you will define your flags and configuration settings.
 
@@ -35,6 +38,27 @@ func makeConfigCmd(context *CmdContext) *cobra.Command {
 	checkCmd := makeCheckCmd(context)
 	configCmd.AddCommand(checkCmd)
 
+	showCmd := makeShowCmd(context)
+	configCmd.AddCommand(showCmd)
+
+	migrateCmd := makeMigrateCmd(context)
+	configCmd.AddCommand(migrateCmd)
+
+	schemaCmd := makeSchemaCmd(context)
+	configCmd.AddCommand(schemaCmd)
+
+	docsCmd := makeDocsCmd(context)
+	configCmd.AddCommand(docsCmd)
+
+	initCmd := makeInitCmd(context)
+	configCmd.AddCommand(initCmd)
+
+	diffCmd := makeDiffCmd(context)
+	configCmd.AddCommand(diffCmd)
+
+	fmtCmd := makeFmtCmd(context)
+	configCmd.AddCommand(fmtCmd)
+
 	return configCmd
 }
 
@@ -53,6 +77,12 @@ Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := requireInputOrConfigDir(cmd)
+			if err != nil {
+				return err
+			}
+			//once we get through validation, silence the usage so it doesn't mix with --output json
+			cmd.SilenceUsage = true
 			return context.App.CheckConfig(&cmdArgs, cmd.OutOrStdout())
 		},
 	}
@@ -60,19 +90,83 @@ to quickly create a Cobra application.`,
 	// Here you will define your flags and configuration settings.
 
 	//local flags
-	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML config to be unsealed.")
-	cmd.MarkFlagRequired("input")
-	cmd.MarkFlagFilename("input", "yaml", "yml")
+	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML or JSON config to be checked, along with the files it includes.")
+	cmd.MarkFlagFilename("input", "yaml", "yml", "json")
+
+	cmdArgs.ConfigDir = cmd.Flags().String(CONFIG_DIR_FLAG, "", "A directory of config fragments to merge and check instead of --input.")
+	cmd.MarkFlagDirname(CONFIG_DIR_FLAG)
+	cmd.MarkFlagsMutuallyExclusive("input", CONFIG_DIR_FLAG)
+
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+	cmdArgs.OutputMode = context.OutputMode
 
 	cmdArgs.PrivateKey = cmd.Flags().StringP("privateKey", "k", "", "The filename of the private key used to seal the config.")
 	cmd.MarkFlagFilename("privateKey")
 
 	cmdArgs.Passphrase = cmd.Flags().StringP("passphrase", "p", "", "The passphrase for the private key, if there is one.")
 
+	cmdArgs.Strict = cmd.Flags().Bool("strict", false, "If set, validation warnings, such as plaintext passwords, fail the check.")
+
+	return cmd
+
+}
+
+func makeShowCmd(context *CmdContext) *cobra.Command {
+
+	var cmdArgs = app.ShowConfigArgs{}
+
+	// cmd represents the show command
+	var cmd = &cobra.Command{
+		Use:   "show",
+		Short: "Show a config merged from its includes or a config directory",
+		Long: `Show a config merged from the files it includes, or from the fragments of a config
+directory, as YAML, or as JSON with --output json.
+
+With --resolved, the accounts are shown after they have inherited from the templates they
+extend, which is the config that is validated and used.  For example:
+
+varanus config show -i config.yaml --resolved`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := requireInputOrConfigDir(cmd)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return context.App.ShowConfig(&cmdArgs, cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML or JSON config to be shown, along with the files it includes.")
+	cmd.MarkFlagFilename("input", "yaml", "yml", "json")
+
+	cmdArgs.ConfigDir = cmd.Flags().String(CONFIG_DIR_FLAG, "", "A directory of config fragments to merge and show instead of --input.")
+	cmd.MarkFlagDirname(CONFIG_DIR_FLAG)
+	cmd.MarkFlagsMutuallyExclusive("input", CONFIG_DIR_FLAG)
+
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+	cmdArgs.OutputMode = context.OutputMode
+
+	cmdArgs.Resolved = cmd.Flags().Bool("resolved", false, "If set, the accounts are shown after inheriting from their templates, and the templates are left out.")
+
 	return cmd
+}
 
+// CONFIG_DIR_FLAG names the flag for a directory of config fragments, used instead of --input
+const CONFIG_DIR_FLAG = "config-dir"
+
+// requireInputOrConfigDir returns an error unless --input or --config-dir is set.  Setting both is
+// rejected by cobra because they are mutually exclusive.
+func requireInputOrConfigDir(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("input") && !cmd.Flags().Changed(CONFIG_DIR_FLAG) {
+		return fmt.Errorf("one of the flags \"input\" or \"%s\" must be set", CONFIG_DIR_FLAG)
+	}
+	return nil
 }
 
+// FORMAT_FLAG_USAGE is the help for the --format flag shared by the config commands
+const FORMAT_FLAG_USAGE = "The format of the input config, yaml or json.  If omitted, it is taken from the input file extension.  Sealing and unsealing write the output in the same format."
+
 const SEALED_FILE_TOKEN = "sealed"
 
 func makeSealCmd(context *CmdContext) *cobra.Command {
@@ -83,62 +177,74 @@ func makeSealCmd(context *CmdContext) *cobra.Command {
 		Use:   "seal",
 		Short: "Seal the sensitive values of a configuration with a public key",
 		Long: `Seal parses a config file and replaces sensitive values, such as passwords, with RSA
-	encrypted values.  Since asymmetric encryption is used, only the public key is required for sealing.
-	
-	The corresponding private key must be provided to the varanus application using the config so that
-	it can unseal the config values during monitoring.
-	
-	For example, consider the following YAML file:
-	
-	  mail:
-	  accounts:
-		- name: test1
-		smtp:
-		  sender_address: "example@example.com"
-		  server_address: "smtp.example.com"
-		  port: 465
-		  username: joeuser@example.com
-		  password: it's a secret
-		imap:
-		  server_address: "imap.example.com"
-		  port: 993
-		  username: janeuser@example.com
-		  password: it's a secret
-	  send_limits: []
-	
-	After running the seal command, the output file will look like:
-	
-	mail:
-	  accounts:
-		- name: test1
-		  smtp:
-			sender_address: "example@example.com"
-			server_address: "smtp.example.com"	
-			port: 465
-			username: joeuser@example.com
-			password: sealed(<encrypted string>)
-		  imap:
-			server_address: "imap.example.com"
-			port: 993
-			username: janeuser@example.com
-			password: sealed(<encrypted string>)
-	  send_limits: []
-	
-	Repeated calls to seal() will ignore values that are already sealed and only seal any unsealed
-	values. Ensure that you are using the same private key, or the resulting config file will not work
-	because the varanus server only accepts a single key.
-	`,
+encrypted values.  Since asymmetric encryption is used, only the public key is required for sealing.
+
+The corresponding private key must be provided to the varanus application using the config so that
+it can unseal the config values during monitoring.  "varanus config docs" lists the fields that are
+sealable.
+
+For example, consider the following YAML file:
+
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: joeuser@example.com
+        password: it's a secret
+      imap:
+        recipient_address: example@example.com
+        server_address: imap.example.com
+        port: 993
+        use_tls: true
+        username: joeuser@example.com
+        password: it's a secret
+        mailbox_name: INBOX
+
+After running the seal command, the output file will look like:
+
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: joeuser@example.com
+        password: sealed(<encrypted string>)
+      imap:
+        recipient_address: example@example.com
+        server_address: imap.example.com
+        port: 993
+        use_tls: true
+        username: joeuser@example.com
+        password: sealed(<encrypted string>)
+        mailbox_name: INBOX
+
+Only the sealed values change; comments and layout are kept.  Repeated calls to seal will ignore
+values that are already sealed and only seal any unsealed values.  Ensure that you are using the
+same key pair, or the resulting config file will not work because the varanus server only accepts
+a single key.
+`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := requireInputOrConfigDir(cmd)
+			if err != nil {
+				return err
+			}
 
-			//set output from input if not set
-			if *cmdArgs.Output == "" {
+			//set output from input if not set; fragments of a config directory are sealed in place
+			if *cmdArgs.Output == "" && *cmdArgs.Input != "" {
 				*cmdArgs.Output = util.AddValueBeforeExtension(*cmdArgs.Input, SEALED_FILE_TOKEN)
 			}
 
 			//once we get through validation, silence the usage
 			cmd.SilenceUsage = true
 
-			err := context.App.SealConfig(&cmdArgs, cmd.OutOrStdout())
+			err = context.App.SealConfig(&cmdArgs, cmd.OutOrStdout())
 			if err != nil {
 				return err
 			}
@@ -150,16 +256,23 @@ func makeSealCmd(context *CmdContext) *cobra.Command {
 	// Here you will define your flags and configuration settings.
 
 	//local flags
-	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML config to be sealed.")
-	cmd.MarkFlagRequired("input")
-	cmd.MarkFlagFilename("input", "yaml", "yml")
+	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML or JSON config to be sealed.  The files it includes are validated with it, but not sealed.")
+	cmd.MarkFlagFilename("input", "yaml", "yml", "json")
+
+	cmdArgs.ConfigDir = cmd.Flags().String(CONFIG_DIR_FLAG, "", "A directory of config fragments to seal in place instead of --input.  Each fragment is sealed on its own.")
+	cmd.MarkFlagDirname(CONFIG_DIR_FLAG)
+	cmd.MarkFlagsMutuallyExclusive("input", CONFIG_DIR_FLAG)
+
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+	cmdArgs.OutputMode = context.OutputMode
 
 	cmdArgs.PublicKey = cmd.Flags().StringP("publicKey", "k", "", "The filename of the public key used to seal the config.")
 	cmd.MarkFlagRequired("publicKey")
 	cmd.MarkFlagFilename("publicKey")
 
-	cmdArgs.Output = cmd.Flags().StringP("output", "o", "", "The filename to write the output to.  If omitted, the input file path is used with '.sealed' injected before the extension.")
-	cmd.MarkFlagFilename("output")
+	cmdArgs.Output = cmd.Flags().StringP("outputFile", "o", "", "The filename to write the output to.  If omitted, the input file path is used with '.sealed' injected before the extension.")
+	cmd.MarkFlagFilename("outputFile")
+	cmd.MarkFlagsMutuallyExclusive("outputFile", CONFIG_DIR_FLAG)
 
 	cmdArgs.ForceOverwrite = cmd.Flags().BoolP("forceOverwrite", "f", false, "If set, overwrite an existing file with the output.")
 
@@ -176,61 +289,70 @@ func makeUnsealCmd(context *CmdContext) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "unseal",
 		Short: "Unseal the sensitive values of a configuration with a private key",
-		Long: `
-	Unseal parses a config file and replaces the sealed sensitive data, such as passwords, with 
-	their plaintext values.  Since asymmetric encryption is used, the private/secret key is
-	required for unsealing.
-		
-	For example, consider the following YAML file:
-	
-	  mail:
-	  accounts:
-		- name: test1
-		smtp:
-		  sender_address: "example@example.com"
-		  server_address: "smtp.example.com"
-		  port: 465
-		  username: joeuser@example.com
-		  password: sealed(<encrypted string>)
-		imap:
-		  server_address: "imap.example.com"
-		  port: 993
-		  username: janeuser@example.com
-		  password: sealed(<encrypted string>)
-	  send_limits: []
-	
-	After running the unseal command, the output file will look like:
-	
-	mail:
-	  accounts:
-		- name: test1
-		  smtp:
-			sender_address: "example@example.com"
-			server_address: "smtp.example.com"	
-			port: 465
-			username: joeuser@example.com
-			password: it's a secret
-		  imap:
-			server_address: "imap.example.com"
-			port: 993
-			username: janeuser@example.com
-			password: it's a secret
-	  send_limits: []
-	
-	Repeated calls to unseal will ignore values that are already unsealed and only unseal any sealed
-	values.
-	`,
+		Long: `Unseal parses a config file and replaces the sealed sensitive values, such as passwords,
+with their plaintext values.  Since asymmetric encryption is used, the private key is required for
+unsealing.
+
+For example, consider the following YAML file:
+
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: joeuser@example.com
+        password: sealed(<encrypted string>)
+      imap:
+        recipient_address: example@example.com
+        server_address: imap.example.com
+        port: 993
+        use_tls: true
+        username: joeuser@example.com
+        password: sealed(<encrypted string>)
+        mailbox_name: INBOX
+
+After running the unseal command, the output file will look like:
+
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: joeuser@example.com
+        password: it's a secret
+      imap:
+        recipient_address: example@example.com
+        server_address: imap.example.com
+        port: 993
+        use_tls: true
+        username: joeuser@example.com
+        password: it's a secret
+        mailbox_name: INBOX
+
+Only the sealed values change; comments and layout are kept.  Repeated calls to unseal will ignore
+values that are already unsealed and only unseal any sealed values.
+`,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			err := requireInputOrConfigDir(cmd)
+			if err != nil {
+				return err
+			}
 
-			//set the output from the input if not set
-			if *cmdArgs.Output == "" {
+			//set the output from the input if not set; fragments of a config directory are unsealed in place
+			if *cmdArgs.Output == "" && *cmdArgs.Input != "" {
 				*cmdArgs.Output = util.AddValueBeforeExtension(*cmdArgs.Input, UNSEALED_FILE_TOKEN)
 			}
 
 			//once we get through validation, silence the usage
 			cmd.SilenceUsage = true
 
-			err := context.App.UnsealConfig(&cmdArgs, cmd.OutOrStdout())
+			err = context.App.UnsealConfig(&cmdArgs, cmd.OutOrStdout())
 			if err != nil {
 				return err
 			}
@@ -242,9 +364,15 @@ func makeUnsealCmd(context *CmdContext) *cobra.Command {
 	// Here you will define your flags and configuration settings.
 
 	//local flags
-	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML config to be unsealed.")
-	cmd.MarkFlagRequired("input")
-	cmd.MarkFlagFilename("input", "yaml", "yml")
+	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML or JSON config to be unsealed.  The files it includes are validated with it, but not unsealed.")
+	cmd.MarkFlagFilename("input", "yaml", "yml", "json")
+
+	cmdArgs.ConfigDir = cmd.Flags().String(CONFIG_DIR_FLAG, "", "A directory of config fragments to unseal in place instead of --input.  Each fragment is unsealed on its own.")
+	cmd.MarkFlagDirname(CONFIG_DIR_FLAG)
+	cmd.MarkFlagsMutuallyExclusive("input", CONFIG_DIR_FLAG)
+
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+	cmdArgs.OutputMode = context.OutputMode
 
 	cmdArgs.PrivateKey = cmd.Flags().StringP("privateKey", "k", "", "The filename of the private key used to seal the config.")
 	cmd.MarkFlagRequired("privateKey")
@@ -252,8 +380,290 @@ func makeUnsealCmd(context *CmdContext) *cobra.Command {
 
 	cmdArgs.Passphrase = cmd.Flags().StringP("passphrase", "p", "", "The passphrase for the private key, if there is one.")
 
-	cmdArgs.Output = cmd.Flags().StringP("output", "o", "", "The filename to write the output to.  If omitted, the input file path is used with '.unsealed' injected before the extension.")
-	cmd.MarkFlagFilename("output")
+	cmdArgs.Output = cmd.Flags().StringP("outputFile", "o", "", "The filename to write the output to.  If omitted, the input file path is used with '.unsealed' injected before the extension.")
+	cmd.MarkFlagFilename("outputFile")
+	cmd.MarkFlagsMutuallyExclusive("outputFile", CONFIG_DIR_FLAG)
+
+	cmdArgs.ForceOverwrite = cmd.Flags().BoolP("forceOverwrite", "f", false, "If set, overwrite an existing file with the output.")
+
+	return cmd
+
+}
+
+const MIGRATED_FILE_TOKEN = "migrated"
+
+func makeMigrateCmd(context *CmdContext) *cobra.Command {
+
+	cmdArgs := app.MigrateConfigArgs{}
+
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Upgrade a configuration to the current config version",
+		Long: `Migrate upgrades a config file from an older version of the config format, given by its top
+level "version" field, to the current version, one version at a time.  A config without a version
+is version 1.  Comments, layout and sealed values are kept, so a sealed config doesn't need to be
+unsealed first.
+
+Older configs are also upgraded in memory whenever they are read, and "varanus config check" warns
+about them, so migrate only needs to be run to update the file.  Only the input file is migrated;
+the files it includes are migrated with their own calls.  For example:
+
+varanus config migrate -i config.yaml -o config.yaml -f`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			//set output from input if not set
+			if *cmdArgs.Output == "" {
+				*cmdArgs.Output = util.AddValueBeforeExtension(*cmdArgs.Input, MIGRATED_FILE_TOKEN)
+			}
+
+			//once we get through validation, silence the usage
+			cmd.SilenceUsage = true
+
+			return context.App.MigrateConfig(&cmdArgs, cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML or JSON config to be migrated.")
+	cmd.MarkFlagRequired("input")
+	cmd.MarkFlagFilename("input", "yaml", "yml", "json")
+
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+	cmdArgs.OutputMode = context.OutputMode
+
+	cmdArgs.Output = cmd.Flags().StringP("outputFile", "o", "", "The filename to write the output to.  If omitted, the input file path is used with '.migrated' injected before the extension.")
+	cmd.MarkFlagFilename("outputFile")
+
+	cmdArgs.ForceOverwrite = cmd.Flags().BoolP("forceOverwrite", "f", false, "If set, overwrite an existing file with the output.")
+
+	return cmd
+
+}
+
+func makeSchemaCmd(context *CmdContext) *cobra.Command {
+
+	cmdArgs := app.ConfigSchemaArgs{}
+
+	cmd := &cobra.Command{
+		Use:   "schema",
+		Short: "Write a JSON Schema for configs",
+		Long: `Schema writes a JSON Schema (draft 2020-12) for varanus configs, generated from the same types
+and field names that are used to read them.  Editors and CI can use it to catch typos, missing
+fields and malformed values like durations and sealed values before varanus runs.  References
+between objects, like account names, are only checked by "varanus config check".
+
+For example, to use the schema with the YAML language server, write it to a file:
+
+varanus config schema -o varanus.schema.json
+
+and add this comment to the top of the config:
+
+# yaml-language-server: $schema=varanus.schema.json`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return context.App.ConfigSchema(&cmdArgs, cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Output = cmd.Flags().StringP("outputFile", "o", "", "The filename to write the schema to.  If omitted, the schema is written to stdout.")
+	cmd.MarkFlagFilename("outputFile", "json")
+
+	cmdArgs.ForceOverwrite = cmd.Flags().BoolP("forceOverwrite", "f", false, "If set, overwrite an existing file with the output.")
+
+	return cmd
+
+}
+
+func makeDocsCmd(context *CmdContext) *cobra.Command {
+
+	cmdArgs := app.ConfigDocsArgs{}
+
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: "Write a Markdown reference of the config fields",
+		Long: `Docs writes a Markdown reference of every config field, with its type, whether it is required,
+its default, an example, whether it can be sealed, and a description.  It is generated from the
+same types that are used to read configs, so it matches this version of varanus.  For example:
+
+varanus config docs -o config-reference.md`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cmd.SilenceUsage = true
+			return context.App.ConfigDocs(&cmdArgs, cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Output = cmd.Flags().StringP("outputFile", "o", "", "The filename to write the reference to.  If omitted, the reference is written to stdout.")
+	cmd.MarkFlagFilename("outputFile", "md")
+
+	cmdArgs.ForceOverwrite = cmd.Flags().BoolP("forceOverwrite", "f", false, "If set, overwrite an existing file with the output.")
+
+	return cmd
+
+}
+
+const DEFAULT_INIT_OUTPUT_FILE = "varanus.yaml"
+
+func makeInitCmd(context *CmdContext) *cobra.Command {
+
+	cmdArgs := app.InitConfigArgs{}
+
+	cmd := &cobra.Command{
+		Use:   "init",
+		Short: "Create a new configuration",
+		Long: `Init creates a new config with accounts, monitors and their notifications.  Without --account
+and --monitor flags it asks for them, one question at a time; with the flags it asks nothing, so it
+can be scripted.  The config is validated before it is written, and the passwords are sealed right
+away if a public key is given.
+
+Each account uses a provider preset for its servers, ports and TLS setting.  The presets are:
+` + describeMailPresets() + `
+An --account is a comma separated list of key=value pairs with the keys name, address and password,
+and optionally preset, use, username, smtp_server, smtp_port, imap_server, imap_port and mailbox.  A
+comma or backslash in a value is escaped with a backslash, which needs quotes in the shell, like
+'password=a\,b'.  use is both, send or receive; an account that only sends has no imap settings, and
+one that only receives has no smtp settings.  The username is the address unless it is given.  A
+--monitor has the keys from and to, which are account names, and optionally period, like 30m, and
+notify, which can be given more than once.  If notify is left out, the first account that sends
+email and that the monitor doesn't test is notified.  For example:
+
+varanus config init -o config.yaml -k public.pem \
+  --account name=work,preset=gmail,address=monitor@example.com,password=app-password \
+  --account name=home,address=me@example.org,password=secret,smtp_server=mail.example.org,imap_server=mail.example.org \
+  --account name=alerts,preset=fastmail,use=send,address=alerts@example.net,password=app-password \
+  --monitor from=work,to=home,period=30m
+
+Passwords given with --account are visible to other users of the machine and in the shell history;
+answer the prompts instead to avoid that.  The prompts don't show the passwords as they are typed.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			//once we get through validation, silence the usage
+			cmd.SilenceUsage = true
+
+			return context.App.InitConfig(&cmdArgs, cmd.InOrStdin(), cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Output = cmd.Flags().StringP("outputFile", "o", DEFAULT_INIT_OUTPUT_FILE, "The filename to write the config to.  The format is taken from the extension.")
+	cmd.MarkFlagFilename("outputFile", "yaml", "yml", "json")
+
+	cmdArgs.ForceOverwrite = cmd.Flags().BoolP("forceOverwrite", "f", false, "If set, overwrite an existing file with the output.")
+
+	cmdArgs.PublicKey = cmd.Flags().StringP("publicKey", "k", "", "The filename of a public key to seal the passwords with.  If omitted, the passwords are written in plaintext.")
+	cmd.MarkFlagFilename("publicKey")
+
+	cmdArgs.Accounts = cmd.Flags().StringArray("account", []string{}, "An account to add, like name=work,preset=gmail,address=...,password=...  Can be given more than once.")
+	cmdArgs.Monitors = cmd.Flags().StringArray("monitor", []string{}, "A monitor to add, like from=work,to=home,period=1h,notify=other  Can be given more than once.")
+
+	cmdArgs.OutputMode = context.OutputMode
+
+	return cmd
+
+}
+
+// describeMailPresets lists the provider presets for the init help
+func describeMailPresets() string {
+	var sb strings.Builder
+	for _, preset := range config.MailProviderPresets() {
+		fmt.Fprintf(&sb, "  %-10s %s\n", preset.Name, preset.Description)
+	}
+	return sb.String()
+}
+
+func makeDiffCmd(context *CmdContext) *cobra.Command {
+
+	cmdArgs := app.DiffConfigArgs{
+		Input:    new(string),
+		Compared: new(string),
+	}
+
+	cmd := &cobra.Command{
+		Use:   "diff OLD_CONFIG NEW_CONFIG",
+		Short: "Compare two configurations",
+		Long: `Diff compares two configs by their values instead of their text, and lists the accounts,
+monitors and fields that were added, removed or changed.  Accounts, templates, monitors and
+notifications are matched by name, so reordering them isn't a change.  Each config is merged with
+the files it includes before it is compared.
+
+Sealing the same password twice gives different sealed values, so a text diff of two sealed configs
+shows every password as changed.  Without a private key, sealed values that differ are listed as not
+compared.  With the private key, they are unsealed and only listed if the password changed, as
+"secret changed"; the passwords are never shown.  For example:
+
+varanus config diff config.yaml config.new.yaml -k private.pem`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			*cmdArgs.Input = args[0]
+			*cmdArgs.Compared = args[1]
+
+			//once we get through validation, silence the usage
+			cmd.SilenceUsage = true
+
+			return context.App.DiffConfig(&cmdArgs, cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+	cmdArgs.OutputMode = context.OutputMode
+
+	cmdArgs.PrivateKey = cmd.Flags().StringP("privateKey", "k", "", "The filename of the private key used to seal the configs.  If omitted, sealed values are not compared.")
+	cmd.MarkFlagFilename("privateKey")
+
+	cmdArgs.Passphrase = cmd.Flags().StringP("passphrase", "p", "", "The passphrase for the private key, if there is one.")
+
+	cmdArgs.Resolved = cmd.Flags().Bool("resolved", false, "If set, the accounts are compared after inheriting from their templates, and the templates are left out.")
+
+	return cmd
+
+}
+
+const FORMATTED_FILE_TOKEN = "formatted"
+
+func makeFmtCmd(context *CmdContext) *cobra.Command {
+
+	cmdArgs := app.FmtConfigArgs{}
+
+	cmd := &cobra.Command{
+		Use:   "fmt",
+		Short: "Write a configuration with its values normalized",
+		Long: `Fmt writes a config file with the values that varanus normalizes when it reads a config: names
+and addresses are trimmed, server addresses and address families are lowercased, and the default
+ports are added to accounts that leave them out.  Validation and the monitors always use the
+normalized values, so fmt only needs to be run to update the file.
+
+Comments, layout and sealed values are kept, and values that are interpolated keep their
+references.  Only the input file is formatted; the files it includes are formatted with their own
+calls.  For example:
+
+varanus config fmt -i config.yaml -o config.yaml -f`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+
+			//set output from input if not set
+			if *cmdArgs.Output == "" {
+				*cmdArgs.Output = util.AddValueBeforeExtension(*cmdArgs.Input, FORMATTED_FILE_TOKEN)
+			}
+
+			//once we get through validation, silence the usage
+			cmd.SilenceUsage = true
+
+			return context.App.FmtConfig(&cmdArgs, cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML or JSON config to be formatted.")
+	cmd.MarkFlagRequired("input")
+	cmd.MarkFlagFilename("input", "yaml", "yml", "json")
+
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+	cmdArgs.OutputMode = context.OutputMode
+
+	cmdArgs.Output = cmd.Flags().StringP("outputFile", "o", "", "The filename to write the output to.  If omitted, the input file path is used with '.formatted' injected before the extension.")
+	cmd.MarkFlagFilename("outputFile")
 
 	cmdArgs.ForceOverwrite = cmd.Flags().BoolP("forceOverwrite", "f", false, "If set, overwrite an existing file with the output.")
 
@@ -35,7 +35,7 @@ func TestConfigCheckCmd(t *testing.T) {
 				"Flags:",
 			},
 			errorContains: []string{
-				"required flag(s) \"input\" not set",
+				"one of the flags \"input\" or \"config-dir\" must be set",
 			},
 			expectedCallCount: 0,
 		},
@@ -52,8 +52,84 @@ func TestConfigCheckCmd(t *testing.T) {
 				assert.Equal(t, "foo.yaml", *argObj.Input)
 				assert.Equal(t, "", *argObj.Passphrase)
 				assert.Equal(t, "", *argObj.PrivateKey)
+				assert.Equal(t, "", *argObj.Format)
+				assert.Equal(t, false, *argObj.Strict)
+			},
+		},
+		//call check with a config directory
+		{
+			arguments: []string{"config", "check", "--config-dir", "conf.d"},
+			outputsContain: []string{
+				"CheckConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.CheckConfigArgs)
+				assert.Equal(t, "", *argObj.Input)
+				assert.Equal(t, "conf.d", *argObj.ConfigDir)
+			},
+		},
+		//the input and a config directory can't be used together
+		{
+			arguments:         []string{"config", "check", "-i", "foo.yaml", "--config-dir", "conf.d"},
+			errorContains:     []string{"[input config-dir] are set none of the others can be"},
+			expectedCallCount: 0,
+		},
+		//call check with a format
+		{
+			arguments: []string{"config", "check", "-i", "foo.conf", "--format", "json"},
+			outputsContain: []string{
+				"CheckConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.CheckConfigArgs)
+				assert.Equal(t, "foo.conf", *argObj.Input)
+				assert.Equal(t, "json", *argObj.Format)
+			},
+		},
+		//call check with JSON output
+		{
+			arguments: []string{"--output", "json", "config", "check", "-i", "foo.yaml"},
+			outputsContain: []string{
+				"CheckConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.CheckConfigArgs)
+				assert.Equal(t, "json", *argObj.OutputMode)
+			},
+		},
+		//the output mode defaults to text
+		{
+			arguments: []string{"config", "check", "-i", "foo.yaml"},
+			outputsContain: []string{
+				"CheckConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.CheckConfigArgs)
+				assert.Equal(t, "text", *argObj.OutputMode)
+			},
+		},
+		//call check with strict
+		{
+			arguments: []string{"config", "check", "-i", "foo.yaml", "--strict"},
+			outputsContain: []string{
+				"CheckConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.CheckConfigArgs)
+				assert.Equal(t, true, *argObj.Strict)
 			},
 		},
+		//an unsupported output mode is rejected before the app is called
+		{
+			arguments:         []string{"config", "check", "-i", "foo.yaml", "--output", "xml"},
+			errorContains:     []string{"unsupported --output 'xml'; expected one of text, json"},
+			expectedCallCount: 0,
+		},
 		//call check with input and key args
 		{
 			arguments: []string{"config", "check", "-i", "foo.yaml", "-k", "keyfile.pem", "--passphrase", "argyle"},
@@ -101,7 +177,7 @@ func TestConfigSealCmd(t *testing.T) {
 				"Flags:",
 			},
 			errorContains: []string{
-				"required flag(s) \"input\", \"publicKey\" not set",
+				"required flag(s) \"publicKey\" not set",
 			},
 			expectedCallCount: 0,
 		},
@@ -125,7 +201,7 @@ func TestConfigSealCmd(t *testing.T) {
 				"Flags:",
 			},
 			errorContains: []string{
-				"required flag(s) \"input\" not set",
+				"one of the flags \"input\" or \"config-dir\" must be set",
 			},
 			expectedCallCount: 0,
 		},
@@ -145,6 +221,26 @@ func TestConfigSealCmd(t *testing.T) {
 				assert.Equal(t, false, *argObj.ForceOverwrite)
 			},
 		},
+		//call seal with a config directory, which is sealed in place
+		{
+			arguments: []string{"config", "seal", "--config-dir", "conf.d", "-k", "keyfile.pub"},
+			outputsContain: []string{
+				"SealConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.SealConfigArgs)
+				assert.Equal(t, "", *argObj.Input)
+				assert.Equal(t, "conf.d", *argObj.ConfigDir)
+				assert.Equal(t, "", *argObj.Output)
+			},
+		},
+		//an output file can't be used with a config directory
+		{
+			arguments:         []string{"config", "seal", "--config-dir", "conf.d", "-k", "keyfile.pub", "-o", "output.yaml"},
+			errorContains:     []string{"[outputFile config-dir] are set none of the others can be"},
+			expectedCallCount: 0,
+		},
 		//call seal with input, key, output, force args
 		{
 			arguments: []string{"config", "seal", "-i", "input.yaml", "-k", "keyfile.pub", "-o", "output.yaml", "-f"},
@@ -161,6 +257,34 @@ func TestConfigSealCmd(t *testing.T) {
 				assert.Equal(t, true, *argObj.ForceOverwrite)
 			},
 		},
+		//the global output flag and the output file flag can be used together
+		{
+			arguments: []string{"config", "seal", "-i", "input.yaml", "-k", "keyfile.pub", "--outputFile", "output.yaml", "--output", "json"},
+			outputsContain: []string{
+				"SealConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.SealConfigArgs)
+				assert.Equal(t, "output.yaml", *argObj.Output)
+				assert.Equal(t, "json", *argObj.OutputMode)
+			},
+		},
+		//call seal with a JSON input and an explicit format
+		{
+			arguments: []string{"config", "seal", "-i", "input.json", "-k", "keyfile.pub", "--format", "json"},
+			outputsContain: []string{
+				"SealConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "SealConfig", calls[0].function)
+				argObj := calls[0].argsObj.(*app.SealConfigArgs)
+				assert.Equal(t, "input.json", *argObj.Input)
+				assert.Equal(t, "json", *argObj.Format)
+				assert.Equal(t, "input.sealed.json", *argObj.Output)
+			},
+		},
 		//call seal that returns an error
 		{
 			arguments:  []string{"config", "seal", "-i", "input.yaml", "-k", "keyfile.pub"},
@@ -194,7 +318,7 @@ func TestConfigUnsealCmd(t *testing.T) {
 				"Flags:",
 			},
 			errorContains: []string{
-				"required flag(s) \"input\", \"privateKey\" not set",
+				"required flag(s) \"privateKey\" not set",
 			},
 			expectedCallCount: 0,
 		},
@@ -218,7 +342,7 @@ func TestConfigUnsealCmd(t *testing.T) {
 				"Flags:",
 			},
 			errorContains: []string{
-				"required flag(s) \"input\" not set",
+				"one of the flags \"input\" or \"config-dir\" must be set",
 			},
 			expectedCallCount: 0,
 		},
@@ -237,6 +361,36 @@ func TestConfigUnsealCmd(t *testing.T) {
 				assert.Equal(t, "", *argObj.Passphrase)
 				assert.Equal(t, "input.unsealed.yaml", *argObj.Output)
 				assert.Equal(t, false, *argObj.ForceOverwrite)
+				assert.Equal(t, "", *argObj.Format)
+			},
+		},
+		//call unseal with a config directory, which is unsealed in place
+		{
+			arguments: []string{"config", "unseal", "--config-dir", "conf.d", "-k", "keyfile.pem"},
+			outputsContain: []string{
+				"UnsealConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.UnsealConfigArgs)
+				assert.Equal(t, "", *argObj.Input)
+				assert.Equal(t, "conf.d", *argObj.ConfigDir)
+				assert.Equal(t, "", *argObj.Output)
+			},
+		},
+		//call unseal with a JSON input and an explicit format
+		{
+			arguments: []string{"config", "unseal", "-i", "input.json", "-k", "keyfile.pem", "--format", "json"},
+			outputsContain: []string{
+				"UnsealConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "UnsealConfig", calls[0].function)
+				argObj := calls[0].argsObj.(*app.UnsealConfigArgs)
+				assert.Equal(t, "input.json", *argObj.Input)
+				assert.Equal(t, "json", *argObj.Format)
+				assert.Equal(t, "input.unsealed.json", *argObj.Output)
 			},
 		},
 		//call seal with input, key, output, force, passphrase args
@@ -278,3 +432,408 @@ func TestConfigUnsealCmd(t *testing.T) {
 	}
 	runTestCases(t, testCases)
 }
+
+func TestConfigShowCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call show with no args --> missing input arg error
+		{
+			arguments: []string{"config", "show"},
+			outputsContain: []string{
+				"Usage:\n  varanus config show [flags]",
+			},
+			errorContains: []string{
+				"one of the flags \"input\" or \"config-dir\" must be set",
+			},
+			expectedCallCount: 0,
+		},
+		//call show with input arg only
+		{
+			arguments: []string{"config", "show", "-i", "foo.yaml"},
+			outputsContain: []string{
+				"ShowConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "ShowConfig", calls[0].function)
+				argObj := calls[0].argsObj.(*app.ShowConfigArgs)
+				assert.Equal(t, "foo.yaml", *argObj.Input)
+				assert.Equal(t, "", *argObj.ConfigDir)
+				assert.Equal(t, "", *argObj.Format)
+				assert.Equal(t, "text", *argObj.OutputMode)
+				assert.Equal(t, false, *argObj.Resolved)
+			},
+		},
+		//call show resolved from a config directory, as JSON
+		{
+			arguments: []string{"--output", "json", "config", "show", "--config-dir", "conf.d", "--resolved"},
+			outputsContain: []string{
+				"ShowConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.ShowConfigArgs)
+				assert.Equal(t, "", *argObj.Input)
+				assert.Equal(t, "conf.d", *argObj.ConfigDir)
+				assert.Equal(t, "json", *argObj.OutputMode)
+				assert.Equal(t, true, *argObj.Resolved)
+			},
+		},
+		//the input and a config directory can't be used together
+		{
+			arguments:         []string{"config", "show", "-i", "foo.yaml", "--config-dir", "conf.d"},
+			errorContains:     []string{"[input config-dir] are set none of the others can be"},
+			expectedCallCount: 0,
+		},
+		//call show that returns an error
+		{
+			arguments:  []string{"config", "show", "-i", "foo.yaml"},
+			appMutator: func(mva *mockVaranusApp) { mva.showConfigError = "injected error" },
+			outputsContain: []string{
+				"ShowConfig called with args",
+			},
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+	}
+	runTestCases(t, testCases)
+}
+
+func TestConfigMigrateCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call migrate with no args --> missing input arg error
+		{
+			arguments: []string{"config", "migrate"},
+			outputsContain: []string{
+				"Usage:\n  varanus config migrate [flags]",
+			},
+			errorContains: []string{
+				"required flag(s) \"input\" not set",
+			},
+			expectedCallCount: 0,
+		},
+		//call migrate with input only --> output derived from the input
+		{
+			arguments: []string{"config", "migrate", "-i", "input.yaml"},
+			outputsContain: []string{
+				"MigrateConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "MigrateConfig", calls[0].function)
+				argObj := calls[0].argsObj.(*app.MigrateConfigArgs)
+				assert.Equal(t, "input.yaml", *argObj.Input)
+				assert.Equal(t, "", *argObj.Format)
+				assert.Equal(t, "text", *argObj.OutputMode)
+				assert.Equal(t, "input.migrated.yaml", *argObj.Output)
+				assert.Equal(t, false, *argObj.ForceOverwrite)
+			},
+		},
+		//call migrate with all args
+		{
+			arguments: []string{"config", "migrate", "-i", "input.conf", "--format", "json", "-o", "output.json", "-f"},
+			outputsContain: []string{
+				"MigrateConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.MigrateConfigArgs)
+				assert.Equal(t, "input.conf", *argObj.Input)
+				assert.Equal(t, "json", *argObj.Format)
+				assert.Equal(t, "output.json", *argObj.Output)
+				assert.Equal(t, true, *argObj.ForceOverwrite)
+			},
+		},
+		//call migrate that returns an error
+		{
+			arguments:  []string{"config", "migrate", "-i", "input.yaml"},
+			appMutator: func(mva *mockVaranusApp) { mva.migrateConfigError = "injected error" },
+			outputsContain: []string{
+				"MigrateConfig called with args",
+			},
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+	}
+	runTestCases(t, testCases)
+}
+
+func TestConfigSchemaCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call schema with no args --> written to stdout
+		{
+			arguments: []string{"config", "schema"},
+			outputsContain: []string{
+				"ConfigSchema called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "ConfigSchema", calls[0].function)
+				argObj := calls[0].argsObj.(*app.ConfigSchemaArgs)
+				assert.Equal(t, "", *argObj.Output)
+				assert.Equal(t, false, *argObj.ForceOverwrite)
+			},
+		},
+		//call schema with an output file
+		{
+			arguments: []string{"config", "schema", "-o", "schema.json", "-f"},
+			outputsContain: []string{
+				"ConfigSchema called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.ConfigSchemaArgs)
+				assert.Equal(t, "schema.json", *argObj.Output)
+				assert.Equal(t, true, *argObj.ForceOverwrite)
+			},
+		},
+		//call schema that returns an error
+		{
+			arguments:         []string{"config", "schema", "-o", "schema.json"},
+			appMutator:        func(mva *mockVaranusApp) { mva.configSchemaError = "injected error" },
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+	}
+	runTestCases(t, testCases)
+}
+
+func TestConfigDocsCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call docs with no args --> written to stdout
+		{
+			arguments: []string{"config", "docs"},
+			outputsContain: []string{
+				"ConfigDocs called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "ConfigDocs", calls[0].function)
+				argObj := calls[0].argsObj.(*app.ConfigDocsArgs)
+				assert.Equal(t, "", *argObj.Output)
+				assert.Equal(t, false, *argObj.ForceOverwrite)
+			},
+		},
+		//call docs with an output file
+		{
+			arguments: []string{"config", "docs", "-o", "reference.md", "-f"},
+			outputsContain: []string{
+				"ConfigDocs called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.ConfigDocsArgs)
+				assert.Equal(t, "reference.md", *argObj.Output)
+				assert.Equal(t, true, *argObj.ForceOverwrite)
+			},
+		},
+		//call docs that returns an error
+		{
+			arguments:         []string{"config", "docs", "-o", "reference.md"},
+			appMutator:        func(mva *mockVaranusApp) { mva.configDocsError = "injected error" },
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+		//the seal help has a complete example
+		{
+			arguments: []string{"config", "seal", "--help"},
+			outputsContain: []string{
+				"        recipient_address: example@example.com\n",
+				"        mailbox_name: INBOX\n",
+				"        password: sealed(<encrypted string>)\n",
+			},
+			expectedCallCount: 0,
+		},
+	}
+	runTestCases(t, testCases)
+}
+
+func TestConfigInitCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call init with no args --> prompts, written to the default file
+		{
+			arguments: []string{"config", "init"},
+			outputsContain: []string{
+				"InitConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "InitConfig", calls[0].function)
+				argObj := calls[0].argsObj.(*app.InitConfigArgs)
+				assert.Equal(t, DEFAULT_INIT_OUTPUT_FILE, *argObj.Output)
+				assert.Equal(t, false, *argObj.ForceOverwrite)
+				assert.Equal(t, "", *argObj.PublicKey)
+				assert.Equal(t, "text", *argObj.OutputMode)
+				assert.Empty(t, *argObj.Accounts)
+				assert.Empty(t, *argObj.Monitors)
+			},
+		},
+		//call init with all args; the specs have commas, so they aren't split
+		{
+			arguments: []string{"config", "init", "-o", "new.json", "-f", "-k", "public.pem",
+				"--account", "name=work,address=a@example.com,password=x",
+				"--account", "name=home,address=b@example.com,password=y",
+				"--monitor", "from=work,to=home,notify=home"},
+			outputsContain: []string{
+				"InitConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.InitConfigArgs)
+				assert.Equal(t, "new.json", *argObj.Output)
+				assert.Equal(t, true, *argObj.ForceOverwrite)
+				assert.Equal(t, "public.pem", *argObj.PublicKey)
+				assert.Equal(t, []string{"name=work,address=a@example.com,password=x", "name=home,address=b@example.com,password=y"}, *argObj.Accounts)
+				assert.Equal(t, []string{"from=work,to=home,notify=home"}, *argObj.Monitors)
+			},
+		},
+		//call init that returns an error
+		{
+			arguments:  []string{"config", "init"},
+			appMutator: func(mva *mockVaranusApp) { mva.initConfigError = "injected error" },
+			outputsContain: []string{
+				"InitConfig called with args",
+			},
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+		//the help lists the presets
+		{
+			arguments: []string{"config", "init", "--help"},
+			outputsContain: []string{
+				"  gmail      Gmail, with an app password\n",
+				"--monitor from=work,to=home,period=30m",
+			},
+			expectedCallCount: 0,
+		},
+	}
+	runTestCases(t, testCases)
+}
+
+func TestConfigDiffCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call diff with one config --> missing argument error
+		{
+			arguments: []string{"config", "diff", "old.yaml"},
+			outputsContain: []string{
+				"Usage:\n  varanus config diff OLD_CONFIG NEW_CONFIG [flags]",
+			},
+			errorContains: []string{
+				"accepts 2 arg(s), received 1",
+			},
+			expectedCallCount: 0,
+		},
+		//call diff with the two configs
+		{
+			arguments: []string{"config", "diff", "old.yaml", "new.yaml"},
+			outputsContain: []string{
+				"DiffConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "DiffConfig", calls[0].function)
+				argObj := calls[0].argsObj.(*app.DiffConfigArgs)
+				assert.Equal(t, "old.yaml", *argObj.Input)
+				assert.Equal(t, "new.yaml", *argObj.Compared)
+				assert.Equal(t, "", *argObj.Format)
+				assert.Equal(t, "text", *argObj.OutputMode)
+				assert.Equal(t, "", *argObj.PrivateKey)
+				assert.Equal(t, "", *argObj.Passphrase)
+				assert.Equal(t, false, *argObj.Resolved)
+			},
+		},
+		//call diff with all args
+		{
+			arguments: []string{"config", "diff", "old.conf", "new.conf", "--format", "json", "-k", "private.pem", "-p", "secret", "--resolved"},
+			outputsContain: []string{
+				"DiffConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.DiffConfigArgs)
+				assert.Equal(t, "old.conf", *argObj.Input)
+				assert.Equal(t, "new.conf", *argObj.Compared)
+				assert.Equal(t, "json", *argObj.Format)
+				assert.Equal(t, "private.pem", *argObj.PrivateKey)
+				assert.Equal(t, "secret", *argObj.Passphrase)
+				assert.Equal(t, true, *argObj.Resolved)
+			},
+		},
+		//call diff that returns an error
+		{
+			arguments:  []string{"config", "diff", "old.yaml", "new.yaml"},
+			appMutator: func(mva *mockVaranusApp) { mva.diffConfigError = "injected error" },
+			outputsContain: []string{
+				"DiffConfig called with args",
+			},
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+	}
+	runTestCases(t, testCases)
+}
+
+func TestConfigFmtCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call fmt with no args --> missing input arg error
+		{
+			arguments: []string{"config", "fmt"},
+			outputsContain: []string{
+				"Usage:\n  varanus config fmt [flags]",
+			},
+			errorContains: []string{
+				"required flag(s) \"input\" not set",
+			},
+			expectedCallCount: 0,
+		},
+		//call fmt with input only --> output derived from the input
+		{
+			arguments: []string{"config", "fmt", "-i", "input.yaml"},
+			outputsContain: []string{
+				"FmtConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "FmtConfig", calls[0].function)
+				argObj := calls[0].argsObj.(*app.FmtConfigArgs)
+				assert.Equal(t, "input.yaml", *argObj.Input)
+				assert.Equal(t, "", *argObj.Format)
+				assert.Equal(t, "text", *argObj.OutputMode)
+				assert.Equal(t, "input.formatted.yaml", *argObj.Output)
+				assert.Equal(t, false, *argObj.ForceOverwrite)
+			},
+		},
+		//call fmt with all args
+		{
+			arguments: []string{"config", "fmt", "-i", "input.conf", "--format", "json", "-o", "output.json", "-f"},
+			outputsContain: []string{
+				"FmtConfig called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.FmtConfigArgs)
+				assert.Equal(t, "input.conf", *argObj.Input)
+				assert.Equal(t, "json", *argObj.Format)
+				assert.Equal(t, "output.json", *argObj.Output)
+				assert.Equal(t, true, *argObj.ForceOverwrite)
+			},
+		},
+		//call fmt that returns an error
+		{
+			arguments:  []string{"config", "fmt", "-i", "input.yaml"},
+			appMutator: func(mva *mockVaranusApp) { mva.fmtConfigError = "injected error" },
+			outputsContain: []string{
+				"FmtConfig called with args",
+			},
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+	}
+	runTestCases(t, testCases)
+}
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"fmt"
+	"slices"
+	"strings"
 	"varanus/internal/app"
 
 	"github.com/spf13/cobra"
@@ -9,6 +12,8 @@ import (
 // CmdContext provides a structure for all the dependencies we pass into the command structures
 type CmdContext struct {
 	App app.VaranusApp
+	//OutputMode is the value of the global --output flag, text or json
+	OutputMode *string
 }
 
 // rootCmd represents the base command when called without any subcommands
@@ -18,7 +23,24 @@ func MakeRootCmd(context *CmdContext) *cobra.Command {
 		Short: "An automated server monitoring tool.",
 		Long: `Define a configuration YAML file, seal it, then run the server to begin monitoring
 email servers.
+
+With --output json, the config commands write a single JSON report with the validation errors,
+sealed value counts, and overall status instead of their usual text.
+
+Exit codes:
+  0  the command succeeded
+  1  the command line was invalid, e.g. a missing or unknown flag
+  2  the command failed, e.g. the config or a key could not be read or the output written
+  3  the config has validation errors
+  4  sealed values could not be sealed, unsealed, or verified
 `,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if !slices.Contains(app.SupportedOutputModes, *context.OutputMode) {
+				return fmt.Errorf("unsupported --output '%s'; expected one of %s",
+					*context.OutputMode, strings.Join(app.SupportedOutputModes, ", "))
+			}
+			return nil
+		},
 	}
 
 	// Here you will define your flags and configuration settings.
@@ -26,6 +48,8 @@ email servers.
 	// will be global for your application.
 
 	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.varanus.yaml)")
+	context.OutputMode = rootCmd.PersistentFlags().String("output", app.OUTPUT_MODE_TEXT,
+		"The output of the command, text or json.")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -35,5 +59,8 @@ email servers.
 	configCmd := makeConfigCmd(context)
 	rootCmd.AddCommand(configCmd)
 
+	runCmd := makeRunCmd(context)
+	rootCmd.AddCommand(runCmd)
+
 	return rootCmd
 }
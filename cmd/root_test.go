@@ -16,6 +16,14 @@ type mockVaranusApp struct {
 	sealConfigError      string
 	unsealConfigError    string
 	sealCheckConfigError string
+	showConfigError      string
+	migrateConfigError   string
+	configSchemaError    string
+	configDocsError      string
+	initConfigError      string
+	diffConfigError      string
+	fmtConfigError       string
+	runError             string
 }
 
 type mockAppCalls struct {
@@ -58,6 +66,102 @@ func (mva *mockVaranusApp) CheckConfig(args *app.CheckConfigArgs, outputStream i
 	return nil
 }
 
+func (mva *mockVaranusApp) ShowConfig(args *app.ShowConfigArgs, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "ShowConfig called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "ShowConfig",
+		argsObj:  args,
+	})
+	if mva.showConfigError != "" {
+		return fmt.Errorf(mva.showConfigError)
+	}
+	return nil
+}
+
+func (mva *mockVaranusApp) MigrateConfig(args *app.MigrateConfigArgs, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "MigrateConfig called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "MigrateConfig",
+		argsObj:  args,
+	})
+	if mva.migrateConfigError != "" {
+		return fmt.Errorf(mva.migrateConfigError)
+	}
+	return nil
+}
+
+func (mva *mockVaranusApp) ConfigSchema(args *app.ConfigSchemaArgs, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "ConfigSchema called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "ConfigSchema",
+		argsObj:  args,
+	})
+	if mva.configSchemaError != "" {
+		return fmt.Errorf(mva.configSchemaError)
+	}
+	return nil
+}
+
+func (mva *mockVaranusApp) ConfigDocs(args *app.ConfigDocsArgs, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "ConfigDocs called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "ConfigDocs",
+		argsObj:  args,
+	})
+	if mva.configDocsError != "" {
+		return fmt.Errorf(mva.configDocsError)
+	}
+	return nil
+}
+
+func (mva *mockVaranusApp) InitConfig(args *app.InitConfigArgs, inputStream io.Reader, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "InitConfig called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "InitConfig",
+		argsObj:  args,
+	})
+	if mva.initConfigError != "" {
+		return fmt.Errorf(mva.initConfigError)
+	}
+	return nil
+}
+
+func (mva *mockVaranusApp) DiffConfig(args *app.DiffConfigArgs, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "DiffConfig called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "DiffConfig",
+		argsObj:  args,
+	})
+	if mva.diffConfigError != "" {
+		return fmt.Errorf(mva.diffConfigError)
+	}
+	return nil
+}
+
+func (mva *mockVaranusApp) FmtConfig(args *app.FmtConfigArgs, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "FmtConfig called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "FmtConfig",
+		argsObj:  args,
+	})
+	if mva.fmtConfigError != "" {
+		return fmt.Errorf(mva.fmtConfigError)
+	}
+	return nil
+}
+
+func (mva *mockVaranusApp) Run(args *app.RunArgs, outputStream io.Writer) error {
+	fmt.Fprintf(outputStream, "Run called with args %#v", args)
+	mva.calls = append(mva.calls, mockAppCalls{
+		function: "Run",
+		argsObj:  args,
+	})
+	if mva.runError != "" {
+		return fmt.Errorf(mva.runError)
+	}
+	return nil
+}
+
 type testCase struct {
 	//arguments supplied to the command
 	arguments []string
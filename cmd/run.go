@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"varanus/internal/app"
+	"varanus/internal/daemon"
+
+	"github.com/spf13/cobra"
+)
+
+func makeRunCmd(context *CmdContext) *cobra.Command {
+
+	var cmdArgs = app.RunArgs{}
+
+	// cmd represents the run command
+	var cmd = &cobra.Command{
+		Use:   "run",
+		Short: "Run the monitors of a config",
+		Long: `Run the email monitors of a config until the process is interrupted.
+
+The config is reloaded on SIGHUP, or when its files change if --watch is set.  A reloaded config
+is validated and its seals are checked before it replaces the running one; if it is rejected, the
+reason is logged and the monitors keep running with the old config.  Monitors are matched by name
+("from->to"), so the ones that didn't change keep their schedule and history.  Monitors with the
+same accounts are named by their order, like "from->to#2", so reordering them swaps their schedules
+and histories.  The send limits carry over to the reloaded config, but the auth lockouts start over.
+
+When the probe of a monitor fails after the last one succeeded, or its first probe fails, an email
+is sent to each of its notification accounts, from the account itself.  Notifications don't wait
+for the send limits.
+
+The monitors of registered types, in monitoring.monitors, are checked with the config but are not
+run yet; their number is printed at startup.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := requireInputOrConfigDir(cmd)
+			if err != nil {
+				return err
+			}
+			cmd.SilenceUsage = true
+			return context.App.Run(&cmdArgs, cmd.OutOrStdout())
+		},
+	}
+
+	//local flags
+	cmdArgs.Input = cmd.Flags().StringP("input", "i", "", "The filename of the YAML or JSON config to run, along with the files it includes.")
+	cmd.MarkFlagFilename("input", "yaml", "yml", "json")
+
+	cmdArgs.ConfigDir = cmd.Flags().String(CONFIG_DIR_FLAG, "", "A directory of config fragments to merge and run instead of --input.")
+	cmd.MarkFlagDirname(CONFIG_DIR_FLAG)
+	cmd.MarkFlagsMutuallyExclusive("input", CONFIG_DIR_FLAG)
+
+	cmdArgs.Format = cmd.Flags().String("format", "", FORMAT_FLAG_USAGE)
+
+	cmdArgs.PrivateKey = cmd.Flags().StringP("privateKey", "k", "", "The filename of the private key used to unseal the passwords.")
+	cmd.MarkFlagFilename("privateKey")
+
+	cmdArgs.Passphrase = cmd.Flags().StringP("passphrase", "p", "", "The passphrase for the private key, if there is one.")
+
+	cmdArgs.WatchInterval = cmd.Flags().Duration("watch", 0, "If set, how often the config files are checked for changes, which reload the config.")
+
+	cmdArgs.ReadDelay = cmd.Flags().Duration("read-delay", daemon.DEFAULT_PROBE_READ_DELAY, "How long a probe waits after sending the test email before reading it.")
+
+	return cmd
+
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+	"varanus/internal/app"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunCmd(t *testing.T) {
+
+	testCases := []testCase{
+		//call run with no args --> missing input arg error
+		{
+			arguments: []string{"run"},
+			outputsContain: []string{
+				"Usage:\n  varanus run [flags]",
+			},
+			errorContains: []string{
+				"one of the flags \"input\" or \"config-dir\" must be set",
+			},
+			expectedCallCount: 0,
+		},
+		//call run with input only --> defaults
+		{
+			arguments: []string{"run", "-i", "input.yaml"},
+			outputsContain: []string{
+				"Run called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				assert.Equal(t, "Run", calls[0].function)
+				argObj := calls[0].argsObj.(*app.RunArgs)
+				assert.Equal(t, "input.yaml", *argObj.Input)
+				assert.Equal(t, "", *argObj.ConfigDir)
+				assert.Equal(t, "", *argObj.PrivateKey)
+				assert.Equal(t, time.Duration(0), *argObj.WatchInterval)
+				assert.Equal(t, time.Minute, *argObj.ReadDelay)
+			},
+		},
+		//call run with all args
+		{
+			arguments: []string{"run", "--config-dir", "conf.d", "-k", "key.pem", "-p", "secret",
+				"--watch", "10s", "--read-delay", "2m"},
+			outputsContain: []string{
+				"Run called with args",
+			},
+			expectedCallCount: 1,
+			checkCalls: func(t *testing.T, calls []mockAppCalls) {
+				argObj := calls[0].argsObj.(*app.RunArgs)
+				assert.Equal(t, "conf.d", *argObj.ConfigDir)
+				assert.Equal(t, "key.pem", *argObj.PrivateKey)
+				assert.Equal(t, "secret", *argObj.Passphrase)
+				assert.Equal(t, 10*time.Second, *argObj.WatchInterval)
+				assert.Equal(t, 2*time.Minute, *argObj.ReadDelay)
+			},
+		},
+		//call run that returns an error
+		{
+			arguments:  []string{"run", "-i", "input.yaml"},
+			appMutator: func(mva *mockVaranusApp) { mva.runError = "injected error" },
+			outputsContain: []string{
+				"Run called with args",
+			},
+			errorContains:     []string{"injected error"},
+			expectedCallCount: 1,
+		},
+	}
+	runTestCases(t, testCases)
+}
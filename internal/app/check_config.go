@@ -3,25 +3,38 @@ package app
 import (
 	"fmt"
 	"io"
+	"strings"
 	"varanus/internal/config"
 	"varanus/internal/secrets"
 	"varanus/internal/validation"
 )
 
 func (va varanusAppImpl) CheckConfig(args *CheckConfigArgs, outputStream io.Writer) error {
+	report := newCommandReport("check", configSource(*args.Input, *args.ConfigDir))
+	return runWithReport(*args.OutputMode, outputStream, report, func(textStream io.Writer) error {
+		return va.checkConfig(args, textStream, report)
+	})
+}
+
+func (va varanusAppImpl) checkConfig(args *CheckConfigArgs, outputStream io.Writer, report *CommandReport) error {
 
 	fmt.Fprint(outputStream, args.HumanReadable())
 
-	overallCheckOk := true
+	//the exit code for the most serious issue found so far
+	issueExitCode := EXIT_CODE_OK
 
-	//load the config
-	config, err := config.ReadConfigFromFile(*args.Input)
+	//load the config, merging the included files or the fragments of the config directory
+	source := configSource(*args.Input, *args.ConfigDir)
+	//the documents are kept so validation errors can be reported with their line numbers
+	configSet, err := readConfigSet(*args.Input, *args.ConfigDir, *args.Format)
 	if err != nil {
 		//return from here because we can't continue checks without a config
-		return newApplicationError("Could not load config from '%s': %w", *args.Input, err)
+		return newApplicationError("Could not load config from '%s': %w", source, err)
 	} else {
 		fmt.Fprintln(outputStream, "The config was loaded successfully.")
+		fmt.Fprint(outputStream, describeConfigSet(configSet))
 	}
+	config := configSet.Config
 
 	validationResult, err := validation.ValidateObject(config)
 	if err != nil {
@@ -29,9 +42,26 @@ func (va varanusAppImpl) CheckConfig(args *CheckConfigArgs, outputStream io.Writ
 		return newApplicationError(
 			"Checking did not complete because the configuration validation had an error -- please report this as a bug: %w", err)
 	} else {
+		//the lint warnings are reported with the validation results
+		config.Lint(&validationResult)
+		validationResult.AddSourceLocations(configSet)
+		report.setValidationResult(validationResult)
 		fmt.Fprint(outputStream, validationResult.HumanReadable())
 		if validationResult.GetErrorCount() > 0 {
-			overallCheckOk = false
+			issueExitCode = EXIT_CODE_INVALID_CONFIG
+		} else if *args.Strict && validationResult.GetWarningCount() > 0 {
+			fmt.Fprintln(outputStream, "The warnings are treated as errors because of the strict check.")
+			issueExitCode = EXIT_CODE_INVALID_CONFIG
+		}
+		if validationResult.GetErrorCount() == 0 {
+			//a valid config is compiled like it is for the monitors, which also resolves the
+			//groups of the send limits
+			runtimeConfig, err := config.Compile(va.resolver)
+			if err != nil {
+				return newApplicationError(
+					"Checking did not complete because the valid configuration could not be compiled -- please report this as a bug: %w", err)
+			}
+			fmt.Fprint(outputStream, describeSendLimitGroups(runtimeConfig.SendLimitBuckets()))
 		}
 	}
 
@@ -51,6 +81,7 @@ func (va varanusAppImpl) CheckConfig(args *CheckConfigArgs, outputStream io.Writ
 
 	//we can check the seals even if the unsealer is nil
 	sealCheckResult := secrets.CheckSealsOnObject(config, unsealer)
+	report.setSealCheckResult(sealCheckResult)
 	//print context based on key presence
 	if unsealer == nil {
 		if sealCheckResult.SealedCount > 0 {
@@ -65,14 +96,36 @@ func (va varanusAppImpl) CheckConfig(args *CheckConfigArgs, outputStream io.Writ
 	}
 	//print seal check status, including seal errors if any
 	fmt.Fprint(outputStream, sealCheckResult.HumanReadable())
-	if len(sealCheckResult.UnsealErrors) > 0 {
-		overallCheckOk = false
+	if len(sealCheckResult.UnsealErrors) > 0 && issueExitCode == EXIT_CODE_OK {
+		issueExitCode = EXIT_CODE_SEAL_ERRORS
 	}
 
-	if overallCheckOk {
+	if issueExitCode == EXIT_CODE_OK {
 		fmt.Fprintln(outputStream, "The configuration appears to be valid.  Congratulations!")
 		return nil
 	} else {
-		return newApplicationError("There are some issues with the configuration.  See the output above for details.")
+		return newApplicationErrorWithExitCode(issueExitCode,
+			"There are some issues with the configuration.  See the output above for details.")
+	}
+}
+
+// describeSendLimitGroups lists the send limit buckets that were grouped automatically by server IP,
+// or returns an empty string if there are none.  Servers that couldn't be resolved are only a
+// warning because DNS may be unavailable where the check is run.
+func describeSendLimitGroups(buckets []config.SendLimitBucket) string {
+	var sb strings.Builder
+	for _, bucket := range buckets {
+		if bucket.ServerIPs == nil {
+			continue //not grouped automatically
+		}
+		if sb.Len() == 0 {
+			fmt.Fprintln(&sb, "Send limit groups by SMTP server IP:")
+		}
+		fmt.Fprintf(&sb, "  every %s: %s (%s)\n",
+			bucket.MinPeriod, strings.Join(bucket.AccountNames, ", "), strings.Join(bucket.ServerIPs, ", "))
+		for _, resolveError := range bucket.ResolveErrors {
+			fmt.Fprintf(&sb, "    warning, grouped by server name only: %s\n", resolveError)
+		}
 	}
+	return sb.String()
 }
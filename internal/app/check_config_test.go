@@ -1,6 +1,8 @@
 package app
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"testing"
 	"varanus/internal/util"
@@ -14,8 +16,12 @@ func TestCheckConfigNominal(t *testing.T) {
 
 	args := CheckConfigArgs{
 		Input:      util.Ptr("tests/example.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
 		PrivateKey: util.Ptr("tests/key-4096.pem"),
 		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
 	}
 
 	app := CreateApp()
@@ -29,17 +35,101 @@ func TestCheckConfigNominal(t *testing.T) {
 	assert.Contains(t, stdOutput, "The config was loaded successfully.")
 	assert.Contains(t, stdOutput, "The integrity of the sealed values was verified with the private key.")
 	assert.Contains(t, stdOutput, "The configuration appears to be valid.")
+	assert.Contains(t, stdOutput, "8 Validation Warnings")
+	assert.Contains(t, stdOutput,
+		"tests/example.yaml:22:9: mail.accounts[0].imap.password: warning: the value is not sealed, so the secret is stored as plaintext")
+	//lint warnings are included
+	assert.Contains(t, stdOutput,
+		"tests/example.yaml:3:7: monitoring.email_monitors[0]: warning: every notification is sent through smtp.example.com")
 
 }
 
+func TestCheckConfigStrict(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := CheckConfigArgs{
+		Input:      util.Ptr("tests/example.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
+		PrivateKey: util.Ptr("tests/key-4096.pem"),
+		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	//the example config has warnings, so the strict check fails
+	err := app.CheckConfig(&args, &sb)
+	assert.ErrorContains(t, err, "There are some issues with the configuration")
+	assert.Equal(t, EXIT_CODE_INVALID_CONFIG, exitCodeForError(err))
+
+	stdOutput := sb.String()
+	assert.Contains(t, stdOutput, "No validation errors")
+	assert.Contains(t, stdOutput, "8 Validation Warnings")
+	assert.Contains(t, stdOutput, "The warnings are treated as errors because of the strict check.")
+	assert.NotContains(t, stdOutput, "The configuration appears to be valid.")
+}
+
+func TestCheckConfigDir(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := CheckConfigArgs{
+		Input:      util.Ptr(""),
+		ConfigDir:  util.Ptr("tests/conf.d"),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
+		PrivateKey: util.Ptr(""),
+		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
+	}
+
+	app := CreateApp()
+
+	err := app.CheckConfig(&args, &sb)
+	assert.Nil(t, err)
+
+	stdOutput := sb.String()
+	assert.Contains(t, stdOutput, "The config was merged from 2 files: tests/conf.d/10-accounts.yaml, tests/conf.d/20-monitors.yaml")
+	assert.Contains(t, stdOutput, "No validation errors")
+	//the issues are located in the fragment they came from
+	assert.Contains(t, stdOutput,
+		"tests/conf.d/20-monitors.yaml:11:9: mail.accounts[2].imap.password: warning: the value is not sealed")
+	assert.Contains(t, stdOutput, "Of 3 total items, 0 are sealed and 3 are unsealed.")
+	assert.Contains(t, stdOutput, "The configuration appears to be valid.")
+
+	//the same fragments can be included from a file
+	sb.Reset()
+	args.Input = util.Ptr("tests/example-include.yaml")
+	args.ConfigDir = util.Ptr("")
+	err = app.CheckConfig(&args, &sb)
+	assert.Nil(t, err)
+	stdOutput = sb.String()
+	assert.Contains(t, stdOutput, "The config was merged from 3 files: tests/example-include.yaml, tests/conf.d/10-accounts.yaml, tests/conf.d/20-monitors.yaml")
+	assert.Contains(t, stdOutput, "The configuration appears to be valid.")
+
+	//a missing directory can't be loaded
+	sb.Reset()
+	args.Input = util.Ptr("")
+	args.ConfigDir = util.Ptr("tests/does-not-exist")
+	err = app.CheckConfig(&args, &sb)
+	assert.ErrorContains(t, err, "Could not load config from 'tests/does-not-exist'")
+}
+
 func TestCheckConfigUnvalidatableNoKey(t *testing.T) {
 
 	var sb strings.Builder
 
 	args := CheckConfigArgs{
 		Input:      util.Ptr("tests/example-unvalidatable.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
 		PrivateKey: util.Ptr(""),
 		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
 	}
 
 	app := CreateApp()
@@ -56,8 +146,10 @@ func TestCheckConfigUnvalidatableNoKey(t *testing.T) {
 	assert.Contains(t, stdOutput, "Of 2 total items, 2 are sealed and 0 are unsealed.")
 	assert.Contains(t, stdOutput, "The config was loaded successfully.")
 	assert.Contains(t, stdOutput, "2 Validation Errors")
-	assert.Contains(t, stdOutput, "send_limits account name 'test1' that does not exist")
-	assert.Contains(t, stdOutput, "account names must not be empty or whitespace")
+	assert.Contains(t, stdOutput,
+		"tests/example-unvalidatable.yaml:18:7: mail.send_limits[0]: send_limits account named 'test1' does not exist")
+	assert.Contains(t, stdOutput,
+		"tests/example-unvalidatable.yaml:3:7: mail.accounts[0]: account names must not be empty or whitespace")
 	assert.Contains(t, stdOutput, "The integrity of sealed values was not checked because no private key was provided.")
 
 }
@@ -68,8 +160,12 @@ func TestCheckConfigValidationFailure(t *testing.T) {
 
 	args := CheckConfigArgs{
 		Input:      util.Ptr("tests/example-validation-failure.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
 		PrivateKey: util.Ptr(""),
 		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
 	}
 
 	app := CreateApp()
@@ -95,8 +191,12 @@ func TestCheckConfigSealError(t *testing.T) {
 
 	args := CheckConfigArgs{
 		Input:      util.Ptr("tests/example-bad-seal.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
 		PrivateKey: util.Ptr("tests/key-4096.pem"),
 		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
 	}
 
 	app := CreateApp()
@@ -115,7 +215,7 @@ func TestCheckConfigSealError(t *testing.T) {
 	assert.Contains(t, stdOutput, "Of 2 total items, 1 are sealed and 1 are unsealed.")
 	assert.Contains(t, stdOutput, "The integrity of the sealed values was checked with the private key, but there were some errors.")
 	assert.Contains(t, stdOutput, "1 seal check errors were detected")
-	assert.Contains(t, stdOutput, "error at path mail.accounts[0].SMTP.password: crypto/rsa: decryption error")
+	assert.Contains(t, stdOutput, "error at path mail.accounts[0].smtp.password: crypto/rsa: decryption error")
 
 }
 
@@ -125,8 +225,12 @@ func TestCheckConfigInvalidYaml(t *testing.T) {
 
 	args := CheckConfigArgs{
 		Input:      util.Ptr("tests/invalid.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
 		PrivateKey: util.Ptr(""),
 		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
 	}
 
 	app := CreateApp()
@@ -146,8 +250,12 @@ func TestCheckConfigBadKeyFile(t *testing.T) {
 
 	args := CheckConfigArgs{
 		Input:      util.Ptr("tests/example.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
 		PrivateKey: util.Ptr("tests/key-4096-bad.pem"),
 		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
 	}
 
 	app := CreateApp()
@@ -165,3 +273,79 @@ func TestCheckConfigBadKeyFile(t *testing.T) {
 	assert.Contains(t, stdOutput, "No validation errors")
 
 }
+
+// fakeResolver resolves hosts from a map, and fails for any other host
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addresses, found := r[host]
+	if !found {
+		return nil, fmt.Errorf("lookup %s: no such host", host)
+	}
+	return addresses, nil
+}
+
+func TestCheckConfigSendLimitGroups(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := CheckConfigArgs{
+		Input:      util.Ptr("tests/example-send-limit-groups.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
+		PrivateKey: util.Ptr(""),
+		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
+	}
+
+	app := varanusAppImpl{
+		resolver: fakeResolver{
+			"smtp.example.com": {"192.0.2.10"},
+			"mail.example.com": {"192.0.2.10", "192.0.2.11"},
+		},
+	}
+
+	err := app.CheckConfig(&args, &sb)
+	assert.Nil(t, err)
+
+	stdOutput := sb.String()
+	assert.Contains(t, stdOutput, "Send limit groups by SMTP server IP:\n"+
+		"  every 10m0s: test1, test2 (192.0.2.10, 192.0.2.11)\n"+
+		"  every 10m0s: test3 ()\n"+
+		"    warning, grouped by server name only: lookup unknown.example.com: no such host\n")
+	assert.Contains(t, stdOutput, "The configuration appears to be valid.")
+
+	//without automatic grouping nothing is listed
+	sb.Reset()
+	args.Input = util.Ptr("tests/example.yaml")
+	err = app.CheckConfig(&args, &sb)
+	assert.Nil(t, err)
+	assert.NotContains(t, sb.String(), "Send limit groups")
+}
+
+func TestCheckConfigTemplates(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := CheckConfigArgs{
+		Input:      util.Ptr("tests/example-templates.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
+		PrivateKey: util.Ptr("tests/key-4096.pem"),
+		Passphrase: util.Ptr(""),
+		Strict:     util.Ptr(false),
+	}
+
+	app := CreateApp()
+
+	err := app.CheckConfig(&args, &sb)
+	assert.Nil(t, err)
+
+	stdOutput := sb.String()
+	assert.Contains(t, stdOutput, "Of 3 total items, 0 are sealed and 3 are unsealed.")
+	assert.Contains(t, stdOutput, "The configuration appears to be valid.")
+	assert.NotContains(t, stdOutput, "Validation Errors")
+
+}
@@ -0,0 +1,24 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"varanus/internal/config"
+)
+
+// ConfigDocs writes the Markdown reference of the config fields to the output file, or to the
+// output stream if no file is given.
+func (va varanusAppImpl) ConfigDocs(args *ConfigDocsArgs, outputStream io.Writer) error {
+
+	if *args.Output != "" {
+		err := config.WriteConfigReferenceToFile(*args.Output, *args.ForceOverwrite)
+		if err != nil {
+			return newApplicationError("Error writing the config reference to '%s': %w", *args.Output, err)
+		}
+		fmt.Fprintf(outputStream, "The config reference was written to '%s'\n", *args.Output)
+		return nil
+	}
+
+	fmt.Fprint(outputStream, config.GenerateConfigReference())
+	return nil
+}
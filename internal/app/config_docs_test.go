@@ -0,0 +1,46 @@
+package app
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigDocs(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := ConfigDocsArgs{
+		Output:         util.Ptr(""),
+		ForceOverwrite: util.Ptr(false),
+	}
+
+	app := CreateApp()
+
+	//only the reference is written to the output stream
+	err := app.ConfigDocs(&args, &sb)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(sb.String(), "# Varanus config reference\n"))
+	reference := sb.String()
+
+	//write to a file
+	tempFile := util.CreateTempFileAndDir("test_output", "config_docs_test.*.md")
+	tempFile.Close()
+	sb.Reset()
+	args.Output = util.Ptr(tempFile.Name())
+	err = app.ConfigDocs(&args, &sb)
+	assert.ErrorContains(t, err, "Error writing the config reference to '"+tempFile.Name()+"'")
+	assert.Equal(t, EXIT_CODE_FAILED, exitCodeForError(err))
+
+	args.ForceOverwrite = util.Ptr(true)
+	err = app.ConfigDocs(&args, &sb)
+	require.Nil(t, err)
+	assert.Equal(t, "The config reference was written to '"+tempFile.Name()+"'\n", sb.String())
+	data, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, reference, string(data))
+}
@@ -0,0 +1,30 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"varanus/internal/config"
+)
+
+// ConfigSchema writes the JSON Schema for configs to the output file, or to the output stream if
+// no file is given.  Only the schema is written to the output stream, so it can be redirected to a
+// file.
+func (va varanusAppImpl) ConfigSchema(args *ConfigSchemaArgs, outputStream io.Writer) error {
+
+	if *args.Output != "" {
+		err := config.WriteJSONSchemaToFile(*args.Output, *args.ForceOverwrite)
+		if err != nil {
+			return newApplicationError("Error writing the schema to '%s': %w", *args.Output, err)
+		}
+		fmt.Fprintf(outputStream, "The schema was written to '%s'\n", *args.Output)
+		return nil
+	}
+
+	schema, err := config.GenerateJSONSchema()
+	if err != nil {
+		//unreachable because the schema can always be written
+		return newApplicationError("Could not write the schema: %w", err)
+	}
+	fmt.Fprint(outputStream, string(schema))
+	return nil
+}
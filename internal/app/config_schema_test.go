@@ -0,0 +1,49 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigSchema(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := ConfigSchemaArgs{
+		Output:         util.Ptr(""),
+		ForceOverwrite: util.Ptr(false),
+	}
+
+	app := CreateApp()
+
+	//only the schema is written to the output stream
+	err := app.ConfigSchema(&args, &sb)
+	require.Nil(t, err)
+	schema := map[string]interface{}{}
+	err = json.Unmarshal([]byte(sb.String()), &schema)
+	require.Nil(t, err)
+	assert.Equal(t, "https://json-schema.org/draft/2020-12/schema", schema["$schema"])
+
+	//write to a file
+	tempFile := util.CreateTempFileAndDir("test_output", "config_schema_test.*.json")
+	tempFile.Close()
+	sb.Reset()
+	args.Output = util.Ptr(tempFile.Name())
+	err = app.ConfigSchema(&args, &sb)
+	assert.ErrorContains(t, err, "Error writing the schema to '"+tempFile.Name()+"'")
+	assert.Equal(t, EXIT_CODE_FAILED, exitCodeForError(err))
+
+	args.ForceOverwrite = util.Ptr(true)
+	err = app.ConfigSchema(&args, &sb)
+	require.Nil(t, err)
+	assert.Equal(t, "The schema was written to '"+tempFile.Name()+"'\n", sb.String())
+	data, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.True(t, json.Valid(data))
+}
@@ -0,0 +1,71 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"varanus/internal/config"
+	"varanus/internal/secrets"
+)
+
+// DiffConfig compares two configs by their decoded values and reports the accounts, monitors and
+// fields that were added, removed or changed.  Sealed values are only compared by their plaintext
+// if a private key is given.
+func (va varanusAppImpl) DiffConfig(args *DiffConfigArgs, outputStream io.Writer) error {
+	report := newCommandReport("diff", *args.Input)
+	report.Compared = *args.Compared
+	return runWithReport(*args.OutputMode, outputStream, report, func(textStream io.Writer) error {
+		return va.diffConfig(args, textStream, report)
+	})
+}
+
+func (va varanusAppImpl) diffConfig(args *DiffConfigArgs, outputStream io.Writer, report *CommandReport) error {
+
+	fmt.Fprint(outputStream, args.HumanReadable())
+
+	configs := []*config.VaranusConfig{}
+	for _, input := range []string{*args.Input, *args.Compared} {
+		configSet, err := readConfigSet(input, "", *args.Format)
+		if err != nil {
+			return newApplicationError("Could not load config from '%s': %w", input, err)
+		}
+		if *args.Resolved {
+			configs = append(configs, configSet.Config)
+		} else {
+			configs = append(configs, configSet.MergedConfig)
+		}
+	}
+
+	var unsealer secrets.SecretUnsealer
+	if len(*args.PrivateKey) > 0 {
+		unsealer = secrets.MakeSecretUnsealer()
+		err := unsealer.LoadPrivateKeyFromFile(*args.PrivateKey, *args.Passphrase)
+		if err != nil {
+			return newApplicationError("Could not load private key from '%s': %w", *args.PrivateKey, err)
+		}
+	}
+
+	changes, err := config.DiffConfigs(configs[0], configs[1], unsealer)
+	if err != nil {
+		return newApplicationErrorWithExitCode(EXIT_CODE_SEAL_ERRORS, "Could not compare the configs: %w", err)
+	}
+	report.setConfigChanges(changes)
+
+	if len(changes) == 0 {
+		fmt.Fprintln(outputStream, "The configs are the same.")
+		return nil
+	}
+
+	notCompared := 0
+	fmt.Fprintf(outputStream, "%d differences from '%s' to '%s':\n", len(changes), *args.Input, *args.Compared)
+	for _, change := range changes {
+		fmt.Fprintf(outputStream, "  %s\n", change)
+		if change.Kind == config.CONFIG_CHANGE_NOT_COMPARED {
+			notCompared++
+		}
+	}
+	if notCompared > 0 {
+		fmt.Fprintf(outputStream,
+			"%d sealed values were sealed again or changed; give the private key to compare their plaintexts.\n", notCompared)
+	}
+	return nil
+}
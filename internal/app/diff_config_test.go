@@ -0,0 +1,145 @@
+package app
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"varanus/internal/config"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sealExampleConfig seals tests/example.yaml into a new file and returns its name
+func sealExampleConfig(t *testing.T, app VaranusApp) string {
+	tempFile := util.CreateTempFileAndDir("test_output", "diff_config_test.*.yaml")
+	tempFile.Close()
+	args := SealConfigArgs{
+		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		Output:         util.Ptr(tempFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+	}
+	err := app.SealConfig(&args, io.Discard)
+	require.Nil(t, err)
+	return tempFile.Name()
+}
+
+func makeDiffConfigArgs(input string, compared string) DiffConfigArgs {
+	return DiffConfigArgs{
+		Input:      util.Ptr(input),
+		Compared:   util.Ptr(compared),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
+		PrivateKey: util.Ptr(""),
+		Passphrase: util.Ptr(""),
+		Resolved:   util.Ptr(false),
+	}
+}
+
+func TestDiffConfig(t *testing.T) {
+
+	app := CreateApp()
+
+	//the same passwords sealed twice have different sealed values
+	sealed1 := sealExampleConfig(t, app)
+	sealed2 := sealExampleConfig(t, app)
+
+	var sb strings.Builder
+	args := makeDiffConfigArgs(sealed1, sealed2)
+	err := app.DiffConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "3 differences from '"+sealed1+"' to '"+sealed2+"':\n")
+	assert.Contains(t, sb.String(), "  mail.accounts[test1].imap.password: sealed differently, not compared without a private key\n")
+	assert.Contains(t, sb.String(), "3 sealed values were sealed again or changed; give the private key to compare their plaintexts.")
+
+	//with the private key, they are the same
+	sb.Reset()
+	args.PrivateKey = util.Ptr("tests/key-4096.pem")
+	err = app.DiffConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "The configs are the same.")
+
+	//a changed field and sealing are reported
+	data, err := os.ReadFile("tests/example.yaml")
+	require.Nil(t, err)
+	changedFile := util.CreateTempFileAndDir("test_output", "diff_config_test.*.yaml")
+	_, err = changedFile.WriteString(strings.Replace(string(data), "port: 465", "port: 587", 1))
+	require.Nil(t, err)
+	changedFile.Close()
+
+	sb.Reset()
+	args = makeDiffConfigArgs(changedFile.Name(), sealed1)
+	args.PrivateKey = util.Ptr("tests/key-4096.pem")
+	args.OutputMode = util.Ptr(OUTPUT_MODE_JSON)
+	err = app.DiffConfig(&args, &sb)
+	require.Nil(t, err)
+	report := CommandReport{}
+	err = json.Unmarshal([]byte(sb.String()), &report)
+	require.Nil(t, err)
+	assert.Equal(t, "diff", report.Command)
+	assert.Equal(t, REPORT_STATUS_OK, report.Status)
+	assert.Equal(t, changedFile.Name(), report.Input)
+	assert.Equal(t, sealed1, report.Compared)
+	expectedDifferences := []ConfigDifferenceReport{
+		{Path: "mail.accounts[test1].smtp.port", Kind: "changed", OldValue: "587", NewValue: "465"},
+		{Path: "mail.accounts[test1].imap.password", Kind: "changed", OldValue: config.DIFF_PLAINTEXT_VALUE, NewValue: config.DIFF_SEALED_VALUE},
+		{Path: "mail.accounts[test2].smtp.password", Kind: "changed", OldValue: config.DIFF_PLAINTEXT_VALUE, NewValue: config.DIFF_SEALED_VALUE},
+		{Path: "mail.accounts[test3].imap.password", Kind: "changed", OldValue: config.DIFF_PLAINTEXT_VALUE, NewValue: config.DIFF_SEALED_VALUE},
+	}
+	assert.Equal(t, expectedDifferences, report.Differences)
+}
+
+func TestDiffConfigFailures(t *testing.T) {
+
+	app := CreateApp()
+	sealed := sealExampleConfig(t, app)
+
+	type testCase struct {
+		input         string
+		compared      string
+		privateKey    string
+		errorContains string
+		exitCode      int
+	}
+
+	testCases := []testCase{
+		{
+			input:         "tests/example.yaml",
+			compared:      "tests/invalid.yaml",
+			errorContains: "Could not load config from 'tests/invalid.yaml'",
+			exitCode:      EXIT_CODE_FAILED,
+		},
+		{
+			input:         "tests/example.yaml",
+			compared:      sealed,
+			privateKey:    "tests/missing.pem",
+			errorContains: "Could not load private key from 'tests/missing.pem'",
+			exitCode:      EXIT_CODE_FAILED,
+		},
+		{
+			input:         "tests/example-bad-seal.yaml",
+			compared:      sealed,
+			privateKey:    "tests/key-4096.pem",
+			errorContains: "Could not compare the configs: could not unseal the old value of",
+			exitCode:      EXIT_CODE_SEAL_ERRORS,
+		},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		var sb strings.Builder
+		args := makeDiffConfigArgs(testCase.input, testCase.compared)
+		args.PrivateKey = util.Ptr(testCase.privateKey)
+		err := app.DiffConfig(&args, &sb)
+		assert.ErrorContains(t, err, testCase.errorContains)
+		assert.Equal(t, testCase.exitCode, exitCodeForError(err))
+	}
+}
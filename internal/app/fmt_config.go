@@ -0,0 +1,53 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"varanus/internal/config"
+)
+
+func (va varanusAppImpl) FmtConfig(args *FmtConfigArgs, outputStream io.Writer) error {
+	report := newCommandReport("fmt", *args.Input)
+	report.Output = *args.Output
+	return runWithReport(*args.OutputMode, outputStream, report, func(textStream io.Writer) error {
+		return va.fmtConfig(args, textStream, report)
+	})
+}
+
+// fmtConfig writes the input config with its values normalized, like trimmed names, lowercased
+// server addresses and default ports.  Only the input file is formatted; the files it includes are
+// formatted on their own.  Like migrate, the config isn't validated, so that a config can be
+// formatted before it is fixed.
+func (va varanusAppImpl) fmtConfig(args *FmtConfigArgs, outputStream io.Writer, report *CommandReport) error {
+
+	fmt.Fprint(outputStream, args.HumanReadable())
+
+	//the config is normalized when it is read, and the document keeps its comments and layout
+	format, err := config.ResolveConfigFormat(*args.Format, *args.Input)
+	if err != nil {
+		return newApplicationError("Could not load config from '%s': %w", *args.Input, err)
+	}
+	document, err := config.ReadConfigDocumentFromFile(*args.Input, format)
+	if err != nil {
+		return newApplicationError("Could not load config from '%s': %w", *args.Input, err)
+	}
+
+	report.Normalized = document.UpdateNormalizedValues()
+	if len(report.Normalized) == 0 {
+		fmt.Fprintln(outputStream, "The config is already normalized, so no values were changed.")
+	} else {
+		fmt.Fprintf(outputStream, "%d values were normalized:\n", len(report.Normalized))
+		for _, path := range report.Normalized {
+			fmt.Fprintf(outputStream, "  %s\n", path)
+		}
+	}
+
+	err = document.WriteToFile(*args.Output, *args.ForceOverwrite)
+	if err != nil {
+		return newApplicationError("Error writing output config to '%s': %w", *args.Output, err)
+	}
+
+	fmt.Fprintf(outputStream, "Fmt operation succeeded.  Results written to '%s'\n", *args.Output)
+
+	return nil
+}
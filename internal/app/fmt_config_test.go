@@ -0,0 +1,116 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeFmtConfigArgs(input string, output string) FmtConfigArgs {
+	return FmtConfigArgs{
+		Input:          util.Ptr(input),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		Output:         util.Ptr(output),
+		ForceOverwrite: util.Ptr(true),
+	}
+}
+
+func TestFmtConfig(t *testing.T) {
+
+	app := CreateApp()
+
+	tempFile := util.CreateTempFileAndDir("test_output", "fmt_config_test.*.yaml")
+	tempFile.Close()
+
+	//a normalized config is written unchanged
+	var sb strings.Builder
+	args := makeFmtConfigArgs("tests/example.yaml", tempFile.Name())
+	err := app.FmtConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "The config is already normalized, so no values were changed.")
+	assert.Contains(t, sb.String(), "Fmt operation succeeded.  Results written to '"+tempFile.Name()+"'")
+
+	input, err := os.ReadFile("tests/example.yaml")
+	require.Nil(t, err)
+	output, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, string(input), string(output))
+
+	//untidy values are normalized and the rest of the file is kept
+	untidyFile := util.CreateTempFileAndDir("test_output", "fmt_config_test.*.yaml")
+	untidy := strings.Replace(string(input), "server_address: smtp.example.com", "server_address: \" SMTP.Example.com\"", 1)
+	//the example has Windows line endings
+	untidy = strings.Replace(untidy, "        port: 993\r\n", "", 1)
+	_, err = untidyFile.WriteString(untidy)
+	require.Nil(t, err)
+	untidyFile.Close()
+
+	sb.Reset()
+	args = makeFmtConfigArgs(untidyFile.Name(), tempFile.Name())
+	args.OutputMode = util.Ptr(OUTPUT_MODE_JSON)
+	err = app.FmtConfig(&args, &sb)
+	require.Nil(t, err)
+	report := CommandReport{}
+	err = json.Unmarshal([]byte(sb.String()), &report)
+	require.Nil(t, err)
+	assert.Equal(t, "fmt", report.Command)
+	assert.Equal(t, REPORT_STATUS_OK, report.Status)
+	assert.Equal(t, tempFile.Name(), report.Output)
+	assert.Equal(t, []string{"mail.accounts[0].smtp.server_address", "mail.accounts[0].imap.port"}, report.Normalized)
+
+	//the account doesn't use TLS, so the default IMAP port is 143
+	output, err = os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, strings.Replace(string(input), "port: 993", "port: 143", 1), string(output))
+}
+
+func TestFmtConfigFailures(t *testing.T) {
+
+	app := CreateApp()
+
+	existingFile := util.CreateTempFileAndDir("test_output", "fmt_config_test.*.yaml")
+	existingFile.Close()
+
+	type testCase struct {
+		input          string
+		format         string
+		forceOverwrite bool
+		errorContains  string
+	}
+
+	testCases := []testCase{
+		{
+			input:          "tests/invalid.yaml",
+			forceOverwrite: true,
+			errorContains:  "Could not load config from 'tests/invalid.yaml'",
+		},
+		{
+			input:          "tests/example.yaml",
+			format:         "toml",
+			forceOverwrite: true,
+			errorContains:  "Could not load config from 'tests/example.yaml'",
+		},
+		{
+			input:         "tests/example.yaml",
+			errorContains: "Error writing output config to '" + existingFile.Name() + "'",
+		},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		var sb strings.Builder
+		args := makeFmtConfigArgs(testCase.input, existingFile.Name())
+		args.Format = util.Ptr(testCase.format)
+		args.ForceOverwrite = util.Ptr(testCase.forceOverwrite)
+		err := app.FmtConfig(&args, &sb)
+		assert.ErrorContains(t, err, testCase.errorContains)
+		assert.Equal(t, EXIT_CODE_FAILED, exitCodeForError(err))
+	}
+}
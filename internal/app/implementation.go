@@ -2,17 +2,101 @@ package app
 
 import (
 	"fmt"
+	"net"
+	"strings"
+	"varanus/internal/config"
 )
 
 type varanusAppImpl struct {
+	resolver config.HostResolver //resolves SMTP servers for send limit groups
 }
 
 func CreateApp() VaranusApp {
-	return varanusAppImpl{}
+	return varanusAppImpl{
+		resolver: net.DefaultResolver,
+	}
 }
 
 func newApplicationError(format string, args ...interface{}) error {
+	return newApplicationErrorWithExitCode(EXIT_CODE_FAILED, format, args...)
+}
+
+func newApplicationErrorWithExitCode(exitCode int, format string, args ...interface{}) error {
 	return ApplicationError{
 		theError: fmt.Errorf(format, args...),
+		exitCode: exitCode,
+	}
+}
+
+// readConfigSet reads the config at input with the files it includes, or the fragments in
+// configDir instead if it is set.  format only applies to input.
+func readConfigSet(input string, configDir string, format string) (*config.ConfigSet, error) {
+	if configDir != "" {
+		return config.ReadConfigSetFromDir(configDir)
+	}
+	configFormat, err := config.ResolveConfigFormat(format, input)
+	if err != nil {
+		return nil, err
+	}
+	return config.ReadConfigSetFromFile(input, configFormat)
+}
+
+// configSource returns the config directory if it is set, or else the input file, for messages
+func configSource(input string, configDir string) string {
+	if configDir != "" {
+		return configDir
+	}
+	return input
+}
+
+// describeConfigSet returns a line about the files that were merged, or an empty string if there
+// was only one
+func describeConfigSet(set *config.ConfigSet) string {
+	if len(set.Documents) < 2 {
+		return ""
+	}
+	filenames := []string{}
+	for _, document := range set.Documents {
+		filenames = append(filenames, document.Filename)
+	}
+	return fmt.Sprintf("The config was merged from %d files: %s\n", len(filenames), strings.Join(filenames, ", "))
+}
+
+// documentsToWrite returns the documents that seal and unseal change.  With a config directory,
+// every fragment is changed in place.  Otherwise only the input file is changed, and the files it
+// includes are sealed or unsealed on their own.
+func documentsToWrite(set *config.ConfigSet, configDir string) []*config.ConfigDocument {
+	if configDir != "" {
+		return set.Documents
+	}
+	return set.Documents[:1]
+}
+
+// writeDocuments writes the sealed items of the documents back out, either to output for a single
+// input file, or in place for the fragments of a config directory.
+func writeDocuments(documents []*config.ConfigDocument, configDir string, output string, forceOverwrite bool) error {
+	for _, document := range documents {
+		filename := output
+		if configDir != "" {
+			filename = document.Filename
+			forceOverwrite = true
+		}
+		err := document.UpdateSealedItems()
+		if err == nil {
+			err = document.WriteToFile(filename, forceOverwrite)
+		}
+		if err != nil {
+			return newApplicationError("Error writing output config to '%s': %w", filename, err)
+		}
+	}
+	return nil
+}
+
+// errorsInFile adds the filename to errors from one of several files
+func errorsInFile(filename string, errs []error) []error {
+	result := make([]error, 0, len(errs))
+	for _, err := range errs {
+		result = append(result, fmt.Errorf("%s: %w", filename, err))
 	}
+	return result
 }
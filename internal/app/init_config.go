@@ -0,0 +1,792 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"varanus/internal/config"
+	"varanus/internal/secrets"
+	"varanus/internal/validation"
+
+	"golang.org/x/term"
+)
+
+// DEFAULT_INIT_TEST_PERIOD is the test_period of new monitors if none is given
+const DEFAULT_INIT_TEST_PERIOD = time.Hour
+
+// the keys of the --account and --monitor specs, like "name=work,preset=gmail".  A comma or
+// backslash in a value is escaped with a backslash, like "password=a\,b".
+const (
+	INIT_KEY_NAME        = "name"
+	INIT_KEY_PRESET      = "preset"
+	INIT_KEY_USE         = "use"
+	INIT_KEY_ADDRESS     = "address"
+	INIT_KEY_USERNAME    = "username"
+	INIT_KEY_PASSWORD    = "password"
+	INIT_KEY_SMTP_SERVER = "smtp_server"
+	INIT_KEY_SMTP_PORT   = "smtp_port"
+	INIT_KEY_IMAP_SERVER = "imap_server"
+	INIT_KEY_IMAP_PORT   = "imap_port"
+	INIT_KEY_MAILBOX     = "mailbox"
+	INIT_KEY_FROM        = "from"
+	INIT_KEY_TO          = "to"
+	INIT_KEY_PERIOD      = "period"
+	INIT_KEY_NOTIFY      = "notify"
+)
+
+var initAccountKeys = []string{INIT_KEY_NAME, INIT_KEY_PRESET, INIT_KEY_USE, INIT_KEY_ADDRESS, INIT_KEY_USERNAME, INIT_KEY_PASSWORD,
+	INIT_KEY_SMTP_SERVER, INIT_KEY_SMTP_PORT, INIT_KEY_IMAP_SERVER, INIT_KEY_IMAP_PORT, INIT_KEY_MAILBOX}
+
+// the values of the use key, which decide whether a new account gets an smtp or imap section
+const (
+	INIT_USE_BOTH    = "both"
+	INIT_USE_SEND    = "send"
+	INIT_USE_RECEIVE = "receive"
+)
+
+var initUses = []string{INIT_USE_BOTH, INIT_USE_SEND, INIT_USE_RECEIVE}
+var initMonitorKeys = []string{INIT_KEY_FROM, INIT_KEY_TO, INIT_KEY_PERIOD, INIT_KEY_NOTIFY}
+
+// errInitInputEnded is returned when the input ends before every question was answered
+var errInitInputEnded = errors.New("the input ended before the config was complete")
+
+// InitConfig writes a new config from the --account and --monitor specs, or from the answers to
+// prompts read from inputStream if there are none.  The config is validated before it is written,
+// and its passwords are sealed if a public key is given.
+func (va varanusAppImpl) InitConfig(args *InitConfigArgs, inputStream io.Reader, outputStream io.Writer) error {
+	report := newCommandReport("init", "")
+	report.Output = *args.Output
+	if args.isInteractive() && *args.OutputMode == OUTPUT_MODE_JSON {
+		//the prompts can't be shown with the JSON output
+		return newApplicationErrorWithExitCode(EXIT_CODE_USAGE,
+			"the json output mode needs the accounts and monitors to be given with --account and --monitor")
+	}
+	return runWithReport(*args.OutputMode, outputStream, report, func(textStream io.Writer) error {
+		return va.initConfig(args, inputStream, textStream, report)
+	})
+}
+
+func (va varanusAppImpl) initConfig(args *InitConfigArgs, inputStream io.Reader, outputStream io.Writer, report *CommandReport) error {
+
+	fmt.Fprint(outputStream, args.HumanReadable())
+
+	//fail before the prompts if the config can't be written or sealed
+	if !*args.ForceOverwrite {
+		if _, err := os.Stat(*args.Output); err == nil {
+			return newApplicationError("The output file '%s' already exists; use --forceOverwrite to replace it", *args.Output)
+		}
+	}
+	var sealer secrets.SecretSealer
+	if len(*args.PublicKey) > 0 {
+		sealer = secrets.MakeSecretSealer()
+		err := sealer.LoadPublicKeyFromFile(*args.PublicKey)
+		if err != nil {
+			return newApplicationError("Could not load public key from '%s': %w", *args.PublicKey, err)
+		}
+	}
+
+	//gather the accounts and monitors
+	var accounts []initAccount
+	var monitors []initMonitor
+	var err error
+	if args.isInteractive() {
+		prompter := initPrompter{input: bufio.NewReader(inputStream), output: outputStream}
+		if file, isFile := inputStream.(*os.File); isFile && term.IsTerminal(int(file.Fd())) {
+			//the passwords aren't echoed when they are typed in a terminal
+			prompter.readPassword = func() (string, error) {
+				password, err := term.ReadPassword(int(file.Fd()))
+				return string(password), err
+			}
+		}
+		accounts, monitors, err = prompter.promptForConfig()
+	} else {
+		accounts, monitors, err = parseInitSpecs(*args.Accounts, *args.Monitors)
+	}
+	if err != nil {
+		return newApplicationErrorWithExitCode(EXIT_CODE_USAGE, "Could not create the config: %w", err)
+	}
+	newConfig, err := buildInitConfig(accounts, monitors)
+	if err != nil {
+		return newApplicationErrorWithExitCode(EXIT_CODE_USAGE, "Could not create the config: %w", err)
+	}
+
+	//the passwords are sealed first so that validation doesn't warn that they are plaintext
+	if sealer != nil {
+		sealResult := sealer.SealObject(newConfig)
+		report.setSealResult(sealResult)
+		fmt.Fprint(outputStream, sealResult.HumanReadable())
+		if len(sealResult.SealErrors) > 0 {
+			return newApplicationErrorWithExitCode(EXIT_CODE_SEAL_ERRORS,
+				"There were errors when sealing the config.  Check the output for details.")
+		}
+	}
+
+	//the config is only written if it is valid
+	validationResult, err := validation.ValidateObject(newConfig)
+	if err != nil {
+		fmt.Fprintf(outputStream, "Config validation failed: %s\n", err)
+		return newApplicationError(
+			"Refusing to write the configuration because validation had an error -- please report this as a bug: %w", err)
+	}
+	newConfig.Lint(&validationResult)
+	report.setValidationResult(validationResult)
+	fmt.Fprint(outputStream, validationResult.HumanReadable())
+	if validationResult.GetErrorCount() > 0 {
+		return newApplicationErrorWithExitCode(EXIT_CODE_INVALID_CONFIG,
+			"Refusing to write the config because it has validation errors.  See the output above for details.")
+	}
+
+	//the output is written in the format of its extension
+	err = newConfig.WriteConfigToFile(*args.Output, *args.ForceOverwrite)
+	if err != nil {
+		return newApplicationError("Error writing config output to '%s': %w", *args.Output, err)
+	}
+
+	fmt.Fprintf(outputStream, "Init operation succeeded.  Config written to '%s'\n", *args.Output)
+	if sealer == nil {
+		fmt.Fprintln(outputStream, "The passwords are not sealed; seal them with 'varanus config seal' before sharing the config.")
+	}
+	return nil
+}
+
+// initAccount is an account for a new config, from an --account spec or the prompts
+type initAccount struct {
+	name       string
+	preset     string
+	use        string //one of initUses
+	address    string
+	username   string
+	password   string
+	smtpServer string //if empty, the server of the preset
+	smtpPort   uint   //if 0, the port of the preset
+	imapServer string
+	imapPort   uint
+	mailbox    string //if empty, config.DEFAULT_MAILBOX_NAME
+}
+
+// sends returns true if the account gets an smtp section
+func (a initAccount) sends() bool {
+	return a.use != INIT_USE_RECEIVE
+}
+
+// receives returns true if the account gets an imap section
+func (a initAccount) receives() bool {
+	return a.use != INIT_USE_SEND
+}
+
+// initMonitor is a monitor for a new config, from a --monitor spec or the prompts
+type initMonitor struct {
+	from   string
+	to     string
+	period time.Duration
+	//notify are the account names that are notified; if empty, one is chosen by defaultNotification
+	notify []string
+}
+
+// buildInitConfig returns a config with the accounts and monitors, at the current config version
+func buildInitConfig(accounts []initAccount, monitors []initMonitor) (*config.VaranusConfig, error) {
+	newConfig := config.VaranusConfig{
+		Version: config.CurrentConfigVersion(),
+		Mail: config.MailConfig{
+			Accounts:   []config.MailAccountConfig{},
+			SendLimits: []config.SendLimitConfig{},
+		},
+		MonitoringConfig: config.MonitorConfig{
+			EmailMonitors: []config.EmailMonitorConfig{},
+		},
+	}
+
+	accountNames := []string{}
+	senders := []string{} //the accounts that can send a notification
+	for _, account := range accounts {
+		preset, found := config.GetMailProviderPreset(account.preset)
+		if !found {
+			return nil, fmt.Errorf("account '%s' has an unknown preset '%s'; expected one of %s",
+				account.name, account.preset, strings.Join(config.MailProviderPresetNames(), ", "))
+		}
+		if slices.Contains(accountNames, account.name) {
+			return nil, fmt.Errorf("there is more than one account named '%s'", account.name)
+		}
+		accountNames = append(accountNames, account.name)
+		if account.sends() {
+			senders = append(senders, account.name)
+		}
+
+		username := account.username
+		if username == "" {
+			username = account.address
+		}
+		newAccount := preset.NewAccount(account.name, account.address, username, account.password)
+		if account.smtpServer != "" {
+			newAccount.SMTP.ServerAddress = account.smtpServer
+		}
+		if account.smtpPort != 0 {
+			newAccount.SMTP.Port = account.smtpPort
+		}
+		if account.imapServer != "" {
+			newAccount.IMAP.ServerAddress = account.imapServer
+		}
+		if account.imapPort != 0 {
+			newAccount.IMAP.Port = account.imapPort
+		}
+		if account.mailbox != "" {
+			newAccount.IMAP.MailboxName = account.mailbox
+		}
+		if !account.sends() {
+			if account.smtpServer != "" || account.smtpPort != 0 {
+				return nil, fmt.Errorf("account '%s' only receives email, so it can't have an %s or %s",
+					account.name, INIT_KEY_SMTP_SERVER, INIT_KEY_SMTP_PORT)
+			}
+			newAccount.SMTP = nil
+		}
+		if !account.receives() {
+			if account.imapServer != "" || account.imapPort != 0 || account.mailbox != "" {
+				return nil, fmt.Errorf("account '%s' only sends email, so it can't have an %s, %s or %s",
+					account.name, INIT_KEY_IMAP_SERVER, INIT_KEY_IMAP_PORT, INIT_KEY_MAILBOX)
+			}
+			newAccount.IMAP = nil
+		}
+		//the generic presets don't know the servers
+		if newAccount.SMTP != nil && newAccount.SMTP.ServerAddress == "" {
+			return nil, fmt.Errorf("account '%s' needs an %s because the '%s' preset doesn't have one",
+				account.name, INIT_KEY_SMTP_SERVER, preset.Name)
+		}
+		if newAccount.IMAP != nil && newAccount.IMAP.ServerAddress == "" {
+			return nil, fmt.Errorf("account '%s' needs an %s because the '%s' preset doesn't have one",
+				account.name, INIT_KEY_IMAP_SERVER, preset.Name)
+		}
+		newConfig.Mail.Accounts = append(newConfig.Mail.Accounts, newAccount)
+	}
+
+	for _, monitor := range monitors {
+		period := monitor.period
+		if period == 0 {
+			period = DEFAULT_INIT_TEST_PERIOD
+		}
+		notify := monitor.notify
+		if len(notify) == 0 {
+			notify = splitInitList(defaultNotification(monitor.from, monitor.to, senders))
+		}
+		if err := checkInitNotifications(monitor.from, monitor.to, notify); err != nil {
+			return nil, err
+		}
+		newMonitor := config.EmailMonitorConfig{
+			FromAccount:   monitor.from,
+			ToAccount:     monitor.to,
+			TestPeriod:    period,
+			Notifications: []config.NotificationConfig{},
+		}
+		for _, name := range notify {
+			newMonitor.Notifications = append(newMonitor.Notifications, config.NotificationConfig{Mail: name})
+		}
+		newConfig.MonitoringConfig.EmailMonitors = append(newConfig.MonitoringConfig.EmailMonitors, newMonitor)
+	}
+
+	return &newConfig, nil
+}
+
+// defaultNotification returns the account that is notified about a monitor when none is given,
+// which is the first of the senders the monitor doesn't test, or "" if there isn't one.  Only an
+// account that sends can be notified, since the notification is sent from the account itself.
+func defaultNotification(from string, to string, senders []string) string {
+	for _, name := range senders {
+		if name != from && name != to {
+			return name
+		}
+	}
+	return ""
+}
+
+// checkInitNotifications returns an error if a monitor would only notify an account it tests,
+// which validation doesn't allow
+func checkInitNotifications(from string, to string, notify []string) error {
+	if len(notify) == 0 {
+		return fmt.Errorf("the monitor from '%s' to '%s' needs an account to notify that it doesn't test and that sends email",
+			from, to)
+	}
+	if len(notify) == 1 && (notify[0] == from || notify[0] == to) {
+		return fmt.Errorf("the only account notified about the monitor from '%s' to '%s' can't be one that it tests", from, to)
+	}
+	return nil
+}
+
+// parseInitSpecs parses the --account and --monitor specs
+func parseInitSpecs(accountSpecs []string, monitorSpecs []string) ([]initAccount, []initMonitor, error) {
+	accounts := []initAccount{}
+	for _, spec := range accountSpecs {
+		account, err := parseInitAccount(spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid account '%s': %w", redactInitSpec(spec), err)
+		}
+		accounts = append(accounts, account)
+	}
+	monitors := []initMonitor{}
+	for _, spec := range monitorSpecs {
+		monitor, err := parseInitMonitor(spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid monitor '%s': %w", spec, err)
+		}
+		monitors = append(monitors, monitor)
+	}
+	return accounts, monitors, nil
+}
+
+func parseInitAccount(spec string) (initAccount, error) {
+	values, err := parseInitSpec(spec, initAccountKeys, INIT_KEY_NAME, INIT_KEY_ADDRESS, INIT_KEY_PASSWORD)
+	if err != nil {
+		return initAccount{}, err
+	}
+	account := initAccount{
+		name:       values[INIT_KEY_NAME][0],
+		preset:     config.DEFAULT_MAIL_PRESET,
+		use:        INIT_USE_BOTH,
+		address:    values[INIT_KEY_ADDRESS][0],
+		password:   values[INIT_KEY_PASSWORD][0],
+		username:   firstValue(values, INIT_KEY_USERNAME),
+		smtpServer: firstValue(values, INIT_KEY_SMTP_SERVER),
+		imapServer: firstValue(values, INIT_KEY_IMAP_SERVER),
+		mailbox:    firstValue(values, INIT_KEY_MAILBOX),
+	}
+	if preset := firstValue(values, INIT_KEY_PRESET); preset != "" {
+		account.preset = preset
+	}
+	if use := firstValue(values, INIT_KEY_USE); use != "" {
+		if err := checkInitUse(use); err != nil {
+			return initAccount{}, err
+		}
+		account.use = use
+	}
+	if _, err := mail.ParseAddress(account.address); err != nil {
+		return initAccount{}, fmt.Errorf("%s '%s' is not a valid email: %w", INIT_KEY_ADDRESS, account.address, err)
+	}
+	if account.smtpPort, err = parseInitPort(values, INIT_KEY_SMTP_PORT); err != nil {
+		return initAccount{}, err
+	}
+	if account.imapPort, err = parseInitPort(values, INIT_KEY_IMAP_PORT); err != nil {
+		return initAccount{}, err
+	}
+	return account, nil
+}
+
+func parseInitMonitor(spec string) (initMonitor, error) {
+	values, err := parseInitSpec(spec, initMonitorKeys, INIT_KEY_FROM, INIT_KEY_TO)
+	if err != nil {
+		return initMonitor{}, err
+	}
+	monitor := initMonitor{
+		from:   values[INIT_KEY_FROM][0],
+		to:     values[INIT_KEY_TO][0],
+		notify: values[INIT_KEY_NOTIFY],
+	}
+	if period := firstValue(values, INIT_KEY_PERIOD); period != "" {
+		monitor.period, err = parseInitPeriod(period)
+		if err != nil {
+			return initMonitor{}, err
+		}
+	}
+	return monitor, nil
+}
+
+// parseInitSpec parses a comma separated list of key=value pairs.  Only the notify key can be
+// given more than once, and the required keys must be given.
+func parseInitSpec(spec string, allowedKeys []string, requiredKeys ...string) (map[string][]string, error) {
+	values := map[string][]string{}
+	for _, pair := range splitInitSpec(spec) {
+		key, value, found := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		value = unescapeInitValue(strings.TrimSpace(value))
+		if !found || key == "" {
+			return nil, fmt.Errorf("'%s' is not a key=value pair", redactInitSpec(pair))
+		}
+		if !slices.Contains(allowedKeys, key) {
+			return nil, fmt.Errorf("unknown key '%s'; expected one of %s", key, strings.Join(allowedKeys, ", "))
+		}
+		if _, duplicate := values[key]; duplicate && key != INIT_KEY_NOTIFY {
+			return nil, fmt.Errorf("the key '%s' is given more than once", key)
+		}
+		values[key] = append(values[key], value)
+	}
+	for _, key := range requiredKeys {
+		if firstValue(values, key) == "" {
+			return nil, fmt.Errorf("the key '%s' is required", key)
+		}
+	}
+	return values, nil
+}
+
+// splitInitSpec splits a spec at the commas that aren't escaped with a backslash.  The pairs keep
+// their escapes.
+func splitInitSpec(spec string) []string {
+	pairs := []string{}
+	start := 0
+	for index := 0; index < len(spec); index++ {
+		switch spec[index] {
+		case '\\':
+			//skip the escaped character
+			index++
+		case ',':
+			pairs = append(pairs, spec[start:index])
+			start = index + 1
+		}
+	}
+	return append(pairs, spec[start:])
+}
+
+// unescapeInitValue removes the backslashes that escape the characters of a value
+func unescapeInitValue(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+	var sb strings.Builder
+	for index := 0; index < len(value); index++ {
+		if value[index] == '\\' && index+1 < len(value) {
+			index++
+		}
+		sb.WriteByte(value[index])
+	}
+	return sb.String()
+}
+
+func firstValue(values map[string][]string, key string) string {
+	if len(values[key]) == 0 {
+		return ""
+	}
+	return values[key][0]
+}
+
+func parseInitPort(values map[string][]string, key string) (uint, error) {
+	value := firstValue(values, key)
+	if value == "" {
+		return 0, nil
+	}
+	return parsePortNumber(key, value)
+}
+
+func parsePortNumber(name string, value string) (uint, error) {
+	port, err := strconv.ParseUint(value, 10, 16)
+	if err != nil || port == 0 {
+		return 0, fmt.Errorf("%s '%s' is not a port number", name, value)
+	}
+	return uint(port), nil
+}
+
+func checkInitUse(use string) error {
+	if !slices.Contains(initUses, use) {
+		return fmt.Errorf("%s '%s' is not one of %s", INIT_KEY_USE, use, strings.Join(initUses, ", "))
+	}
+	return nil
+}
+
+func parseInitPeriod(value string) (time.Duration, error) {
+	period, err := time.ParseDuration(value)
+	if err != nil || period <= 0 {
+		return 0, fmt.Errorf("%s '%s' is not a positive duration like 30m or 1h", INIT_KEY_PERIOD, value)
+	}
+	return period, nil
+}
+
+// redactInitSpec hides the password in an account spec, so it isn't shown in errors
+func redactInitSpec(spec string) string {
+	pairs := splitInitSpec(spec)
+	for index, pair := range pairs {
+		if key, _, found := strings.Cut(pair, "="); found && strings.TrimSpace(key) == INIT_KEY_PASSWORD {
+			pairs[index] = key + "=<redacted>"
+		}
+	}
+	return strings.Join(pairs, ",")
+}
+
+// initPrompter asks for the accounts and monitors of a new config, one answer per line
+type initPrompter struct {
+	input  *bufio.Reader
+	output io.Writer
+	//readPassword reads a password without echoing it, or is nil to read it from input like the
+	//other answers
+	readPassword func() (string, error)
+}
+
+func (p initPrompter) promptForConfig() ([]initAccount, []initMonitor, error) {
+	fmt.Fprintln(p.output)
+	fmt.Fprintln(p.output, "Accounts send and receive the test emails and notifications.  The provider presets are:")
+	for _, preset := range config.MailProviderPresets() {
+		fmt.Fprintf(p.output, "  %-10s %s\n", preset.Name, preset.Description)
+	}
+	if p.readPassword != nil {
+		fmt.Fprintln(p.output, "The passwords are not shown as they are typed.")
+	}
+
+	accounts := []initAccount{}
+	accountNames := []string{}
+	for {
+		fmt.Fprintln(p.output)
+		name, err := p.askUntilValid(fmt.Sprintf("Account %d name (leave empty when done)", len(accounts)+1), "",
+			func(answer string) error {
+				if answer == "" && len(accounts) == 0 {
+					return errors.New("at least one account is needed")
+				}
+				if slices.Contains(accountNames, answer) {
+					return fmt.Errorf("there is already an account named '%s'", answer)
+				}
+				return nil
+			})
+		if err != nil {
+			return nil, nil, err
+		}
+		if name == "" {
+			break
+		}
+		account, err := p.promptForAccount(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		accounts = append(accounts, account)
+		accountNames = append(accountNames, name)
+	}
+
+	fmt.Fprintln(p.output)
+	fmt.Fprintln(p.output, "Monitors send a test email from one account to another and notify other accounts when it doesn't arrive.")
+	monitors := []initMonitor{}
+	senders := []string{}
+	receivers := []string{}
+	for _, account := range accounts {
+		if account.sends() {
+			senders = append(senders, account.name)
+		}
+		if account.receives() {
+			receivers = append(receivers, account.name)
+		}
+	}
+	if len(senders) == 0 || len(receivers) == 0 {
+		fmt.Fprintln(p.output, "A monitor needs an account that sends and an account that receives, so none are added.")
+		return accounts, monitors, nil
+	}
+	for {
+		question := "Add a monitor?"
+		if len(monitors) > 0 {
+			question = "Add another monitor?"
+		}
+		add, err := p.askYesNo(question, len(monitors) == 0)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !add {
+			break
+		}
+		monitor, err := p.promptForMonitor(accountNames, senders, receivers)
+		if err != nil {
+			return nil, nil, err
+		}
+		monitors = append(monitors, monitor)
+	}
+	return accounts, monitors, nil
+}
+
+func (p initPrompter) promptForAccount(name string) (initAccount, error) {
+	account := initAccount{name: name}
+	var err error
+
+	account.preset, err = p.askUntilValid("Provider preset", config.DEFAULT_MAIL_PRESET, func(answer string) error {
+		if _, found := config.GetMailProviderPreset(answer); !found {
+			return fmt.Errorf("expected one of %s", strings.Join(config.MailProviderPresetNames(), ", "))
+		}
+		return nil
+	})
+	if err != nil {
+		return account, err
+	}
+	preset, _ := config.GetMailProviderPreset(account.preset)
+	account.use, err = p.askUntilValid("Used to send, receive or both", INIT_USE_BOTH, checkInitUse)
+	if err != nil {
+		return account, err
+	}
+
+	account.address, err = p.askUntilValid("Email address", "", func(answer string) error {
+		_, err := mail.ParseAddress(answer)
+		return err
+	})
+	if err != nil {
+		return account, err
+	}
+	if account.username, err = p.ask("Username", account.address); err != nil {
+		return account, err
+	}
+	if account.password, err = p.askPassword("Password"); err != nil {
+		return account, err
+	}
+	if account.sends() {
+		if account.smtpServer, err = p.askUntilValid("SMTP server", preset.SMTPServer, requireAnswer); err != nil {
+			return account, err
+		}
+		if account.smtpPort, err = p.askPort("SMTP port", preset.SMTPPort); err != nil {
+			return account, err
+		}
+	}
+	if account.receives() {
+		if account.imapServer, err = p.askUntilValid("IMAP server", preset.IMAPServer, requireAnswer); err != nil {
+			return account, err
+		}
+		if account.imapPort, err = p.askPort("IMAP port", preset.IMAPPort); err != nil {
+			return account, err
+		}
+	}
+	return account, nil
+}
+
+// promptForMonitor asks for a monitor from one of the senders to one of the receivers, which
+// notifies any of the accounts
+func (p initPrompter) promptForMonitor(accountNames []string, senders []string, receivers []string) (initMonitor, error) {
+	monitor := initMonitor{}
+	isOneOf := func(names []string) func(answer string) error {
+		return func(answer string) error {
+			if !slices.Contains(names, answer) {
+				return fmt.Errorf("expected one of %s", strings.Join(names, ", "))
+			}
+			return nil
+		}
+	}
+	isAccount := isOneOf(accountNames)
+	var err error
+
+	if monitor.from, err = p.askUntilValid("From account", senders[0], isOneOf(senders)); err != nil {
+		return monitor, err
+	}
+	defaultTo := receivers[0]
+	for _, name := range receivers {
+		if name != monitor.from {
+			defaultTo = name
+			break
+		}
+	}
+	if monitor.to, err = p.askUntilValid("To account", defaultTo, isOneOf(receivers)); err != nil {
+		return monitor, err
+	}
+	//like "1h" instead of "1h0m0s"
+	defaultPeriod := strings.TrimSuffix(strings.TrimSuffix(DEFAULT_INIT_TEST_PERIOD.String(), "0s"), "0m")
+	period, err := p.askUntilValid("Test period", defaultPeriod, func(answer string) error {
+		_, err := parseInitPeriod(answer)
+		return err
+	})
+	if err != nil {
+		return monitor, err
+	}
+	monitor.period, _ = parseInitPeriod(period)
+
+	notify, err := p.askUntilValid("Accounts to notify, separated by commas",
+		defaultNotification(monitor.from, monitor.to, senders), func(answer string) error {
+			for _, name := range splitInitList(answer) {
+				if err := isAccount(name); err != nil {
+					return fmt.Errorf("'%s' is not an account; %w", name, err)
+				}
+			}
+			return checkInitNotifications(monitor.from, monitor.to, splitInitList(answer))
+		})
+	if err != nil {
+		return monitor, err
+	}
+	monitor.notify = splitInitList(notify)
+	return monitor, nil
+}
+
+// ask writes the question and returns the answer, or defaultValue if the answer is empty
+func (p initPrompter) ask(question string, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(p.output, "%s [%s]: ", question, defaultValue)
+	} else {
+		fmt.Fprintf(p.output, "%s: ", question)
+	}
+	line, err := p.input.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", errInitInputEnded
+		}
+		return "", fmt.Errorf("could not read the answer: %w", err)
+	}
+	answer := strings.TrimSpace(line)
+	if answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// askUntilValid asks the question again until check accepts the answer
+func (p initPrompter) askUntilValid(question string, defaultValue string, check func(answer string) error) (string, error) {
+	for {
+		answer, err := p.ask(question, defaultValue)
+		if err != nil {
+			return "", err
+		}
+		if err = check(answer); err == nil {
+			return answer, nil
+		}
+		fmt.Fprintf(p.output, "  %s\n", err)
+	}
+}
+
+// askPassword asks for a password until one is given, without echoing it if readPassword is set
+func (p initPrompter) askPassword(question string) (string, error) {
+	if p.readPassword == nil {
+		return p.askUntilValid(question, "", requireAnswer)
+	}
+	for {
+		fmt.Fprintf(p.output, "%s: ", question)
+		password, err := p.readPassword()
+		//the newline that ended the password wasn't echoed either
+		fmt.Fprintln(p.output)
+		if err != nil {
+			return "", fmt.Errorf("could not read the answer: %w", err)
+		}
+		if err = requireAnswer(password); err == nil {
+			return password, nil
+		}
+		fmt.Fprintf(p.output, "  %s\n", err)
+	}
+}
+
+func (p initPrompter) askYesNo(question string, defaultValue bool) (bool, error) {
+	defaultAnswer := "n"
+	if defaultValue {
+		defaultAnswer = "y"
+	}
+	answer, err := p.askUntilValid(question, defaultAnswer, func(answer string) error {
+		if !slices.Contains([]string{"y", "yes", "n", "no"}, strings.ToLower(answer)) {
+			return errors.New("expected y or n")
+		}
+		return nil
+	})
+	return strings.HasPrefix(strings.ToLower(answer), "y"), err
+}
+
+func (p initPrompter) askPort(question string, defaultValue uint) (uint, error) {
+	answer, err := p.askUntilValid(question, strconv.FormatUint(uint64(defaultValue), 10), func(answer string) error {
+		_, err := parsePortNumber("the port", answer)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return parsePortNumber("the port", answer)
+}
+
+func requireAnswer(answer string) error {
+	if answer == "" {
+		return errors.New("an answer is required")
+	}
+	return nil
+}
+
+func splitInitList(list string) []string {
+	names := []string{}
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,426 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"varanus/internal/config"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeInitConfigArgs(output string, accounts []string, monitors []string) InitConfigArgs {
+	return InitConfigArgs{
+		Output:         util.Ptr(output),
+		ForceOverwrite: util.Ptr(true),
+		OutputMode:     util.Ptr(""),
+		PublicKey:      util.Ptr(""),
+		Accounts:       &accounts,
+		Monitors:       &monitors,
+	}
+}
+
+func TestInitConfigFromFlags(t *testing.T) {
+
+	var sb strings.Builder
+
+	tempFile := util.CreateTempFileAndDir("test_output", "init_config_test.*.yaml")
+	tempFile.Close()
+	args := makeInitConfigArgs(tempFile.Name(),
+		[]string{
+			"name=work,preset=gmail,address=monitor@example.com,password=app-password",
+			"name=home,address=me@example.org,username=me,password=secret,smtp_server=mail.example.org,imap_server=mail.example.org,imap_port=1993,mailbox=Tests",
+			"name=alerts,preset=FASTMAIL,address=alerts@example.net,password=other",
+			`name=inbox,use=receive,address=in@example.org,password=a\,b\\c,imap_server=mail.example.org`,
+		},
+		[]string{
+			"from=work,to=home,period=30m",
+			"from=home,to=work,notify=alerts,notify=home",
+		})
+
+	app := CreateApp()
+
+	err := app.InitConfig(&args, strings.NewReader(""), &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "Init operation succeeded.  Config written to '"+tempFile.Name()+"'")
+	assert.Contains(t, sb.String(), "The passwords are not sealed")
+	//the passwords aren't shown
+	assert.NotContains(t, sb.String(), "app-password")
+
+	newConfig, err := config.ReadConfigFromFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, config.CurrentConfigVersion(), newConfig.Version)
+	require.Len(t, newConfig.Mail.Accounts, 4)
+
+	work := newConfig.Mail.Accounts[0]
+	assert.Equal(t, "smtp.gmail.com", work.SMTP.ServerAddress)
+	assert.Equal(t, uint(465), work.SMTP.Port)
+	assert.Equal(t, true, work.SMTP.UseTLS)
+	assert.Equal(t, "monitor@example.com", work.SMTP.Username)
+	assert.Equal(t, "app-password", work.SMTP.Password.GetValue())
+	assert.Equal(t, "imap.gmail.com", work.IMAP.ServerAddress)
+	assert.Equal(t, uint(993), work.IMAP.Port)
+	assert.Equal(t, "monitor@example.com", work.IMAP.RecipientAddress)
+	assert.Equal(t, config.DEFAULT_MAILBOX_NAME, work.IMAP.MailboxName)
+
+	home := newConfig.Mail.Accounts[1]
+	assert.Equal(t, "mail.example.org", home.SMTP.ServerAddress)
+	assert.Equal(t, uint(465), home.SMTP.Port)
+	assert.Equal(t, "me", home.SMTP.Username)
+	assert.Equal(t, "mail.example.org", home.IMAP.ServerAddress)
+	assert.Equal(t, uint(1993), home.IMAP.Port)
+	assert.Equal(t, "Tests", home.IMAP.MailboxName)
+
+	assert.Equal(t, "smtp.fastmail.com", newConfig.Mail.Accounts[2].SMTP.ServerAddress)
+
+	//an account that only receives has no smtp section, and the escaped comma is in the password
+	inbox := newConfig.Mail.Accounts[3]
+	assert.Nil(t, inbox.SMTP)
+	assert.Equal(t, "mail.example.org", inbox.IMAP.ServerAddress)
+	assert.Equal(t, `a,b\c`, inbox.IMAP.Password.GetValue())
+
+	expectedMonitors := []config.EmailMonitorConfig{
+		{
+			FromAccount:   "work",
+			ToAccount:     "home",
+			TestPeriod:    30 * time.Minute,
+			Notifications: []config.NotificationConfig{{Mail: "alerts"}},
+		},
+		{
+			FromAccount:   "home",
+			ToAccount:     "work",
+			TestPeriod:    DEFAULT_INIT_TEST_PERIOD,
+			Notifications: []config.NotificationConfig{{Mail: "alerts"}, {Mail: "home"}},
+		},
+	}
+	assert.Equal(t, expectedMonitors, newConfig.MonitoringConfig.EmailMonitors)
+
+	//the JSON report has the validation results
+	sb.Reset()
+	args.OutputMode = util.Ptr(OUTPUT_MODE_JSON)
+	err = app.InitConfig(&args, strings.NewReader(""), &sb)
+	require.Nil(t, err)
+	report := CommandReport{}
+	err = json.Unmarshal([]byte(sb.String()), &report)
+	require.Nil(t, err)
+	assert.Equal(t, "init", report.Command)
+	assert.Equal(t, REPORT_STATUS_OK, report.Status)
+	assert.Equal(t, tempFile.Name(), report.Output)
+	assert.NotEmpty(t, report.ValidationErrors)
+}
+
+func TestInitConfigInteractive(t *testing.T) {
+
+	var sb strings.Builder
+
+	tempFile := util.CreateTempFileAndDir("test_output", "init_config_test.*.yaml")
+	tempFile.Close()
+	args := makeInitConfigArgs(tempFile.Name(), []string{}, []string{})
+	args.PublicKey = util.Ptr("tests/key-4096.pub")
+
+	answers := []string{
+		"", //at least one account is needed
+		"work",
+		"gmail",
+		"", //sends and receives
+		"not an address",
+		"monitor@example.com",
+		"", //username is the address
+		"app-password",
+		"", "", "", "", //the servers and ports of the preset
+		"work", //already used
+		"home",
+		"unknown", //not a preset
+		"tls",
+		"sometimes", //not a use
+		"both",
+		"me@example.org",
+		"me",
+		"secret",
+		"", //the tls preset has no servers
+		"mail.example.org",
+		"0", //not a port
+		"587",
+		"mail.example.org",
+		"",
+		"alerts",
+		"fastmail",
+		"send",
+		"alerts@example.net",
+		"", "other", "", "", //no imap server or port
+
+		"",      //done with the accounts
+		"maybe", //not yes or no
+		"",      //add a monitor
+		"nobody",
+		"work",
+		"alerts", //only sends
+		"",       //to the next account
+		"soon",
+		"30m",
+		"home", //the only notification can't be tested by the monitor
+		"",     //notify the account that isn't tested
+		"",     //no more monitors
+	}
+
+	app := CreateApp()
+
+	err := app.InitConfig(&args, strings.NewReader(strings.Join(answers, "\n")+"\n"), &sb)
+	require.Nil(t, err)
+	output := sb.String()
+	assert.Contains(t, output, "at least one account is needed")
+	assert.Contains(t, output, "there is already an account named 'work'")
+	assert.Contains(t, output, "expected one of tls, plaintext, gmail")
+	assert.Contains(t, output, "SMTP server: ")
+	assert.Contains(t, output, "an answer is required")
+	assert.Contains(t, output, "the port '0' is not a port number")
+	assert.Contains(t, output, "expected y or n")
+	assert.Contains(t, output, "use 'sometimes' is not one of both, send, receive")
+	assert.Contains(t, output, "expected one of work, home, alerts")
+	assert.Contains(t, output, "expected one of work, home\n")
+	assert.Contains(t, output, "period 'soon' is not a positive duration")
+	assert.Contains(t, output, "Test period [1h]: ")
+	assert.Contains(t, output, "the only account notified about the monitor from 'work' to 'home' can't be one that it tests")
+	assert.Contains(t, output, "The seal operation sealed 5 items.")
+	assert.Contains(t, output, "Init operation succeeded.")
+	assert.NotContains(t, output, "The passwords are not sealed")
+
+	newConfig, err := config.ReadConfigFromFile(tempFile.Name())
+	require.Nil(t, err)
+	require.Len(t, newConfig.Mail.Accounts, 3)
+	home := newConfig.Mail.Accounts[1]
+	assert.Equal(t, "mail.example.org", home.SMTP.ServerAddress)
+	assert.Equal(t, uint(587), home.SMTP.Port)
+	assert.Equal(t, "me", home.IMAP.Username)
+	for _, account := range newConfig.Mail.Accounts {
+		assert.True(t, account.SMTP.Password.IsValueSealed())
+	}
+	assert.True(t, home.IMAP.Password.IsValueSealed())
+	assert.Nil(t, newConfig.Mail.Accounts[2].IMAP)
+	expectedMonitors := []config.EmailMonitorConfig{
+		{
+			FromAccount:   "work",
+			ToAccount:     "home",
+			TestPeriod:    30 * time.Minute,
+			Notifications: []config.NotificationConfig{{Mail: "alerts"}},
+		},
+	}
+	assert.Equal(t, expectedMonitors, newConfig.MonitoringConfig.EmailMonitors)
+}
+
+func TestInitConfigFailures(t *testing.T) {
+
+	tempFile := util.CreateTempFileAndDir("test_output", "init_config_test.*.yaml")
+	tempFile.Close()
+
+	validAccounts := []string{
+		"name=work,preset=gmail,address=monitor@example.com,password=app-password",
+		"name=home,preset=fastmail,address=me@example.org,password=secret",
+	}
+
+	type testCase struct {
+		accounts         []string
+		monitors         []string
+		input            string
+		outputMode       string
+		publicKey        string
+		forceOverwrite   bool
+		errorContains    string
+		errorNotContains string
+		exitCode         int
+	}
+
+	testCases := []testCase{
+		//the output exists
+		{
+			accounts:       validAccounts,
+			forceOverwrite: false,
+			errorContains:  "The output file '" + tempFile.Name() + "' already exists",
+			exitCode:       EXIT_CODE_FAILED,
+		},
+		{
+			accounts:       validAccounts,
+			publicKey:      "tests/missing.pub",
+			forceOverwrite: true,
+			errorContains:  "Could not load public key from 'tests/missing.pub'",
+			exitCode:       EXIT_CODE_FAILED,
+		},
+		//the prompts can't be answered with the JSON output
+		{
+			outputMode:     OUTPUT_MODE_JSON,
+			forceOverwrite: true,
+			errorContains:  "the json output mode needs the accounts and monitors",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			input:          "work\ngmail\n",
+			forceOverwrite: true,
+			errorContains:  "the input ended before the config was complete",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		//the account specs
+		{
+			accounts:         []string{"name=work,password=secret,address"},
+			forceOverwrite:   true,
+			errorContains:    "invalid account 'name=work,password=<redacted>,address': 'address' is not a key=value pair",
+			errorNotContains: "secret",
+			exitCode:         EXIT_CODE_USAGE,
+		},
+		{
+			accounts:         []string{`name=work,password=se\,cret,address`},
+			forceOverwrite:   true,
+			errorContains:    "invalid account 'name=work,password=<redacted>,address'",
+			errorNotContains: "cret",
+			exitCode:         EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,address=monitor@example.com,password=secret,use=sometimes"},
+			forceOverwrite: true,
+			errorContains:  "use 'sometimes' is not one of both, send, receive",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,use=receive,address=monitor@example.com,password=secret,smtp_server=mail.example.com,imap_server=mail.example.com"},
+			forceOverwrite: true,
+			errorContains:  "account 'work' only receives email, so it can't have an smtp_server or smtp_port",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,use=send,preset=gmail,address=monitor@example.com,password=secret,mailbox=Tests"},
+			forceOverwrite: true,
+			errorContains:  "account 'work' only sends email, so it can't have an imap_server, imap_port or mailbox",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,address=monitor@example.com,password=secret,port=25"},
+			forceOverwrite: true,
+			errorContains:  "unknown key 'port'; expected one of name, preset",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,name=home,address=monitor@example.com,password=secret"},
+			forceOverwrite: true,
+			errorContains:  "the key 'name' is given more than once",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,address=monitor@example.com"},
+			forceOverwrite: true,
+			errorContains:  "the key 'password' is required",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,address=monitor,password=secret"},
+			forceOverwrite: true,
+			errorContains:  "address 'monitor' is not a valid email",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,preset=gmail,address=monitor@example.com,password=secret,smtp_port=70000"},
+			forceOverwrite: true,
+			errorContains:  "smtp_port '70000' is not a port number",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,preset=hotmail,address=monitor@example.com,password=secret"},
+			forceOverwrite: true,
+			errorContains:  "account 'work' has an unknown preset 'hotmail'",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{"name=work,address=monitor@example.com,password=secret,imap_server=imap.example.com"},
+			forceOverwrite: true,
+			errorContains:  "account 'work' needs an smtp_server because the 'tls' preset doesn't have one",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       []string{validAccounts[0], validAccounts[0]},
+			forceOverwrite: true,
+			errorContains:  "there is more than one account named 'work'",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		//the monitor specs
+		{
+			accounts:       validAccounts,
+			monitors:       []string{"from=work,to=home,period=-1h"},
+			forceOverwrite: true,
+			errorContains:  "invalid monitor 'from=work,to=home,period=-1h': period '-1h' is not a positive duration",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			accounts:       validAccounts,
+			monitors:       []string{"from=work,to=home"},
+			forceOverwrite: true,
+			errorContains:  "the monitor from 'work' to 'home' needs an account to notify that it doesn't test",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		{
+			//an account that only receives can't send the notification
+			accounts: []string{validAccounts[0], validAccounts[1],
+				"name=rx,use=receive,address=rx@example.org,password=secret,imap_server=mail.example.org"},
+			monitors:       []string{"from=work,to=home"},
+			forceOverwrite: true,
+			errorContains:  "the monitor from 'work' to 'home' needs an account to notify that it doesn't test and that sends email",
+			exitCode:       EXIT_CODE_USAGE,
+		},
+		//the config is validated before it is written
+		{
+			accounts:       validAccounts,
+			monitors:       []string{"from=work,to=nobody,notify=home"},
+			forceOverwrite: true,
+			errorContains:  "Refusing to write the config because it has validation errors",
+			exitCode:       EXIT_CODE_INVALID_CONFIG,
+		},
+	}
+
+	app := CreateApp()
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		var sb strings.Builder
+		args := makeInitConfigArgs(tempFile.Name(), testCase.accounts, testCase.monitors)
+		args.ForceOverwrite = util.Ptr(testCase.forceOverwrite)
+		args.OutputMode = util.Ptr(testCase.outputMode)
+		args.PublicKey = util.Ptr(testCase.publicKey)
+		err := app.InitConfig(&args, strings.NewReader(testCase.input), &sb)
+		assert.ErrorContains(t, err, testCase.errorContains)
+		if testCase.errorNotContains != "" {
+			assert.NotContains(t, err.Error(), testCase.errorNotContains)
+		}
+		assert.Equal(t, testCase.exitCode, exitCodeForError(err))
+	}
+}
+
+func TestInitPrompterPassword(t *testing.T) {
+
+	var sb strings.Builder
+
+	//the passwords typed in a terminal are read without echo instead of from the input
+	passwords := []string{"", "secret"}
+	prompter := initPrompter{
+		input:  bufio.NewReader(strings.NewReader("not the password\n")),
+		output: &sb,
+		readPassword: func() (string, error) {
+			password := passwords[0]
+			passwords = passwords[1:]
+			return password, nil
+		},
+	}
+
+	password, err := prompter.askPassword("Password")
+	require.Nil(t, err)
+	assert.Equal(t, "secret", password)
+	assert.Equal(t, "Password: \n  an answer is required\nPassword: \n", sb.String())
+
+	prompter.readPassword = func() (string, error) {
+		return "", errors.New("not a terminal")
+	}
+	_, err = prompter.askPassword("Password")
+	assert.ErrorContains(t, err, "could not read the answer: not a terminal")
+}
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"time"
 )
 
 type SealConfigArgs struct {
 	Input          *string
+	ConfigDir      *string //if set, the fragments in the directory are sealed in place instead of Input
+	Format         *string //yaml or json; if empty, the format is taken from the input file extension
+	OutputMode     *string //text or json; if empty, text
 	Output         *string
 	ForceOverwrite *bool
 	PublicKey      *string
@@ -19,6 +23,9 @@ func (c SealConfigArgs) HumanReadable() string {
 
 	fmt.Fprintln(&sb, "Sealing config")
 	fmt.Fprintln(&sb, "  Input: ", *c.Input)
+	fmt.Fprintln(&sb, "  ConfigDir: ", *c.ConfigDir)
+	fmt.Fprintln(&sb, "  Format: ", *c.Format)
+	fmt.Fprintln(&sb, "  OutputMode: ", *c.OutputMode)
 	fmt.Fprintln(&sb, "  PublicKey: ", *c.PublicKey)
 	fmt.Fprintln(&sb, "  Output: ", *c.Output)
 	fmt.Fprintln(&sb, "  ForceOverwrite: ", *c.ForceOverwrite)
@@ -28,6 +35,9 @@ func (c SealConfigArgs) HumanReadable() string {
 
 type UnsealConfigArgs struct {
 	Input          *string
+	ConfigDir      *string //if set, the fragments in the directory are unsealed in place instead of Input
+	Format         *string //yaml or json; if empty, the format is taken from the input file extension
+	OutputMode     *string //text or json; if empty, text
 	Output         *string
 	ForceOverwrite *bool
 	PrivateKey     *string
@@ -40,6 +50,9 @@ func (c UnsealConfigArgs) HumanReadable() string {
 
 	fmt.Fprintln(&sb, "Unsealing config")
 	fmt.Fprintln(&sb, "  Input: ", *c.Input)
+	fmt.Fprintln(&sb, "  ConfigDir: ", *c.ConfigDir)
+	fmt.Fprintln(&sb, "  Format: ", *c.Format)
+	fmt.Fprintln(&sb, "  OutputMode: ", *c.OutputMode)
 	fmt.Fprintln(&sb, "  PrivateKey: ", *c.PrivateKey)
 	fmt.Fprintf(&sb, "  Passphrase: <redacted value of length %d>\n", len(*c.Passphrase))
 	fmt.Fprintln(&sb, "  Output: ", *c.Output)
@@ -50,8 +63,12 @@ func (c UnsealConfigArgs) HumanReadable() string {
 
 type CheckConfigArgs struct {
 	Input      *string
+	ConfigDir  *string //if set, the fragments in the directory are merged and checked instead of Input
+	Format     *string //yaml or json; if empty, the format is taken from the input file extension
+	OutputMode *string //text or json; if empty, text
 	PrivateKey *string
 	Passphrase *string
+	Strict     *bool //if set, validation warnings fail the check
 }
 
 func (c CheckConfigArgs) HumanReadable() string {
@@ -60,8 +77,132 @@ func (c CheckConfigArgs) HumanReadable() string {
 
 	fmt.Fprintln(&sb, "Checking config with:")
 	fmt.Fprintln(&sb, "  Input: ", *c.Input)
+	fmt.Fprintln(&sb, "  ConfigDir: ", *c.ConfigDir)
+	fmt.Fprintln(&sb, "  Format: ", *c.Format)
+	fmt.Fprintln(&sb, "  OutputMode: ", *c.OutputMode)
 	fmt.Fprintln(&sb, "  PrivateKey: ", *c.PrivateKey)
 	fmt.Fprintf(&sb, "  Passphrase: <redacted value of length %d>\n", len(*c.Passphrase))
+	fmt.Fprintln(&sb, "  Strict: ", *c.Strict)
+
+	return sb.String()
+}
+
+type ShowConfigArgs struct {
+	Input      *string
+	ConfigDir  *string //if set, the fragments in the directory are merged and shown instead of Input
+	Format     *string //yaml or json; if empty, the format is taken from the input file extension
+	OutputMode *string //text or json; the config is written as YAML for text
+	Resolved   *bool   //if set, the accounts are shown after inheriting from their templates
+}
+
+type MigrateConfigArgs struct {
+	Input          *string
+	Format         *string //yaml or json; if empty, the format is taken from the input file extension
+	OutputMode     *string //text or json; if empty, text
+	Output         *string
+	ForceOverwrite *bool
+}
+
+func (c MigrateConfigArgs) HumanReadable() string {
+
+	var sb strings.Builder
+
+	fmt.Fprintln(&sb, "Migrating config")
+	fmt.Fprintln(&sb, "  Input: ", *c.Input)
+	fmt.Fprintln(&sb, "  Format: ", *c.Format)
+	fmt.Fprintln(&sb, "  OutputMode: ", *c.OutputMode)
+	fmt.Fprintln(&sb, "  Output: ", *c.Output)
+	fmt.Fprintln(&sb, "  ForceOverwrite: ", *c.ForceOverwrite)
+
+	return sb.String()
+}
+
+type FmtConfigArgs struct {
+	Input          *string
+	Format         *string //yaml or json; if empty, the format is taken from the input file extension
+	OutputMode     *string //text or json; if empty, text
+	Output         *string
+	ForceOverwrite *bool
+}
+
+func (c FmtConfigArgs) HumanReadable() string {
+
+	var sb strings.Builder
+
+	fmt.Fprintln(&sb, "Formatting config")
+	fmt.Fprintln(&sb, "  Input: ", *c.Input)
+	fmt.Fprintln(&sb, "  Format: ", *c.Format)
+	fmt.Fprintln(&sb, "  OutputMode: ", *c.OutputMode)
+	fmt.Fprintln(&sb, "  Output: ", *c.Output)
+	fmt.Fprintln(&sb, "  ForceOverwrite: ", *c.ForceOverwrite)
+
+	return sb.String()
+}
+
+type ConfigSchemaArgs struct {
+	Output         *string //if empty, the schema is written to the output stream
+	ForceOverwrite *bool
+}
+
+type ConfigDocsArgs struct {
+	Output         *string //if empty, the reference is written to the output stream
+	ForceOverwrite *bool
+}
+
+type InitConfigArgs struct {
+	Output         *string
+	ForceOverwrite *bool
+	OutputMode     *string //text or json; json needs the accounts and monitors as flags
+	PublicKey      *string //if set, the passwords are sealed with the key
+	//Accounts and Monitors are specs like "name=work,preset=gmail,address=...".  If both are
+	//empty, they are prompted for instead.
+	Accounts *[]string
+	Monitors *[]string
+}
+
+func (c InitConfigArgs) HumanReadable() string {
+
+	var sb strings.Builder
+
+	fmt.Fprintln(&sb, "Creating config")
+	fmt.Fprintln(&sb, "  OutputMode: ", *c.OutputMode)
+	fmt.Fprintln(&sb, "  PublicKey: ", *c.PublicKey)
+	//the account specs have passwords, so only the counts are shown
+	fmt.Fprintln(&sb, "  Accounts: ", len(*c.Accounts))
+	fmt.Fprintln(&sb, "  Monitors: ", len(*c.Monitors))
+	fmt.Fprintln(&sb, "  Output: ", *c.Output)
+	fmt.Fprintln(&sb, "  ForceOverwrite: ", *c.ForceOverwrite)
+
+	return sb.String()
+}
+
+// isInteractive returns true if the accounts and monitors are prompted for
+func (c InitConfigArgs) isInteractive() bool {
+	return len(*c.Accounts) == 0 && len(*c.Monitors) == 0
+}
+
+type DiffConfigArgs struct {
+	Input      *string //the old config
+	Compared   *string //the new config, compared with Input
+	Format     *string //yaml or json; if empty, the format is taken from each file extension
+	OutputMode *string //text or json; if empty, text
+	PrivateKey *string //if set, sealed values are compared by their plaintext
+	Passphrase *string
+	Resolved   *bool //if set, the accounts are compared after inheriting from their templates
+}
+
+func (c DiffConfigArgs) HumanReadable() string {
+
+	var sb strings.Builder
+
+	fmt.Fprintln(&sb, "Comparing configs")
+	fmt.Fprintln(&sb, "  Input: ", *c.Input)
+	fmt.Fprintln(&sb, "  Compared: ", *c.Compared)
+	fmt.Fprintln(&sb, "  Format: ", *c.Format)
+	fmt.Fprintln(&sb, "  OutputMode: ", *c.OutputMode)
+	fmt.Fprintln(&sb, "  PrivateKey: ", *c.PrivateKey)
+	fmt.Fprintf(&sb, "  Passphrase: <redacted value of length %d>\n", len(*c.Passphrase))
+	fmt.Fprintln(&sb, "  Resolved: ", *c.Resolved)
 
 	return sb.String()
 }
@@ -70,14 +211,68 @@ type VaranusApp interface {
 	SealConfig(args *SealConfigArgs, outputStream io.Writer) error
 	UnsealConfig(args *UnsealConfigArgs, outputStream io.Writer) error
 	CheckConfig(args *CheckConfigArgs, outputStream io.Writer) error
+	ShowConfig(args *ShowConfigArgs, outputStream io.Writer) error
+	MigrateConfig(args *MigrateConfigArgs, outputStream io.Writer) error
+	ConfigSchema(args *ConfigSchemaArgs, outputStream io.Writer) error
+	ConfigDocs(args *ConfigDocsArgs, outputStream io.Writer) error
+	//InitConfig reads the answers to its prompts from inputStream
+	InitConfig(args *InitConfigArgs, inputStream io.Reader, outputStream io.Writer) error
+	DiffConfig(args *DiffConfigArgs, outputStream io.Writer) error
+	FmtConfig(args *FmtConfigArgs, outputStream io.Writer) error
+	//Run runs the monitors until the process is interrupted, reloading the config on SIGHUP
+	Run(args *RunArgs, outputStream io.Writer) error
+}
+
+type RunArgs struct {
+	Input         *string
+	ConfigDir     *string //if set, the fragments in the directory are merged and run instead of Input
+	Format        *string //yaml or json; if empty, the format is taken from the input file extension
+	PrivateKey    *string
+	Passphrase    *string
+	WatchInterval *time.Duration //if not zero, the config files are checked for changes this often
+	ReadDelay     *time.Duration //how long a probe waits for the test email before reading it
+}
+
+func (c RunArgs) HumanReadable() string {
+
+	var sb strings.Builder
+
+	fmt.Fprintln(&sb, "Running monitors with:")
+	fmt.Fprintln(&sb, "  Input: ", *c.Input)
+	fmt.Fprintln(&sb, "  ConfigDir: ", *c.ConfigDir)
+	fmt.Fprintln(&sb, "  Format: ", *c.Format)
+	fmt.Fprintln(&sb, "  PrivateKey: ", *c.PrivateKey)
+	fmt.Fprintf(&sb, "  Passphrase: <redacted value of length %d>\n", len(*c.Passphrase))
+	fmt.Fprintln(&sb, "  WatchInterval: ", *c.WatchInterval)
+	fmt.Fprintln(&sb, "  ReadDelay: ", *c.ReadDelay)
+
+	return sb.String()
 }
 
 type ApplicationError struct {
 	theError error
+	exitCode int
 }
 
 func (ae ApplicationError) Error() string {
 	return ae.theError.Error()
 }
 
+// ExitCode returns the process exit code for the error, one of the EXIT_CODE_* values
+func (ae ApplicationError) ExitCode() int {
+	return ae.exitCode
+}
+
 func (ae *ApplicationError) Unwrap() error { return ae.theError }
+
+// exitCodeForError returns the process exit code for an error returned by a VaranusApp command
+func exitCodeForError(err error) int {
+	if err == nil {
+		return EXIT_CODE_OK
+	}
+	appErr, ok := err.(ApplicationError)
+	if !ok {
+		return EXIT_CODE_FAILED
+	}
+	return appErr.ExitCode()
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -14,6 +15,12 @@ func TestApplicationError(t *testing.T) {
 	assert.True(t, ok)
 
 	assert.Equal(t, "test error some string 55", appError.Unwrap().Error())
+	assert.Equal(t, EXIT_CODE_FAILED, appError.ExitCode())
+
+	err = newApplicationErrorWithExitCode(EXIT_CODE_INVALID_CONFIG, "invalid")
+	assert.Equal(t, EXIT_CODE_INVALID_CONFIG, exitCodeForError(err))
+	assert.Equal(t, EXIT_CODE_FAILED, exitCodeForError(fmt.Errorf("not an application error")))
+	assert.Equal(t, EXIT_CODE_OK, exitCodeForError(nil))
 }
 
 func TestVaranusAppInterface(t *testing.T) {
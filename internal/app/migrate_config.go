@@ -0,0 +1,54 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"varanus/internal/config"
+)
+
+func (va varanusAppImpl) MigrateConfig(args *MigrateConfigArgs, outputStream io.Writer) error {
+	report := newCommandReport("migrate", *args.Input)
+	report.Output = *args.Output
+	return runWithReport(*args.OutputMode, outputStream, report, func(textStream io.Writer) error {
+		return va.migrateConfig(args, textStream, report)
+	})
+}
+
+// migrateConfig upgrades the input config to the current version and writes it to the output.
+// Only the input file is migrated; the files it includes are migrated on their own.  The config
+// isn't validated, so that a config can be upgraded before it is fixed.
+func (va varanusAppImpl) migrateConfig(args *MigrateConfigArgs, outputStream io.Writer, report *CommandReport) error {
+
+	fmt.Fprint(outputStream, args.HumanReadable())
+
+	//the document is upgraded when it is read, keeping its comments, layout and sealed values
+	format, err := config.ResolveConfigFormat(*args.Format, *args.Input)
+	if err != nil {
+		return newApplicationError("Could not load config from '%s': %w", *args.Input, err)
+	}
+	document, err := config.ReadConfigDocumentFromFile(*args.Input, format)
+	if err != nil {
+		return newApplicationError("Could not load config from '%s': %w", *args.Input, err)
+	}
+
+	report.Migrations = []string{}
+	if len(document.Migrations) == 0 {
+		fmt.Fprintf(outputStream, "The config is already version %d, so nothing was changed.\n", config.CurrentConfigVersion())
+	} else {
+		fmt.Fprintf(outputStream, "The config was upgraded from version %d to %d:\n",
+			document.Migrations[0].FromVersion, config.CurrentConfigVersion())
+		for _, migration := range document.Migrations {
+			fmt.Fprintf(outputStream, "  %s\n", migration)
+			report.Migrations = append(report.Migrations, migration.String())
+		}
+	}
+
+	err = document.WriteToFile(*args.Output, *args.ForceOverwrite)
+	if err != nil {
+		return newApplicationError("Error writing output config to '%s': %w", *args.Output, err)
+	}
+
+	fmt.Fprintf(outputStream, "Migrate operation succeeded.  Results written to '%s'\n", *args.Output)
+
+	return nil
+}
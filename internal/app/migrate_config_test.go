@@ -0,0 +1,191 @@
+package app
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"varanus/internal/config"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestMigrateConfig(t *testing.T) {
+
+	var sb strings.Builder
+
+	tempFile := util.CreateTempFileAndDir("test_output", "migrate_config_test.*.yaml")
+	tempFile.Close()
+	args := MigrateConfigArgs{
+		Input:          util.Ptr("tests/example.yaml"),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		Output:         util.Ptr(tempFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	//a current config is written unchanged
+	err := app.MigrateConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "The config is already version 1, so nothing was changed.")
+	assert.Contains(t, sb.String(), "Migrate operation succeeded.  Results written to '"+tempFile.Name()+"'")
+
+	input, err := os.ReadFile("tests/example.yaml")
+	require.Nil(t, err)
+	output, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, string(input), string(output))
+
+	//the JSON report lists the migrations, which are none
+	sb.Reset()
+	args.OutputMode = util.Ptr(OUTPUT_MODE_JSON)
+	err = app.MigrateConfig(&args, &sb)
+	require.Nil(t, err)
+	report := CommandReport{}
+	err = json.Unmarshal([]byte(sb.String()), &report)
+	require.Nil(t, err)
+	assert.Equal(t, "migrate", report.Command)
+	assert.Equal(t, REPORT_STATUS_OK, report.Status)
+	assert.Equal(t, tempFile.Name(), report.Output)
+}
+
+// registerTestMigration registers a migration that renames mail.old_accounts to mail.accounts,
+// for the duration of the test
+func registerTestMigration(t *testing.T) {
+	unregister := config.RegisterConfigMigration(config.ConfigMigration{
+		Description: "mail.old_accounts becomes mail.accounts",
+		Migrate: func(root *yaml.Node) error {
+			for keyIndex := 0; keyIndex+1 < len(root.Content); keyIndex += 2 {
+				mail := root.Content[keyIndex+1]
+				if root.Content[keyIndex].Value != "mail" || mail.Kind != yaml.MappingNode {
+					continue
+				}
+				for mailIndex := 0; mailIndex+1 < len(mail.Content); mailIndex += 2 {
+					if mail.Content[mailIndex].Value == "old_accounts" {
+						mail.Content[mailIndex].Value = "accounts"
+					}
+				}
+			}
+			return nil
+		},
+	})
+	t.Cleanup(unregister)
+}
+
+const MIGRATE_TEST_V1_YAML = `# an old config
+mail:
+  old_accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        password: a secret # keep me
+`
+
+func TestMigrateConfigWithMigration(t *testing.T) {
+	registerTestMigration(t)
+	require.Equal(t, 2, config.CurrentConfigVersion())
+
+	inputFile := util.CreateTempFileAndDir("test_output", "migrate_config_test.*.yaml")
+	_, err := inputFile.WriteString(MIGRATE_TEST_V1_YAML)
+	require.Nil(t, err)
+	inputFile.Close()
+	tempFile := util.CreateTempFileAndDir("test_output", "migrate_config_test.*.yaml")
+	tempFile.Close()
+
+	args := MigrateConfigArgs{
+		Input:          util.Ptr(inputFile.Name()),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(OUTPUT_MODE_JSON),
+		Output:         util.Ptr(tempFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	var sb strings.Builder
+	err = app.MigrateConfig(&args, &sb)
+	require.Nil(t, err)
+	report := CommandReport{}
+	err = json.Unmarshal([]byte(sb.String()), &report)
+	require.Nil(t, err)
+	assert.Equal(t, REPORT_STATUS_OK, report.Status)
+	assert.Equal(t, []string{"version 1 to 2: mail.old_accounts becomes mail.accounts"}, report.Migrations)
+
+	//the upgraded document keeps its comments and layout
+	output, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	expected := "version: 2\n" + strings.Replace(MIGRATE_TEST_V1_YAML, "old_accounts:", "accounts:", 1)
+	assert.Equal(t, expected, string(output))
+
+	//and migrating it again changes nothing
+	sb.Reset()
+	args.Input = util.Ptr(tempFile.Name())
+	args.OutputMode = util.Ptr("")
+	err = app.MigrateConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "The config is already version 2, so nothing was changed.")
+	again, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, string(output), string(again))
+}
+
+func TestMigrateConfigFailures(t *testing.T) {
+
+	newerFile := util.CreateTempFileAndDir("test_output", "migrate_config_test.*.yaml")
+	_, err := newerFile.WriteString("version: 99\nmail:\n  accounts: []\n")
+	require.Nil(t, err)
+	newerFile.Close()
+
+	tempFile := util.CreateTempFileAndDir("test_output", "migrate_config_test.*.yaml")
+	tempFile.Close()
+
+	type testCase struct {
+		input          string
+		forceOverwrite bool
+		errorContains  string
+	}
+
+	testCases := []testCase{
+		{
+			input:          "tests/invalid.yaml",
+			forceOverwrite: true,
+			errorContains:  "Could not load config from 'tests/invalid.yaml'",
+		},
+		{
+			input:          newerFile.Name(),
+			forceOverwrite: true,
+			errorContains:  "config version 99 is newer than the latest version this varanus supports, 1",
+		},
+		{
+			input:          "tests/example.yaml",
+			forceOverwrite: false,
+			errorContains:  "Error writing output config to '" + tempFile.Name() + "'",
+		},
+	}
+
+	app := CreateApp()
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		var sb strings.Builder
+		args := MigrateConfigArgs{
+			Input:          util.Ptr(testCase.input),
+			Format:         util.Ptr(""),
+			OutputMode:     util.Ptr(""),
+			Output:         util.Ptr(tempFile.Name()),
+			ForceOverwrite: util.Ptr(testCase.forceOverwrite),
+		}
+		err := app.MigrateConfig(&args, &sb)
+		assert.ErrorContains(t, err, testCase.errorContains)
+		assert.Equal(t, EXIT_CODE_FAILED, exitCodeForError(err))
+	}
+}
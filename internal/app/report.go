@@ -0,0 +1,223 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"varanus/internal/config"
+	"varanus/internal/secrets"
+	"varanus/internal/validation"
+)
+
+// OUTPUT_MODE_TEXT writes human-readable progress and results to the output stream
+const OUTPUT_MODE_TEXT = "text"
+
+// OUTPUT_MODE_JSON writes a single CommandReport as JSON to the output stream and nothing else
+const OUTPUT_MODE_JSON = "json"
+
+// SupportedOutputModes lists the accepted values of the --output flag
+var SupportedOutputModes = []string{OUTPUT_MODE_TEXT, OUTPUT_MODE_JSON}
+
+// Exit codes returned by the varanus command.  ApplicationError.ExitCode gives the code for an
+// error returned from a VaranusApp command.
+//
+//	0  the command succeeded
+//	1  the command line was invalid, e.g. a missing or unknown flag
+//	2  the command failed, e.g. the config or a key could not be read or the output written
+//	3  the config has validation errors
+//	4  sealed values could not be sealed, unsealed, or verified
+const (
+	EXIT_CODE_OK             = 0
+	EXIT_CODE_USAGE          = 1
+	EXIT_CODE_FAILED         = 2
+	EXIT_CODE_INVALID_CONFIG = 3
+	EXIT_CODE_SEAL_ERRORS    = 4
+)
+
+// REPORT_STATUS_* are the values of CommandReport.Status, one for each exit code
+const (
+	REPORT_STATUS_OK             = "ok"
+	REPORT_STATUS_FAILED         = "failed"
+	REPORT_STATUS_INVALID_CONFIG = "invalid_config"
+	REPORT_STATUS_SEAL_ERRORS    = "seal_errors"
+)
+
+// CommandReport is the machine-readable result of a config command, written when the output mode
+// is OUTPUT_MODE_JSON.
+type CommandReport struct {
+	Command  string `json:"command"`
+	Status   string `json:"status"`
+	ExitCode int    `json:"exit_code"`
+	//Error is the message of the error returned by the command, if any
+	Error  string `json:"error,omitempty"`
+	Input  string `json:"input"`
+	Output string `json:"output,omitempty"`
+	//Compared is the second config of diff, which Input is compared with
+	Compared string `json:"compared,omitempty"`
+	//ValidationErrors has the errors, warnings and info found by validation.  It is empty if
+	//validation did not run.
+	ValidationErrors []ValidationErrorReport `json:"validation_errors"`
+	//Seals is nil if the command did not get as far as counting the sealed values
+	Seals *SealCountsReport `json:"seals,omitempty"`
+	//Migrations are the upgrades applied by migrate, like "version 1 to 2: ..."
+	Migrations []string `json:"migrations,omitempty"`
+	//Differences are the changes found by diff; nil for the other commands
+	Differences []ConfigDifferenceReport `json:"differences,omitempty"`
+	//Normalized are the paths of the values that fmt changed or added
+	Normalized []string `json:"normalized,omitempty"`
+}
+
+// ConfigDifferenceReport is a single change between the configs compared by diff.  The values of
+// secrets are never included.
+type ConfigDifferenceReport struct {
+	Path     string `json:"path"`
+	Kind     string `json:"kind"`
+	OldValue string `json:"old_value,omitempty"`
+	NewValue string `json:"new_value,omitempty"`
+}
+
+// ValidationErrorReport is a single validation error, warning or info in a CommandReport
+type ValidationErrorReport struct {
+	Path     string              `json:"path"`
+	Message  string              `json:"message"`
+	Severity validation.Severity `json:"severity"`
+	File     string              `json:"file,omitempty"`
+	Line     int                 `json:"line,omitempty"`
+	Column   int                 `json:"column,omitempty"`
+}
+
+// SealCountsReport counts the sealed values of the config after a command
+type SealCountsReport struct {
+	Total    int `json:"total"`
+	Sealed   int `json:"sealed"`
+	Unsealed int `json:"unsealed"`
+	//Changed is the number of values sealed or unsealed by the command, always 0 for check
+	Changed int      `json:"changed"`
+	Errors  []string `json:"errors"`
+}
+
+func newCommandReport(command string, input string) *CommandReport {
+	return &CommandReport{
+		Command:          command,
+		Input:            input,
+		ValidationErrors: []ValidationErrorReport{},
+	}
+}
+
+func (r *CommandReport) setValidationResult(result validation.ValidationResult) {
+	r.ValidationErrors = []ValidationErrorReport{}
+	for _, validationError := range result.GetIssueList() {
+		errorReport := ValidationErrorReport{
+			Path:     validationError.Path,
+			Message:  validationError.Error,
+			Severity: validationError.Severity,
+		}
+		if validationError.Location != nil {
+			errorReport.File = validationError.Location.Filename
+			errorReport.Line = validationError.Location.Line
+			errorReport.Column = validationError.Location.Column
+		}
+		r.ValidationErrors = append(r.ValidationErrors, errorReport)
+	}
+}
+
+func (r *CommandReport) setSealCheckResult(result secrets.SealCheckResult) {
+	r.Seals = &SealCountsReport{
+		Total:    result.SealedCount + result.UnsealedCount,
+		Sealed:   result.SealedCount,
+		Unsealed: result.UnsealedCount,
+		Errors:   errorStrings(result.UnsealErrors),
+	}
+}
+
+func (r *CommandReport) setSealResult(result secrets.SealResult) {
+	r.Seals = &SealCountsReport{
+		Total:    result.TotalSealedCount + result.TotalUnsealedCount,
+		Sealed:   result.TotalSealedCount,
+		Unsealed: result.TotalUnsealedCount,
+		Changed:  result.NumberSealed,
+		Errors:   errorStrings(result.SealErrors),
+	}
+}
+
+func (r *CommandReport) setUnsealResult(result secrets.UnsealResult) {
+	r.Seals = &SealCountsReport{
+		Total:    result.TotalSealedCount + result.TotalUnsealedCount,
+		Sealed:   result.TotalSealedCount,
+		Unsealed: result.TotalUnsealedCount,
+		Changed:  result.NumberUnsealed,
+		Errors:   errorStrings(result.UnsealErrors),
+	}
+}
+
+func (r *CommandReport) setConfigChanges(changes []config.ConfigChange) {
+	r.Differences = []ConfigDifferenceReport{}
+	for _, change := range changes {
+		r.Differences = append(r.Differences, ConfigDifferenceReport{
+			Path:     change.Path,
+			Kind:     string(change.Kind),
+			OldValue: change.OldValue,
+			NewValue: change.NewValue,
+		})
+	}
+}
+
+// finish sets the status and exit code from the error returned by the command
+func (r *CommandReport) finish(err error) {
+	r.ExitCode = exitCodeForError(err)
+	switch r.ExitCode {
+	case EXIT_CODE_OK:
+		r.Status = REPORT_STATUS_OK
+	case EXIT_CODE_INVALID_CONFIG:
+		r.Status = REPORT_STATUS_INVALID_CONFIG
+	case EXIT_CODE_SEAL_ERRORS:
+		r.Status = REPORT_STATUS_SEAL_ERRORS
+	default:
+		r.Status = REPORT_STATUS_FAILED
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+func errorStrings(errs []error) []string {
+	result := make([]string, 0, len(errs))
+	for _, err := range errs {
+		result = append(result, err.Error())
+	}
+	return result
+}
+
+// runWithReport runs a command, giving it the stream for its human-readable output.  In
+// OUTPUT_MODE_JSON, the human-readable output is discarded and the report is written to the
+// output stream instead once the command is done.
+func runWithReport(
+	outputMode string,
+	outputStream io.Writer,
+	report *CommandReport,
+	command func(textStream io.Writer) error,
+) error {
+	if outputMode == "" {
+		outputMode = OUTPUT_MODE_TEXT
+	}
+	if !slices.Contains(SupportedOutputModes, outputMode) {
+		return newApplicationErrorWithExitCode(EXIT_CODE_USAGE,
+			"unsupported output mode '%s'; expected one of %s", outputMode, strings.Join(SupportedOutputModes, ", "))
+	}
+
+	if outputMode == OUTPUT_MODE_TEXT {
+		return command(outputStream)
+	}
+
+	err := command(io.Discard)
+	report.finish(err)
+	reportJson, marshalErr := json.MarshalIndent(report, "", "  ")
+	if marshalErr != nil {
+		//unreachable because the report only has plain fields
+		return newApplicationError("Could not write the report: %w", marshalErr)
+	}
+	fmt.Fprintln(outputStream, string(reportJson))
+	return err
+}
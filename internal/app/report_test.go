@@ -0,0 +1,183 @@
+package app
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"varanus/internal/util"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConfigJsonReport(t *testing.T) {
+
+	type TestCase struct {
+		input            string
+		privateKey       string
+		status           string
+		exitCode         int
+		validationErrors []ValidationErrorReport //only the errors
+		warningCount     int
+		seals            *SealCountsReport
+	}
+
+	testCases := []TestCase{
+		{
+			input:            "tests/example.yaml",
+			privateKey:       "tests/key-4096.pem",
+			status:           REPORT_STATUS_OK,
+			exitCode:         EXIT_CODE_OK,
+			validationErrors: []ValidationErrorReport{},
+			//3 plaintext passwords, 4 servers without TLS, and a monitor notifying through the server
+			//it tests
+			warningCount: 8,
+			seals:        &SealCountsReport{Total: 4, Sealed: 1, Unsealed: 3, Errors: []string{}},
+		},
+		{
+			input:    "tests/example-unvalidatable.yaml",
+			status:   REPORT_STATUS_INVALID_CONFIG,
+			exitCode: EXIT_CODE_INVALID_CONFIG,
+			validationErrors: []ValidationErrorReport{
+				{"mail.accounts[0]", "account names must not be empty or whitespace", validation.SEVERITY_ERROR,
+					"tests/example-unvalidatable.yaml", 3, 7},
+				{"mail.send_limits[0]", "send_limits account named 'test1' does not exist", validation.SEVERITY_ERROR,
+					"tests/example-unvalidatable.yaml", 18, 7},
+			},
+			//2 servers without TLS and the unused account
+			warningCount: 3,
+			seals:        &SealCountsReport{Total: 2, Sealed: 2, Unsealed: 0, Errors: []string{}},
+		},
+		{
+			input:            "tests/does-not-exist.yaml",
+			status:           REPORT_STATUS_FAILED,
+			exitCode:         EXIT_CODE_FAILED,
+			validationErrors: []ValidationErrorReport{},
+		},
+	}
+
+	for index, testCase := range testCases {
+		var sb strings.Builder
+
+		args := CheckConfigArgs{
+			Input:      util.Ptr(testCase.input),
+			ConfigDir:  util.Ptr(""),
+			Format:     util.Ptr(""),
+			OutputMode: util.Ptr(OUTPUT_MODE_JSON),
+			PrivateKey: util.Ptr(testCase.privateKey),
+			Passphrase: util.Ptr(""),
+			Strict:     util.Ptr(false),
+		}
+
+		app := CreateApp()
+		err := app.CheckConfig(&args, &sb)
+		assert.Equal(t, testCase.exitCode, exitCodeForError(err), "for test %d", index)
+
+		//the output is only the report
+		report := CommandReport{}
+		require.Nil(t, json.Unmarshal([]byte(sb.String()), &report), "for test %d: %s", index, sb.String())
+		assert.Equal(t, "check", report.Command, "for test %d", index)
+		assert.Equal(t, testCase.input, report.Input, "for test %d", index)
+		assert.Equal(t, testCase.status, report.Status, "for test %d", index)
+		assert.Equal(t, testCase.exitCode, report.ExitCode, "for test %d", index)
+		validationErrors := []ValidationErrorReport{}
+		warningCount := 0
+		for _, validationError := range report.ValidationErrors {
+			switch validationError.Severity {
+			case validation.SEVERITY_ERROR:
+				validationErrors = append(validationErrors, validationError)
+			case validation.SEVERITY_WARNING:
+				warningCount++
+			}
+		}
+		assert.Equal(t, testCase.validationErrors, validationErrors, "for test %d", index)
+		assert.Equal(t, testCase.warningCount, warningCount, "for test %d", index)
+		assert.Equal(t, testCase.seals, report.Seals, "for test %d", index)
+		if err != nil {
+			assert.Equal(t, err.Error(), report.Error, "for test %d", index)
+		} else {
+			assert.Empty(t, report.Error, "for test %d", index)
+		}
+	}
+}
+
+func TestSealConfigJsonReport(t *testing.T) {
+
+	var sb strings.Builder
+
+	outputFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.yaml")
+	outputFile.Close()
+	args := SealConfigArgs{
+		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(OUTPUT_MODE_JSON),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+		Output:         util.Ptr(outputFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+
+	app := CreateApp()
+	err := app.SealConfig(&args, &sb)
+	require.Nil(t, err)
+
+	//the plaintext passwords that were sealed are still reported as warnings because validation
+	//runs before sealing
+	expectedReport := `{
+  "command": "seal",
+  "status": "ok",
+  "exit_code": 0,
+  "input": "tests/example.yaml",
+  "output": "` + outputFile.Name() + `",
+  "validation_errors": [
+    {
+      "path": "mail.accounts[0].smtp",
+      "message": "use_tls is false, so the password is sent to 'smtp.example.com' without encryption",
+      "severity": "warning",
+      "file": "tests/example.yaml",
+      "line": 11,
+      "column": 7
+    },
+`
+	assert.True(t, strings.HasPrefix(sb.String(), expectedReport), sb.String())
+	expectedSeals := `  "seals": {
+    "total": 4,
+    "sealed": 4,
+    "unsealed": 0,
+    "changed": 3,
+    "errors": []
+  }
+}
+`
+	assert.True(t, strings.HasSuffix(sb.String(), expectedSeals), sb.String())
+
+	//unseal it again
+	sb.Reset()
+	unsealArgs := UnsealConfigArgs{
+		Input:          util.Ptr(outputFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(OUTPUT_MODE_JSON),
+		PrivateKey:     util.Ptr("tests/key-4096-bad.pem"),
+		Passphrase:     util.Ptr(""),
+		Output:         util.Ptr(outputFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+	err = app.UnsealConfig(&unsealArgs, &sb)
+	assert.Equal(t, EXIT_CODE_FAILED, exitCodeForError(err))
+	report := CommandReport{}
+	require.Nil(t, json.Unmarshal([]byte(sb.String()), &report))
+	assert.Equal(t, "unseal", report.Command)
+	assert.Equal(t, REPORT_STATUS_FAILED, report.Status)
+	assert.Contains(t, report.Error, "Could not load private key from 'tests/key-4096-bad.pem'")
+	assert.Nil(t, report.Seals)
+
+	//an unsupported output mode is an error before anything is written
+	sb.Reset()
+	args.OutputMode = util.Ptr("xml")
+	err = app.SealConfig(&args, &sb)
+	assert.ErrorContains(t, err, "unsupported output mode 'xml'; expected one of text, json")
+	assert.Equal(t, EXIT_CODE_USAGE, exitCodeForError(err))
+	assert.Empty(t, sb.String())
+}
@@ -0,0 +1,188 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"varanus/internal/config"
+	"varanus/internal/daemon"
+	"varanus/internal/secrets"
+	"varanus/internal/validation"
+
+	"github.com/rs/zerolog/log"
+)
+
+func (va varanusAppImpl) Run(args *RunArgs, outputStream io.Writer) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	return va.run(ctx, args, hangups, outputStream)
+}
+
+func (va varanusAppImpl) run(ctx context.Context, args *RunArgs, hangups <-chan os.Signal, outputStream io.Writer) error {
+
+	fmt.Fprint(outputStream, args.HumanReadable())
+
+	source := configSource(*args.Input, *args.ConfigDir)
+
+	var unsealer secrets.SecretUnsealer
+	if len(*args.PrivateKey) > 0 {
+		unsealer = secrets.MakeSecretUnsealer()
+		err := unsealer.LoadPrivateKeyFromFile(*args.PrivateKey, *args.Passphrase)
+		if err != nil {
+			return newApplicationError("Could not load private key from '%s': %w", *args.PrivateKey, err)
+		}
+	}
+
+	loader := &runConfigLoader{args: args, unsealer: unsealer, resolver: va.resolver}
+	monitorDaemon, err := daemon.MakeDaemon(loader.load, daemon.MakeEmailProberFactory(unsealer, *args.ReadDelay))
+	if err != nil {
+		return newApplicationErrorWithExitCode(exitCodeForError(err),
+			"Could not start the monitors with the config from '%s': %w", source, err)
+	}
+	fmt.Fprintf(outputStream, "Running %d monitors with the config from '%s'.  Send SIGHUP to reload it.\n",
+		len(monitorDaemon.MonitorNames()), source)
+	if unrunTypes := monitorDaemon.Config().UnrunMonitorTypes(); len(unrunTypes) > 0 {
+		types := slices.Clone(unrunTypes)
+		slices.Sort(types)
+		fmt.Fprintf(outputStream, "The %d monitors of registered types (%s) are checked but not run.\n",
+			len(unrunTypes), strings.Join(slices.Compact(types), ", "))
+	}
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hangups:
+				log.Info().Msg("Reloading the config because of SIGHUP")
+				monitorDaemon.Reload()
+			}
+		}
+	}()
+	if *args.WatchInterval > 0 {
+		go watchConfigFiles(ctx, *args.WatchInterval, loader.fingerprint, monitorDaemon.Reload)
+	}
+
+	err = monitorDaemon.Run(ctx)
+	if err != nil {
+		return newApplicationError("The monitors stopped with an error: %w", err)
+	}
+	fmt.Fprintln(outputStream, "The monitors were stopped.")
+	return nil
+}
+
+// runConfigLoader loads the config for the daemon, at startup and on every reload.  A config is
+// only returned if it is valid, its seals can be checked, and it compiles.
+type runConfigLoader struct {
+	args     *RunArgs
+	unsealer secrets.SecretUnsealer
+	resolver config.HostResolver
+
+	mutex     sync.Mutex
+	filenames []string //the files of the last config that was loaded, which are watched for changes
+}
+
+func (l *runConfigLoader) load() (*config.RuntimeConfig, error) {
+	configSet, err := readConfigSet(*l.args.Input, *l.args.ConfigDir, *l.args.Format)
+	if err != nil {
+		return nil, newApplicationError("the config could not be loaded: %w", err)
+	}
+
+	validationResult, err := validation.ValidateObject(configSet.Config)
+	if err != nil {
+		return nil, newApplicationError("the config could not be validated: %w", err)
+	}
+	if validationResult.GetErrorCount() > 0 {
+		validationResult.AddSourceLocations(configSet)
+		return nil, newApplicationErrorWithExitCode(EXIT_CODE_INVALID_CONFIG,
+			"the config is not valid:\n%s", validationResult.HumanReadable())
+	}
+
+	//the mail workers unseal the passwords, so the seals must be checked with the key
+	sealCheckResult := secrets.CheckSealsOnObject(configSet.Config, l.unsealer)
+	if len(sealCheckResult.UnsealErrors) > 0 {
+		return nil, newApplicationErrorWithExitCode(EXIT_CODE_SEAL_ERRORS,
+			"the sealed values could not be verified: %w", errors.Join(sealCheckResult.UnsealErrors...))
+	}
+	if l.unsealer == nil && sealCheckResult.SealedCount > 0 {
+		return nil, newApplicationErrorWithExitCode(EXIT_CODE_SEAL_ERRORS,
+			"the config has %d sealed values, but no private key was provided to unseal them", sealCheckResult.SealedCount)
+	}
+
+	runtimeConfig, err := configSet.Config.Compile(l.resolver)
+	if err != nil {
+		return nil, newApplicationErrorWithExitCode(EXIT_CODE_INVALID_CONFIG, "the config could not be compiled: %w", err)
+	}
+
+	filenames := []string{}
+	for _, document := range configSet.Documents {
+		filenames = append(filenames, document.Filename)
+	}
+	l.mutex.Lock()
+	l.filenames = filenames
+	l.mutex.Unlock()
+
+	return runtimeConfig, nil
+}
+
+// fingerprint returns a string that changes when one of the files of the config changes, or, for a
+// config directory, when a fragment is added or removed
+func (l *runConfigLoader) fingerprint() string {
+	l.mutex.Lock()
+	filenames := slices.Clone(l.filenames)
+	l.mutex.Unlock()
+
+	var sb strings.Builder
+	if *l.args.ConfigDir != "" {
+		entries, err := os.ReadDir(*l.args.ConfigDir)
+		if err != nil {
+			fmt.Fprintf(&sb, "%s: %s\n", *l.args.ConfigDir, err)
+		}
+		for _, entry := range entries {
+			fmt.Fprintln(&sb, entry.Name())
+		}
+	}
+	for _, filename := range filenames {
+		info, err := os.Stat(filename)
+		if err != nil {
+			fmt.Fprintf(&sb, "%s: %s\n", filename, err)
+			continue
+		}
+		fmt.Fprintf(&sb, "%s %d %s\n", filename, info.Size(), info.ModTime())
+	}
+	return sb.String()
+}
+
+// watchConfigFiles calls reload whenever the fingerprint changes, checking it every interval until
+// ctx is done
+func watchConfigFiles(ctx context.Context, interval time.Duration, fingerprint func() string, reload func()) {
+	last := fingerprint()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			current := fingerprint()
+			if current != last {
+				last = current
+				log.Info().Msg("Reloading the config because its files changed")
+				reload()
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"varanus/internal/secrets"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeRunArgs(input string, privateKey string) *RunArgs {
+	return &RunArgs{
+		Input:         util.Ptr(input),
+		ConfigDir:     util.Ptr(""),
+		Format:        util.Ptr(""),
+		PrivateKey:    util.Ptr(privateKey),
+		Passphrase:    util.Ptr(""),
+		WatchInterval: util.Ptr(time.Duration(0)),
+		ReadDelay:     util.Ptr(time.Minute),
+	}
+}
+
+func TestRunConfigLoader(t *testing.T) {
+
+	unsealer := secrets.MakeSecretUnsealer()
+	require.Nil(t, unsealer.LoadPrivateKeyFromFile("tests/key-4096.pem", ""))
+
+	type loaderTestCase struct {
+		input            string
+		unsealer         secrets.SecretUnsealer
+		expectedExitCode int
+		errorContains    string
+	}
+	testCases := []loaderTestCase{
+		{input: "tests/example.yaml", unsealer: unsealer},
+		{input: "tests/example-include.yaml"},
+		{input: "tests/missing.yaml", expectedExitCode: EXIT_CODE_FAILED, errorContains: "the config could not be loaded"},
+		{input: "tests/example-unvalidatable.yaml", unsealer: unsealer, expectedExitCode: EXIT_CODE_INVALID_CONFIG,
+			errorContains: "the config is not valid"},
+		{input: "tests/example-bad-seal.yaml", unsealer: unsealer, expectedExitCode: EXIT_CODE_SEAL_ERRORS,
+			errorContains: "the sealed values could not be verified"},
+		{input: "tests/example.yaml", expectedExitCode: EXIT_CODE_SEAL_ERRORS,
+			errorContains: "the config has 1 sealed values, but no private key was provided"},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+		loader := runConfigLoader{
+			args:     makeRunArgs(testCase.input, ""),
+			unsealer: testCase.unsealer,
+			resolver: fakeResolver{},
+		}
+		runtimeConfig, err := loader.load()
+		if testCase.errorContains == "" {
+			require.Nil(t, err)
+			assert.NotNil(t, runtimeConfig)
+			assert.Contains(t, loader.fingerprint(), testCase.input)
+		} else {
+			assert.ErrorContains(t, err, testCase.errorContains)
+			assert.Equal(t, testCase.expectedExitCode, exitCodeForError(err))
+			assert.Nil(t, runtimeConfig)
+		}
+	}
+}
+
+func TestRunStopsWhenCanceled(t *testing.T) {
+
+	va := varanusAppImpl{resolver: fakeResolver{}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var sb strings.Builder
+	err := va.run(ctx, makeRunArgs("tests/example.yaml", "tests/key-4096.pem"), nil, &sb)
+	assert.Nil(t, err)
+	assert.Contains(t, sb.String(), "Running 1 monitors with the config from 'tests/example.yaml'.")
+	assert.Contains(t, sb.String(), "The monitors were stopped.")
+
+	//a config that is rejected at startup is an error with its exit code
+	sb.Reset()
+	err = va.run(ctx, makeRunArgs("tests/example-unvalidatable.yaml", ""), nil, &sb)
+	assert.ErrorContains(t, err, "Could not start the monitors with the config from 'tests/example-unvalidatable.yaml'")
+	assert.Equal(t, EXIT_CODE_INVALID_CONFIG, exitCodeForError(err))
+}
+
+func TestWatchConfigFiles(t *testing.T) {
+
+	filename := filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(filename, []byte("a"), 0600))
+	loader := runConfigLoader{args: makeRunArgs(filename, ""), filenames: []string{filename}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reloads := make(chan struct{}, 10)
+	go watchConfigFiles(ctx, time.Millisecond, loader.fingerprint, func() { reloads <- struct{}{} })
+
+	//let the watcher take the first fingerprint, then change the size of the file
+	time.Sleep(10 * time.Millisecond)
+	assert.Len(t, reloads, 0)
+	require.Nil(t, os.WriteFile(filename, []byte("ab"), 0600))
+	select {
+	case <-reloads:
+	case <-time.After(time.Second):
+		assert.Fail(t, "the config was not reloaded after its file changed")
+	}
+}
@@ -1,9 +1,12 @@
 package app
 
 import (
+	"encoding/json"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"varanus/internal/config"
 	"varanus/internal/util"
 
 	"github.com/stretchr/testify/assert"
@@ -21,6 +24,9 @@ func TestSealConfigNominalNoForce(t *testing.T) {
 	require.Nil(t, err)
 	args := SealConfigArgs{
 		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PublicKey:      util.Ptr("tests/key-4096.pub"),
 		Output:         util.Ptr(tempFile.Name()),
 		ForceOverwrite: util.Ptr(false),
@@ -39,6 +45,82 @@ func TestSealConfigNominalNoForce(t *testing.T) {
 
 }
 
+func TestSealConfigDir(t *testing.T) {
+
+	var sb strings.Builder
+
+	//copy the fragments because they are sealed in place
+	err := os.MkdirAll("test_output", 0744)
+	require.Nil(t, err)
+	dir, err := os.MkdirTemp("test_output", "seal_config_dir.*")
+	require.Nil(t, err)
+	filenames := []string{"10-accounts.yaml", "20-monitors.yaml"}
+	originals := map[string]string{}
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filepath.Join("tests/conf.d", filename))
+		require.Nil(t, err)
+		originals[filename] = string(data)
+		err = os.WriteFile(filepath.Join(dir, filename), data, 0600)
+		require.Nil(t, err)
+	}
+
+	args := SealConfigArgs{
+		Input:          util.Ptr(""),
+		ConfigDir:      util.Ptr(dir),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+		Output:         util.Ptr(""),
+		ForceOverwrite: util.Ptr(false),
+	}
+
+	app := CreateApp()
+
+	err = app.SealConfig(&args, &sb)
+	require.Nil(t, err)
+	stdOutput := sb.String()
+	assert.Contains(t, stdOutput, "The seal operation sealed 3 items.")
+	assert.Contains(t, stdOutput, "Seal operation succeeded.  Results written to '"+dir+"'")
+
+	//each fragment is sealed in place and keeps its comments
+	for _, filename := range filenames {
+		document, err := config.ReadConfigDocumentFromFile(filepath.Join(dir, filename), config.CONFIG_FORMAT_YAML)
+		require.Nil(t, err)
+		for _, account := range document.Config.Mail.Accounts {
+			if account.SMTP != nil {
+				assert.True(t, account.SMTP.Password.IsValueSealed(), "for %s", filename)
+			}
+			if account.IMAP != nil {
+				assert.True(t, account.IMAP.Password.IsValueSealed(), "for %s", filename)
+			}
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		require.Nil(t, err)
+		assert.True(t, strings.HasPrefix(string(data), strings.SplitN(originals[filename], "\n", 2)[0]), "for %s", filename)
+	}
+
+	//unsealing in place gives back the original fragments
+	sb.Reset()
+	unsealArgs := UnsealConfigArgs{
+		Input:          util.Ptr(""),
+		ConfigDir:      util.Ptr(dir),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PrivateKey:     util.Ptr("tests/key-4096.pem"),
+		Passphrase:     util.Ptr(""),
+		Output:         util.Ptr(""),
+		ForceOverwrite: util.Ptr(false),
+	}
+	err = app.UnsealConfig(&unsealArgs, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "The unseal operation unsealed 3 items.")
+	for _, filename := range filenames {
+		data, err := os.ReadFile(filepath.Join(dir, filename))
+		require.Nil(t, err)
+		assert.Equal(t, originals[filename], string(data), "for %s", filename)
+	}
+}
+
 func TestSealConfigNoForceButFileExists(t *testing.T) {
 
 	var sb strings.Builder
@@ -48,6 +130,9 @@ func TestSealConfigNoForceButFileExists(t *testing.T) {
 	//don't delete the temp file
 	args := SealConfigArgs{
 		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PublicKey:      util.Ptr("tests/key-4096.pub"),
 		Output:         util.Ptr(tempFile.Name()),
 		ForceOverwrite: util.Ptr(false),
@@ -73,6 +158,9 @@ func TestSealConfigUnvalidatable(t *testing.T) {
 	tempFile.Close()
 	args := SealConfigArgs{
 		Input:          util.Ptr("tests/example-unvalidatable.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PublicKey:      util.Ptr("tests/key-4096.pub"),
 		Output:         util.Ptr(tempFile.Name()),
 		ForceOverwrite: util.Ptr(true),
@@ -91,7 +179,7 @@ func TestSealConfigUnvalidatable(t *testing.T) {
 	// t.FailNow()
 	assert.Contains(t, stdOutput, "The config was loaded successfully.")
 	assert.Contains(t, stdOutput, "2 Validation Errors")
-	assert.Contains(t, stdOutput, "send_limits account name 'test1' that does not exist")
+	assert.Contains(t, stdOutput, "send_limits account named 'test1' does not exist")
 	assert.Contains(t, stdOutput, "account names must not be empty or whitespace")
 
 }
@@ -104,6 +192,9 @@ func TestSealConfigValidationFailure(t *testing.T) {
 	tempFile.Close()
 	args := SealConfigArgs{
 		Input:          util.Ptr("tests/example-validation-failure.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PublicKey:      util.Ptr("tests/key-4096.pub"),
 		Output:         util.Ptr(tempFile.Name()),
 		ForceOverwrite: util.Ptr(true),
@@ -136,6 +227,9 @@ func TestSealConfigSealError(t *testing.T) {
 	tempFile.Close()
 	args := SealConfigArgs{
 		Input:          util.Ptr("tests/example-seal-failure.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PublicKey:      util.Ptr("tests/key-4096.pub"),
 		Output:         util.Ptr(tempFile.Name()),
 		ForceOverwrite: util.Ptr(true),
@@ -145,8 +239,9 @@ func TestSealConfigSealError(t *testing.T) {
 
 	err := app.SealConfig(&args, &sb)
 	assert.NotNil(t, err)
-	_, ok := err.(ApplicationError)
+	appErr, ok := err.(ApplicationError)
 	assert.True(t, ok)
+	assert.Equal(t, EXIT_CODE_SEAL_ERRORS, appErr.ExitCode())
 	assert.ErrorContains(t, err, "There were errors when sealing the config.  Check the output for details.")
 
 	stdOutput := sb.String()
@@ -169,6 +264,9 @@ func TestSealConfigInvalidYaml(t *testing.T) {
 	tempFile.Close()
 	args := SealConfigArgs{
 		Input:          util.Ptr("tests/invalid.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PublicKey:      util.Ptr("tests/key-4096.pub"),
 		Output:         util.Ptr(tempFile.Name()),
 		ForceOverwrite: util.Ptr(true),
@@ -193,6 +291,9 @@ func TestSealConfigBadKeyFile(t *testing.T) {
 	tempFile.Close()
 	args := SealConfigArgs{
 		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PublicKey:      util.Ptr("tests/key-4096-bad.pem"),
 		Output:         util.Ptr(tempFile.Name()),
 		ForceOverwrite: util.Ptr(true),
@@ -213,3 +314,216 @@ func TestSealConfigBadKeyFile(t *testing.T) {
 	assert.Contains(t, stdOutput, "No validation errors")
 
 }
+
+func TestSealConfigJsonPreservesFormat(t *testing.T) {
+
+	var sb strings.Builder
+
+	//make a JSON copy of the example config
+	exampleConfig, err := config.ReadConfigFromFile("tests/example.yaml")
+	require.Nil(t, err)
+	jsonFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.json")
+	jsonFile.Close()
+	err = exampleConfig.WriteConfigToFile(jsonFile.Name(), true)
+	require.Nil(t, err)
+
+	//the output name has no extension, but the output is still JSON like the input
+	sealedFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.out")
+	sealedFile.Close()
+	args := SealConfigArgs{
+		Input:          util.Ptr(jsonFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+		Output:         util.Ptr(sealedFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	err = app.SealConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "After the seal operation, of 4 total items, 4 are sealed and 0 are unsealed.")
+
+	sealedData, err := os.ReadFile(sealedFile.Name())
+	require.Nil(t, err)
+	assert.True(t, json.Valid(sealedData))
+
+	//unseal with the format given explicitly since the extension doesn't say
+	sb.Reset()
+	unsealedFile := util.CreateTempFileAndDir("test_output", "unseal_config_test.*.out")
+	unsealedFile.Close()
+	unsealArgs := UnsealConfigArgs{
+		Input:          util.Ptr(sealedFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr("json"),
+		OutputMode:     util.Ptr(""),
+		PrivateKey:     util.Ptr("tests/key-4096.pem"),
+		Passphrase:     util.Ptr(""),
+		Output:         util.Ptr(unsealedFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+	err = app.UnsealConfig(&unsealArgs, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "After the unseal operation, of 4 total items, 0 are sealed and 4 are unsealed.")
+
+	unsealedConfig, err := config.ReadConfigFromFileWithFormat(unsealedFile.Name(), config.CONFIG_FORMAT_JSON)
+	require.Nil(t, err)
+	assert.Equal(t, "it's a secret", unsealedConfig.Mail.Accounts[0].SMTP.Password.GetValue())
+
+	sb.Reset()
+	args.Format = util.Ptr("toml")
+	err = app.SealConfig(&args, &sb)
+	assert.ErrorContains(t, err, "unsupported config format 'toml'")
+}
+
+func TestSealConfigPreservesLayout(t *testing.T) {
+
+	var sb strings.Builder
+
+	//add comments to the example config, which also has Windows line endings
+	exampleData, err := os.ReadFile("tests/example.yaml")
+	require.Nil(t, err)
+	commentedData := "# example config with comments\r\n" +
+		strings.Replace(string(exampleData), "\nmail:\r\n", "\nmail: # the accounts\r\n", 1)
+	inputFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.yaml")
+	_, err = inputFile.WriteString(commentedData)
+	require.Nil(t, err)
+	inputFile.Close()
+
+	outputFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.yaml")
+	outputFile.Close()
+	args := SealConfigArgs{
+		Input:          util.Ptr(inputFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+		Output:         util.Ptr(outputFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	err = app.SealConfig(&args, &sb)
+	require.Nil(t, err)
+
+	//everything but the newly sealed passwords is unchanged
+	sealedData, err := os.ReadFile(outputFile.Name())
+	require.Nil(t, err)
+	inputLines := strings.Split(commentedData, "\n")
+	sealedLines := strings.Split(string(sealedData), "\n")
+	require.Equal(t, len(inputLines), len(sealedLines))
+	changedLines := 0
+	for index := range inputLines {
+		if inputLines[index] != sealedLines[index] {
+			assert.Contains(t, sealedLines[index], "password: sealed(", "for line %d", index)
+			changedLines++
+		}
+	}
+	assert.Equal(t, 3, changedLines)
+	assert.True(t, strings.HasPrefix(string(sealedData), "# example config with comments\r\n"))
+	assert.Contains(t, string(sealedData), "\nmail: # the accounts\r\n")
+}
+
+func TestSealConfigWithMergeKey(t *testing.T) {
+
+	var sb strings.Builder
+
+	//the second account merges in the smtp settings of the first, including its password
+	inputData := `mail:
+  accounts:
+    - name: test1
+      smtp: &smtpdefaults
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        password: it's a secret
+    - name: test2
+      smtp:
+        <<: *smtpdefaults
+        sender_address: example2@example.com
+`
+	inputFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.yaml")
+	_, err := inputFile.WriteString(inputData)
+	require.Nil(t, err)
+	inputFile.Close()
+
+	outputFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.yaml")
+	outputFile.Close()
+	args := SealConfigArgs{
+		Input:          util.Ptr(inputFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+		Output:         util.Ptr(outputFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	err = app.SealConfig(&args, &sb)
+	require.Nil(t, err)
+
+	//the password is sealed where it is anchored, and the merge is kept
+	sealedData, err := os.ReadFile(outputFile.Name())
+	require.Nil(t, err)
+	assert.Contains(t, string(sealedData), "      smtp: &smtpdefaults\n")
+	assert.Contains(t, string(sealedData), "        <<: *smtpdefaults\n")
+	assert.Equal(t, 1, strings.Count(string(sealedData), "password: sealed("))
+	assert.NotContains(t, string(sealedData), "it's a secret")
+
+	//and unsealing it gives both accounts the password back
+	sb.Reset()
+	unsealArgs := UnsealConfigArgs{
+		Input:          util.Ptr(outputFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PrivateKey:     util.Ptr("tests/key-4096.pem"),
+		Passphrase:     util.Ptr(""),
+		Output:         util.Ptr(outputFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+	err = app.UnsealConfig(&unsealArgs, &sb)
+	require.Nil(t, err)
+	unsealedData, err := os.ReadFile(outputFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, inputData, string(unsealedData))
+}
+
+func TestSealConfigTemplates(t *testing.T) {
+
+	var sb strings.Builder
+
+	tempFile := util.CreateTempFileAndDir("test_output", "seal_config_test.*.yaml")
+	tempFile.Close()
+	args := SealConfigArgs{
+		Input:          util.Ptr("tests/example-templates.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+		Output:         util.Ptr(tempFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	err := app.SealConfig(&args, &sb)
+	assert.Nil(t, err)
+
+	stdOutput := sb.String()
+	assert.Contains(t, stdOutput, "The seal operation sealed 3 items.")
+	assert.Contains(t, stdOutput, "After the seal operation, of 3 total items, 3 are sealed and 0 are unsealed.")
+
+	//the templates are written back, not the accounts they resolve to
+	data, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Contains(t, string(data), "extends: provider")
+	assert.NotContains(t, string(data), "it's a secret")
+
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"fmt"
+	"io"
+)
+
+// ShowConfig writes the config, merged from its includes or config directory, to the output
+// stream.  It is written as YAML, or as JSON in OUTPUT_MODE_JSON; nothing else is written, so the
+// output can be saved as a config.
+func (va varanusAppImpl) ShowConfig(args *ShowConfigArgs, outputStream io.Writer) error {
+
+	source := configSource(*args.Input, *args.ConfigDir)
+	configSet, err := readConfigSet(*args.Input, *args.ConfigDir, *args.Format)
+	if err != nil {
+		return newApplicationError("Could not load config from '%s': %w", source, err)
+	}
+
+	configObj := configSet.MergedConfig
+	if *args.Resolved {
+		configObj = configSet.Config
+	}
+
+	var output string
+	switch *args.OutputMode {
+	case "", OUTPUT_MODE_TEXT:
+		output, err = configObj.ToYAML()
+	case OUTPUT_MODE_JSON:
+		output, err = configObj.ToJSON()
+	default:
+		return newApplicationErrorWithExitCode(EXIT_CODE_USAGE, "unsupported output mode '%s'", *args.OutputMode)
+	}
+	if err != nil {
+		//unreachable because a config that was read can always be written
+		return newApplicationError("Could not write the config: %w", err)
+	}
+
+	fmt.Fprint(outputStream, output)
+	return nil
+}
@@ -0,0 +1,103 @@
+package app
+
+import (
+	"strings"
+	"testing"
+	"varanus/internal/util"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShowConfigResolved(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := ShowConfigArgs{
+		Input:      util.Ptr("tests/example-templates.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
+		Resolved:   util.Ptr(true),
+	}
+
+	app := CreateApp()
+
+	err := app.ShowConfig(&args, &sb)
+	require.Nil(t, err)
+
+	//each account only has the sections it declares
+	expected := `mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: joeuser@example.com
+        password: it's a secret
+    - name: test2
+      imap:
+        recipient_address: example2@example.com
+        server_address: imap.example.com
+        port: 1993
+        use_tls: false
+        username: janeuser@example.com
+        password: it's a secret2
+        mailbox_name: INBOX
+    - name: test3
+      smtp:
+        sender_address: alerts@example.org
+        server_address: smtp.example.org
+        port: 465
+        use_tls: true
+        username: alerts@example.org
+        password: it's a secret3
+  send_limits: []
+monitoring:
+  email_monitors:
+    - from_account: test1
+      to_account: test2
+      test_period: 1h0m0s
+      notifications:
+        - mail: test3
+`
+	assert.Equal(t, expected, sb.String())
+}
+
+func TestShowConfig(t *testing.T) {
+
+	var sb strings.Builder
+
+	args := ShowConfigArgs{
+		Input:      util.Ptr("tests/example-templates.yaml"),
+		ConfigDir:  util.Ptr(""),
+		Format:     util.Ptr(""),
+		OutputMode: util.Ptr(""),
+		Resolved:   util.Ptr(false),
+	}
+
+	app := CreateApp()
+
+	//without resolving, the templates are shown
+	err := app.ShowConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.Contains(t, sb.String(), "  templates:\n    - name: provider\n")
+	assert.Contains(t, sb.String(), "    - name: test1\n      extends: provider\n")
+
+	//JSON output
+	sb.Reset()
+	args.OutputMode = util.Ptr(OUTPUT_MODE_JSON)
+	err = app.ShowConfig(&args, &sb)
+	require.Nil(t, err)
+	assert.True(t, strings.HasPrefix(sb.String(), "{\n  \"mail\": {\n    \"templates\": ["), sb.String())
+
+	//a config that can't be loaded
+	sb.Reset()
+	args.Input = util.Ptr("tests/invalid.yaml")
+	err = app.ShowConfig(&args, &sb)
+	assert.ErrorContains(t, err, "Could not load config from 'tests/invalid.yaml'")
+	assert.Equal(t, EXIT_CODE_FAILED, exitCodeForError(err))
+	assert.Equal(t, "", sb.String())
+}
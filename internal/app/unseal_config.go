@@ -3,32 +3,45 @@ package app
 import (
 	"fmt"
 	"io"
-	"varanus/internal/config"
 	"varanus/internal/secrets"
 	"varanus/internal/validation"
 )
 
 func (va varanusAppImpl) UnsealConfig(args *UnsealConfigArgs, outputStream io.Writer) error {
+	report := newCommandReport("unseal", configSource(*args.Input, *args.ConfigDir))
+	report.Output = configSource(*args.Output, *args.ConfigDir)
+	return runWithReport(*args.OutputMode, outputStream, report, func(textStream io.Writer) error {
+		return va.unsealConfig(args, textStream, report)
+	})
+}
+
+func (va varanusAppImpl) unsealConfig(args *UnsealConfigArgs, outputStream io.Writer, report *CommandReport) error {
 
 	fmt.Fprint(outputStream, args.HumanReadable())
 
-	//load the config
-	configObj, err := config.ReadConfigFromFile(*args.Input)
+	//load the config, merging the included files or the fragments of the config directory
+	source := configSource(*args.Input, *args.ConfigDir)
+	//the documents keep the comments and layout of the input so only the sealed values change
+	configSet, err := readConfigSet(*args.Input, *args.ConfigDir, *args.Format)
 	if err != nil {
-		return newApplicationError("Could not load config from '%s': %w", *args.Input, err)
+		return newApplicationError("Could not load config from '%s': %w", source, err)
 	} else {
 		fmt.Fprintln(outputStream, "The config was loaded successfully.")
+		fmt.Fprint(outputStream, describeConfigSet(configSet))
 	}
 
-	validationResult, err := validation.ValidateObject(configObj)
+	//the merged config is validated so references between the files are checked
+	validationResult, err := validation.ValidateObject(configSet.Config)
 	if err != nil {
 		fmt.Fprintf(outputStream, "Config validation failed: %s\n", err)
 		return newApplicationError(
 			"Refusing to unseal the configuration because validation had an error -- please report this as a bug: %w", err)
 	}
+	validationResult.AddSourceLocations(configSet)
+	report.setValidationResult(validationResult)
 	fmt.Fprint(outputStream, validationResult.HumanReadable())
 	if validationResult.GetErrorCount() > 0 {
-		return newApplicationError("Refusing to unseal unvalidated config file %s", *args.Input)
+		return newApplicationErrorWithExitCode(EXIT_CODE_INVALID_CONFIG, "Refusing to unseal unvalidated config file %s", source)
 	}
 
 	//create the unsealer
@@ -39,20 +52,31 @@ func (va varanusAppImpl) UnsealConfig(args *UnsealConfigArgs, outputStream io.Wr
 		return newApplicationError("Could not load private key from '%s': %w", *args.PrivateKey, err)
 	}
 
-	//unseal the config
-	unsealResult := unsealer.UnsealObject(configObj)
+	//unseal each document on its own, so that each can be written back
+	documents := documentsToWrite(configSet, *args.ConfigDir)
+	unsealResult := secrets.UnsealResult{}
+	for _, document := range documents {
+		documentResult := unsealer.UnsealObject(document.Config)
+		if len(documents) > 1 {
+			documentResult.UnsealErrors = errorsInFile(document.Filename, documentResult.UnsealErrors)
+		}
+		unsealResult = unsealResult.Add(documentResult)
+	}
+	report.setUnsealResult(unsealResult)
 	fmt.Fprint(outputStream, unsealResult.HumanReadable())
 	if len(unsealResult.UnsealErrors) > 0 {
-		return newApplicationError("There were errors when unsealing the config.  Check the output for details.")
+		return newApplicationErrorWithExitCode(EXIT_CODE_SEAL_ERRORS,
+			"There were errors when unsealing the config.  Check the output for details.")
 	}
 
 	//write the config out
-	err = configObj.WriteConfigToFile(*args.Output, *args.ForceOverwrite)
+	//the output is written in the same format as the input
+	err = writeDocuments(documents, *args.ConfigDir, *args.Output, *args.ForceOverwrite)
 	if err != nil {
-		return newApplicationError("Error writing output config to '%s': %w", *args.Output, err)
+		return err
 	}
 
-	fmt.Fprintf(outputStream, "Unseal operation succeeded.  Results written to '%s'\n", *args.Output)
+	fmt.Fprintf(outputStream, "Unseal operation succeeded.  Results written to '%s'\n", report.Output)
 
 	return nil
 }
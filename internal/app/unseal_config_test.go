@@ -21,6 +21,9 @@ func TestUnsealConfigNominalNoForce(t *testing.T) {
 	require.Nil(t, err)
 	args := UnsealConfigArgs{
 		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PrivateKey:     util.Ptr("tests/key-4096.pem"),
 		Passphrase:     util.Ptr(""),
 		Output:         util.Ptr(tempFile.Name()),
@@ -51,6 +54,9 @@ func TestUnsealConfigNoForceButFileExists(t *testing.T) {
 	//don't delete the temp file
 	args := UnsealConfigArgs{
 		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PrivateKey:     util.Ptr("tests/key-4096.pem"),
 		Passphrase:     util.Ptr(""),
 		Output:         util.Ptr(tempFile.Name()),
@@ -77,6 +83,9 @@ func TestUnsealConfigUnvalidatable(t *testing.T) {
 	tempFile.Close()
 	args := UnsealConfigArgs{
 		Input:          util.Ptr("tests/example-unvalidatable.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PrivateKey:     util.Ptr("tests/key-4096.pem"),
 		Passphrase:     util.Ptr(""),
 		Output:         util.Ptr(tempFile.Name()),
@@ -96,7 +105,7 @@ func TestUnsealConfigUnvalidatable(t *testing.T) {
 	// t.FailNow()
 	assert.Contains(t, stdOutput, "The config was loaded successfully.")
 	assert.Contains(t, stdOutput, "2 Validation Errors")
-	assert.Contains(t, stdOutput, "send_limits account name 'test1' that does not exist")
+	assert.Contains(t, stdOutput, "send_limits account named 'test1' does not exist")
 	assert.Contains(t, stdOutput, "account names must not be empty or whitespace")
 
 }
@@ -109,6 +118,9 @@ func TestUnsealConfigValidationFailure(t *testing.T) {
 	tempFile.Close()
 	args := UnsealConfigArgs{
 		Input:          util.Ptr("tests/example-validation-failure.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PrivateKey:     util.Ptr("tests/key-4096.pem"),
 		Passphrase:     util.Ptr(""),
 		Output:         util.Ptr(tempFile.Name()),
@@ -142,6 +154,9 @@ func TestUnsealConfigSealError(t *testing.T) {
 	tempFile.Close()
 	args := UnsealConfigArgs{
 		Input:          util.Ptr("tests/example-bad-seal.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PrivateKey:     util.Ptr("tests/key-4096.pem"),
 		Passphrase:     util.Ptr(""),
 		Output:         util.Ptr(tempFile.Name()),
@@ -164,7 +179,7 @@ func TestUnsealConfigSealError(t *testing.T) {
 	assert.Contains(t, stdOutput, "The unseal operation unsealed 0 items.")
 	assert.Contains(t, stdOutput, "After the unseal operation, of 2 total items, 1 are sealed and 1 are unsealed.")
 	assert.Contains(t, stdOutput, "1 unseal errors were detected")
-	assert.Contains(t, stdOutput, "error at path mail.accounts[0].SMTP.password: failed to unseal secret crypto/rsa: decryption error")
+	assert.Contains(t, stdOutput, "error at path mail.accounts[0].smtp.password: failed to unseal secret crypto/rsa: decryption error")
 
 }
 
@@ -176,6 +191,9 @@ func TestUnsealConfigInvalidYaml(t *testing.T) {
 	tempFile.Close()
 	args := UnsealConfigArgs{
 		Input:          util.Ptr("tests/invalid.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PrivateKey:     util.Ptr("tests/key-4096.pem"),
 		Passphrase:     util.Ptr(""),
 		Output:         util.Ptr(tempFile.Name()),
@@ -201,6 +219,9 @@ func TestUnsealConfigBadKeyFile(t *testing.T) {
 	tempFile.Close()
 	args := UnsealConfigArgs{
 		Input:          util.Ptr("tests/example.yaml"),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
 		PrivateKey:     util.Ptr("tests/key-4096-bad.pem"),
 		Passphrase:     util.Ptr(""),
 		Output:         util.Ptr(tempFile.Name()),
@@ -222,3 +243,59 @@ func TestUnsealConfigBadKeyFile(t *testing.T) {
 	assert.Contains(t, stdOutput, "No validation errors")
 
 }
+
+func TestUnsealConfigEscapesInterpolation(t *testing.T) {
+
+	var sb strings.Builder
+
+	//the password has a literal "$" and text that would otherwise be a reference
+	inputData := `interpolate: true
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        password: pa$$word$${HOME}
+`
+	inputFile := util.CreateTempFileAndDir("test_output", "unseal_config_test.*.yaml")
+	_, err := inputFile.WriteString(inputData)
+	require.Nil(t, err)
+	inputFile.Close()
+	outputFile := util.CreateTempFileAndDir("test_output", "unseal_config_test.*.yaml")
+	outputFile.Close()
+
+	app := CreateApp()
+
+	sealArgs := SealConfigArgs{
+		Input:          util.Ptr(inputFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PublicKey:      util.Ptr("tests/key-4096.pub"),
+		Output:         util.Ptr(outputFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+	err = app.SealConfig(&sealArgs, &sb)
+	require.Nil(t, err)
+
+	unsealArgs := UnsealConfigArgs{
+		Input:          util.Ptr(outputFile.Name()),
+		ConfigDir:      util.Ptr(""),
+		Format:         util.Ptr(""),
+		OutputMode:     util.Ptr(""),
+		PrivateKey:     util.Ptr("tests/key-4096.pem"),
+		Passphrase:     util.Ptr(""),
+		Output:         util.Ptr(outputFile.Name()),
+		ForceOverwrite: util.Ptr(true),
+	}
+	err = app.UnsealConfig(&unsealArgs, &sb)
+	require.Nil(t, err)
+
+	//the round trip gives back the escaped password
+	outputData, err := os.ReadFile(outputFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, inputData, string(outputData))
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"strings"
+	"varanus/internal/util"
+	"varanus/internal/validation"
+)
+
+// AccountCapability is a server that an account must have to be used by a reference
+type AccountCapability string
+
+// ACCOUNT_CAPABILITY_ANY accepts any account
+const ACCOUNT_CAPABILITY_ANY AccountCapability = ""
+
+// ACCOUNT_CAPABILITY_SMTP requires an account with an smtp section, to send email
+const ACCOUNT_CAPABILITY_SMTP AccountCapability = "SMTP"
+
+// ACCOUNT_CAPABILITY_IMAP requires an account with an imap section, to receive email
+const ACCOUNT_CAPABILITY_IMAP AccountCapability = "IMAP"
+
+// AccountRef is a reference by name from somewhere in the config to one of the mail accounts, like
+// the from_account of a monitor.  The config types that have references check them with Validate,
+// so that every reference is reported the same way, with the names that were likely meant.
+type AccountRef struct {
+	Description string //names the reference in messages, like "from_account"
+	Name        string
+	Needs       AccountCapability
+}
+
+// hasCapability returns true if the account can be used for a reference that needs capability
+func (a MailAccountConfig) hasCapability(capability AccountCapability) bool {
+	switch capability {
+	case ACCOUNT_CAPABILITY_SMTP:
+		return a.SMTP != nil
+	case ACCOUNT_CAPABILITY_IMAP:
+		return a.IMAP != nil
+	}
+	return true
+}
+
+// Validate adds a validation error for object if the account doesn't exist or doesn't have the
+// capability the reference needs.  The error suggests the names of the accounts that have the
+// capability and are closest to the name.  An empty or whitespace name is not checked, since the
+// fields are required by their validate tags.
+func (r AccountRef) Validate(vet validation.ValidationErrorTracker, object interface{}, mailConfig MailConfig) {
+	if strings.TrimSpace(r.Name) == "" {
+		return
+	}
+
+	capableNames := []string{}
+	for _, account := range mailConfig.Accounts {
+		if account.hasCapability(r.Needs) {
+			capableNames = append(capableNames, account.Name)
+		}
+	}
+	suggestions := util.DescribeSuggestions(util.SuggestNames(r.Name, capableNames))
+
+	account := mailConfig.GetAccountByName(r.Name)
+	if account == nil {
+		vet.AddValidationError(object, "%s named '%s' does not exist%s", r.Description, r.Name, suggestions)
+	} else if !account.hasCapability(r.Needs) {
+		vet.AddValidationError(object, "%s named '%s' must have an %s configuration%s",
+			r.Description, r.Name, r.Needs, suggestions)
+	}
+}
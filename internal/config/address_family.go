@@ -0,0 +1,66 @@
+package config
+
+import (
+	"slices"
+	"strings"
+)
+
+// AddressFamily selects which IP address family is used to connect to a mail server
+type AddressFamily string
+
+// ADDRESS_FAMILY_ANY lets the system choose IPv4 or IPv6 (dual-stack)
+const ADDRESS_FAMILY_ANY AddressFamily = "any"
+
+// ADDRESS_FAMILY_IPV4 only connects over IPv4
+const ADDRESS_FAMILY_IPV4 AddressFamily = "ipv4"
+
+// ADDRESS_FAMILY_IPV6 only connects over IPv6
+const ADDRESS_FAMILY_IPV6 AddressFamily = "ipv6"
+
+// SupportedAddressFamilies lists the accepted address_family values
+var SupportedAddressFamilies = []AddressFamily{ADDRESS_FAMILY_ANY, ADDRESS_FAMILY_IPV4, ADDRESS_FAMILY_IPV6}
+
+// Network returns the network name used with net.Dial for the address family.  An empty address
+// family is treated as ADDRESS_FAMILY_ANY.
+func (af AddressFamily) Network() string {
+	switch af {
+	case ADDRESS_FAMILY_IPV4:
+		return "tcp4"
+	case ADDRESS_FAMILY_IPV6:
+		return "tcp6"
+	default:
+		return "tcp"
+	}
+}
+
+// OrAny returns the address family, or ADDRESS_FAMILY_ANY if it is not set.
+func (af AddressFamily) OrAny() AddressFamily {
+	if af == "" {
+		return ADDRESS_FAMILY_ANY
+	}
+	return af
+}
+
+// normalized returns the address family trimmed and lowercased, like "IPv4" to ADDRESS_FAMILY_IPV4
+func (af AddressFamily) normalized() AddressFamily {
+	return AddressFamily(strings.ToLower(strings.TrimSpace(string(af))))
+}
+
+// IsSupported returns true if the address family is empty or one of SupportedAddressFamilies.
+func (af AddressFamily) IsSupported() bool {
+	return af == "" || slices.Contains(SupportedAddressFamilies, af)
+}
+
+// describeSupportedAddressFamilies lists the supported values for validation messages
+func describeSupportedAddressFamilies() string {
+	return strings.Join(addressFamilyNames(), ", ")
+}
+
+// addressFamilyNames returns SupportedAddressFamilies as strings
+func addressFamilyNames() []string {
+	names := make([]string, 0, len(SupportedAddressFamilies))
+	for _, supported := range SupportedAddressFamilies {
+		names = append(names, string(supported))
+	}
+	return names
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"testing"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestAddressFamily(t *testing.T) {
+
+	type TestCase struct {
+		family          AddressFamily
+		expectedNetwork string
+		expectedOrAny   AddressFamily
+		error           string
+	}
+
+	testCases := []TestCase{
+		{"", "tcp", ADDRESS_FAMILY_ANY, ""},
+		{ADDRESS_FAMILY_ANY, "tcp", ADDRESS_FAMILY_ANY, ""},
+		{ADDRESS_FAMILY_IPV4, "tcp4", ADDRESS_FAMILY_IPV4, ""},
+		{ADDRESS_FAMILY_IPV6, "tcp6", ADDRESS_FAMILY_IPV6, ""},
+		{"IPv6", "tcp", "IPv6", "address_family 'IPv6' is not supported; expected one of any, ipv4, ipv6"},
+		{"inet6", "tcp", "inet6", "address_family 'inet6' is not supported; expected one of any, ipv4, ipv6"},
+	}
+
+	for index, testCase := range testCases {
+		assert.Equal(t, testCase.expectedNetwork, testCase.family.Network(), "for test %d", index)
+		assert.Equal(t, testCase.expectedOrAny, testCase.family.OrAny(), "for test %d", index)
+
+		assert.Equal(t, testCase.error == "", testCase.family.IsSupported(), "for test %d", index)
+
+		//the family is validated as part of the account
+		account := MailAccountConfig{Name: "test1", SMTP: &SMTPConfig{}, AddressFamily: testCase.family}
+		validationResult := validation.ValidationResult{}
+		err := account.Validate(&validationResult, nil)
+		require.Nil(t, err)
+		if testCase.error == "" {
+			assert.Equal(t, 0, validationResult.GetErrorCount(), "for test %d", index)
+		} else {
+			require.Equal(t, 1, validationResult.GetErrorCount(), "for test %d", index)
+			assert.Equal(t, testCase.error, validationResult.GetErrorList()[0].Error, "for test %d", index)
+		}
+	}
+}
+
+func TestAddressFamilyFromYaml(t *testing.T) {
+
+	yamlData := `name: dual
+address_family: ipv6
+local_address: "[2001:db8::10]"
+smtp:
+  sender_address: example@example.com
+  server_address: "[2001:db8::25]"
+  port: 465
+  username: joeuser@example.com
+  password: sealed(+abcdef==)
+`
+
+	account := MailAccountConfig{}
+	err := yaml.Unmarshal([]byte(yamlData), &account)
+	require.Nil(t, err)
+
+	assert.Equal(t, ADDRESS_FAMILY_IPV6, account.AddressFamily)
+	assert.Equal(t, "[2001:db8::10]", account.LocalAddress)
+
+	validationResult, err := validation.ValidateObject(account)
+	require.Nil(t, err)
+	assert.Equal(t, 0, validationResult.GetErrorCount())
+
+	monitor := EmailMonitorConfig{}
+	assert.Equal(t, []AddressFamily{""}, monitor.GetProbeAddressFamilies())
+	monitor.AddressFamilies = []AddressFamily{ADDRESS_FAMILY_IPV4, ADDRESS_FAMILY_IPV6}
+	assert.Equal(t, []AddressFamily{ADDRESS_FAMILY_IPV4, ADDRESS_FAMILY_IPV6}, monitor.GetProbeAddressFamilies())
+}
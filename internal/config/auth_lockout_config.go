@@ -0,0 +1,27 @@
+package config
+
+import (
+	"time"
+	"varanus/internal/validation"
+)
+
+// DEFAULT_AUTH_FAILURE_THRESHOLD is the number of consecutive rejected logins before logins for an
+// account are suspended, when auth_lockout is not configured
+const DEFAULT_AUTH_FAILURE_THRESHOLD = 3
+
+// DEFAULT_AUTH_COOL_DOWN is how long logins for an account are suspended, when auth_lockout is not
+// configured
+const DEFAULT_AUTH_COOL_DOWN = time.Hour
+
+// AuthLockoutConfig controls how the mail worker protects accounts from being locked by their
+// provider after a password change.  Once an account's credentials are rejected failure_threshold
+// times in a row, no logins are attempted for the account until cool_down has passed.
+type AuthLockoutConfig struct {
+	FailureThreshold uint          `yaml:"failure_threshold" doc:"The number of logins rejected in a row before logins for the account are suspended" example:"3" validate:"positive" message:"auth_lockout failure_threshold must be greater than zero"`
+	CoolDown         time.Duration `yaml:"cool_down" doc:"How long logins for the account are suspended" example:"1h" validate:"positive" message:"auth_lockout cool_down must be positive, not '%s'"`
+}
+
+func (c AuthLockoutConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
+	//both settings are checked by their validate tags
+	return validation.CheckFieldRules(vet, c)
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"testing"
+	"time"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthLockoutValidation(t *testing.T) {
+
+	type TestCase struct {
+		Mutator func(c *AuthLockoutConfig)
+		Error   string
+	}
+
+	testCases := []TestCase{
+		{
+			Mutator: func(c *AuthLockoutConfig) { c.FailureThreshold = 0 },
+			Error:   "auth_lockout failure_threshold must be greater than zero",
+		},
+		{
+			Mutator: func(c *AuthLockoutConfig) { c.CoolDown = 0 },
+			Error:   "auth_lockout cool_down must be positive, not '0s'",
+		},
+		{
+			Mutator: func(c *AuthLockoutConfig) { c.CoolDown = -time.Minute },
+			Error:   "auth_lockout cool_down must be positive, not '-1m0s'",
+		},
+	}
+
+	baseConfig := AuthLockoutConfig{
+		FailureThreshold: 3,
+		CoolDown:         time.Hour,
+	}
+
+	{
+		//nominal case test should have no errors
+		validationResult := validation.ValidationResult{}
+		err := baseConfig.Validate(&validationResult, nil)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, validationResult.GetErrorCount())
+	}
+
+	// test loop
+	for index, testCase := range testCases {
+		config := baseConfig
+		testCase.Mutator(&config)
+
+		validationResult := validation.ValidationResult{}
+		err := config.Validate(&validationResult, nil)
+
+		assert.Nil(t, err)
+		require.Equal(t, 1, validationResult.GetErrorCount(), "for test %d", index)
+		singleError := validationResult.GetErrorList()[0]
+		assert.IsType(t, AuthLockoutConfig{}, singleError.Object, "for test %d", index)
+		assert.Equal(t, testCase.Error, singleError.Error, "for test %d", index)
+	}
+}
+
+func TestAuthLockoutFromYaml(t *testing.T) {
+
+	yamlData := `mail:
+  accounts: []
+  send_limits: []
+  auth_lockout:
+    failure_threshold: 5
+    cool_down: 30m
+monitoring:
+  email_monitors: []
+`
+
+	c, err := ReadConfig([]byte(yamlData))
+	require.Nil(t, err)
+
+	validationResult, err := validation.ValidateObject(c)
+	require.Nil(t, err)
+	assert.Equal(t, 0, validationResult.GetErrorCount())
+
+	assert.Equal(t, AuthLockoutConfig{FailureThreshold: 5, CoolDown: 30 * time.Minute}, c.Mail.GetAuthLockout())
+
+	//without auth_lockout the defaults are used
+	c.Mail.AuthLockout = nil
+	assert.Equal(t,
+		AuthLockoutConfig{FailureThreshold: DEFAULT_AUTH_FAILURE_THRESHOLD, CoolDown: DEFAULT_AUTH_COOL_DOWN},
+		c.Mail.GetAuthLockout())
+}
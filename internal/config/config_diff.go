@@ -0,0 +1,315 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"varanus/internal/secrets"
+	"varanus/internal/walker"
+)
+
+// Configs are compared field by field on the decoded objects, not on their text, because sealing
+// is randomized: the same password sealed twice gives two different sealed values.  The changes
+// use the paths of the walker, except that accounts, templates, monitors and notifications are
+// matched by name instead of by their position in the list, like "mail.accounts[work].smtp.port",
+// so that adding an account doesn't change every account after it.  The module configs are matched
+// by their name in the same way, like "modules[dns]".
+
+// ConfigChangeKind says how a value differs between two configs
+type ConfigChangeKind string
+
+const (
+	CONFIG_CHANGE_ADDED   ConfigChangeKind = "added"
+	CONFIG_CHANGE_REMOVED ConfigChangeKind = "removed"
+	CONFIG_CHANGE_CHANGED ConfigChangeKind = "changed"
+	//CONFIG_CHANGE_SECRET_CHANGED is a sealed item whose plaintext changed; the values are never shown
+	CONFIG_CHANGE_SECRET_CHANGED ConfigChangeKind = "secret changed"
+	//CONFIG_CHANGE_NOT_COMPARED is two sealed values that differ, which can only be compared after
+	//they are unsealed
+	CONFIG_CHANGE_NOT_COMPARED ConfigChangeKind = "not compared"
+)
+
+// the values shown for a sealed item whose sealing changed, since the secret can't be shown
+const (
+	DIFF_SEALED_VALUE    = "sealed"
+	DIFF_PLAINTEXT_VALUE = "plaintext"
+)
+
+// ConfigChange is a difference between two configs
+type ConfigChange struct {
+	Kind ConfigChangeKind
+	Path string
+	//OldValue and NewValue are only set for changed values
+	OldValue string
+	NewValue string
+}
+
+func (cc ConfigChange) String() string {
+	switch cc.Kind {
+	case CONFIG_CHANGE_CHANGED:
+		return fmt.Sprintf("%s: %s -> %s", cc.Path, cc.OldValue, cc.NewValue)
+	case CONFIG_CHANGE_SECRET_CHANGED:
+		return fmt.Sprintf("%s: secret changed", cc.Path)
+	case CONFIG_CHANGE_NOT_COMPARED:
+		return fmt.Sprintf("%s: sealed differently, not compared without a private key", cc.Path)
+	}
+	return fmt.Sprintf("%s: %s", cc.Path, cc.Kind)
+}
+
+// diffListKeys give the key that the items of a list are matched by.  Lists of other objects are
+// matched by position.
+var diffListKeys = map[reflect.Type]func(item reflect.Value) string{
+	reflect.TypeOf(MailAccountConfig{}):   func(item reflect.Value) string { return item.Interface().(MailAccountConfig).Name },
+	reflect.TypeOf(MailAccountTemplate{}): func(item reflect.Value) string { return item.Interface().(MailAccountTemplate).Name },
+	reflect.TypeOf(EmailMonitorConfig{}): func(item reflect.Value) string {
+		monitor := item.Interface().(EmailMonitorConfig)
+		return monitor.FromAccount + "->" + monitor.ToAccount
+	},
+	reflect.TypeOf(NotificationConfig{}): func(item reflect.Value) string { return item.Interface().(NotificationConfig).Mail },
+}
+
+// DiffConfigs returns the changes from oldConfig to newConfig.  If unsealer is nil, sealed items
+// that differ are CONFIG_CHANGE_NOT_COMPARED; otherwise their plaintexts are compared.  An error
+// is returned if a sealed item can't be unsealed.
+func DiffConfigs(oldConfig *VaranusConfig, newConfig *VaranusConfig, unsealer secrets.SecretUnsealer) ([]ConfigChange, error) {
+	differ := configDiffer{changes: []ConfigChange{}, unsealer: unsealer}
+	err := differ.diffValues("", reflect.ValueOf(*oldConfig), reflect.ValueOf(*newConfig))
+	if err != nil {
+		return nil, err
+	}
+	return differ.changes, nil
+}
+
+type configDiffer struct {
+	changes  []ConfigChange
+	unsealer secrets.SecretUnsealer
+}
+
+func (d *configDiffer) add(kind ConfigChangeKind, path string, oldValue string, newValue string) {
+	d.changes = append(d.changes, ConfigChange{Kind: kind, Path: path, OldValue: oldValue, NewValue: newValue})
+}
+
+func (d *configDiffer) diffValues(path string, oldValue reflect.Value, newValue reflect.Value) error {
+	switch oldValue.Type() {
+	case sealedItemType:
+		return d.diffSecrets(path, oldValue.Interface().(secrets.SealedItem), newValue.Interface().(secrets.SealedItem))
+	case durationType:
+		d.diffScalars(path, oldValue.Interface().(time.Duration).String(), newValue.Interface().(time.Duration).String())
+		return nil
+	case forceConfigFailureType:
+		d.diffScalars(path, oldValue.Interface().(ForceConfigFailure).Value, newValue.Interface().(ForceConfigFailure).Value)
+		return nil
+	}
+
+	switch oldValue.Kind() {
+	case reflect.Pointer:
+		switch {
+		case oldValue.IsNil() && newValue.IsNil():
+			return nil
+		case oldValue.IsNil():
+			d.add(CONFIG_CHANGE_ADDED, path, "", "")
+			return nil
+		case newValue.IsNil():
+			d.add(CONFIG_CHANGE_REMOVED, path, "", "")
+			return nil
+		}
+		return d.diffValues(path, oldValue.Elem(), newValue.Elem())
+	case reflect.Struct:
+		for index := 0; index < oldValue.NumField(); index++ {
+			field := oldValue.Type().Field(index)
+			key := walker.GetYamlNameFromTag(field.Tag)
+			inline := walker.IsYamlInline(field.Tag)
+			if !field.IsExported() || (key == "" && !inline) || key == "-" {
+				continue
+			}
+			fieldPath := key
+			if inline {
+				fieldPath = path
+			} else if path != "" {
+				fieldPath = path + "." + key
+			}
+			err := d.diffValues(fieldPath, oldValue.Field(index), newValue.Field(index))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case reflect.Interface:
+		//the values of registered types, which can only be compared if they have the same type
+		switch {
+		case oldValue.IsNil() && newValue.IsNil():
+			return nil
+		case oldValue.IsNil():
+			d.add(CONFIG_CHANGE_ADDED, path, "", "")
+			return nil
+		case newValue.IsNil():
+			d.add(CONFIG_CHANGE_REMOVED, path, "", "")
+			return nil
+		case oldValue.Elem().Type() != newValue.Elem().Type():
+			d.add(CONFIG_CHANGE_CHANGED, path, oldValue.Elem().Type().String(), newValue.Elem().Type().String())
+			return nil
+		}
+		return d.diffValues(path, oldValue.Elem(), newValue.Elem())
+	case reflect.Map:
+		return d.diffMaps(path, oldValue, newValue)
+	case reflect.Slice:
+		if keyOf, isKeyed := diffListKeys[oldValue.Type().Elem()]; isKeyed {
+			return d.diffKeyedLists(path, oldValue, newValue, keyOf)
+		}
+		if baseType(oldValue.Type().Elem()).Kind() == reflect.Struct {
+			return d.diffListsByPosition(path, oldValue, newValue)
+		}
+		//lists of names and other values are compared as a whole
+		d.diffScalars(path, formatDiffList(oldValue), formatDiffList(newValue))
+		return nil
+	}
+	d.diffScalars(path, fmt.Sprint(oldValue.Interface()), fmt.Sprint(newValue.Interface()))
+	return nil
+}
+
+// diffMaps matches the values of two maps with string keys by key, like the keyed lists
+func (d *configDiffer) diffMaps(path string, oldMap reflect.Value, newMap reflect.Value) error {
+	keys := []string{}
+	for _, key := range append(oldMap.MapKeys(), newMap.MapKeys()...) {
+		if !slices.Contains(keys, key.String()) {
+			keys = append(keys, key.String())
+		}
+	}
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		itemPath := fmt.Sprintf("%s[%s]", path, key)
+		mapKey := reflect.ValueOf(key).Convert(oldMap.Type().Key())
+		oldItem := oldMap.MapIndex(mapKey)
+		newItem := newMap.MapIndex(mapKey)
+		switch {
+		case !newItem.IsValid():
+			d.add(CONFIG_CHANGE_REMOVED, itemPath, "", "")
+		case !oldItem.IsValid():
+			d.add(CONFIG_CHANGE_ADDED, itemPath, "", "")
+		default:
+			err := d.diffValues(itemPath, oldItem, newItem)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (d *configDiffer) diffScalars(path string, oldValue string, newValue string) {
+	if oldValue != newValue {
+		d.add(CONFIG_CHANGE_CHANGED, path, oldValue, newValue)
+	}
+}
+
+// diffKeyedLists matches the items of two lists by key.  If a key is used more than once, the
+// later items get "#2", "#3" and so on, so they are matched by their position among the items
+// with that key.
+func (d *configDiffer) diffKeyedLists(path string, oldList reflect.Value, newList reflect.Value, keyOf func(reflect.Value) string) error {
+	oldKeys, oldItems := keyDiffList(oldList, keyOf)
+	newKeys, newItems := keyDiffList(newList, keyOf)
+
+	for _, key := range oldKeys {
+		itemPath := fmt.Sprintf("%s[%s]", path, key)
+		newItem, found := newItems[key]
+		if !found {
+			d.add(CONFIG_CHANGE_REMOVED, itemPath, "", "")
+			continue
+		}
+		err := d.diffValues(itemPath, oldItems[key], newItem)
+		if err != nil {
+			return err
+		}
+	}
+	for _, key := range newKeys {
+		if _, found := oldItems[key]; !found {
+			d.add(CONFIG_CHANGE_ADDED, fmt.Sprintf("%s[%s]", path, key), "", "")
+		}
+	}
+	return nil
+}
+
+func keyDiffList(list reflect.Value, keyOf func(reflect.Value) string) ([]string, map[string]reflect.Value) {
+	keys := []string{}
+	items := map[string]reflect.Value{}
+	counts := map[string]int{}
+	for index := 0; index < list.Len(); index++ {
+		key := keyOf(list.Index(index))
+		counts[key]++
+		if counts[key] > 1 {
+			key = fmt.Sprintf("%s#%d", key, counts[key])
+		}
+		keys = append(keys, key)
+		items[key] = list.Index(index)
+	}
+	return keys, items
+}
+
+func (d *configDiffer) diffListsByPosition(path string, oldList reflect.Value, newList reflect.Value) error {
+	for index := 0; index < max(oldList.Len(), newList.Len()); index++ {
+		itemPath := fmt.Sprintf("%s[%d]", path, index)
+		switch {
+		case index >= newList.Len():
+			d.add(CONFIG_CHANGE_REMOVED, itemPath, "", "")
+		case index >= oldList.Len():
+			d.add(CONFIG_CHANGE_ADDED, itemPath, "", "")
+		default:
+			err := d.diffValues(itemPath, oldList.Index(index), newList.Index(index))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// diffSecrets compares two sealed items without showing their values
+func (d *configDiffer) diffSecrets(path string, oldItem secrets.SealedItem, newItem secrets.SealedItem) error {
+	if oldItem.IsValueSealed() == newItem.IsValueSealed() && oldItem.GetValue() == newItem.GetValue() {
+		return nil
+	}
+	bothSealed := oldItem.IsValueSealed() && newItem.IsValueSealed()
+
+	if d.unsealer == nil && oldItem.IsValueSealed() != newItem.IsValueSealed() {
+		//the plaintext can't be compared with the sealed value, but the sealing changed
+		d.add(CONFIG_CHANGE_CHANGED, path, describeSealing(oldItem), describeSealing(newItem))
+		return nil
+	}
+	if d.unsealer == nil && bothSealed {
+		d.add(CONFIG_CHANGE_NOT_COMPARED, path, "", "")
+		return nil
+	}
+
+	oldSecret, err := oldItem.ReadSecret(d.unsealer)
+	if err != nil {
+		return fmt.Errorf("could not unseal the old value of %s: %w", path, err)
+	}
+	newSecret, err := newItem.ReadSecret(d.unsealer)
+	if err != nil {
+		return fmt.Errorf("could not unseal the new value of %s: %w", path, err)
+	}
+	if oldSecret != newSecret {
+		d.add(CONFIG_CHANGE_SECRET_CHANGED, path, "", "")
+	} else if oldItem.IsValueSealed() != newItem.IsValueSealed() {
+		d.add(CONFIG_CHANGE_CHANGED, path, describeSealing(oldItem), describeSealing(newItem))
+	}
+	return nil
+}
+
+func describeSealing(item secrets.SealedItem) string {
+	if item.IsValueSealed() {
+		return DIFF_SEALED_VALUE
+	}
+	return DIFF_PLAINTEXT_VALUE
+}
+
+func formatDiffList(list reflect.Value) string {
+	items := []string{}
+	for index := 0; index < list.Len(); index++ {
+		items = append(items, fmt.Sprint(list.Index(index).Interface()))
+	}
+	return "[" + strings.Join(items, ", ") + "]"
+}
@@ -0,0 +1,246 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"varanus/internal/secrets"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const DIFF_TEST_ACCOUNTS_YAML = `mail:
+  accounts:
+    - name: work
+      smtp:
+        sender_address: work@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: work
+        password: secret1
+    - name: home
+      imap:
+        recipient_address: home@example.org
+        server_address: imap.example.org
+        port: 993
+        use_tls: true
+        username: home
+        password: secret2
+        mailbox_name: INBOX
+  send_limits:
+    - min_period: 10m
+      account_names: [work]
+`
+
+const DIFF_TEST_MONITORS_YAML = `monitoring:
+  email_monitors:
+    - from_account: work
+      to_account: home
+      test_period: 1h
+      notifications:
+        - mail: home
+        - mail: work
+`
+
+func TestDiffConfigs(t *testing.T) {
+
+	type testCase struct {
+		oldYaml         string
+		newYaml         string
+		expectedChanges []ConfigChange
+	}
+
+	testCases := []testCase{
+		//the same config has no changes
+		{
+			oldYaml:         DIFF_TEST_ACCOUNTS_YAML + DIFF_TEST_MONITORS_YAML,
+			newYaml:         DIFF_TEST_ACCOUNTS_YAML + DIFF_TEST_MONITORS_YAML,
+			expectedChanges: []ConfigChange{},
+		},
+		//fields are compared by path, with accounts matched by name
+		{
+			oldYaml: DIFF_TEST_ACCOUNTS_YAML + DIFF_TEST_MONITORS_YAML,
+			newYaml: strings.NewReplacer(
+				"port: 465", "port: 587",
+				"use_tls: true\n        username: home", "use_tls: false\n        username: home",
+				"test_period: 1h", "test_period: 90m",
+				"min_period: 10m", "min_period: 1h",
+				"account_names: [work]", "account_names: [work, home]",
+			).Replace(DIFF_TEST_ACCOUNTS_YAML + DIFF_TEST_MONITORS_YAML),
+			expectedChanges: []ConfigChange{
+				{Kind: CONFIG_CHANGE_CHANGED, Path: "mail.accounts[work].smtp.port", OldValue: "465", NewValue: "587"},
+				{Kind: CONFIG_CHANGE_CHANGED, Path: "mail.accounts[home].imap.use_tls", OldValue: "true", NewValue: "false"},
+				{Kind: CONFIG_CHANGE_CHANGED, Path: "mail.send_limits[0].min_period", OldValue: "10m0s", NewValue: "1h0m0s"},
+				{Kind: CONFIG_CHANGE_CHANGED, Path: "mail.send_limits[0].account_names", OldValue: "[work]", NewValue: "[work, home]"},
+				{Kind: CONFIG_CHANGE_CHANGED, Path: "monitoring.email_monitors[work->home].test_period", OldValue: "1h0m0s", NewValue: "1h30m0s"},
+			},
+		},
+		//reordering accounts and notifications isn't a change
+		{
+			oldYaml: DIFF_TEST_ACCOUNTS_YAML + DIFF_TEST_MONITORS_YAML,
+			newYaml: `mail:
+  accounts:
+    - name: home
+      imap:
+        recipient_address: home@example.org
+        server_address: imap.example.org
+        port: 993
+        use_tls: true
+        username: home
+        password: secret2
+        mailbox_name: INBOX
+    - name: work
+      smtp:
+        sender_address: work@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: work
+        password: secret1
+  send_limits:
+    - min_period: 10m
+      account_names: [work]
+monitoring:
+  email_monitors:
+    - from_account: work
+      to_account: home
+      test_period: 1h
+      notifications:
+        - mail: work
+        - mail: home
+`,
+			expectedChanges: []ConfigChange{},
+		},
+		//added and removed objects are reported once, not by each field
+		{
+			oldYaml: DIFF_TEST_ACCOUNTS_YAML + DIFF_TEST_MONITORS_YAML,
+			newYaml: strings.NewReplacer(
+				"    - name: home\n", "    - name: house\n",
+				"        - mail: work\n", "",
+				"  send_limits:\n    - min_period: 10m\n      account_names: [work]\n", "  send_limits: []\n  proxy:\n    url: socks5://proxy.example.com:1080\n",
+			).Replace(DIFF_TEST_ACCOUNTS_YAML+DIFF_TEST_MONITORS_YAML) + `    - from_account: house
+      to_account: work
+      test_period: 1h
+      notifications:
+        - mail: house
+`,
+			expectedChanges: []ConfigChange{
+				{Kind: CONFIG_CHANGE_REMOVED, Path: "mail.accounts[home]"},
+				{Kind: CONFIG_CHANGE_ADDED, Path: "mail.accounts[house]"},
+				{Kind: CONFIG_CHANGE_REMOVED, Path: "mail.send_limits[0]"},
+				{Kind: CONFIG_CHANGE_ADDED, Path: "mail.proxy"},
+				{Kind: CONFIG_CHANGE_REMOVED, Path: "monitoring.email_monitors[work->home].notifications[work]"},
+				{Kind: CONFIG_CHANGE_ADDED, Path: "monitoring.email_monitors[house->work]"},
+			},
+		},
+		//items with the same key are matched in order
+		{
+			oldYaml: DIFF_TEST_MONITORS_YAML,
+			newYaml: DIFF_TEST_MONITORS_YAML + strings.Replace(strings.TrimPrefix(DIFF_TEST_MONITORS_YAML, "monitoring:\n  email_monitors:\n"), "1h", "2h", 1),
+			expectedChanges: []ConfigChange{
+				{Kind: CONFIG_CHANGE_ADDED, Path: "monitoring.email_monitors[work->home#2]"},
+			},
+		},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		oldConfig, err := ReadConfig([]byte(testCase.oldYaml))
+		require.Nil(t, err)
+		newConfig, err := ReadConfig([]byte(testCase.newYaml))
+		require.Nil(t, err)
+
+		changes, err := DiffConfigs(oldConfig, newConfig, nil)
+		require.Nil(t, err)
+		assert.Equal(t, testCase.expectedChanges, changes)
+	}
+}
+
+// fakeUnsealer unseals values like "1:secret", where the number stands for the random part of a
+// real sealed value
+type fakeUnsealer struct {
+	secrets.SecretUnsealer
+}
+
+func (fu fakeUnsealer) UnsealSecret(cipherText string) (string, error) {
+	_, plaintext, found := strings.Cut(cipherText, ":")
+	if !found {
+		return "", fmt.Errorf("cannot unseal '%s'", cipherText)
+	}
+	return plaintext, nil
+}
+
+func TestDiffConfigsSecrets(t *testing.T) {
+
+	sealed := func(value string) secrets.SealedItem { return secrets.CreateUnsafeSealedItem(value, true) }
+	plaintext := func(value string) secrets.SealedItem { return secrets.CreateUnsafeSealedItem(value, false) }
+	const path = "mail.accounts[work].smtp.password"
+
+	type testCase struct {
+		oldPassword     secrets.SealedItem
+		newPassword     secrets.SealedItem
+		unsealer        secrets.SecretUnsealer
+		expectedChanges []ConfigChange
+		errorContains   string
+	}
+
+	testCases := []testCase{
+		//plaintexts are compared without a key, but never shown
+		{oldPassword: plaintext("a"), newPassword: plaintext("a"), expectedChanges: []ConfigChange{}},
+		{oldPassword: plaintext("a"), newPassword: plaintext("b"),
+			expectedChanges: []ConfigChange{{Kind: CONFIG_CHANGE_SECRET_CHANGED, Path: path}}},
+		//sealed values are opaque without a key
+		{oldPassword: sealed("1:a"), newPassword: sealed("1:a"), expectedChanges: []ConfigChange{}},
+		{oldPassword: sealed("1:a"), newPassword: sealed("2:b"),
+			expectedChanges: []ConfigChange{{Kind: CONFIG_CHANGE_NOT_COMPARED, Path: path}}},
+		{oldPassword: plaintext("a"), newPassword: sealed("1:a"),
+			expectedChanges: []ConfigChange{{Kind: CONFIG_CHANGE_CHANGED, Path: path, OldValue: DIFF_PLAINTEXT_VALUE, NewValue: DIFF_SEALED_VALUE}}},
+		//with a key, the plaintexts are compared
+		{oldPassword: sealed("1:a"), newPassword: sealed("2:b"), unsealer: fakeUnsealer{},
+			expectedChanges: []ConfigChange{{Kind: CONFIG_CHANGE_SECRET_CHANGED, Path: path}}},
+		{oldPassword: sealed("1:a"), newPassword: sealed("2:a"), unsealer: fakeUnsealer{}, expectedChanges: []ConfigChange{}},
+		{oldPassword: plaintext("a"), newPassword: sealed("1:a"), unsealer: fakeUnsealer{},
+			expectedChanges: []ConfigChange{{Kind: CONFIG_CHANGE_CHANGED, Path: path, OldValue: DIFF_PLAINTEXT_VALUE, NewValue: DIFF_SEALED_VALUE}}},
+		{oldPassword: sealed("1:a"), newPassword: plaintext("b"), unsealer: fakeUnsealer{},
+			expectedChanges: []ConfigChange{{Kind: CONFIG_CHANGE_SECRET_CHANGED, Path: path}}},
+		{oldPassword: sealed("1:a"), newPassword: sealed("wrong key"), unsealer: fakeUnsealer{},
+			errorContains: "could not unseal the new value of " + path},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		makeConfig := func(password secrets.SealedItem) *VaranusConfig {
+			return &VaranusConfig{Mail: MailConfig{Accounts: []MailAccountConfig{
+				{Name: "work", SMTP: &SMTPConfig{Password: password}},
+			}}}
+		}
+
+		changes, err := DiffConfigs(makeConfig(testCase.oldPassword), makeConfig(testCase.newPassword), testCase.unsealer)
+		if testCase.errorContains != "" {
+			assert.ErrorContains(t, err, testCase.errorContains)
+			continue
+		}
+		require.Nil(t, err)
+		assert.Equal(t, testCase.expectedChanges, changes)
+	}
+}
+
+func TestConfigChangeString(t *testing.T) {
+
+	testCases := map[string]ConfigChange{
+		"mail.accounts[work]: added":                        {Kind: CONFIG_CHANGE_ADDED, Path: "mail.accounts[work]"},
+		"mail.accounts[work]: removed":                      {Kind: CONFIG_CHANGE_REMOVED, Path: "mail.accounts[work]"},
+		"mail.accounts[work].smtp.port: 465 -> 587":         {Kind: CONFIG_CHANGE_CHANGED, Path: "mail.accounts[work].smtp.port", OldValue: "465", NewValue: "587"},
+		"mail.accounts[work].smtp.password: secret changed": {Kind: CONFIG_CHANGE_SECRET_CHANGED, Path: "mail.accounts[work].smtp.password"},
+		"mail.accounts[work].smtp.password: sealed differently, not compared without a private key": {
+			Kind: CONFIG_CHANGE_NOT_COMPARED, Path: "mail.accounts[work].smtp.password"},
+	}
+
+	for expected, change := range testCases {
+		assert.Equal(t, expected, change.String())
+	}
+}
@@ -0,0 +1,381 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"varanus/internal/secrets"
+	"varanus/internal/validation"
+	"varanus/internal/walker"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigDocument is a config together with the YAML node tree it was read from.  Changes to the
+// sealed items of Config can be copied into the node tree with UpdateSealedItems, and the document
+// written back out with its comments, key order, anchors, and quoting intact.
+type ConfigDocument struct {
+	Config *VaranusConfig
+	Format ConfigFormat
+	//Filename is the file the document was read from, if any
+	Filename string
+	//Migrations are the upgrades applied to the document because it was from an older version
+	Migrations []AppliedMigration
+	root       yaml.Node
+	//true if the input used Windows line endings, so the output can use them too
+	crlf bool
+}
+
+// ReadConfigDocumentFromFile reads the config document at filename in the given format.
+func ReadConfigDocumentFromFile(filename string, format ConfigFormat) (*ConfigDocument, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("file read error for config file %s: %s", filename, err)
+	}
+
+	document, err := ReadConfigDocument(data, format)
+	if err != nil {
+		return nil, fmt.Errorf("error with the contents of %s: %s", filename, err)
+	}
+	document.Filename = filename
+	for index := range document.Config.migratedSources {
+		document.Config.migratedSources[index].filename = filename
+	}
+
+	return document, nil
+}
+
+// ReadConfigDocument creates a ConfigDocument from data in the given format.  A document from an
+// older version is upgraded to the current version.
+func ReadConfigDocument(data []byte, format ConfigFormat) (*ConfigDocument, error) {
+
+	//the config is decoded separately from the node tree because only the decoder supports
+	//KnownFields
+	config, err := ReadConfigWithFormat(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	document := &ConfigDocument{
+		Config: config,
+		Format: format,
+		crlf:   bytes.Contains(data, []byte("\r\n")),
+	}
+	//the node tree is kept with plain line endings because comments would otherwise keep the
+	//carriage returns and end up doubled on output
+	if document.crlf {
+		data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	}
+	err = yaml.Unmarshal(data, &document.root)
+	if err != nil {
+		//unreachable because the same data was already decoded
+		return nil, fmt.Errorf("unmarshal error: %w", err)
+	}
+	untagMergeKeys(&document.root)
+	//the node tree is upgraded like the config, so the paths of the config are in it and it is
+	//written in the current version
+	document.Migrations, err = migrateNode(&document.root)
+	if err != nil {
+		//unreachable because the config was already upgraded
+		return nil, err
+	}
+
+	return document, nil
+}
+
+// UpdateSealedItems copies the value of every SealedItem in Config into the scalar node it was read
+// from.  Nothing else in the node tree is changed, and values that were interpolated keep their
+// references.  A value that was merged in with "<<" is written to the anchored mapping it came from.
+//
+// The errors name the path of the item but never its value, which is a secret.
+func (d *ConfigDocument) UpdateSealedItems() error {
+
+	//the walker adds the value of the needle to errors returned by the callback, so the error is
+	//returned from here instead
+	var updateErr error
+	sealedItemWorker := func(needle interface{}, path string) error {
+		si := needle.(secrets.SealableReader)
+
+		node, err := findNodeByPath(&d.root, path)
+		if err != nil {
+			if si.GetValue() == "" {
+				//an empty item that was never in the document stays out of it
+				return nil
+			}
+			updateErr = err
+			return err
+		}
+		if node.Kind != yaml.ScalarNode {
+			updateErr = fmt.Errorf("the node at path %s is not a scalar", path)
+			return updateErr
+		}
+		value := si.GetValue()
+		if d.Config.Interpolate {
+			if hasInterpolationReference(node.Value) {
+				//the value comes from elsewhere, so the reference is kept rather than writing
+				//the secret into the document
+				return nil
+			}
+			if current, _ := interpolateString(node.Value, path); current == value {
+				return nil
+			}
+			//the value is written so that interpolation reads it back unchanged
+			value = escapeInterpolation(value)
+		}
+
+		if node.Value != value {
+			node.Value = value
+			//marks the value as a string so it is quoted if it would otherwise be read as a
+			//number, bool, etc.
+			node.Tag = "!!str"
+		}
+		return nil
+	}
+
+	var sealedItemInterfaceType = reflect.TypeOf((*secrets.SealableReader)(nil)).Elem()
+	err := walker.WalkObjectImmutable(d.Config, sealedItemInterfaceType, sealedItemWorker)
+	if updateErr != nil {
+		return updateErr
+	}
+	return err
+}
+
+// ToBytes returns the document in its format, with the line endings of the input.
+func (d *ConfigDocument) ToBytes() ([]byte, error) {
+	data, err := d.encode()
+	if err != nil {
+		return nil, err
+	}
+	if d.crlf {
+		data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+	}
+	return data, nil
+}
+
+func (d *ConfigDocument) encode() ([]byte, error) {
+	if d.Format == CONFIG_FORMAT_JSON {
+		var compact bytes.Buffer
+		err := writeNodeAsJson(&d.root, &compact)
+		if err != nil {
+			return nil, err
+		}
+		var indented bytes.Buffer
+		err = json.Indent(&indented, compact.Bytes(), "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		indented.WriteString("\n")
+		return indented.Bytes(), nil
+	}
+
+	var yamlData bytes.Buffer
+	yamlEncoder := yaml.NewEncoder(&yamlData)
+	yamlEncoder.SetIndent(2)
+	err := yamlEncoder.Encode(&d.root)
+	if err != nil {
+		return nil, err
+	}
+	return yamlData.Bytes(), nil
+}
+
+// WriteToFile writes the document to filename in its format.  If forceOverwrite is False, then an
+// error will occur if the file already exists.
+func (d *ConfigDocument) WriteToFile(filename string, forceOverwrite bool) error {
+	data, err := d.ToBytes()
+	if err != nil {
+		return fmt.Errorf("marshaling error: %w", err)
+	}
+	return writeBytesToFile(data, filename, forceOverwrite)
+}
+
+// LocatePath returns the source location of the object at path.  If the path isn't in the
+// document, e.g. because it is a default that was left out, the location of the nearest parent
+// that is in the document is returned instead.  Fields are located at their key.
+//
+// Implements validation.SourceLocator
+func (d *ConfigDocument) LocatePath(path string) (validation.SourceLocation, bool) {
+	segments := splitPath(path)
+	for len(segments) > 0 {
+		key, value, err := findSegments(&d.root, segments)
+		if err == nil {
+			node := value
+			if key != nil {
+				node = key
+			}
+			return validation.SourceLocation{Filename: d.Filename, Line: node.Line, Column: node.Column}, true
+		}
+		segments = segments[:len(segments)-1]
+	}
+
+	//fall back to the top of the document
+	node := resolveNode(&d.root)
+	if node.Line == 0 {
+		//empty document
+		return validation.SourceLocation{}, false
+	}
+	return validation.SourceLocation{Filename: d.Filename, Line: node.Line, Column: node.Column}, true
+}
+
+// findNodeByPath returns the node at a path as produced by the walker, like
+// "mail.accounts[0].smtp.password", starting from a document or mapping node.
+func findNodeByPath(root *yaml.Node, path string) (*yaml.Node, error) {
+	_, value, err := findSegments(root, splitPath(path))
+	if err != nil {
+		return nil, fmt.Errorf("no node found for path %s", path)
+	}
+	return value, nil
+}
+
+// findSegments returns the node at the path made of segments, and the key node for it if the last
+// segment is a field
+func findSegments(root *yaml.Node, segments []pathSegment) (*yaml.Node, *yaml.Node, error) {
+	var key *yaml.Node
+	node := root
+	for _, segment := range segments {
+		node = resolveNode(node)
+		if index, isIndex := segment.index(); isIndex {
+			if node.Kind != yaml.SequenceNode || index >= len(node.Content) {
+				return nil, nil, fmt.Errorf("no node for %s", segment)
+			}
+			key = nil
+			node = node.Content[index]
+			continue
+		}
+		if node.Kind != yaml.MappingNode {
+			return nil, nil, fmt.Errorf("no node for %s", segment)
+		}
+		var found *yaml.Node
+		key, found = findMappingEntry(node, segment.key())
+		if found == nil {
+			return nil, nil, fmt.Errorf("no node for %s", segment)
+		}
+		node = found
+	}
+	return key, resolveNode(node), nil
+}
+
+// findMappingEntry returns the key and value nodes for key in a mapping node, or nils if it doesn't
+// have key.  A key that the mapping doesn't have itself is looked up in the mappings it merges with
+// "<<", in the order YAML gives them precedence, so the nodes returned may belong to an anchor.
+func findMappingEntry(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	var keyNode, valueNode *yaml.Node
+	merged := []*yaml.Node{}
+	for keyIndex := 0; keyIndex+1 < len(mapping.Content); keyIndex += 2 {
+		if isMergeKey(mapping.Content[keyIndex]) {
+			merged = append(merged, resolveNode(mapping.Content[keyIndex+1]))
+		} else if mapping.Content[keyIndex].Value == key {
+			keyNode = mapping.Content[keyIndex]
+			valueNode = mapping.Content[keyIndex+1]
+		}
+	}
+	if valueNode != nil {
+		return keyNode, valueNode
+	}
+
+	for _, merge := range merged {
+		//a merge is a mapping or a sequence of mappings, the first of which takes precedence
+		sources := []*yaml.Node{merge}
+		if merge.Kind == yaml.SequenceNode {
+			sources = merge.Content
+		}
+		for _, source := range sources {
+			source = resolveNode(source)
+			if source.Kind != yaml.MappingNode {
+				continue
+			}
+			keyNode, valueNode = findMappingEntry(source, key)
+			if valueNode != nil {
+				return keyNode, valueNode
+			}
+		}
+	}
+	return nil, nil
+}
+
+// untagMergeKeys clears the tag that the decoder gives the "<<" merge keys under node, which the
+// encoder would otherwise write out as "!!merge <<".  Without a tag, "<<" is still a merge key.
+func untagMergeKeys(node *yaml.Node) {
+	if node.Kind == yaml.MappingNode {
+		for keyIndex := 0; keyIndex+1 < len(node.Content); keyIndex += 2 {
+			if isMergeKey(node.Content[keyIndex]) && node.Content[keyIndex].Style&yaml.TaggedStyle == 0 {
+				node.Content[keyIndex].Tag = ""
+			}
+		}
+	}
+	for _, child := range node.Content {
+		untagMergeKeys(child)
+	}
+}
+
+// isMergeKey returns true if node is the "<<" key that merges other mappings into a mapping.  Like
+// the decoder, an untagged "<<" is a merge key, while a quoted one has the string tag.
+func isMergeKey(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Value == "<<" &&
+		(node.Tag == "" || node.Tag == "!" || node.ShortTag() == "!!merge")
+}
+
+// resolveNode skips the document node and follows aliases
+func resolveNode(node *yaml.Node) *yaml.Node {
+	for {
+		switch {
+		case node.Kind == yaml.DocumentNode && len(node.Content) > 0:
+			node = node.Content[0]
+		case node.Kind == yaml.AliasNode && node.Alias != nil:
+			node = node.Alias
+		default:
+			return node
+		}
+	}
+}
+
+// pathSegment is a field name, a sequence index like "[2]", or a map key like "[dns]"
+type pathSegment string
+
+func (s pathSegment) index() (int, bool) {
+	if !strings.HasPrefix(string(s), "[") || !strings.HasSuffix(string(s), "]") {
+		return 0, false
+	}
+	index, err := strconv.Atoi(string(s[1 : len(s)-1]))
+	if err != nil || index < 0 {
+		return 0, false
+	}
+	return index, true
+}
+
+// key returns the mapping key of the segment, which is a field name, or a map key like "[dns]"
+func (s pathSegment) key() string {
+	if strings.HasPrefix(string(s), "[") && strings.HasSuffix(string(s), "]") {
+		return string(s[1 : len(s)-1])
+	}
+	return string(s)
+}
+
+// splitPath splits a walker path like "mail.accounts[0].smtp" into "mail", "accounts", "[0]", "smtp"
+func splitPath(path string) []pathSegment {
+	segments := []pathSegment{}
+	for _, field := range strings.Split(path, ".") {
+		for field != "" {
+			bracket := strings.Index(field, "[")
+			if bracket == 0 {
+				end := strings.Index(field, "]")
+				if end < 0 {
+					end = len(field) - 1
+				}
+				segments = append(segments, pathSegment(field[:end+1]))
+				field = field[end+1:]
+				continue
+			}
+			if bracket < 0 {
+				bracket = len(field)
+			}
+			segments = append(segments, pathSegment(field[:bracket]))
+			field = field[bracket:]
+		}
+	}
+	return segments
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"varanus/internal/secrets"
+	"varanus/internal/util"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const DOCUMENT_TEST_YAML = `# accounts used by the monitors
+mail:
+  accounts:
+    - name: test1 # the primary account
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        # rotated every quarter
+        password: "it's a secret"
+    - name: test2
+      smtp:
+        sender_address: example2@example.com
+        server_address: smtp2.example.com
+        port: 4652
+        username: joeuser2@example.com
+        password: sealed(+aaaa==)
+monitoring:
+  email_monitors:
+    - from_account: test2
+      to_account: test1
+      test_period: 1h
+      notifications:
+        - mail: test1
+        - mail: test2
+`
+
+func TestConfigDocumentUpdateSealedItems(t *testing.T) {
+	document, err := ReadConfigDocument([]byte(DOCUMENT_TEST_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	require.Equal(t, "test1", document.Config.Mail.Accounts[0].Name)
+
+	//nothing changes if the sealed items were not changed
+	err = document.UpdateSealedItems()
+	require.Nil(t, err)
+	output, err := document.ToBytes()
+	require.Nil(t, err)
+	assert.Equal(t, DOCUMENT_TEST_YAML, string(output))
+
+	//only the changed scalars are rewritten
+	document.Config.Mail.Accounts[0].SMTP.Password = secrets.CreateUnsafeSealedItem("+bbbb==", true)
+	document.Config.Mail.Accounts[1].SMTP.Password = secrets.CreateUnsafeSealedItem("123", false)
+	err = document.UpdateSealedItems()
+	require.Nil(t, err)
+	output, err = document.ToBytes()
+	require.Nil(t, err)
+	expected := `# accounts used by the monitors
+mail:
+  accounts:
+    - name: test1 # the primary account
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        # rotated every quarter
+        password: "sealed(+bbbb==)"
+    - name: test2
+      smtp:
+        sender_address: example2@example.com
+        server_address: smtp2.example.com
+        port: 4652
+        username: joeuser2@example.com
+        password: "123"
+monitoring:
+  email_monitors:
+    - from_account: test2
+      to_account: test1
+      test_period: 1h
+      notifications:
+        - mail: test1
+        - mail: test2
+`
+	assert.Equal(t, expected, string(output))
+
+	//and the written file reads back to the same config
+	tempFile := util.CreateTempFileAndDir("test_output", "document.*.yaml")
+	tempFile.Close()
+	err = document.WriteToFile(tempFile.Name(), true)
+	require.Nil(t, err)
+	readBack, err := ReadConfigDocumentFromFile(tempFile.Name(), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Equal(t, document.Config, readBack.Config)
+
+	//refuses to overwrite without force
+	err = document.WriteToFile(tempFile.Name(), false)
+	assert.ErrorContains(t, err, "could not open file")
+}
+
+func TestConfigDocumentJson(t *testing.T) {
+	data, err := os.ReadFile("tests/example.json")
+	require.Nil(t, err)
+	document, err := ReadConfigDocument(data, CONFIG_FORMAT_JSON)
+	require.Nil(t, err)
+
+	document.Config.Mail.Accounts[1].SMTP.Password = secrets.CreateUnsafeSealedItem("+cccc==", true)
+	err = document.UpdateSealedItems()
+	require.Nil(t, err)
+	output, err := document.ToBytes()
+	require.Nil(t, err)
+
+	readBack, err := ReadConfigWithFormat(output, CONFIG_FORMAT_JSON)
+	require.Nil(t, err)
+	assert.Equal(t, document.Config, readBack)
+	assert.Equal(t, "sealed(+cccc==)", readBack.Mail.Accounts[1].SMTP.Password.GetValue())
+}
+
+const DOCUMENT_TEST_MERGE_YAML = `mail:
+  accounts:
+    - name: test1
+      smtp: &smtpdefaults
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        password: shared secret
+    - name: test2
+      smtp:
+        <<: *smtpdefaults
+        sender_address: example2@example.com
+    - name: test3
+      smtp:
+        <<: [{password: other secret}, *smtpdefaults]
+        sender_address: example3@example.com
+`
+
+func TestConfigDocumentMergeKeys(t *testing.T) {
+	document, err := ReadConfigDocument([]byte(DOCUMENT_TEST_MERGE_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	require.Equal(t, "shared secret", document.Config.Mail.Accounts[1].SMTP.Password.GetValue())
+
+	//a merged value is written to the anchored mapping, which keeps the merge for the others
+	document.Config.Mail.Accounts[0].SMTP.Password = secrets.CreateUnsafeSealedItem("+aaaa==", true)
+	document.Config.Mail.Accounts[1].SMTP.Password = secrets.CreateUnsafeSealedItem("+bbbb==", true)
+	document.Config.Mail.Accounts[2].SMTP.Password = secrets.CreateUnsafeSealedItem("+cccc==", true)
+	err = document.UpdateSealedItems()
+	require.Nil(t, err)
+	output, err := document.ToBytes()
+	require.Nil(t, err)
+	expected := strings.Replace(DOCUMENT_TEST_MERGE_YAML, "password: shared secret", "password: sealed(+bbbb==)", 1)
+	expected = strings.Replace(expected, "{password: other secret}", "{password: sealed(+cccc==)}", 1)
+	assert.Equal(t, expected, string(output))
+
+	readBack, err := ReadConfig(output)
+	require.Nil(t, err)
+	assert.Equal(t, "sealed(+bbbb==)", readBack.Mail.Accounts[0].SMTP.Password.GetValue())
+	assert.Equal(t, "sealed(+bbbb==)", readBack.Mail.Accounts[1].SMTP.Password.GetValue())
+	assert.Equal(t, "sealed(+cccc==)", readBack.Mail.Accounts[2].SMTP.Password.GetValue())
+
+	//fields that come from a merge are located in the anchored mapping
+	location, found := document.LocatePath("mail.accounts[1].smtp.username")
+	require.True(t, found)
+	assert.Equal(t, 8, location.Line)
+}
+
+func TestConfigDocumentMissingSealedItem(t *testing.T) {
+	data := "mail:\n  accounts:\n    - name: test1\n      smtp:\n        server_address: smtp.example.com\n"
+	document, err := ReadConfigDocument([]byte(data), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+
+	//an empty item that is not in the document is left out
+	err = document.UpdateSealedItems()
+	assert.Nil(t, err)
+
+	document.Config.Mail.Accounts[0].SMTP.Password = secrets.CreateUnsafeSealedItem("+aaaa==", true)
+	err = document.UpdateSealedItems()
+	assert.ErrorContains(t, err, "no node found for path mail.accounts[0].smtp.password")
+	//the value is a secret, so it isn't in the error
+	assert.NotContains(t, err.Error(), "+aaaa==")
+
+	_, err = ReadConfigDocument([]byte("mail: [\n"), CONFIG_FORMAT_YAML)
+	assert.NotNil(t, err)
+
+	_, err = ReadConfigDocumentFromFile("tests/does-not-exist.yaml", CONFIG_FORMAT_YAML)
+	assert.ErrorContains(t, err, "file read error for config file tests/does-not-exist.yaml")
+}
+
+func TestFindNodeByPath(t *testing.T) {
+	data := `a:
+  b:
+    - x: 1
+    - &anchor
+      y: two
+  c: *anchor
+`
+	root := yaml.Node{}
+	require.Nil(t, yaml.Unmarshal([]byte(data), &root))
+
+	type TestCase struct {
+		path  string
+		value string
+		error bool
+	}
+
+	testCases := []TestCase{
+		{"a.b[0].x", "1", false},
+		{"a.b[1].y", "two", false},
+		{"a.c.y", "two", false},
+		{"a.b[2].x", "", true},
+		{"a.b.x", "", true},
+		{"a.b[0].z", "", true},
+		{"a.b[0].x.y", "", true},
+		{"a.e", "", true},
+	}
+
+	for index, testCase := range testCases {
+		node, err := findNodeByPath(&root, testCase.path)
+		if testCase.error {
+			assert.ErrorContains(t, err, "no node found for path "+testCase.path, "for test %d", index)
+		} else {
+			require.Nil(t, err, "for test %d", index)
+			assert.Equal(t, testCase.value, node.Value, "for test %d", index)
+		}
+	}
+}
+
+func TestConfigDocumentLocatePath(t *testing.T) {
+	document, err := ReadConfigDocument([]byte(DOCUMENT_TEST_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	document.Filename = "config.yaml"
+
+	type TestCase struct {
+		path   string
+		line   int
+		column int
+	}
+
+	testCases := []TestCase{
+		//the top of the document
+		{"", 2, 1},
+		{"mail", 2, 1},
+		//sequence entries are located at their first key
+		{"mail.accounts[1]", 12, 7},
+		//fields are located at their key
+		{"mail.accounts[1].smtp", 13, 7},
+		{"mail.accounts[0].smtp.password", 11, 9},
+		//paths that are not in the document fall back to their nearest parent
+		{"mail.accounts[0].imap", 4, 7},
+		{"mail.send_limits", 2, 1},
+		{"monitoring.email_monitors[0].notifications[1]", 26, 11},
+	}
+
+	for index, testCase := range testCases {
+		location, found := document.LocatePath(testCase.path)
+		assert.True(t, found, "for test %d", index)
+		assert.Equal(t, validation.SourceLocation{Filename: "config.yaml", Line: testCase.line, Column: testCase.column}, location, "for test %d", index)
+	}
+
+	//an empty document can't locate anything
+	emptyDocument := ConfigDocument{}
+	_, found := emptyDocument.LocatePath("mail")
+	assert.False(t, found)
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFormat is the file format of a config
+type ConfigFormat string
+
+// CONFIG_FORMAT_YAML is the default config format
+const CONFIG_FORMAT_YAML ConfigFormat = "yaml"
+
+// CONFIG_FORMAT_JSON reads and writes configs as JSON, using the same field names as YAML
+const CONFIG_FORMAT_JSON ConfigFormat = "json"
+
+// SupportedConfigFormats lists the accepted config formats
+var SupportedConfigFormats = []ConfigFormat{CONFIG_FORMAT_YAML, CONFIG_FORMAT_JSON}
+
+// ConfigFormatFromFilename returns CONFIG_FORMAT_JSON for files with a .json extension and
+// CONFIG_FORMAT_YAML for anything else.
+func ConfigFormatFromFilename(filename string) ConfigFormat {
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		return CONFIG_FORMAT_JSON
+	}
+	return CONFIG_FORMAT_YAML
+}
+
+// ResolveConfigFormat returns the format named by format, or the format implied by the filename
+// extension if format is empty.
+func ResolveConfigFormat(format string, filename string) (ConfigFormat, error) {
+	if format == "" {
+		return ConfigFormatFromFilename(filename), nil
+	}
+	configFormat := ConfigFormat(strings.ToLower(format))
+	if !slices.Contains(SupportedConfigFormats, configFormat) {
+		return "", fmt.Errorf("unsupported config format '%s'; expected one of yaml, json", format)
+	}
+	return configFormat, nil
+}
+
+// objectToJson marshals object to indented JSON.  The object is marshaled to a YAML node tree first
+// so that the YAML field names, omitempty rules, and MarshalYAML methods are used for JSON too and
+// the field order matches the YAML output.
+func objectToJson(object interface{}) (string, error) {
+	var node yaml.Node
+	err := node.Encode(object)
+	if err != nil {
+		return "", err
+	}
+
+	var compact bytes.Buffer
+	err = writeNodeAsJson(&node, &compact)
+	if err != nil {
+		return "", err
+	}
+
+	var indented bytes.Buffer
+	err = json.Indent(&indented, compact.Bytes(), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	indented.WriteString("\n")
+	return indented.String(), nil
+}
+
+// writeNodeAsJson writes the YAML node tree as compact JSON, keeping the order of mapping keys
+func writeNodeAsJson(node *yaml.Node, buffer *bytes.Buffer) error {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			buffer.WriteString("null")
+			return nil
+		}
+		return writeNodeAsJson(node.Content[0], buffer)
+
+	case yaml.MappingNode:
+		buffer.WriteString("{")
+		for index := 0; index+1 < len(node.Content); index += 2 {
+			if index > 0 {
+				buffer.WriteString(",")
+			}
+			key, err := json.Marshal(node.Content[index].Value)
+			if err != nil {
+				return err
+			}
+			buffer.Write(key)
+			buffer.WriteString(":")
+			err = writeNodeAsJson(node.Content[index+1], buffer)
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteString("}")
+		return nil
+
+	case yaml.SequenceNode:
+		buffer.WriteString("[")
+		for index, item := range node.Content {
+			if index > 0 {
+				buffer.WriteString(",")
+			}
+			err := writeNodeAsJson(item, buffer)
+			if err != nil {
+				return err
+			}
+		}
+		buffer.WriteString("]")
+		return nil
+
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!null":
+			buffer.WriteString("null")
+			return nil
+		case "!!bool", "!!int", "!!float":
+			//decode through YAML so that the JSON form is canonical, e.g. 0x10 becomes 16
+			var value interface{}
+			err := node.Decode(&value)
+			if err != nil {
+				return err
+			}
+			scalar, err := json.Marshal(value)
+			if err != nil {
+				return fmt.Errorf("the value '%s' can't be represented in JSON: %w", node.Value, err)
+			}
+			buffer.Write(scalar)
+			return nil
+		default:
+			scalar, err := json.Marshal(node.Value)
+			if err != nil {
+				return err
+			}
+			buffer.Write(scalar)
+			return nil
+		}
+
+	default:
+		return fmt.Errorf("the YAML node at line %d can't be represented in JSON", node.Line)
+	}
+}
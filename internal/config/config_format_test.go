@@ -0,0 +1,211 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"varanus/internal/util"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigFormatFromFilename(t *testing.T) {
+	assert.Equal(t, CONFIG_FORMAT_JSON, ConfigFormatFromFilename("config.json"))
+	assert.Equal(t, CONFIG_FORMAT_JSON, ConfigFormatFromFilename("path/to/config.sealed.JSON"))
+	assert.Equal(t, CONFIG_FORMAT_YAML, ConfigFormatFromFilename("config.yaml"))
+	assert.Equal(t, CONFIG_FORMAT_YAML, ConfigFormatFromFilename("config.yml"))
+	assert.Equal(t, CONFIG_FORMAT_YAML, ConfigFormatFromFilename("config"))
+
+	format, err := ResolveConfigFormat("", "config.json")
+	assert.Nil(t, err)
+	assert.Equal(t, CONFIG_FORMAT_JSON, format)
+
+	format, err = ResolveConfigFormat("JSON", "config.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, CONFIG_FORMAT_JSON, format)
+
+	format, err = ResolveConfigFormat("yaml", "config.json")
+	assert.Nil(t, err)
+	assert.Equal(t, CONFIG_FORMAT_YAML, format)
+
+	_, err = ResolveConfigFormat("toml", "config.toml")
+	assert.ErrorContains(t, err, "unsupported config format 'toml'; expected one of yaml, json")
+}
+
+func TestReadJsonConfig(t *testing.T) {
+	yamlConfig, err := ReadConfigFromFile("tests/example.yaml")
+	require.Nil(t, err)
+
+	jsonConfig, err := ReadConfigFromFile("tests/example.json")
+	require.Nil(t, err)
+	assert.Equal(t, yamlConfig, jsonConfig)
+
+	validationResult, err := validation.ValidateObject(jsonConfig)
+	require.Nil(t, err)
+	assert.Equal(t, 0, validationResult.GetErrorCount())
+
+	//the format can be given explicitly
+	jsonData, err := os.ReadFile("tests/example.json")
+	require.Nil(t, err)
+	tempFile := util.CreateTempFileAndDir("test_output", "json_config.*.txt")
+	_, err = tempFile.Write(jsonData)
+	require.Nil(t, err)
+	tempFile.Close()
+	c, err := ReadConfigFromFileWithFormat(tempFile.Name(), CONFIG_FORMAT_JSON)
+	require.Nil(t, err)
+	assert.Equal(t, yamlConfig, c)
+}
+
+func TestReadJsonConfigErrors(t *testing.T) {
+	type TestCase struct {
+		data  string
+		error string
+	}
+
+	testCases := []TestCase{
+		{`{"mail": {"accounts": [], "unknown_field": 1}}`, "field unknown_field not found in type config.MailConfig"},
+		{`{"mail": {"accounts": []}`, "unmarshal error: invalid JSON: unexpected end of JSON input"},
+		{"mail:\n  accounts: []\n", "unmarshal error: invalid JSON: invalid character"},
+		{`{"mail": {"accounts": {}}}`, "cannot unmarshal !!map into []config.MailAccountConfig"},
+	}
+
+	for index, testCase := range testCases {
+		c, err := ReadConfigWithFormat([]byte(testCase.data), CONFIG_FORMAT_JSON)
+		assert.Nil(t, c, "for test %d", index)
+		assert.ErrorContains(t, err, testCase.error, "for test %d", index)
+	}
+}
+
+func TestWriteJsonConfig(t *testing.T) {
+	c, err := ReadConfigFromFile("tests/example.yaml")
+	require.Nil(t, err)
+
+	expectedOutput := `{
+  "mail": {
+    "accounts": [
+      {
+        "name": "test1",
+        "smtp": {
+          "sender_address": "example@example.com",
+          "server_address": "smtp.example.com",
+          "port": 465,
+          "use_tls": false,
+          "username": "joeuser@example.com",
+          "password": "it's a secret"
+        },
+        "imap": {
+          "recipient_address": "example@example.com",
+          "server_address": "imap.example.com",
+          "port": 993,
+          "use_tls": false,
+          "username": "janeuser@example.com",
+          "password": "sealed(+bbbbbb==)",
+          "mailbox_name": "INBOX"
+        }
+      },
+      {
+        "name": "test2",
+        "smtp": {
+          "sender_address": "example2@example.com",
+          "server_address": "smtp2.example.com",
+          "port": 4652,
+          "use_tls": false,
+          "username": "joeuser2@example.com",
+          "password": "it's a secret2"
+        }
+      }
+    ],
+    "send_limits": [
+      {
+        "min_period": "10m0s",
+        "account_names": [
+          "test1"
+        ]
+      }
+    ]
+  },
+  "monitoring": {
+    "email_monitors": [
+      {
+        "from_account": "test2",
+        "to_account": "test1",
+        "test_period": "1h0m0s",
+        "notifications": [
+          {
+            "mail": "test1"
+          },
+          {
+            "mail": "test2"
+          }
+        ]
+      }
+    ]
+  }
+}
+`
+
+	jsonOutput, err := c.ToJSON()
+	require.Nil(t, err)
+	assert.Equal(t, expectedOutput, jsonOutput)
+
+	//the .json extension selects JSON
+	tempFile := util.CreateTempFileAndDir("test_output", "output.*.json")
+	tempFile.Close()
+	err = c.WriteConfigToFile(tempFile.Name(), true)
+	require.Nil(t, err)
+	outputData, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, expectedOutput, string(outputData))
+
+	//and the written file reads back to the same config
+	readBack, err := ReadConfigFromFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.Equal(t, c, readBack)
+
+	//the format can be forced
+	err = c.WriteConfigToFileWithFormat(tempFile.Name(), CONFIG_FORMAT_YAML, true)
+	require.Nil(t, err)
+	outputData, err = os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	yamlOutput, err := c.ToYAML()
+	require.Nil(t, err)
+	assert.Equal(t, yamlOutput, string(outputData))
+}
+
+func TestObjectToJsonScalars(t *testing.T) {
+	type scalars struct {
+		Text    string             `yaml:"text"`
+		Number  int                `yaml:"number"`
+		Real    float64            `yaml:"real"`
+		Flag    bool               `yaml:"flag"`
+		Nothing *string            `yaml:"nothing"`
+		Quoted  string             `yaml:"quoted"`
+		Numeric string             `yaml:"numeric"`
+		Map     map[string]float64 `yaml:"map"`
+	}
+	jsonOutput, err := objectToJson(scalars{
+		Text:    "hello",
+		Number:  -12,
+		Real:    1.5,
+		Flag:    true,
+		Quoted:  "say \"hi\"",
+		Numeric: "123",
+		Map:     map[string]float64{},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, `{
+  "text": "hello",
+  "number": -12,
+  "real": 1.5,
+  "flag": true,
+  "nothing": null,
+  "quoted": "say \"hi\"",
+  "numeric": "123",
+  "map": {}
+}
+`, jsonOutput)
+
+	_, err = objectToJson(FailsYamlMarshal{"foobar"})
+	assert.ErrorContains(t, err, "intentional marshal failure")
+}
@@ -0,0 +1,298 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"varanus/internal/validation"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_DIR_PATTERNS are the fragments merged from a config directory, in filename order
+var CONFIG_DIR_PATTERNS = []string{"*.yaml", "*.yml", "*.json"}
+
+// ConfigSet is a config merged from several documents: a config file and the files it includes,
+// or the fragments of a config directory.  The lists of accounts, send limits and monitors are
+// appended in document order.  The other settings, like mail.proxy, may only be set by one
+// document.
+//
+// Each document keeps its own Config, so the fragments can be sealed and written back one at a
+// time.  Config is the merged copy that is validated and used, and accounts can extend templates
+// from any of the documents.
+type ConfigSet struct {
+	//Config is the merged config after the accounts have inherited from their templates
+	Config *VaranusConfig
+	//MergedConfig is the merged config before the templates are resolved
+	MergedConfig *VaranusConfig
+	//Documents are in the order they were merged; with includes, the including file is first
+	Documents []*ConfigDocument
+	//mappings from the paths of the merged config to the paths in the documents
+	mappings []pathMapping
+}
+
+// pathMapping maps a merged path, like "mail.accounts[3]", to the path of the same object in one
+// of the documents, like "mail.accounts[0]"
+type pathMapping struct {
+	mergedPath string
+	document   int
+	path       string
+}
+
+// ReadConfigSetFromFile reads the config at filename in the given format, along with the files it
+// includes.  A config without includes gives a set with a single document.
+func ReadConfigSetFromFile(filename string, format ConfigFormat) (*ConfigSet, error) {
+	documents, err := readIncludedDocuments(filename, format, []string{}, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	return mergeConfigDocuments(documents)
+}
+
+// ReadConfigSetFromDir reads and merges the config fragments in dir, which are the files matching
+// CONFIG_DIR_PATTERNS, and the files they include.
+func ReadConfigSetFromDir(dir string) (*ConfigSet, error) {
+	filenames := []string{}
+	for _, pattern := range CONFIG_DIR_PATTERNS {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			//unreachable because the patterns are fixed
+			return nil, fmt.Errorf("bad pattern %s: %w", pattern, err)
+		}
+		filenames = append(filenames, matches...)
+	}
+	if len(filenames) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return nil, fmt.Errorf("could not read config directory %s: %w", dir, err)
+		}
+		return nil, fmt.Errorf("config directory %s has no %s files", dir, strings.Join(CONFIG_DIR_PATTERNS, ", "))
+	}
+	slices.Sort(filenames)
+
+	documents := []*ConfigDocument{}
+	loaded := map[string]bool{}
+	for _, filename := range filenames {
+		fragmentDocuments, err := readIncludedDocuments(filename, ConfigFormatFromFilename(filename), []string{}, loaded)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, fragmentDocuments...)
+	}
+	return mergeConfigDocuments(documents)
+}
+
+// readIncludedDocuments reads the document at filename, followed by the documents it includes.
+// includeChain is the files that included this one, to detect cycles, and loaded is the files
+// already read, so a file included twice is only merged once.
+func readIncludedDocuments(filename string, format ConfigFormat, includeChain []string, loaded map[string]bool) ([]*ConfigDocument, error) {
+	absoluteFilename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, fmt.Errorf("could not find the path of %s: %w", filename, err)
+	}
+	if slices.Contains(includeChain, absoluteFilename) {
+		return nil, fmt.Errorf("include cycle: %s includes itself through %s", filename, strings.Join(includeChain, " -> "))
+	}
+	if loaded[absoluteFilename] {
+		return []*ConfigDocument{}, nil
+	}
+	loaded[absoluteFilename] = true
+
+	document, err := ReadConfigDocumentFromFile(filename, format)
+	if err != nil {
+		return nil, err
+	}
+	documents := []*ConfigDocument{document}
+
+	includeChain = append(includeChain, absoluteFilename)
+	for _, pattern := range document.Config.Include {
+		//includes are relative to the file that has them
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(filename), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad include pattern '%s' in %s: %w", pattern, filename, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			//a glob can match nothing, but a plain filename should exist
+			return nil, fmt.Errorf("included file %s from %s does not exist", pattern, filename)
+		}
+		for _, match := range matches {
+			includedDocuments, err := readIncludedDocuments(match, ConfigFormatFromFilename(match), includeChain, loaded)
+			if err != nil {
+				return nil, err
+			}
+			documents = append(documents, includedDocuments...)
+		}
+	}
+
+	return documents, nil
+}
+
+// mergeConfigDocuments merges the configs of the documents in order
+func mergeConfigDocuments(documents []*ConfigDocument) (*ConfigSet, error) {
+	set := &ConfigSet{
+		MergedConfig: &VaranusConfig{},
+		Documents:    documents,
+		mappings:     []pathMapping{},
+	}
+	merged := set.MergedConfig
+
+	//the document that set each single setting, for conflicts
+	settingSources := map[string]int{}
+	mergeSetting := func(documentIndex int, path string, isSet bool, apply func()) error {
+		if !isSet {
+			return nil
+		}
+		if firstIndex, found := settingSources[path]; found {
+			return fmt.Errorf("%s is set in both %s and %s", path,
+				documents[firstIndex].Filename, documents[documentIndex].Filename)
+		}
+		settingSources[path] = documentIndex
+		apply()
+		return nil
+	}
+
+	for documentIndex, document := range documents {
+		fragment := document.Config
+
+		for index, template := range fragment.Mail.Templates {
+			set.addMapping("mail.templates", len(merged.Mail.Templates), documentIndex, index)
+			merged.Mail.Templates = append(merged.Mail.Templates, template)
+		}
+		for index, account := range fragment.Mail.Accounts {
+			set.addMapping("mail.accounts", len(merged.Mail.Accounts), documentIndex, index)
+			merged.Mail.Accounts = append(merged.Mail.Accounts, account)
+			merged.accountSources = append(merged.accountSources, document.Filename)
+		}
+		for index, sendLimit := range fragment.Mail.SendLimits {
+			set.addMapping("mail.send_limits", len(merged.Mail.SendLimits), documentIndex, index)
+			merged.Mail.SendLimits = append(merged.Mail.SendLimits, sendLimit)
+		}
+		for index, monitor := range fragment.MonitoringConfig.EmailMonitors {
+			set.addMapping("monitoring.email_monitors", len(merged.MonitoringConfig.EmailMonitors), documentIndex, index)
+			merged.MonitoringConfig.EmailMonitors = append(merged.MonitoringConfig.EmailMonitors, monitor)
+		}
+		for index, monitor := range fragment.MonitoringConfig.Monitors {
+			set.addMapping("monitoring.monitors", len(merged.MonitoringConfig.Monitors), documentIndex, index)
+			merged.MonitoringConfig.Monitors = append(merged.MonitoringConfig.Monitors, monitor)
+		}
+
+		err := mergeSetting(documentIndex, "mail.proxy", fragment.Mail.Proxy != nil,
+			func() { merged.Mail.Proxy = fragment.Mail.Proxy })
+		if err == nil {
+			err = mergeSetting(documentIndex, "mail.auth_lockout", fragment.Mail.AuthLockout != nil,
+				func() { merged.Mail.AuthLockout = fragment.Mail.AuthLockout })
+		}
+		if err == nil {
+			err = mergeSetting(documentIndex, "force_failure", fragment.ForceFailure != nil,
+				func() { merged.ForceFailure = fragment.ForceFailure })
+		}
+		//each module config is a single setting, so it can only be in one document
+		moduleNames := []string{}
+		for name := range fragment.Modules {
+			moduleNames = append(moduleNames, name)
+		}
+		slices.Sort(moduleNames)
+		for _, name := range moduleNames {
+			if err == nil {
+				moduleConfig := fragment.Modules[name]
+				err = mergeSetting(documentIndex, "modules."+name, true, func() {
+					if merged.Modules == nil {
+						merged.Modules = ModuleConfigs{}
+					}
+					merged.Modules[name] = moduleConfig
+				})
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	//the unresolved references are reported at their merged paths
+	for documentIndex, document := range documents {
+		merged.migratedSources = append(merged.migratedSources, document.Config.migratedSources...)
+		for _, reference := range document.Config.unresolvedReferences {
+			reference.Path = set.mergedPath(documentIndex, reference.Path)
+			merged.unresolvedReferences = append(merged.unresolvedReferences, reference)
+		}
+	}
+
+	//the accounts that inherited from templates are normalized again, since their default ports
+	//are only filled in once they are resolved
+	resolved := merged.resolveTemplates(set.findNode)
+	err := validation.NormalizeObject(&resolved)
+	if err != nil {
+		return nil, err
+	}
+	set.Config = &resolved
+
+	return set, nil
+}
+
+// findNode returns the node for a path of the merged config, or nil if it isn't in a document
+func (s *ConfigSet) findNode(path string) *yaml.Node {
+	for _, mapping := range s.mappings {
+		if hasPathPrefix(path, mapping.mergedPath) {
+			node, err := findNodeByPath(&s.Documents[mapping.document].root, mapping.path+path[len(mapping.mergedPath):])
+			if err != nil {
+				return nil
+			}
+			return node
+		}
+	}
+	return nil
+}
+
+func (s *ConfigSet) addMapping(listPath string, mergedIndex int, documentIndex int, index int) {
+	s.mappings = append(s.mappings, pathMapping{
+		mergedPath: fmt.Sprintf("%s[%d]", listPath, mergedIndex),
+		document:   documentIndex,
+		path:       fmt.Sprintf("%s[%d]", listPath, index),
+	})
+}
+
+// mergedPath returns the merged path for a path in one of the documents
+func (s *ConfigSet) mergedPath(documentIndex int, path string) string {
+	for _, mapping := range s.mappings {
+		if mapping.document == documentIndex && hasPathPrefix(path, mapping.path) {
+			return mapping.mergedPath + path[len(mapping.path):]
+		}
+	}
+	return path
+}
+
+// hasPathPrefix returns true if path is prefix or is inside it, so "mail.accounts[1]" is a prefix
+// of "mail.accounts[1].smtp" but not of "mail.accounts[10]"
+func hasPathPrefix(path string, prefix string) bool {
+	if !strings.HasPrefix(path, prefix) {
+		return false
+	}
+	rest := path[len(prefix):]
+	return rest == "" || strings.HasPrefix(rest, ".") || strings.HasPrefix(rest, "[")
+}
+
+// LocatePath returns the source location of the object at a path of the merged config, in the
+// document it came from.  Paths that aren't in a merged list are located in the document that
+// has them, or the first document.
+//
+// Implements validation.SourceLocator
+func (s *ConfigSet) LocatePath(path string) (validation.SourceLocation, bool) {
+	for _, mapping := range s.mappings {
+		if hasPathPrefix(path, mapping.mergedPath) {
+			return s.Documents[mapping.document].LocatePath(mapping.path + path[len(mapping.mergedPath):])
+		}
+	}
+	for _, document := range s.Documents {
+		if _, _, err := findSegments(&document.root, splitPath(path)); err == nil {
+			return document.LocatePath(path)
+		}
+	}
+	if len(s.Documents) == 0 {
+		return validation.SourceLocation{}, false
+	}
+	return s.Documents[0].LocatePath(path)
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const SET_TEST_ACCOUNTS_YAML = `mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        password: it's a secret
+    - name: test2
+      smtp:
+        sender_address: example2@example.com
+        server_address: smtp2.example.com
+        port: 465
+        username: joeuser2@example.com
+        password: it's a secret2
+`
+
+const SET_TEST_MONITORS_YAML = `# the monitors and the account they send to
+mail:
+  accounts:
+    - name: test3
+      imap:
+        recipient_address: example@example.com
+        server_address: imap.example.com
+        port: 993
+        username: janeuser@example.com
+        password: it's a secret3
+        mailbox_name: INBOX
+  send_limits:
+    - min_period: 10m
+      account_names:
+        - test1
+monitoring:
+  email_monitors:
+    - from_account: test1
+      to_account: test3
+      test_period: 1h
+      notifications:
+        - mail: test2
+`
+
+// writeConfigFiles writes files, keyed by their names, to a new directory under test_output and
+// returns the directory
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	err := os.MkdirAll("test_output", 0744)
+	require.Nil(t, err)
+	dir, err := os.MkdirTemp("test_output", "config_set.*")
+	require.Nil(t, err)
+	for name, contents := range files {
+		filename := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(filename), 0744)
+		require.Nil(t, err)
+		err = os.WriteFile(filename, []byte(contents), 0600)
+		require.Nil(t, err)
+	}
+	return dir
+}
+
+func TestReadConfigSetFromDir(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"10-accounts.yaml": SET_TEST_ACCOUNTS_YAML,
+		"20-monitors.yaml": SET_TEST_MONITORS_YAML,
+		"README.md":        "not a config",
+	})
+
+	set, err := ReadConfigSetFromDir(dir)
+	require.Nil(t, err)
+	require.Len(t, set.Documents, 2)
+	assert.Equal(t, filepath.Join(dir, "10-accounts.yaml"), set.Documents[0].Filename)
+	assert.Equal(t, filepath.Join(dir, "20-monitors.yaml"), set.Documents[1].Filename)
+
+	//the lists are appended in file order
+	config := set.Config
+	require.Len(t, config.Mail.Accounts, 3)
+	assert.Equal(t, "test1", config.Mail.Accounts[0].Name)
+	assert.Equal(t, "test3", config.Mail.Accounts[2].Name)
+	assert.Len(t, config.Mail.SendLimits, 1)
+	assert.Len(t, config.MonitoringConfig.EmailMonitors, 1)
+
+	validationResult, err := validation.ValidateObject(config)
+	require.Nil(t, err)
+	assert.Equal(t, 0, validationResult.GetErrorCount())
+
+	//merged paths are located in the fragment they came from
+	location, found := set.LocatePath("mail.accounts[2].imap.port")
+	assert.True(t, found)
+	assert.Equal(t, validation.SourceLocation{Filename: set.Documents[1].Filename, Line: 8, Column: 9}, location)
+	location, found = set.LocatePath("mail.accounts[1]")
+	assert.True(t, found)
+	assert.Equal(t, validation.SourceLocation{Filename: set.Documents[0].Filename, Line: 10, Column: 7}, location)
+	location, found = set.LocatePath("monitoring.email_monitors[0].to_account")
+	assert.True(t, found)
+	assert.Equal(t, validation.SourceLocation{Filename: set.Documents[1].Filename, Line: 19, Column: 7}, location)
+	location, found = set.LocatePath("monitoring")
+	assert.True(t, found)
+	assert.Equal(t, validation.SourceLocation{Filename: set.Documents[1].Filename, Line: 16, Column: 1}, location)
+
+	//each fragment keeps its own config
+	assert.Len(t, set.Documents[0].Config.Mail.Accounts, 2)
+	assert.Len(t, set.Documents[1].Config.Mail.Accounts, 1)
+
+	//directories without fragments are errors
+	_, err = ReadConfigSetFromDir(filepath.Join(dir, "missing"))
+	assert.ErrorContains(t, err, "could not read config directory")
+	emptyDir := writeConfigFiles(t, map[string]string{})
+	_, err = ReadConfigSetFromDir(emptyDir)
+	assert.ErrorContains(t, err, "has no *.yaml, *.yml, *.json files")
+}
+
+func TestReadConfigSetIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"varanus.yaml":             "include:\n  - teams/*.yaml\n  - monitors.yaml\n",
+		"teams/accounts.yaml":      SET_TEST_ACCOUNTS_YAML,
+		"monitors.yaml":            SET_TEST_MONITORS_YAML,
+		"unrelated/ignored.yaml":   "not: [valid",
+		"cycle.yaml":               "include: [cycle-back.yaml]\n",
+		"cycle-back.yaml":          "include: [cycle.yaml]\n",
+		"missing.yaml":             "include: [does-not-exist.yaml]\n",
+		"empty-glob.yaml":          "include: [does-not-exist/*.yaml]\n",
+		"twice.yaml":               "include: [monitors.yaml, '*monitors.yaml']\n",
+		"proxy1.yaml":              "include: [proxy2.yaml]\nmail:\n  proxy:\n    url: socks5://proxy1:1080\n",
+		"proxy2.yaml":              "mail:\n  proxy:\n    url: socks5://proxy2:1080\n",
+		"duplicates.yaml":          "include: [teams/accounts.yaml, extra/more-accounts.yaml]\n",
+		"extra/more-accounts.yaml": "mail:\n  accounts:\n    - name: test2\n",
+		"unresolved.yaml":          "include: [teams/accounts.yaml, unresolved-include.yaml]\n",
+		"unresolved-include.yaml":  "interpolate: true\nmail:\n  accounts:\n    - name: ${VARANUS_TEST_UNSET}\n",
+	})
+	os.Unsetenv("VARANUS_TEST_UNSET")
+
+	set, err := ReadConfigSetFromFile(filepath.Join(dir, "varanus.yaml"), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	require.Len(t, set.Documents, 3)
+	assert.Equal(t, filepath.Join(dir, "teams", "accounts.yaml"), set.Documents[1].Filename)
+	assert.Equal(t, filepath.Join(dir, "monitors.yaml"), set.Documents[2].Filename)
+	assert.Len(t, set.Config.Mail.Accounts, 3)
+	assert.Nil(t, set.Config.Include)
+
+	_, err = ReadConfigSetFromFile(filepath.Join(dir, "cycle.yaml"), CONFIG_FORMAT_YAML)
+	assert.ErrorContains(t, err, "include cycle")
+
+	_, err = ReadConfigSetFromFile(filepath.Join(dir, "missing.yaml"), CONFIG_FORMAT_YAML)
+	assert.ErrorContains(t, err, "does-not-exist.yaml from "+filepath.Join(dir, "missing.yaml")+" does not exist")
+
+	set, err = ReadConfigSetFromFile(filepath.Join(dir, "empty-glob.yaml"), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Len(t, set.Documents, 1)
+
+	//a file included twice is only merged once
+	set, err = ReadConfigSetFromFile(filepath.Join(dir, "twice.yaml"), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Len(t, set.Documents, 2)
+
+	//settings that aren't lists can only be set once
+	_, err = ReadConfigSetFromFile(filepath.Join(dir, "proxy1.yaml"), CONFIG_FORMAT_YAML)
+	assert.ErrorContains(t, err, "mail.proxy is set in both "+
+		filepath.Join(dir, "proxy1.yaml")+" and "+filepath.Join(dir, "proxy2.yaml"))
+
+	//duplicate names are reported with both files
+	set, err = ReadConfigSetFromFile(filepath.Join(dir, "duplicates.yaml"), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	validationResult, err := validation.ValidateObject(set.Config)
+	require.Nil(t, err)
+	assert.Contains(t, validationResult.HumanReadable(), "duplicate account name 'test2' in "+
+		filepath.Join(dir, "teams", "accounts.yaml")+" and "+filepath.Join(dir, "extra", "more-accounts.yaml"))
+
+	//unresolved references are reported at their merged paths
+	set, err = ReadConfigSetFromFile(filepath.Join(dir, "unresolved.yaml"), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	require.Len(t, set.Config.GetUnresolvedReferences(), 1)
+	assert.Equal(t, "mail.accounts[2].name", set.Config.GetUnresolvedReferences()[0].Path)
+	location, found := set.LocatePath("mail.accounts[2].name")
+	assert.True(t, found)
+	assert.Equal(t, validation.SourceLocation{Filename: set.Documents[2].Filename, Line: 4, Column: 7}, location)
+}
@@ -0,0 +1,268 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"varanus/internal/walker"
+)
+
+// The config reference is generated from the config types so that it can't drift from them.  Each
+// yaml field has these struct tags:
+//
+//	doc      a sentence describing the field, required
+//	default  the value used when the field is left out, if there is one; a default that copies a
+//	         constant, like a port, is checked against it by the tests
+//	example  an example value
+//
+// Whether a field is required follows the same rules as the JSON Schema, see schema.go, and sealed
+// items are sealable.
+
+// DOC_TAG, DEFAULT_TAG and EXAMPLE_TAG are the struct tags that document the config fields
+const (
+	DOC_TAG     = "doc"
+	DEFAULT_TAG = "default"
+	EXAMPLE_TAG = "example"
+)
+
+// configTypeDocs describe each config type at the top of its section
+var configTypeDocs = map[reflect.Type]string{
+	reflect.TypeOf(VaranusConfig{}):       "The top level of a config file.",
+	reflect.TypeOf(MailConfig{}):          "The mail accounts and how they are used.",
+	reflect.TypeOf(MailAccountTemplate{}): "Settings shared by the accounts that extend the template, like the servers of a provider.  It has the same fields as an account.",
+	reflect.TypeOf(MailAccountConfig{}):   "A mail account that sends or receives the test emails and notifications.",
+	reflect.TypeOf(SMTPConfig{}):          "The SMTP server that an account sends email with.",
+	reflect.TypeOf(IMAPConfig{}):          "The IMAP server that an account receives email with.",
+	reflect.TypeOf(ProxyConfig{}):         "A proxy that the SMTP and IMAP connections are made through.",
+	reflect.TypeOf(SendLimitConfig{}):     "A limit on how often a group of accounts sends email, for providers that limit sending.",
+	reflect.TypeOf(AuthLockoutConfig{}):   "How logins are suspended after an account's credentials are rejected, so that the provider doesn't lock the account.",
+	reflect.TypeOf(MonitorConfig{}):       "The monitors that test the accounts.",
+	reflect.TypeOf(EmailMonitorConfig{}):  "A monitor that sends a test email from one account to another and checks that it arrives.",
+	reflect.TypeOf(NotificationConfig{}):  "Where a notification is sent when a monitor fails.",
+	reflect.TypeOf(TypedMonitorConfig{}):  "A monitor of a type registered by a module.  Its other fields are the fields of that type, which are described in their own sections.  These monitors are checked with the config, but they are not run yet.",
+}
+
+// configReferenceSection documents one config type
+type configReferenceSection struct {
+	configType reflect.Type
+	//paths are where the type is used in a config, like "mail.accounts[]"
+	paths []string
+}
+
+// GenerateConfigReference returns a Markdown reference of every config field, with a section for
+// each config type in the order they are first used.
+func GenerateConfigReference() string {
+	sections := []*configReferenceSection{}
+	sectionsByType := map[reflect.Type]*configReferenceSection{}
+
+	var addSection func(configType reflect.Type, path string)
+	addSection = func(configType reflect.Type, path string) {
+		if section, found := sectionsByType[configType]; found {
+			section.paths = append(section.paths, path)
+			return
+		}
+		section := &configReferenceSection{configType: configType, paths: []string{path}}
+		sections = append(sections, section)
+		sectionsByType[configType] = section
+
+		for index := 0; index < configType.NumField(); index++ {
+			field := configType.Field(index)
+			key := walker.GetYamlNameFromTag(field.Tag)
+			if !field.IsExported() || key == "" || key == "-" {
+				continue
+			}
+			if fieldType, isStruct := documentedStructType(field.Type); isStruct {
+				fieldPath := key
+				if path != "" {
+					fieldPath = path + "." + key
+				}
+				if isListType(field.Type) {
+					fieldPath += "[]"
+				}
+				addSection(fieldType, fieldPath)
+			}
+		}
+	}
+	addSection(reflect.TypeOf(VaranusConfig{}), "")
+	//the registered types aren't fields of the config types, so they are added after them
+	for _, name := range RegisteredModuleConfigs() {
+		addSection(moduleConfigRegistry.configType(name), "modules."+name)
+	}
+	for _, name := range RegisteredMonitorTypes() {
+		addSection(monitorTypeRegistry.configType(name), "monitoring.monitors[]")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintln(&sb, "# Varanus config reference")
+	fmt.Fprintln(&sb)
+	fmt.Fprintln(&sb, "This reference is generated by `varanus config docs` from the config types.  Configs can be")
+	fmt.Fprintln(&sb, "written in YAML or JSON with the same field names.  Sealable fields can be sealed with")
+	fmt.Fprintln(&sb, "`varanus config seal`.  `varanus config check` checks a config against these rules and the")
+	fmt.Fprintln(&sb, "references between its objects, like account names.")
+	for _, section := range sections {
+		section.write(&sb)
+	}
+	return sb.String()
+}
+
+// WriteConfigReferenceToFile writes the config reference to filename.  If forceOverwrite is False,
+// then an error will occur if the file already exists.
+func WriteConfigReferenceToFile(filename string, forceOverwrite bool) error {
+	return writeBytesToFile([]byte(GenerateConfigReference()), filename, forceOverwrite)
+}
+
+func (s *configReferenceSection) write(sb *strings.Builder) {
+	partial := slices.Contains(schemaPartialTypes, s.configType)
+
+	rows := []string{}
+	requiredKeys := []string{}
+	for index := 0; index < s.configType.NumField(); index++ {
+		field := s.configType.Field(index)
+		key := walker.GetYamlNameFromTag(field.Tag)
+		if !field.IsExported() || key == "" || key == "-" {
+			continue
+		}
+		required := isRequiredField(field) && (!partial || slices.Contains(TEMPLATE_OWN_FIELDS, key))
+		if required {
+			requiredKeys = append(requiredKeys, key)
+		}
+		sealable := baseType(field.Type) == sealedItemType
+		rows = append(rows, fmt.Sprintf("| `%s` | %s | %s | %s | %s | %s | %s |",
+			key,
+			describeFieldType(field.Type),
+			yesNo(required),
+			markdownCell(field.Tag.Get(DEFAULT_TAG), false),
+			markdownCell(field.Tag.Get(EXAMPLE_TAG), true),
+			yesNo(sealable),
+			markdownCell(field.Tag.Get(DOC_TAG), false),
+		))
+	}
+
+	fmt.Fprintln(sb)
+	fmt.Fprintf(sb, "## %s\n", s.configType.Name())
+	fmt.Fprintln(sb)
+	fmt.Fprintln(sb, configTypeDoc(s.configType))
+	if s.paths[0] != "" {
+		usedAt := []string{}
+		for _, path := range s.paths {
+			usedAt = append(usedAt, "`"+path+"`")
+		}
+		fmt.Fprintln(sb)
+		fmt.Fprintf(sb, "Used at %s.\n", strings.Join(usedAt, ", "))
+	}
+	if partial {
+		fmt.Fprintln(sb)
+		fmt.Fprintf(sb, "Only %s is required, because the accounts that extend a template are checked after they inherit from it.\n",
+			markdownCodeList(requiredKeys))
+	} else if hasYamlField(s.configType, JSON_SCHEMA_EXTENDS_FIELD) {
+		fmt.Fprintln(sb)
+		fmt.Fprintf(sb, "An object that sets `%s` only needs the required fields that its template doesn't set, including the fields of its nested objects.\n",
+			JSON_SCHEMA_EXTENDS_FIELD)
+	}
+	fmt.Fprintln(sb)
+	fmt.Fprintln(sb, "| Field | Type | Required | Default | Example | Sealable | Description |")
+	fmt.Fprintln(sb, "| --- | --- | --- | --- | --- | --- | --- |")
+	for _, row := range rows {
+		fmt.Fprintln(sb, row)
+	}
+}
+
+// configTypeDoc returns the description of a config type, which for a registered type is the
+// description it was registered with
+func configTypeDoc(t reflect.Type) string {
+	if doc, found := configTypeDocs[t]; found {
+		return doc
+	}
+	for _, name := range RegisteredModuleConfigs() {
+		if moduleConfigRegistry.configType(name) == t {
+			return moduleConfigRegistry.entries[name].description
+		}
+	}
+	for _, name := range RegisteredMonitorTypes() {
+		if monitorTypeRegistry.configType(name) == t {
+			return fmt.Sprintf("%s  Its `%s` is `%s`.", monitorTypeRegistry.entries[name].description, MONITOR_TYPE_KEY, name)
+		}
+	}
+	return ""
+}
+
+// describeFieldType returns the type of a field for the reference, linking to config types
+func describeFieldType(t reflect.Type) string {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		return describeFieldType(t.Elem())
+	case t == durationType:
+		return "duration"
+	case t == sealedItemType || t == forceConfigFailureType:
+		return "string"
+	case t == moduleConfigsType:
+		return "mapping of module names to their configs"
+	case isListType(t):
+		return "list of " + describeFieldType(t.Elem())
+	}
+	if enum, isEnum := schemaEnums[t]; isEnum {
+		return "one of " + markdownCodeList(enum)
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		return fmt.Sprintf("[%s](#%s)", t.Name(), strings.ToLower(t.Name()))
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return t.Kind().String()
+}
+
+// documentedStructType returns the config type for a field that is an object or a list of
+// objects, which gets its own section
+func documentedStructType(t reflect.Type) (reflect.Type, bool) {
+	t = baseType(t)
+	if t.Kind() != reflect.Struct || t == durationType || t == sealedItemType || t == forceConfigFailureType {
+		return nil, false
+	}
+	return t, true
+}
+
+// baseType returns the type of a field without pointers and lists
+func baseType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Pointer || isListType(t) {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isListType(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+}
+
+func yesNo(value bool) string {
+	if value {
+		return "yes"
+	}
+	return "no"
+}
+
+// markdownCell escapes text for a table cell, as code if asCode is set
+func markdownCell(text string, asCode bool) string {
+	if text == "" {
+		return ""
+	}
+	text = strings.ReplaceAll(text, "|", `\|`)
+	if asCode {
+		return "`" + text + "`"
+	}
+	return text
+}
+
+func markdownCodeList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, "`"+value+"`")
+	}
+	return strings.Join(quoted, ", ")
+}
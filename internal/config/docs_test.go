@@ -0,0 +1,130 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"varanus/internal/walker"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFieldsAreDocumented(t *testing.T) {
+	//every section has a description, and every field of every config type has a doc tag
+	checked := map[reflect.Type]bool{}
+	var checkType func(configType reflect.Type)
+	checkType = func(configType reflect.Type) {
+		if checked[configType] {
+			return
+		}
+		checked[configType] = true
+		assert.NotEmpty(t, configTypeDocs[configType], "for %s", configType.Name())
+
+		for index := 0; index < configType.NumField(); index++ {
+			field := configType.Field(index)
+			key := walker.GetYamlNameFromTag(field.Tag)
+			if key == "" {
+				continue
+			}
+			assert.NotEmpty(t, field.Tag.Get(DOC_TAG), "for %s.%s", configType.Name(), key)
+			if fieldType, isStruct := documentedStructType(field.Type); isStruct {
+				checkType(fieldType)
+			}
+		}
+	}
+	checkType(reflect.TypeOf(VaranusConfig{}))
+	assert.Len(t, checked, len(configTypeDocs))
+}
+
+func TestGenerateConfigReference(t *testing.T) {
+	reference := GenerateConfigReference()
+
+	assert.True(t, strings.HasPrefix(reference, "# Varanus config reference\n"))
+
+	expectedLines := []string{
+		"## VaranusConfig\n\nThe top level of a config file.\n\n| Field |",
+		"## SMTPConfig\n\nThe SMTP server that an account sends email with.\n\nUsed at `mail.templates[].smtp`, `mail.accounts[].smtp`.\n",
+		"## ProxyConfig\n\nA proxy that the SMTP and IMAP connections are made through.\n\nUsed at `mail.templates[].proxy`, `mail.accounts[].proxy`, `mail.proxy`.\n",
+		"| `recipient_address` | string | yes |  | `monitor@example.org` | no | The email address the test emails are sent to |\n",
+		"| `mailbox_name` | string | yes |  | `INBOX` | no | The mailbox that the test emails are received in |\n",
+		"| `password` | string | yes |  | `sealed(...)` | yes | The password to log in to the SMTP server |\n",
+		"| `password` | string | no | no login | `sealed(...)` | yes | The password to log in to the proxy |\n",
+		"| `test_period` | duration | yes |  | `1h` | no | How often the test email is sent |\n",
+		"| `address_family` | one of `any`, `ipv4`, `ipv6` | no | any | `ipv4` | no |",
+		"| `accounts` | list of [MailAccountConfig](#mailaccountconfig) | no |",
+		"Only `name` is required, because the accounts that extend a template are checked after they inherit from it.\n",
+	}
+	for _, expected := range expectedLines {
+		assert.Contains(t, reference, expected)
+	}
+
+	//each type, and each registered type, has one section
+	assert.Equal(t, 1, strings.Count(reference, "## SMTPConfig\n"))
+	registeredCount := len(RegisteredModuleConfigs()) + len(RegisteredMonitorTypes())
+	assert.Equal(t, len(configTypeDocs)+registeredCount, strings.Count(reference, "\n## "))
+}
+
+// durationTag formats a duration the way the default tags do, like "1h" rather than "1h0m0s"
+func durationTag(duration time.Duration) string {
+	text := duration.String()
+	if strings.HasSuffix(text, "m0s") {
+		text = strings.TrimSuffix(text, "0s")
+	}
+	if strings.HasSuffix(text, "h0m") {
+		text = strings.TrimSuffix(text, "0m")
+	}
+	return text
+}
+
+func TestDefaultTagsMatchConstants(t *testing.T) {
+	//the default tags are hand-written, so the ones that describe a constant are checked against it
+	fromConstants := map[string]string{
+		"VaranusConfig.version":            fmt.Sprint(FIRST_CONFIG_VERSION),
+		"MailConfig.auth_lockout":          fmt.Sprintf("failure_threshold %d, cool_down %s", DEFAULT_AUTH_FAILURE_THRESHOLD, durationTag(DEFAULT_AUTH_COOL_DOWN)),
+		"MailAccountConfig.address_family": string(ADDRESS_FAMILY_ANY),
+		"SMTPConfig.port":                  fmt.Sprintf("%d with use_tls, otherwise %d", DEFAULT_SMTP_TLS_PORT, DEFAULT_SMTP_PORT),
+		"IMAPConfig.port":                  fmt.Sprintf("%d with use_tls, otherwise %d", DEFAULT_IMAP_TLS_PORT, DEFAULT_IMAP_PORT),
+	}
+	//the other default tags describe what happens without the field rather than a value
+	descriptions := []string{"false", "direct connections", "mail.proxy", "chosen by the system", "no login", "one test"}
+
+	checked := map[reflect.Type]bool{}
+	found := map[string]bool{}
+	var checkType func(configType reflect.Type)
+	checkType = func(configType reflect.Type) {
+		if checked[configType] {
+			return
+		}
+		checked[configType] = true
+
+		for index := 0; index < configType.NumField(); index++ {
+			field := configType.Field(index)
+			key := walker.GetYamlNameFromTag(field.Tag)
+			if key == "" {
+				continue
+			}
+			if tag, hasDefault := field.Tag.Lookup(DEFAULT_TAG); hasDefault {
+				typeName := configType.Name()
+				if configType == reflect.TypeOf(MailAccountTemplate{}) {
+					//a template has the fields of an account
+					typeName = reflect.TypeOf(MailAccountConfig{}).Name()
+				}
+				name := typeName + "." + key
+				if expected, fromConstant := fromConstants[name]; fromConstant {
+					found[name] = true
+					assert.Equal(t, expected, tag, "for %s", name)
+				} else {
+					assert.Contains(t, descriptions, tag, "for %s, which needs to be checked against its constant", name)
+				}
+			}
+			if fieldType, isStruct := documentedStructType(field.Type); isStruct {
+				checkType(fieldType)
+			}
+		}
+	}
+	checkType(reflect.TypeOf(VaranusConfig{}))
+	assert.Len(t, found, len(fromConstants))
+	assert.Equal(t, "1h30m", durationTag(90*time.Minute))
+}
@@ -1,73 +1,69 @@
 package config
 
 import (
+	"strings"
 	"time"
 	"varanus/internal/validation"
 )
 
 type EmailMonitorConfig struct {
-	FromAccount   string               `yaml:"from_account"`
-	ToAccount     string               `yaml:"to_account"`
-	TestPeriod    time.Duration        `yaml:"test_period"`
-	Notifications []NotificationConfig `yaml:"notifications"`
+	FromAccount   string               `yaml:"from_account" doc:"The name of the account that sends the test email; it must have smtp settings" example:"work-account" validate:"required" message:"from_account must not be empty"`
+	ToAccount     string               `yaml:"to_account" doc:"The name of the account that receives the test email; it must have imap settings" example:"home-account" validate:"required" message:"to_account must not be empty"`
+	TestPeriod    time.Duration        `yaml:"test_period" doc:"How often the test email is sent" example:"1h" validate:"positive" message:"test_period must be a positive value, not '%d'"`
+	Notifications []NotificationConfig `yaml:"notifications" doc:"Where to send a notification when the test fails"`
+	//if set, one probe is run for each address family instead of a single probe using the
+	//address_family of each account
+	AddressFamilies []AddressFamily `yaml:"address_families,omitempty" doc:"If set, a test is run for each address family instead of one test with the address_family of each account" default:"one test" example:"[ipv4, ipv6]"`
+}
+
+// GetProbeAddressFamilies returns the address family of each probe the monitor runs.  If the
+// monitor doesn't list any address families, a single probe is run with an empty address family,
+// which means each account uses its own address_family setting.
+func (c EmailMonitorConfig) GetProbeAddressFamilies() []AddressFamily {
+	if len(c.AddressFamilies) == 0 {
+		return []AddressFamily{""}
+	}
+	return c.AddressFamilies
+}
+
+// Normalize trims the account names and lowercases the address families
+func (c *EmailMonitorConfig) Normalize() {
+	c.FromAccount = strings.TrimSpace(c.FromAccount)
+	c.ToAccount = strings.TrimSpace(c.ToAccount)
+	for index := range c.AddressFamilies {
+		c.AddressFamilies[index] = c.AddressFamilies[index].normalized()
+	}
 }
 
 func (c EmailMonitorConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
 
+	//check the rules of the validate tags
+	err := validation.CheckFieldRules(vet, c)
+	if err != nil {
+		return err
+	}
+
 	vConfig := castInterfaceToVaranusConfig(root)
 
-	if len(c.FromAccount) == 0 {
-		vet.AddValidationError(
-			c,
-			"from_account must not be empty",
-		)
-	} else { //else avoids a double validation error from an empty string
-		account := vConfig.Mail.GetAccountByName(c.FromAccount)
-		if account == nil {
+	AccountRef{Description: "from_account", Name: c.FromAccount, Needs: ACCOUNT_CAPABILITY_SMTP}.Validate(vet, c, vConfig.Mail)
+	AccountRef{Description: "to_account", Name: c.ToAccount, Needs: ACCOUNT_CAPABILITY_IMAP}.Validate(vet, c, vConfig.Mail)
+
+	familiesInUse := map[AddressFamily]bool{}
+	for _, family := range c.AddressFamilies {
+		if !family.IsSupported() {
 			vet.AddValidationError(
 				c,
-				"from_account named '%s' does not exist", c.FromAccount,
+				"address_families entry '%s' is not supported; expected one of %s",
+				family, describeSupportedAddressFamilies(),
 			)
-		} else {
-			//only check for SMTP if we can get the account
-			if account.SMTP == nil {
-				vet.AddValidationError(
-					c,
-					"from_account named '%s' must have an SMTP configuration", c.FromAccount,
-				)
-			}
-
 		}
-	}
-
-	if len(c.ToAccount) == 0 {
-		vet.AddValidationError(
-			c,
-			"to_account must not be empty",
-		)
-	} else { //else avoids a double validation error from an empty string
-		account := vConfig.Mail.GetAccountByName(c.ToAccount)
-		if account == nil {
+		if familiesInUse[family.OrAny()] {
 			vet.AddValidationError(
 				c,
-				"to_account named '%s' does not exist", c.ToAccount,
+				"address_families lists '%s' more than once", family.OrAny(),
 			)
-		} else {
-			//only check for IMAP if we can get the account
-			if account.IMAP == nil {
-				vet.AddValidationError(
-					c,
-					"to_account named '%s' must have an IMAP configuration", c.ToAccount,
-				)
-			}
 		}
-	}
-
-	if c.TestPeriod.Nanoseconds() <= 0 {
-		vet.AddValidationError(
-			c,
-			"test_period must be a positive value, not '%d'", c.TestPeriod,
-		)
+		familiesInUse[family.OrAny()] = true
 	}
 
 	if len(c.Notifications) == 0 {
@@ -39,6 +39,13 @@ func TestEmailMonitorConfigValidation(t *testing.T) {
 			Mutator: func(c *EmailMonitorConfig) { c.Notifications = []NotificationConfig{{"test1"}, {"test2"}} },
 			Error:   "",
 		},
+		{
+			//should accept one probe per address family
+			Mutator: func(c *EmailMonitorConfig) {
+				c.AddressFamilies = []AddressFamily{ADDRESS_FAMILY_IPV4, ADDRESS_FAMILY_IPV6}
+			},
+			Error: "",
+		},
 	}
 	errorTestCases := []TestCase{
 		{
@@ -63,7 +70,16 @@ func TestEmailMonitorConfigValidation(t *testing.T) {
 		},
 		{
 			Mutator: func(c *EmailMonitorConfig) { c.FromAccount = "test2" },
-			Error:   "from_account named 'test2' must have an SMTP configuration",
+			Error:   "from_account named 'test2' must have an SMTP configuration; did you mean 'test1' or 'test3'?",
+		},
+		{
+			//only the accounts with an SMTP configuration are suggested
+			Mutator: func(c *EmailMonitorConfig) { c.FromAccount = "tset2" },
+			Error:   "from_account named 'tset2' does not exist; did you mean 'test1' or 'test3'?",
+		},
+		{
+			Mutator: func(c *EmailMonitorConfig) { c.ToAccount = "Test2" },
+			Error:   "to_account named 'Test2' does not exist; did you mean 'test2' or 'test3'?",
 		},
 		{
 			Mutator: func(c *EmailMonitorConfig) { c.TestPeriod = time.Duration(-1) },
@@ -73,6 +89,20 @@ func TestEmailMonitorConfigValidation(t *testing.T) {
 			Mutator: func(c *EmailMonitorConfig) { c.TestPeriod = time.Duration(0) },
 			Error:   "test_period must be a positive value, not '0'",
 		},
+		{
+			Mutator: func(c *EmailMonitorConfig) {
+				c.AddressFamilies = []AddressFamily{ADDRESS_FAMILY_IPV6, ADDRESS_FAMILY_IPV6}
+			},
+			Error: "address_families lists 'ipv6' more than once",
+		},
+		{
+			Mutator: func(c *EmailMonitorConfig) { c.AddressFamilies = []AddressFamily{"ipv7"} },
+			Error:   "address_families entry 'ipv7' is not supported; expected one of any, ipv4, ipv6",
+		},
+		{
+			Mutator: func(c *EmailMonitorConfig) { c.AddressFamilies = []AddressFamily{"", ADDRESS_FAMILY_ANY} },
+			Error:   "address_families lists 'any' more than once",
+		},
 		{
 			Mutator: func(c *EmailMonitorConfig) { c.Notifications = []NotificationConfig{} },
 			Error:   "the list of notifications is empty. Each monitor must have at least on notification defined",
@@ -1,62 +1,56 @@
 package config
 
 import (
-	"net/mail"
-
 	"strings"
 	"varanus/internal/secrets"
-	"varanus/internal/util"
 	"varanus/internal/validation"
 )
 
+// the ports used for IMAP if an account doesn't set one
+const (
+	DEFAULT_IMAP_PORT     = 143
+	DEFAULT_IMAP_TLS_PORT = 993
+)
+
 type IMAPConfig struct {
-	RecipientAddress string             `yaml:"recipient_address"`
-	ServerAddress    string             `yaml:"server_address"`
-	Port             uint               `yaml:"port"`
-	UseTLS           bool               `yaml:"use_tls"`
-	Username         string             `yaml:"username"`
-	Password         secrets.SealedItem `yaml:"password"`
-	MailboxName      string             `yaml:"mailbox_name"`
+	RecipientAddress string             `yaml:"recipient_address" doc:"The email address the test emails are sent to" example:"monitor@example.org" validate:"email"`
+	ServerAddress    string             `yaml:"server_address" doc:"The hostname or IP address of the IMAP server" example:"imap.example.org" validate:"hostname"`
+	Port             uint               `yaml:"port,omitempty" doc:"The port of the IMAP server" default:"993 with use_tls, otherwise 143" example:"993" validate:"port"`
+	UseTLS           bool               `yaml:"use_tls" doc:"If true, the connection to the server is encrypted with TLS" default:"false" example:"true"`
+	Username         string             `yaml:"username" doc:"The username to log in to the IMAP server" example:"monitor@example.org" validate:"required"`
+	Password         secrets.SealedItem `yaml:"password" doc:"The password to log in to the IMAP server" example:"sealed(...)"`
+	MailboxName      string             `yaml:"mailbox_name" doc:"The mailbox that the test emails are received in" example:"INBOX" validate:"required"`
 }
 
-func (c IMAPConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
-
-	_, addressError := mail.ParseAddress(c.RecipientAddress)
-	if addressError != nil {
-		vet.AddValidationError(
-			c,
-			"recipient_address '%s' is not a valid email: %s", c.RecipientAddress, addressError,
-		)
-	}
+// Normalize trims the address, username and mailbox name, and lowercases the server address.  The
+// default port is filled in by the account.
+func (c *IMAPConfig) Normalize() {
+	c.RecipientAddress = strings.TrimSpace(c.RecipientAddress)
+	c.ServerAddress = strings.ToLower(strings.TrimSpace(c.ServerAddress))
+	c.Username = strings.TrimSpace(c.Username)
+	c.MailboxName = strings.TrimSpace(c.MailboxName)
+}
 
-	c.ServerAddress = strings.TrimSpace(c.ServerAddress)
-	if !util.IsUrlHost(c.ServerAddress) {
-		vet.AddValidationError(
-			c,
-			"server_address '%s' is not a valid hostname", c.ServerAddress,
-		)
+// defaultPort returns the port to use if none is set
+func (c IMAPConfig) defaultPort() uint {
+	if c.UseTLS {
+		return DEFAULT_IMAP_TLS_PORT
 	}
+	return DEFAULT_IMAP_PORT
+}
 
-	if c.Port == 0 {
-		vet.AddValidationError(
-			c,
-			"port value is required and cannot be 0",
-		)
-	}
+func (c IMAPConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
 
-	c.Username = strings.TrimSpace(c.Username)
-	if len(c.Username) == 0 {
-		vet.AddValidationError(
-			c,
-			"username must not be empty or whitespace",
-		)
+	//check the rules of the validate tags
+	err := validation.CheckFieldRules(vet, c)
+	if err != nil {
+		return err
 	}
 
-	c.MailboxName = strings.TrimSpace(c.MailboxName)
-	if len(c.MailboxName) == 0 {
-		vet.AddValidationError(
+	if !c.UseTLS {
+		vet.AddValidationWarning(
 			c,
-			"mailbox_name must not be empty or whitespace",
+			"use_tls is false, so the password is sent to '%s' without encryption", c.ServerAddress,
 		)
 	}
 
@@ -93,6 +93,7 @@ func TestIMAPConfigValidation(t *testing.T) {
 		//do validation
 		config := util.DeepCopy(baseConfig).(IMAPConfig) //make a copy of the config
 		testCase.Mutator(&config)                        //modify the config
+		require.Nil(t, validation.NormalizeObject(&config))
 		validationResult, err := validation.ValidateObject(config)
 		//checks
 		assert.Nil(t, err)
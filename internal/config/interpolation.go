@@ -0,0 +1,176 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Interpolation replaces references in the scalar values of a config when it is read, if the
+// config sets "interpolate: true" at the top level.  The references are:
+//
+//	${NAME}             the value of the environment variable NAME, which must be set
+//	${NAME:-default}    the value of NAME, or default if NAME is unset or empty
+//	${file:/path}       the contents of the file at /path, without a trailing newline
+//
+// "$$" is a literal "$", and a "$" that isn't followed by "{" is left as is.  References can't be
+// nested, and a default can't contain "}".  Relative file paths are relative to the working
+// directory.
+//
+// A plain (unquoted) value is read as if the result had been written in its place, so
+// "port: ${SMTP_PORT}" works for a number; a quoted value is always a string.  References that
+// can't be resolved are replaced with an empty string and reported by validation at the path of
+// the value.  Seal and unseal write their values with every "$" doubled, so that they are read back
+// unchanged, and keep the references of values that have them.
+
+// INTERPOLATE_FIELD is the top level field that turns on interpolation.  It, and the version
+// field, are read before the values are interpolated, so they can't have references.
+const INTERPOLATE_FIELD = "interpolate"
+
+// INTERPOLATION_FILE_PREFIX starts a reference to the contents of a file
+const INTERPOLATION_FILE_PREFIX = "file:"
+
+// INTERPOLATION_DEFAULT_SEPARATOR separates an environment variable name from its default
+const INTERPOLATION_DEFAULT_SEPARATOR = ":-"
+
+var environmentVariableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// UnresolvedReference is an interpolation reference in a config value that could not be resolved
+type UnresolvedReference struct {
+	//Path is the path of the value, like "mail.accounts[0].smtp.password"
+	Path string
+	//Reference is the reference as written, like "${SMTP_PASSWORD}"
+	Reference string
+	Reason    error
+}
+
+// interpolateNode replaces the references in the scalar values under node, which has the given
+// path, and returns the references that could not be resolved.  Mapping keys are not changed, and
+// aliases are skipped because their values are interpolated where they are anchored.
+func interpolateNode(node *yaml.Node, path string) []UnresolvedReference {
+	unresolved := []UnresolvedReference{}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			unresolved = append(unresolved, interpolateNode(child, path)...)
+		}
+	case yaml.MappingNode:
+		for keyIndex := 0; keyIndex+1 < len(node.Content); keyIndex += 2 {
+			childPath := node.Content[keyIndex].Value
+			if path != "" {
+				childPath = path + "." + childPath
+			}
+			unresolved = append(unresolved, interpolateNode(node.Content[keyIndex+1], childPath)...)
+		}
+	case yaml.SequenceNode:
+		for index, child := range node.Content {
+			unresolved = append(unresolved, interpolateNode(child, fmt.Sprintf("%s[%d]", path, index))...)
+		}
+	case yaml.ScalarNode:
+		value, scalarUnresolved := interpolateString(node.Value, path)
+		unresolved = append(unresolved, scalarUnresolved...)
+		if value != node.Value {
+			node.Value = value
+			if node.Style&(yaml.SingleQuotedStyle|yaml.DoubleQuotedStyle|yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+				//a plain value was tagged as a string for the reference, so resolve it again
+				node.Tag = ""
+			}
+		}
+	}
+	return unresolved
+}
+
+// interpolateString replaces the references in value, which is at path
+func interpolateString(value string, path string) (string, []UnresolvedReference) {
+	unresolved := []UnresolvedReference{}
+	var sb strings.Builder
+	for index := 0; index < len(value); {
+		rest := value[index:]
+		switch {
+		case strings.HasPrefix(rest, "$$"):
+			sb.WriteByte('$')
+			index += 2
+		case strings.HasPrefix(rest, "${"):
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				unresolved = append(unresolved, UnresolvedReference{
+					Path:      path,
+					Reference: rest,
+					Reason:    errors.New("the reference has no closing '}'"),
+				})
+				index = len(value)
+				continue
+			}
+			resolved, err := resolveReference(rest[2:end])
+			if err != nil {
+				unresolved = append(unresolved, UnresolvedReference{Path: path, Reference: rest[:end+1], Reason: err})
+			}
+			sb.WriteString(resolved)
+			index += end + 1
+		default:
+			sb.WriteByte(value[index])
+			index += 1
+		}
+	}
+	return sb.String(), unresolved
+}
+
+// resolveReference returns the value for the inside of a reference, e.g. "NAME:-default"
+func resolveReference(reference string) (string, error) {
+	if filename, isFile := strings.CutPrefix(reference, INTERPOLATION_FILE_PREFIX); isFile {
+		data, err := os.ReadFile(filename)
+		if err != nil {
+			return "", fmt.Errorf("could not read the file: %w", err)
+		}
+		//files usually end with a newline that isn't part of the value
+		value := strings.TrimSuffix(string(data), "\n")
+		return strings.TrimSuffix(value, "\r"), nil
+	}
+
+	name, defaultValue, hasDefault := strings.Cut(reference, INTERPOLATION_DEFAULT_SEPARATOR)
+	if !environmentVariableNamePattern.MatchString(name) {
+		return "", fmt.Errorf("'%s' is not a valid environment variable name", name)
+	}
+	value, found := os.LookupEnv(name)
+	switch {
+	case hasDefault && value == "":
+		return defaultValue, nil
+	case !found:
+		return "", fmt.Errorf("the environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// escapeInterpolation returns value with every "$" doubled, so that interpolating it gives value
+func escapeInterpolation(value string) string {
+	return strings.ReplaceAll(value, "$", "$$")
+}
+
+// hasInterpolationReference returns true if value has a reference, as opposed to only escaped "$"
+func hasInterpolationReference(value string) bool {
+	return strings.Contains(strings.ReplaceAll(value, "$$", ""), "${")
+}
+
+// unknownFieldErrors returns only the errors from err about unknown fields, or nil if there are
+// none.  Other type errors in a config with interpolation are from values that haven't been
+// interpolated yet.
+func unknownFieldErrors(err error) error {
+	var typeError *yaml.TypeError
+	if !errors.As(err, &typeError) {
+		return err
+	}
+	unknownFields := []string{}
+	for _, message := range typeError.Errors {
+		if strings.Contains(message, "not found in type") {
+			unknownFields = append(unknownFields, message)
+		}
+	}
+	if len(unknownFields) == 0 {
+		return nil
+	}
+	return &yaml.TypeError{Errors: unknownFields}
+}
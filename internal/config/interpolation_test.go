@@ -0,0 +1,186 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"varanus/internal/secrets"
+	"varanus/internal/util"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterpolateString(t *testing.T) {
+	t.Setenv("VARANUS_TEST_HOST", "smtp.example.com")
+	t.Setenv("VARANUS_TEST_EMPTY", "")
+	os.Unsetenv("VARANUS_TEST_UNSET")
+
+	passwordFile := util.CreateTempFileAndDir("test_output", "password-*")
+	_, err := passwordFile.WriteString("it's a secret\n")
+	require.Nil(t, err)
+	passwordFile.Close()
+	defer os.Remove(passwordFile.Name())
+
+	type TestCase struct {
+		Value    string
+		Expected string
+		//Unresolved is the reason for the unresolved reference, if any
+		Unresolved string
+	}
+
+	testCases := []TestCase{
+		{Value: "no references", Expected: "no references"},
+		{Value: "${VARANUS_TEST_HOST}", Expected: "smtp.example.com"},
+		{Value: "mail.${VARANUS_TEST_HOST}:25", Expected: "mail.smtp.example.com:25"},
+		{Value: "${VARANUS_TEST_UNSET:-default}", Expected: "default"},
+		{Value: "${VARANUS_TEST_EMPTY:-default}", Expected: "default"},
+		{Value: "${VARANUS_TEST_HOST:-default}", Expected: "smtp.example.com"},
+		{Value: "${VARANUS_TEST_UNSET:-}", Expected: ""},
+		{Value: "${VARANUS_TEST_EMPTY}", Expected: ""},
+		{Value: "${file:" + passwordFile.Name() + "}", Expected: "it's a secret"},
+		{Value: "costs $$5 or $${VARANUS_TEST_HOST}", Expected: "costs $5 or ${VARANUS_TEST_HOST}"},
+		{Value: "a lone $ sign", Expected: "a lone $ sign"},
+		{Value: "trailing $", Expected: "trailing $"},
+		{
+			Value:      "x${VARANUS_TEST_UNSET}y",
+			Expected:   "xy",
+			Unresolved: "the environment variable VARANUS_TEST_UNSET is not set",
+		},
+		{Value: "${}", Expected: "", Unresolved: "'' is not a valid environment variable name"},
+		{Value: "${NOT-VALID}", Expected: "", Unresolved: "'NOT-VALID' is not a valid environment variable name"},
+		{Value: "x${VARANUS_TEST_HOST", Expected: "x", Unresolved: "the reference has no closing '}'"},
+		{Value: "${file:test_output/does-not-exist}", Expected: "", Unresolved: "could not read the file"},
+	}
+
+	for index, testCase := range testCases {
+		value, unresolved := interpolateString(testCase.Value, "mail.path")
+		assert.Equal(t, testCase.Expected, value, "for test %d", index)
+		if testCase.Unresolved == "" {
+			assert.Len(t, unresolved, 0, "for test %d", index)
+		} else if assert.Len(t, unresolved, 1, "for test %d", index) {
+			assert.Equal(t, "mail.path", unresolved[0].Path, "for test %d", index)
+			assert.ErrorContains(t, unresolved[0].Reason, testCase.Unresolved, "for test %d", index)
+		}
+	}
+}
+
+const INTERPOLATION_TEST_YAML = `interpolate: true
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: ${VARANUS_TEST_HOST}
+        port: ${VARANUS_TEST_PORT:-465}
+        username: joeuser@example.com
+        password: "${VARANUS_TEST_PASSWORD}"
+monitoring:
+  email_monitors:
+    - from_account: test1
+      to_account: test1
+      test_period: ${VARANUS_TEST_PERIOD:-1h}
+      notifications:
+        - mail: test1
+`
+
+func TestReadConfigInterpolation(t *testing.T) {
+	t.Setenv("VARANUS_TEST_HOST", "smtp.example.com")
+	t.Setenv("VARANUS_TEST_PORT", "587")
+	t.Setenv("VARANUS_TEST_PASSWORD", "it's a secret")
+	os.Unsetenv("VARANUS_TEST_PERIOD")
+
+	config, err := ReadConfig([]byte(INTERPOLATION_TEST_YAML))
+	require.Nil(t, err)
+	assert.True(t, config.Interpolate)
+	assert.Equal(t, "smtp.example.com", config.Mail.Accounts[0].SMTP.ServerAddress)
+	assert.Equal(t, uint(587), config.Mail.Accounts[0].SMTP.Port)
+	assert.Equal(t, "it's a secret", config.Mail.Accounts[0].SMTP.Password.GetValue())
+	assert.Equal(t, "1h0m0s", config.MonitoringConfig.EmailMonitors[0].TestPeriod.String())
+	assert.Len(t, config.GetUnresolvedReferences(), 0)
+
+	//without interpolate the references are plain values
+	_, err = ReadConfig([]byte(INTERPOLATION_TEST_YAML[len("interpolate: true\n"):]))
+	assert.ErrorContains(t, err, "cannot unmarshal !!str `${VARAN...` into uint")
+
+	//unresolved references are reported by validation at their paths
+	os.Unsetenv("VARANUS_TEST_HOST")
+	config, err = ReadConfig([]byte(INTERPOLATION_TEST_YAML))
+	require.Nil(t, err)
+	assert.Equal(t, "", config.Mail.Accounts[0].SMTP.ServerAddress)
+	require.Len(t, config.GetUnresolvedReferences(), 1)
+
+	validationResult, err := validation.ValidateObject(config)
+	require.Nil(t, err)
+	assert.Contains(t, validationResult.GetErrorList(), validation.SingleValidationError{
+		Error:    "could not resolve ${VARANUS_TEST_HOST}: the environment variable VARANUS_TEST_HOST is not set",
+		Object:   *config,
+		Path:     "mail.accounts[0].smtp.server_address",
+		Severity: validation.SEVERITY_ERROR,
+	})
+
+	//unknown fields are still rejected
+	_, err = ReadConfig([]byte(INTERPOLATION_TEST_YAML + "unknown: ${VARANUS_TEST_PORT}\n"))
+	assert.ErrorContains(t, err, "line 18: field unknown not found in type config.VaranusConfig")
+
+	//type errors after interpolation have the line of the value
+	t.Setenv("VARANUS_TEST_PORT", "not a port")
+	_, err = ReadConfig([]byte(INTERPOLATION_TEST_YAML))
+	assert.ErrorContains(t, err, "unmarshal error after interpolation")
+	assert.ErrorContains(t, err, "line 8: cannot unmarshal !!str `not a port` into uint")
+}
+
+func TestConfigDocumentKeepsInterpolationReferences(t *testing.T) {
+	t.Setenv("VARANUS_TEST_HOST", "smtp.example.com")
+	t.Setenv("VARANUS_TEST_PASSWORD", "it's a secret")
+
+	document, err := ReadConfigDocument([]byte(INTERPOLATION_TEST_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Equal(t, "it's a secret", document.Config.Mail.Accounts[0].SMTP.Password.GetValue())
+
+	//the interpolated password is not written into the document when it changes
+	document.Config.Mail.Accounts[0].SMTP.Password = secrets.CreateUnsafeSealedItem("+aaaa==", true)
+	err = document.UpdateSealedItems()
+	require.Nil(t, err)
+	output, err := document.ToBytes()
+	require.Nil(t, err)
+	assert.Equal(t, INTERPOLATION_TEST_YAML, string(output))
+}
+
+const INTERPOLATION_ESCAPE_TEST_YAML = `interpolate: true
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        username: joeuser@example.com
+        password: pa$$word
+`
+
+func TestConfigDocumentEscapesInterpolation(t *testing.T) {
+	document, err := ReadConfigDocument([]byte(INTERPOLATION_ESCAPE_TEST_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Equal(t, "pa$word", document.Config.Mail.Accounts[0].SMTP.Password.GetValue())
+
+	//an unchanged value is left as written
+	err = document.UpdateSealedItems()
+	require.Nil(t, err)
+	output, err := document.ToBytes()
+	require.Nil(t, err)
+	assert.Equal(t, INTERPOLATION_ESCAPE_TEST_YAML, string(output))
+
+	//a value with "$" is escaped so that it isn't read as a reference
+	document.Config.Mail.Accounts[0].SMTP.Password = secrets.CreateUnsafeSealedItem("a$b${HOME}$$", false)
+	err = document.UpdateSealedItems()
+	require.Nil(t, err)
+	output, err = document.ToBytes()
+	require.Nil(t, err)
+	assert.Contains(t, string(output), "password: a$$b$${HOME}$$$$\n")
+
+	readBack, err := ReadConfig(output)
+	require.Nil(t, err)
+	assert.Equal(t, "a$b${HOME}$$", readBack.Mail.Accounts[0].SMTP.Password.GetValue())
+	assert.Empty(t, readBack.GetUnresolvedReferences())
+}
@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"varanus/internal/validation"
+)
+
+// Lint looks for logical problems across the whole config that validation of each object doesn't
+// catch, and reports each as a warning with an explanation.  The config should already be valid;
+// references to accounts that don't exist are skipped.
+func (c VaranusConfig) Lint(vet validation.PathIssueTracker) {
+	c.lintMonitors(vet)
+	c.lintSendLimitGroups(vet)
+	c.lintUnusedAccounts(vet)
+}
+
+func lintWarning(vet validation.PathIssueTracker, path string, object interface{}, message string, messageArgs ...interface{}) {
+	vet.AddIssueAtPath(validation.SEVERITY_WARNING, path, object, message, messageArgs...)
+}
+
+func (c VaranusConfig) lintMonitors(vet validation.PathIssueTracker) {
+	for index, monitor := range c.MonitoringConfig.EmailMonitors {
+		path := fmt.Sprintf("monitoring.email_monitors[%d]", index)
+
+		if monitor.FromAccount != "" && monitor.FromAccount == monitor.ToAccount {
+			lintWarning(vet, path, monitor,
+				"from_account and to_account are both '%s'; the account sends the test message to itself, "+
+					"so a problem that only affects delivery between different accounts won't be detected",
+				monitor.FromAccount,
+			)
+		}
+
+		//the send limit delays the test messages, so the monitor can't run as often as configured
+		fromAccount := c.Mail.GetAccountByName(monitor.FromAccount)
+		for limitIndex, sendLimit := range c.Mail.SendLimits {
+			if fromAccount != nil && monitor.TestPeriod > 0 && monitor.TestPeriod < sendLimit.MinPeriod &&
+				sendLimit.coversAccount(*fromAccount) {
+				lintWarning(vet, path, monitor,
+					"test_period %s is shorter than the min_period %s of send_limits[%d], which includes from_account '%s'; "+
+						"the test messages are held back by the send limit, so tests will run less often than configured",
+					monitor.TestPeriod, sendLimit.MinPeriod, limitIndex, monitor.FromAccount,
+				)
+			}
+		}
+
+		c.lintMonitorNotifications(vet, path, monitor)
+	}
+}
+
+// lintMonitorNotifications warns when every notification of a monitor is sent through a server the
+// monitor is testing, because an outage of that server would also stop the notification about it.
+func (c VaranusConfig) lintMonitorNotifications(vet validation.PathIssueTracker, path string, monitor EmailMonitorConfig) {
+	monitoredServers := map[string]bool{}
+	if account := c.Mail.GetAccountByName(monitor.FromAccount); account != nil && account.SMTP != nil {
+		monitoredServers[normalizeServerName(account.SMTP.ServerAddress)] = true
+	}
+	if account := c.Mail.GetAccountByName(monitor.ToAccount); account != nil && account.IMAP != nil {
+		monitoredServers[normalizeServerName(account.IMAP.ServerAddress)] = true
+	}
+
+	sharedServers := []string{}
+	for _, notification := range monitor.Notifications {
+		account := c.Mail.GetAccountByName(notification.Mail)
+		if account == nil || account.SMTP == nil {
+			return //can't tell which server the notification uses
+		}
+		server := normalizeServerName(account.SMTP.ServerAddress)
+		if !monitoredServers[server] {
+			return //at least one notification avoids the monitored servers
+		}
+		if !slices.Contains(sharedServers, server) {
+			sharedServers = append(sharedServers, server)
+		}
+	}
+	if len(sharedServers) == 0 {
+		return //no notifications
+	}
+
+	lintWarning(vet, path, monitor,
+		"every notification is sent through %s, which this monitor is testing; "+
+			"if that server fails, the notification about the failure will likely fail too, "+
+			"so add a notification through an account on another server",
+		strings.Join(sharedServers, ", "),
+	)
+}
+
+func normalizeServerName(serverAddress string) string {
+	return strings.ToLower(strings.TrimSuffix(strings.TrimSpace(serverAddress), "."))
+}
+
+func (c VaranusConfig) lintSendLimitGroups(vet validation.PathIssueTracker) {
+	//the first send limit each account is listed in
+	firstGroups := map[string]int{}
+	for index, sendLimit := range c.Mail.SendLimits {
+		for _, accountName := range sendLimit.AccountNames {
+			firstIndex, found := firstGroups[accountName]
+			if !found {
+				firstGroups[accountName] = index
+				continue
+			}
+			if firstIndex == index {
+				continue //listed twice in the same group, which doesn't change anything
+			}
+			lintWarning(vet, fmt.Sprintf("mail.send_limits[%d]", index), sendLimit,
+				"account '%s' is also in send_limits[%d]; an account in more than one send limit group waits for "+
+					"all of them, so only the longest min_period has an effect and the groups are throttled together",
+				accountName, firstIndex,
+			)
+		}
+	}
+}
+
+func (c VaranusConfig) lintUnusedAccounts(vet validation.PathIssueTracker) {
+	used := map[string]bool{}
+	for _, monitor := range c.MonitoringConfig.EmailMonitors {
+		used[monitor.FromAccount] = true
+		used[monitor.ToAccount] = true
+		for _, notification := range monitor.Notifications {
+			used[notification.Mail] = true
+		}
+	}
+
+	for index, account := range c.Mail.Accounts {
+		if !used[account.Name] {
+			lintWarning(vet, fmt.Sprintf("mail.accounts[%d]", index), account,
+				"account '%s' is not used by any monitor or notification; it is never tested, "+
+					"so either add a monitor for it or remove it",
+				account.Name,
+			)
+		}
+	}
+}
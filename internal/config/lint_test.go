@@ -0,0 +1,127 @@
+package config
+
+import (
+	"testing"
+	"time"
+	"varanus/internal/util"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+
+	type TestCase struct {
+		Mutator func(c *VaranusConfig)
+		Path    string
+		Warning string
+	}
+
+	testCases := []TestCase{
+		{
+			Mutator: func(c *VaranusConfig) {
+				c.MonitoringConfig.EmailMonitors[0].ToAccount = "test1"
+				//keep test2 in use, so it isn't reported as unused
+				c.MonitoringConfig.EmailMonitors[0].Notifications = append(
+					c.MonitoringConfig.EmailMonitors[0].Notifications, NotificationConfig{Mail: "test2"})
+			},
+			Path:    "monitoring.email_monitors[0]",
+			Warning: "from_account and to_account are both 'test1'; the account sends the test message to itself",
+		},
+		{
+			Mutator: func(c *VaranusConfig) {
+				c.Mail.SendLimits = []SendLimitConfig{{MinPeriod: time.Hour, AccountNames: []string{"test2", "test1"}}}
+			},
+			Path:    "monitoring.email_monitors[0]",
+			Warning: "test_period 10m0s is shorter than the min_period 1h0m0s of send_limits[0], which includes from_account 'test1'",
+		},
+		{
+			//a send limit grouped by server IP without account names covers every SMTP account
+			Mutator: func(c *VaranusConfig) {
+				c.Mail.SendLimits = []SendLimitConfig{{MinPeriod: time.Hour, GroupByServerIP: true}}
+			},
+			Path:    "monitoring.email_monitors[0]",
+			Warning: "test_period 10m0s is shorter than the min_period 1h0m0s of send_limits[0], which includes from_account 'test1'",
+		},
+		{
+			Mutator: func(c *VaranusConfig) {
+				c.Mail.SendLimits = []SendLimitConfig{{MinPeriod: time.Hour, AccountNames: []string{"test1", "test3"}, GroupByServerIP: true}}
+			},
+			Path:    "monitoring.email_monitors[0]",
+			Warning: "test_period 10m0s is shorter than the min_period 1h0m0s of send_limits[0], which includes from_account 'test1'",
+		},
+		{
+			Mutator: func(c *VaranusConfig) {
+				c.Mail.SendLimits = []SendLimitConfig{
+					{MinPeriod: time.Minute, AccountNames: []string{"test1"}},
+					{MinPeriod: time.Minute, AccountNames: []string{"test3"}},
+					{MinPeriod: time.Minute, AccountNames: []string{"test2", "test1"}},
+				}
+			},
+			Path:    "mail.send_limits[2]",
+			Warning: "account 'test1' is also in send_limits[0]; an account in more than one send limit group waits for all of them",
+		},
+		{
+			Mutator: func(c *VaranusConfig) {
+				c.Mail.Accounts = append(c.Mail.Accounts, MailAccountConfig{Name: "test4"})
+			},
+			Path:    "mail.accounts[3]",
+			Warning: "account 'test4' is not used by any monitor or notification",
+		},
+		{
+			//the notification account uses the same SMTP server as the from_account
+			Mutator: func(c *VaranusConfig) { c.Mail.Accounts[2].SMTP.ServerAddress = "SMTP1.example.com." },
+			Path:    "monitoring.email_monitors[0]",
+			Warning: "every notification is sent through smtp1.example.com, which this monitor is testing",
+		},
+		{
+			//the notification account uses the same server as the to_account's IMAP
+			Mutator: func(c *VaranusConfig) { c.Mail.Accounts[2].SMTP.ServerAddress = "imap2.example.com" },
+			Path:    "monitoring.email_monitors[0]",
+			Warning: "every notification is sent through imap2.example.com, which this monitor is testing",
+		},
+	}
+
+	baseConfig := VaranusConfig{
+		Mail: MailConfig{
+			Accounts: []MailAccountConfig{
+				{Name: "test1", SMTP: &SMTPConfig{ServerAddress: "smtp1.example.com"}},
+				{Name: "test2", IMAP: &IMAPConfig{ServerAddress: "imap2.example.com"}},
+				{Name: "test3", SMTP: &SMTPConfig{ServerAddress: "smtp3.example.com"}},
+			},
+			SendLimits: []SendLimitConfig{{MinPeriod: 10 * time.Minute, AccountNames: []string{"test1", "test1"}}},
+		},
+		MonitoringConfig: MonitorConfig{
+			EmailMonitors: []EmailMonitorConfig{
+				{
+					FromAccount:   "test1",
+					ToAccount:     "test2",
+					TestPeriod:    10 * time.Minute,
+					Notifications: []NotificationConfig{{Mail: "test3"}},
+				},
+			},
+		},
+	}
+
+	{
+		//nominal case should have no warnings
+		config := util.DeepCopy(baseConfig).(VaranusConfig)
+		result := validation.ValidationResult{}
+		config.Lint(&result)
+		assert.Len(t, result.GetIssueList(), 0)
+	}
+
+	for index, testCase := range testCases {
+		config := util.DeepCopy(baseConfig).(VaranusConfig)
+		testCase.Mutator(&config)
+
+		result := validation.ValidationResult{}
+		config.Lint(&result)
+
+		require.Equal(t, 1, len(result.GetIssueList()), "for test %d: %v", index, result.GetIssueList())
+		warning := result.GetWarningList()[0]
+		assert.Equal(t, testCase.Path, warning.Path, "for test %d", index)
+		assert.Contains(t, warning.Error, testCase.Warning, "for test %d", index)
+	}
+}
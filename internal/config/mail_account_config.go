@@ -1,25 +1,48 @@
 package config
 
 import (
+	"net"
 	"strings"
+	"varanus/internal/util"
 	"varanus/internal/validation"
 )
 
 type MailAccountConfig struct {
-	Name string
-	SMTP *SMTPConfig `yaml:",omitempty"`
-	IMAP *IMAPConfig `yaml:",omitempty"`
+	Name          string        `yaml:"name" doc:"The unique name that monitors, notifications and send limits use for the account" example:"work-account" validate:"required" message:"account names must not be empty or whitespace"`
+	Extends       string        `yaml:"extends,omitempty" doc:"The name of a template whose settings the account inherits unless it sets them itself; the smtp and imap settings are only inherited if the account has an smtp or imap section" example:"provider"`
+	SMTP          *SMTPConfig   `yaml:"smtp,omitempty" doc:"The SMTP server that sends email from the account"`
+	IMAP          *IMAPConfig   `yaml:"imap,omitempty" doc:"The IMAP server that receives email for the account"`
+	Proxy         *ProxyConfig  `yaml:"proxy,omitempty" doc:"A proxy for the connections of this account, instead of mail.proxy" default:"mail.proxy"`
+	AddressFamily AddressFamily `yaml:"address_family,omitempty" doc:"The IP address family used to connect to the servers, or to the proxy if there is one.  An http or socks5h proxy picks the family it uses to reach the servers" default:"any" example:"ipv4"`
+	LocalAddress  string        `yaml:"local_address,omitempty" doc:"The source IP address for connections from this account" default:"chosen by the system" example:"192.0.2.10"`
+}
+
+// Normalize trims the names and local address, lowercases the address family and fills in the
+// default ports of the servers.  The ports of an account that extends a template are left alone
+// until the template is resolved, so that the account can inherit them.
+func (c *MailAccountConfig) Normalize() {
+	c.Name = strings.TrimSpace(c.Name)
+	c.Extends = strings.TrimSpace(c.Extends)
+	c.AddressFamily = c.AddressFamily.normalized()
+	c.LocalAddress = strings.TrimSpace(c.LocalAddress)
+
+	if c.Extends != "" {
+		return
+	}
+	if c.SMTP != nil && c.SMTP.Port == 0 {
+		c.SMTP.Port = c.SMTP.defaultPort()
+	}
+	if c.IMAP != nil && c.IMAP.Port == 0 {
+		c.IMAP.Port = c.IMAP.defaultPort()
+	}
 }
 
 func (c MailAccountConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
 
-	//validate fields
-	c.Name = strings.TrimSpace(c.Name)
-	if len(c.Name) == 0 {
-		vet.AddValidationError(
-			c,
-			"account names must not be empty or whitespace",
-		)
+	//check the rules of the validate tags
+	err := validation.CheckFieldRules(vet, c)
+	if err != nil {
+		return err
 	}
 
 	//validate ServerConfig level logic
@@ -30,5 +53,26 @@ func (c MailAccountConfig) Validate(vet validation.ValidationErrorTracker, root
 			"Every server config must specify one of the imap or smtp sections.  They cannot both be empty.")
 	}
 
+	if !c.AddressFamily.IsSupported() {
+		vet.AddValidationError(c,
+			"address_family '%s' is not supported; expected one of %s",
+			c.AddressFamily, describeSupportedAddressFamilies())
+	}
+
+	//the local address is optional, but must be an IP of the selected family if present
+	if c.LocalAddress != "" {
+		localIP := net.ParseIP(util.UnbracketHost(c.LocalAddress))
+		if !util.IsIPLiteral(c.LocalAddress) {
+			vet.AddValidationError(c,
+				"local_address '%s' is not a valid IP address", c.LocalAddress)
+		} else if c.AddressFamily == ADDRESS_FAMILY_IPV4 && localIP.To4() == nil {
+			vet.AddValidationError(c,
+				"local_address '%s' is not an IPv4 address but the address_family is %s", c.LocalAddress, c.AddressFamily)
+		} else if c.AddressFamily == ADDRESS_FAMILY_IPV6 && localIP.To4() != nil {
+			vet.AddValidationError(c,
+				"local_address '%s' is not an IPv6 address but the address_family is %s", c.LocalAddress, c.AddressFamily)
+		}
+	}
+
 	return nil
 }
@@ -29,6 +29,32 @@ func TestMailAccountConfigValidation(t *testing.T) {
 			Error:           "Every server config must specify one of the imap or smtp sections.  They cannot both be empty.",
 			ErrorObjectType: MailAccountConfig{},
 		},
+		{
+			Mutator:         func(c *MailAccountConfig) { c.LocalAddress = "mail.example.com" },
+			Error:           "local_address 'mail.example.com' is not a valid IP address",
+			ErrorObjectType: MailAccountConfig{},
+		},
+		{
+			Mutator: func(c *MailAccountConfig) {
+				c.AddressFamily = ADDRESS_FAMILY_IPV4
+				c.LocalAddress = "2001:db8::10"
+			},
+			Error:           "local_address '2001:db8::10' is not an IPv4 address but the address_family is ipv4",
+			ErrorObjectType: MailAccountConfig{},
+		},
+		{
+			Mutator: func(c *MailAccountConfig) {
+				c.AddressFamily = ADDRESS_FAMILY_IPV6
+				c.LocalAddress = "192.0.2.10"
+			},
+			Error:           "local_address '192.0.2.10' is not an IPv6 address but the address_family is ipv6",
+			ErrorObjectType: MailAccountConfig{},
+		},
+		{
+			Mutator:         func(c *MailAccountConfig) { c.AddressFamily = "ipv5" },
+			Error:           "address_family 'ipv5' is not supported",
+			ErrorObjectType: MailAccountConfig{},
+		},
 		//pass one error through to each of the IMAP and SMTP structs to check end to end behavior
 		{
 			Mutator:         func(c *MailAccountConfig) { c.SMTP.Username = "" },
@@ -61,11 +87,23 @@ func TestMailAccountConfigValidation(t *testing.T) {
 		},
 	}
 
-	{ //nominal case test should have no errors
+	nominalMutators := []func(c *MailAccountConfig){
+		func(c *MailAccountConfig) {},
+		func(c *MailAccountConfig) { c.AddressFamily = ADDRESS_FAMILY_ANY; c.LocalAddress = "192.0.2.10" },
+		func(c *MailAccountConfig) { c.AddressFamily = ADDRESS_FAMILY_IPV4; c.LocalAddress = "192.0.2.10" },
+		func(c *MailAccountConfig) { c.AddressFamily = ADDRESS_FAMILY_IPV6; c.LocalAddress = "[2001:db8::10]" },
+		func(c *MailAccountConfig) {
+			c.SMTP.ServerAddress = "[2001:db8::25]"
+			c.IMAP.ServerAddress = "192.0.2.25"
+		},
+	}
+	for index, mutator := range nominalMutators {
+		//nominal case test should have no errors
 		config := util.DeepCopy(baseConfig).(MailAccountConfig) //make a copy of the config
+		mutator(&config)
 		validationResult, err := validation.ValidateObject(config)
 		assert.Nil(t, err)
-		assert.Equal(t, 0, validationResult.GetErrorCount())
+		assert.Equal(t, 0, validationResult.GetErrorCount(), "for nominal test %d", index)
 	}
 
 	// test loop
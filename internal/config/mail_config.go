@@ -3,33 +3,72 @@ package config
 import "varanus/internal/validation"
 
 type MailConfig struct {
-	Accounts   []MailAccountConfig `yaml:"accounts"`
-	SendLimits []SendLimitConfig   `yaml:"send_limits"`
+	//Templates have settings that accounts inherit with extends; see templates.go
+	Templates   []MailAccountTemplate `yaml:"templates,omitempty" doc:"Shared account settings that accounts inherit with extends"`
+	Accounts    []MailAccountConfig   `yaml:"accounts" doc:"The mail accounts that send and receive the test emails and notifications"`
+	SendLimits  []SendLimitConfig     `yaml:"send_limits" doc:"Limits on how often accounts send email"`
+	Proxy       *ProxyConfig          `yaml:"proxy,omitempty" doc:"The proxy for the connections of every account without its own proxy" default:"direct connections"`
+	AuthLockout *AuthLockoutConfig    `yaml:"auth_lockout,omitempty" doc:"How logins are suspended after an account's credentials are rejected" default:"failure_threshold 3, cool_down 1h"`
 }
 
+// GetAccountByName returns the account with the name, or nil if there is none.  The account is
+// the one in c.Accounts, not a copy.  It is for checking the config; the monitors and the mail
+// worker look accounts up in the compiled RuntimeConfig instead.
 func (c MailConfig) GetAccountByName(name string) *MailAccountConfig {
-	for _, account := range c.Accounts {
-		if account.Name == name {
-			return &account
+	for index := range c.Accounts {
+		if c.Accounts[index].Name == name {
+			return &c.Accounts[index]
 		}
 	}
 	return nil
 }
 
+// GetProxyForAccount returns the proxy that connections for account should use, or nil if the
+// connections should be made directly.  An account-level proxy takes precedence over the global one.
+func (c MailConfig) GetProxyForAccount(account *MailAccountConfig) *ProxyConfig {
+	if account != nil && account.Proxy != nil {
+		return account.Proxy
+	}
+	return c.Proxy
+}
+
+// GetAuthLockout returns the auth_lockout config, or the defaults if it is not set.
+func (c MailConfig) GetAuthLockout() AuthLockoutConfig {
+	if c.AuthLockout != nil {
+		return *c.AuthLockout
+	}
+	return AuthLockoutConfig{
+		FailureThreshold: DEFAULT_AUTH_FAILURE_THRESHOLD,
+		CoolDown:         DEFAULT_AUTH_COOL_DOWN,
+	}
+}
+
 func (c MailConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
 
+	//the files the accounts came from, to report where duplicates are in a merged config
+	accountSources := getAccountSources(root)
+
 	//make sure the account names are unique
-	namesInUse := map[string]bool{}
-	for _, account := range c.Accounts {
+	namesInUse := map[string]int{}
+	for index, account := range c.Accounts {
 		//validation error if the name is already in use
-		if namesInUse[account.Name] {
-			vet.AddValidationError(
-				c,
-				"duplicate account name '%s'", account.Name,
-			)
+		if firstIndex, found := namesInUse[account.Name]; found {
+			if len(accountSources) == len(c.Accounts) && accountSources[firstIndex] != accountSources[index] {
+				vet.AddValidationError(
+					c,
+					"duplicate account name '%s' in %s and %s",
+					account.Name, accountSources[firstIndex], accountSources[index],
+				)
+			} else {
+				vet.AddValidationError(
+					c,
+					"duplicate account name '%s'", account.Name,
+				)
+			}
+			continue
 		}
 		//add the name in use to the map
-		namesInUse[account.Name] = true
+		namesInUse[account.Name] = index
 	}
 
 	return nil
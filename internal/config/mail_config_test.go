@@ -46,6 +46,8 @@ func TestMailConfig(t *testing.T) {
 	assert.Equal(t, &config.Accounts[0], config.GetAccountByName("test1"))
 	assert.Equal(t, &config.Accounts[1], config.GetAccountByName("test2"))
 	assert.Nil(t, config.GetAccountByName("nonexistent"))
+	//the account is the one in the list, not a copy
+	assert.Same(t, &config.Accounts[1], config.GetAccountByName("test2"))
 
 }
 
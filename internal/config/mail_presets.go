@@ -0,0 +1,90 @@
+package config
+
+import (
+	"slices"
+	"strings"
+	"varanus/internal/secrets"
+)
+
+// MailProviderPreset has the servers, ports and TLS setting of a common mail provider, used by
+// "varanus config init" to fill in new accounts.  use_tls connects with TLS from the start, so the
+// presets use the implicit TLS ports, not the STARTTLS ones.
+type MailProviderPreset struct {
+	Name        string
+	Description string
+	//SMTPServer and IMAPServer are empty for the generic presets, where they must be given
+	SMTPServer string
+	SMTPPort   uint
+	IMAPServer string
+	IMAPPort   uint
+	UseTLS     bool
+}
+
+// DEFAULT_MAIL_PRESET is the preset used when none is chosen
+const DEFAULT_MAIL_PRESET = "tls"
+
+// DEFAULT_MAILBOX_NAME is the mailbox that new accounts receive the test emails in
+const DEFAULT_MAILBOX_NAME = "INBOX"
+
+var mailProviderPresets = []MailProviderPreset{
+	{Name: "tls", Description: "any provider, with TLS on ports 465 and 993", SMTPPort: DEFAULT_SMTP_TLS_PORT, IMAPPort: DEFAULT_IMAP_TLS_PORT, UseTLS: true},
+	{Name: "plaintext", Description: "any provider, without TLS on ports 25 and 143", SMTPPort: DEFAULT_SMTP_PORT, IMAPPort: DEFAULT_IMAP_PORT, UseTLS: false},
+	{Name: "gmail", Description: "Gmail, with an app password",
+		SMTPServer: "smtp.gmail.com", SMTPPort: 465, IMAPServer: "imap.gmail.com", IMAPPort: 993, UseTLS: true},
+	{Name: "fastmail", Description: "Fastmail, with an app password",
+		SMTPServer: "smtp.fastmail.com", SMTPPort: 465, IMAPServer: "imap.fastmail.com", IMAPPort: 993, UseTLS: true},
+	{Name: "yahoo", Description: "Yahoo Mail, with an app password",
+		SMTPServer: "smtp.mail.yahoo.com", SMTPPort: 465, IMAPServer: "imap.mail.yahoo.com", IMAPPort: 993, UseTLS: true},
+	{Name: "zoho", Description: "Zoho Mail",
+		SMTPServer: "smtp.zoho.com", SMTPPort: 465, IMAPServer: "imap.zoho.com", IMAPPort: 993, UseTLS: true},
+}
+
+// MailProviderPresets returns the presets in the order they are offered
+func MailProviderPresets() []MailProviderPreset {
+	return slices.Clone(mailProviderPresets)
+}
+
+// GetMailProviderPreset returns the preset with the given name, ignoring case
+func GetMailProviderPreset(name string) (MailProviderPreset, bool) {
+	for _, preset := range mailProviderPresets {
+		if strings.EqualFold(preset.Name, strings.TrimSpace(name)) {
+			return preset, true
+		}
+	}
+	return MailProviderPreset{}, false
+}
+
+// MailProviderPresetNames returns the names of the presets, for usage messages
+func MailProviderPresetNames() []string {
+	names := []string{}
+	for _, preset := range mailProviderPresets {
+		names = append(names, preset.Name)
+	}
+	return names
+}
+
+// NewAccount returns an account with the servers of the preset that sends from and receives at
+// address.  The same username and password are used for SMTP and IMAP, which is how most providers
+// work; the servers of the generic presets are left empty for the caller to set.
+func (p MailProviderPreset) NewAccount(name string, address string, username string, password string) MailAccountConfig {
+	return MailAccountConfig{
+		Name: name,
+		SMTP: &SMTPConfig{
+			SenderAddress: address,
+			ServerAddress: p.SMTPServer,
+			Port:          p.SMTPPort,
+			UseTLS:        p.UseTLS,
+			Username:      username,
+			Password:      secrets.CreateSealedItem(password),
+		},
+		IMAP: &IMAPConfig{
+			RecipientAddress: address,
+			ServerAddress:    p.IMAPServer,
+			Port:             p.IMAPPort,
+			UseTLS:           p.UseTLS,
+			Username:         username,
+			Password:         secrets.CreateSealedItem(password),
+			MailboxName:      DEFAULT_MAILBOX_NAME,
+		},
+	}
+}
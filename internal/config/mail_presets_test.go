@@ -0,0 +1,93 @@
+package config
+
+import (
+	"testing"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetMailProviderPreset(t *testing.T) {
+
+	type testCase struct {
+		name         string
+		expectFound  bool
+		expectedName string
+	}
+
+	testCases := []testCase{
+		{name: "gmail", expectFound: true, expectedName: "gmail"},
+		{name: " GMail ", expectFound: true, expectedName: "gmail"},
+		{name: DEFAULT_MAIL_PRESET, expectFound: true, expectedName: "tls"},
+		{name: "hotmail", expectFound: false},
+		{name: "", expectFound: false},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		preset, found := GetMailProviderPreset(testCase.name)
+		assert.Equal(t, testCase.expectFound, found)
+		assert.Equal(t, testCase.expectedName, preset.Name)
+	}
+}
+
+func TestMailProviderPresets(t *testing.T) {
+
+	presets := MailProviderPresets()
+	assert.Equal(t, len(presets), len(MailProviderPresetNames()))
+	//the caller can't change the presets
+	presets[0].Name = "changed"
+	assert.NotEqual(t, "changed", MailProviderPresets()[0].Name)
+
+	for _, preset := range MailProviderPresets() {
+		t.Logf("checking preset %s", preset.Name)
+
+		assert.NotEmpty(t, preset.Description)
+		assert.NotZero(t, preset.SMTPPort)
+		assert.NotZero(t, preset.IMAPPort)
+		//the servers are given together or not at all
+		assert.Equal(t, preset.SMTPServer == "", preset.IMAPServer == "")
+		if preset.SMTPServer == "" {
+			continue
+		}
+
+		//an account for a provider is valid without any changes
+		account := preset.NewAccount("account", "monitor@example.com", "monitor", "password")
+		result, err := validation.ValidateObject(account)
+		require.Nil(t, err)
+		assert.Equal(t, 0, result.GetErrorCount(), result.HumanReadable())
+	}
+}
+
+func TestMailProviderPresetNewAccount(t *testing.T) {
+
+	preset, found := GetMailProviderPreset("plaintext")
+	require.True(t, found)
+
+	account := preset.NewAccount("account", "monitor@example.com", "monitor", "password")
+	assert.Equal(t, "account", account.Name)
+	expectedSMTP := SMTPConfig{
+		SenderAddress: "monitor@example.com",
+		ServerAddress: "",
+		Port:          25,
+		UseTLS:        false,
+		Username:      "monitor",
+		Password:      account.SMTP.Password,
+	}
+	assert.Equal(t, expectedSMTP, *account.SMTP)
+	assert.Equal(t, "password", account.SMTP.Password.GetValue())
+
+	expectedIMAP := IMAPConfig{
+		RecipientAddress: "monitor@example.com",
+		ServerAddress:    "",
+		Port:             143,
+		UseTLS:           false,
+		Username:         "monitor",
+		Password:         account.IMAP.Password,
+		MailboxName:      DEFAULT_MAILBOX_NAME,
+	}
+	assert.Equal(t, expectedIMAP, *account.IMAP)
+	assert.Equal(t, "password", account.IMAP.Password.GetValue())
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configs are versioned by the top level "version" field, so that older configs can still be read
+// after a field changes shape.  A config without a version is version 1, the format from before
+// versioning.  Older documents are upgraded one version at a time by the migrations below, which
+// work on the YAML node tree so the comments, layout and sealed values of the document are kept.
+//
+// Configs are upgraded in memory whenever they are read, and "varanus config migrate" writes the
+// upgraded document.
+
+// CONFIG_VERSION_FIELD is the top level field that has the version of a config
+const CONFIG_VERSION_FIELD = "version"
+
+// FIRST_CONFIG_VERSION is the version of a config that doesn't have a version field
+const FIRST_CONFIG_VERSION = 1
+
+// ConfigMigration upgrades a config document from one version to the next
+type ConfigMigration struct {
+	//Description says what changes, like "use_tls becomes tls_mode"
+	Description string
+	//Migrate changes the top level mapping node of the document.  The version field is updated
+	//afterwards, so Migrate doesn't need to.
+	Migrate func(root *yaml.Node) error
+}
+
+// configMigrations has the migrations in order; the migration at index i upgrades a config from
+// version FIRST_CONFIG_VERSION+i.  New migrations are added at the end.
+var configMigrations = []ConfigMigration{}
+
+// RegisterConfigMigration adds a migration after the last one, so that configs are upgraded one
+// version further.  It returns a function that removes the migration again, for tests that need a
+// migration without changing the version for the rest of the package.
+func RegisterConfigMigration(migration ConfigMigration) func() {
+	saved := configMigrations
+	configMigrations = append(slices.Clip(saved), migration)
+	return func() { configMigrations = saved }
+}
+
+// CurrentConfigVersion returns the version that configs are upgraded to, which is one past the
+// last migration
+func CurrentConfigVersion() int {
+	return FIRST_CONFIG_VERSION + len(configMigrations)
+}
+
+// AppliedMigration is a migration that was applied to a config document
+type AppliedMigration struct {
+	FromVersion int
+	ToVersion   int
+	Description string
+}
+
+func (am AppliedMigration) String() string {
+	return fmt.Sprintf("version %d to %d: %s", am.FromVersion, am.ToVersion, am.Description)
+}
+
+// migrateNode upgrades the document or mapping node root to the current version and returns the
+// migrations that were applied, which are none if it was already current.  Configs newer than the
+// current version are an error because they can't be downgraded.
+func migrateNode(root *yaml.Node) ([]AppliedMigration, error) {
+	applied := []AppliedMigration{}
+	mapping := resolveNode(root)
+	if mapping.Kind != yaml.MappingNode {
+		//an empty document, or one that isn't a config, which the decoder reports
+		return applied, nil
+	}
+
+	version, err := getNodeVersion(mapping)
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentConfigVersion() {
+		return nil, fmt.Errorf("config version %d is newer than the latest version this varanus supports, %d",
+			version, CurrentConfigVersion())
+	}
+
+	for ; version < CurrentConfigVersion(); version++ {
+		migration := configMigrations[version-FIRST_CONFIG_VERSION]
+		err = migration.Migrate(mapping)
+		if err != nil {
+			return nil, fmt.Errorf("could not upgrade the config from version %d to %d: %w", version, version+1, err)
+		}
+		setNodeVersion(mapping, version+1)
+		applied = append(applied, AppliedMigration{
+			FromVersion: version,
+			ToVersion:   version + 1,
+			Description: migration.Description,
+		})
+	}
+	return applied, nil
+}
+
+// migrateConfigData upgrades the config in data to the current version.  If it was already
+// current, data is returned as is so that errors keep its line numbers; otherwise the upgraded
+// document is returned as YAML.
+func migrateConfigData(data []byte) ([]byte, []AppliedMigration, error) {
+	var root yaml.Node
+	err := yaml.Unmarshal(data, &root)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unmarshal error: %w", err)
+	}
+	applied, err := migrateNode(&root)
+	if err != nil || len(applied) == 0 {
+		return data, applied, err
+	}
+
+	var migrated bytes.Buffer
+	encoder := yaml.NewEncoder(&migrated)
+	encoder.SetIndent(2)
+	err = encoder.Encode(&root)
+	if err != nil {
+		//unreachable because the node tree was just decoded
+		return nil, nil, fmt.Errorf("could not encode the upgraded config: %w", err)
+	}
+	return migrated.Bytes(), applied, nil
+}
+
+// getNodeVersion returns the version of the config in a mapping node
+func getNodeVersion(mapping *yaml.Node) (int, error) {
+	versionNode := mappingValue(mapping, CONFIG_VERSION_FIELD)
+	if versionNode == nil {
+		return FIRST_CONFIG_VERSION, nil
+	}
+	version, err := strconv.Atoi(versionNode.Value)
+	if err != nil || versionNode.Kind != yaml.ScalarNode {
+		return 0, fmt.Errorf("the config version '%s' is not a whole number", versionNode.Value)
+	}
+	if version < FIRST_CONFIG_VERSION {
+		return 0, fmt.Errorf("the config version must be at least %d, not %d", FIRST_CONFIG_VERSION, version)
+	}
+	return version, nil
+}
+
+// setNodeVersion sets the version field of a mapping node, adding it at the top if it is missing
+func setNodeVersion(mapping *yaml.Node, version int) {
+	versionNode := mappingValue(mapping, CONFIG_VERSION_FIELD)
+	if versionNode == nil {
+		versionNode = &yaml.Node{Kind: yaml.ScalarNode}
+		keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: CONFIG_VERSION_FIELD}
+		mapping.Content = append([]*yaml.Node{keyNode, versionNode}, mapping.Content...)
+	}
+	versionNode.Tag = "!!int"
+	versionNode.Style = 0
+	versionNode.Value = strconv.Itoa(version)
+}
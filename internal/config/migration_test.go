@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"testing"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// useTestMigrations replaces the registry with migrations that rename a field, for the duration
+// of the test
+func useTestMigrations(t *testing.T) {
+	saved := configMigrations
+	t.Cleanup(func() { configMigrations = saved })
+
+	renameKey := func(from string, to string) func(root *yaml.Node) error {
+		return func(root *yaml.Node) error {
+			mail := mappingValue(root, "mail")
+			if mail == nil || mail.Kind != yaml.MappingNode {
+				return fmt.Errorf("there is no mail section")
+			}
+			for keyIndex := 0; keyIndex+1 < len(mail.Content); keyIndex += 2 {
+				if mail.Content[keyIndex].Value == from {
+					mail.Content[keyIndex].Value = to
+				}
+			}
+			return nil
+		}
+	}
+	configMigrations = []ConfigMigration{
+		{Description: "mail.old_accounts becomes mail.interim_accounts", Migrate: renameKey("old_accounts", "interim_accounts")},
+		{Description: "mail.interim_accounts becomes mail.accounts", Migrate: renameKey("interim_accounts", "accounts")},
+	}
+}
+
+const MIGRATION_TEST_V1_YAML = `# an old config
+mail:
+  old_accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: joeuser@example.com
+        password: sealed(AAAA) # keep me
+`
+
+func TestMigrateNode(t *testing.T) {
+	useTestMigrations(t)
+	assert.Equal(t, 3, CurrentConfigVersion())
+
+	type testCase struct {
+		yaml string
+		//the versions migrated from, in order
+		fromVersions  []int
+		errorContains string
+	}
+
+	testCases := []testCase{
+		{yaml: MIGRATION_TEST_V1_YAML, fromVersions: []int{1, 2}},
+		{yaml: "version: 1\n" + MIGRATION_TEST_V1_YAML, fromVersions: []int{1, 2}},
+		{yaml: "version: 2\nmail:\n  interim_accounts: []\n", fromVersions: []int{2}},
+		{yaml: "version: 3\nmail:\n  accounts: []\n", fromVersions: []int{}},
+		{yaml: "", fromVersions: []int{}},
+		{yaml: "version: 4\nmail: {}\n", errorContains: "config version 4 is newer than the latest version this varanus supports, 3"},
+		{yaml: "version: 0\nmail: {}\n", errorContains: "the config version must be at least 1, not 0"},
+		{yaml: "version: one\nmail: {}\n", errorContains: "the config version 'one' is not a whole number"},
+		{yaml: "version: 1\n", errorContains: "could not upgrade the config from version 1 to 2: there is no mail section"},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		var root yaml.Node
+		err := yaml.Unmarshal([]byte(testCase.yaml), &root)
+		require.Nil(t, err)
+
+		applied, err := migrateNode(&root)
+		if testCase.errorContains != "" {
+			assert.ErrorContains(t, err, testCase.errorContains)
+			continue
+		}
+		require.Nil(t, err)
+		fromVersions := []int{}
+		for _, migration := range applied {
+			fromVersions = append(fromVersions, migration.FromVersion)
+			assert.Equal(t, migration.FromVersion+1, migration.ToVersion)
+		}
+		assert.Equal(t, testCase.fromVersions, fromVersions)
+	}
+}
+
+func TestReadMigratedConfigDocument(t *testing.T) {
+	useTestMigrations(t)
+
+	document, err := ReadConfigDocument([]byte(MIGRATION_TEST_V1_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+
+	//the config is read as the current version
+	require.Len(t, document.Config.Mail.Accounts, 1)
+	assert.Equal(t, 3, document.Config.Version)
+	assert.Equal(t, "sealed(AAAA)", document.Config.Mail.Accounts[0].SMTP.Password.GetValue())
+	require.Len(t, document.Migrations, 2)
+	assert.Equal(t, "version 1 to 2: mail.old_accounts becomes mail.interim_accounts", document.Migrations[0].String())
+
+	//the document is written in the current version with its comments and sealed values
+	data, err := document.ToBytes()
+	require.Nil(t, err)
+	expected := `version: 3
+# an old config
+mail:
+  accounts:
+    - name: test1
+      smtp:
+        sender_address: example@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: joeuser@example.com
+        password: sealed(AAAA) # keep me
+`
+	assert.Equal(t, expected, string(data))
+
+	//validation warns that the file should be upgraded
+	validationResult, err := validation.ValidateObject(document.Config)
+	require.Nil(t, err)
+	warnings := validationResult.GetWarningList()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "", warnings[0].Path)
+	assert.Contains(t, warnings[0].Error,
+		"the config is version 1 and was upgraded to version 3 when it was read; run 'varanus config migrate' to upgrade the file")
+
+	//a config that is already current has no migrations and no warning
+	document, err = ReadConfigDocument(data, CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Len(t, document.Migrations, 0)
+	assert.Len(t, document.Config.migratedSources, 0)
+}
+
+func TestConfigMigrationsAreComplete(t *testing.T) {
+	//every version up to the current one can be upgraded
+	for index, migration := range configMigrations {
+		assert.NotEmpty(t, migration.Description, "migration from version %d", FIRST_CONFIG_VERSION+index)
+		assert.NotNil(t, migration.Migrate, "migration from version %d", FIRST_CONFIG_VERSION+index)
+	}
+	assert.Equal(t, FIRST_CONFIG_VERSION+len(configMigrations), CurrentConfigVersion())
+}
+
+func TestReadMigratedConfigSet(t *testing.T) {
+	useTestMigrations(t)
+
+	dir := writeConfigFiles(t, map[string]string{
+		"10-old.yaml":     MIGRATION_TEST_V1_YAML,
+		"20-current.yaml": "version: 3\nmail:\n  accounts: []\n",
+	})
+	set, err := ReadConfigSetFromDir(dir)
+	require.Nil(t, err)
+	require.Len(t, set.Config.Mail.Accounts, 1)
+
+	//only the older file is reported
+	validationResult, err := validation.ValidateObject(set.Config)
+	require.Nil(t, err)
+	warnings := validationResult.GetWarningList()
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0].Error, "the config in "+dir+"/10-old.yaml is version 1")
+}
@@ -5,7 +5,9 @@ import (
 )
 
 type MonitorConfig struct {
-	EmailMonitors []EmailMonitorConfig `yaml:"email_monitors"`
+	EmailMonitors []EmailMonitorConfig `yaml:"email_monitors" doc:"The monitors that send a test email from one account to another"`
+	//Monitors are the monitors of the types registered by modules, see registry.go
+	Monitors []TypedMonitorConfig `yaml:"monitors,omitempty" doc:"The monitors of the types registered by modules, chosen by their type field"`
 }
 
 func (c MonitorConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
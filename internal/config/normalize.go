@@ -0,0 +1,125 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"varanus/internal/walker"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Configs are normalized when they are read, see validation.Normalizable, so that validation and
+// the monitors use the trimmed, lowercased and defaulted values.  The documents keep the values
+// as they were written until UpdateNormalizedValues copies the normalized ones into them, which
+// is how "config fmt" writes a config in its normalized form.
+
+// UpdateNormalizedValues copies the normalized values of Config into the node tree: strings that
+// were trimmed or lowercased are replaced, and default ports that were left out are added after
+// the fields that come before them.  Comments and the rest of the tree are kept, values that
+// were interpolated keep their references, and fields merged in with "<<" are left to the mapping
+// they come from.
+//
+// The paths of the values that were changed or added are returned.
+func (d *ConfigDocument) UpdateNormalizedValues() []string {
+	updater := normalizedValueUpdater{interpolate: d.Config.Interpolate, updated: []string{}}
+	updater.update(resolveNode(&d.root), reflect.ValueOf(*d.Config), "")
+	return updater.updated
+}
+
+type normalizedValueUpdater struct {
+	interpolate bool
+	updated     []string
+}
+
+func (u *normalizedValueUpdater) update(node *yaml.Node, value reflect.Value, path string) {
+	node = resolveNode(node)
+
+	switch value.Type() {
+	case durationType, sealedItemType, forceConfigFailureType:
+		//these aren't changed by normalization, and their text differs from their decoded value
+		return
+	}
+
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			u.update(node, value.Elem(), path)
+		}
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		//missing fields are added after the field before them, so they are in the struct order
+		insertAt := 0
+		for index := 0; index < value.NumField(); index++ {
+			field := value.Type().Field(index)
+			key := walker.GetYamlNameFromTag(field.Tag)
+			if !field.IsExported() || key == "" || key == "-" {
+				continue
+			}
+			fieldPath := key
+			if path != "" {
+				fieldPath = path + "." + key
+			}
+
+			keyIndex := findMappingKey(node, key)
+			if keyIndex >= 0 {
+				u.update(node.Content[keyIndex+1], value.Field(index), fieldPath)
+				insertAt = keyIndex + 2
+				continue
+			}
+			if _, merged := findMappingEntry(node, key); merged != nil {
+				//the field is merged in with "<<", so it is updated in the mapping it comes from
+				continue
+			}
+			//the only defaults that normalization fills in are the ports
+			if isUintKind(field.Type.Kind()) && !value.Field(index).IsZero() {
+				keyNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+				valueNode := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: fmt.Sprint(value.Field(index).Uint())}
+				node.Content = append(node.Content[:insertAt], append([]*yaml.Node{keyNode, valueNode}, node.Content[insertAt:]...)...)
+				insertAt += 2
+				u.updated = append(u.updated, fieldPath)
+			}
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for index := 0; index < min(value.Len(), len(node.Content)); index++ {
+			u.update(node.Content[index], value.Index(index), fmt.Sprintf("%s[%d]", path, index))
+		}
+	case reflect.String:
+		if node.Kind != yaml.ScalarNode || node.Value == value.String() {
+			return
+		}
+		if u.interpolate && hasInterpolationReference(node.Value) {
+			return
+		}
+		node.Value = value.String()
+		//the quotes were likely only there for the whitespace, so they are left to the encoder,
+		//and the tag makes it quote the value if it would otherwise be read as a number, bool, etc.
+		node.Style &^= yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+		node.Tag = "!!str"
+		u.updated = append(u.updated, path)
+	}
+}
+
+// findMappingKey returns the index of the key in the content of a mapping node, or -1.  Like the
+// decoder, the last key wins if there are duplicates.
+func findMappingKey(node *yaml.Node, key string) int {
+	found := -1
+	for keyIndex := 0; keyIndex+1 < len(node.Content); keyIndex += 2 {
+		if node.Content[keyIndex].Value == key {
+			found = keyIndex
+		}
+	}
+	return found
+}
+
+func isUintKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
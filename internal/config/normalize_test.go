@@ -0,0 +1,238 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const NORMALIZE_TEST_YAML = `# the account is written carelessly
+mail:
+  accounts:
+    - name: " work "
+      address_family: IPv4
+      smtp:
+        sender_address: " work@example.com"
+        server_address: " SMTP.Example.COM "
+        use_tls: true
+        username: work
+        password: secret1
+    - name: home
+      imap:
+        recipient_address: home@example.org
+        server_address: imap.example.org
+        username: " home "
+        password: secret2
+        mailbox_name: "INBOX "
+  send_limits:
+    - min_period: 10m
+      account_names: [" work"]
+monitoring:
+  email_monitors:
+    - from_account: "work "
+      to_account: home
+      test_period: 1h
+      address_families: [IPV6]
+      notifications:
+        - mail: " home"
+`
+
+const NORMALIZED_TEST_YAML = `# the account is written carelessly
+mail:
+  accounts:
+    - name: work
+      address_family: ipv4
+      smtp:
+        sender_address: work@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: work
+        password: secret1
+    - name: home
+      imap:
+        recipient_address: home@example.org
+        server_address: imap.example.org
+        port: 143
+        username: home
+        password: secret2
+        mailbox_name: INBOX
+  send_limits:
+    - min_period: 10m
+      account_names: [work]
+monitoring:
+  email_monitors:
+    - from_account: work
+      to_account: home
+      test_period: 1h
+      address_families: [ipv6]
+      notifications:
+        - mail: home
+`
+
+func TestReadConfigNormalizes(t *testing.T) {
+
+	config, err := ReadConfig([]byte(NORMALIZE_TEST_YAML))
+	require.Nil(t, err)
+
+	work := config.Mail.Accounts[0]
+	assert.Equal(t, "work", work.Name)
+	assert.Equal(t, ADDRESS_FAMILY_IPV4, work.AddressFamily)
+	assert.Equal(t, "work@example.com", work.SMTP.SenderAddress)
+	assert.Equal(t, "smtp.example.com", work.SMTP.ServerAddress)
+	assert.Equal(t, uint(DEFAULT_SMTP_TLS_PORT), work.SMTP.Port)
+
+	home := config.Mail.Accounts[1]
+	assert.Equal(t, "home", home.IMAP.Username)
+	assert.Equal(t, "INBOX", home.IMAP.MailboxName)
+	assert.Equal(t, uint(DEFAULT_IMAP_PORT), home.IMAP.Port)
+
+	assert.Equal(t, []string{"work"}, config.Mail.SendLimits[0].AccountNames)
+	monitor := config.MonitoringConfig.EmailMonitors[0]
+	assert.Equal(t, "work", monitor.FromAccount)
+	assert.Equal(t, []AddressFamily{ADDRESS_FAMILY_IPV6}, monitor.AddressFamilies)
+	assert.Equal(t, "home", monitor.Notifications[0].Mail)
+
+	//the normalized config is the same as the one written normally
+	normalized, err := ReadConfig([]byte(NORMALIZED_TEST_YAML))
+	require.Nil(t, err)
+	assert.Equal(t, normalized, config)
+}
+
+func TestNormalizeTemplatePorts(t *testing.T) {
+
+	type testCase struct {
+		templateSmtp string
+		accountSmtp  string
+		expectedPort uint
+	}
+
+	testCases := []testCase{
+		//the account inherits the port of the template
+		{templateSmtp: "port: 587\n        use_tls: true", accountSmtp: "username: work", expectedPort: 587},
+		//the default port is filled in after the use_tls of the template is inherited
+		{templateSmtp: "use_tls: true", accountSmtp: "username: work", expectedPort: DEFAULT_SMTP_TLS_PORT},
+		{templateSmtp: "use_tls: true", accountSmtp: "use_tls: false", expectedPort: DEFAULT_SMTP_PORT},
+		//the port of the account wins
+		{templateSmtp: "port: 587", accountSmtp: "port: 2525", expectedPort: 2525},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		document, err := ReadConfigDocument([]byte(`mail:
+  templates:
+    - name: provider
+      smtp:
+        server_address: smtp.example.com
+        `+testCase.templateSmtp+`
+  accounts:
+    - name: work
+      extends: provider
+      smtp:
+        `+testCase.accountSmtp+`
+`), CONFIG_FORMAT_YAML)
+		require.Nil(t, err)
+
+		set, err := mergeConfigDocuments([]*ConfigDocument{document})
+		require.Nil(t, err)
+		assert.Equal(t, testCase.expectedPort, set.Config.Mail.Accounts[0].SMTP.Port)
+	}
+}
+
+func TestConfigDocumentUpdateNormalizedValues(t *testing.T) {
+
+	document, err := ReadConfigDocument([]byte(NORMALIZE_TEST_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+
+	updated := document.UpdateNormalizedValues()
+	expectedUpdated := []string{
+		"mail.accounts[0].name",
+		"mail.accounts[0].smtp.sender_address",
+		"mail.accounts[0].smtp.server_address",
+		"mail.accounts[0].smtp.port",
+		"mail.accounts[0].address_family",
+		"mail.accounts[1].imap.port",
+		"mail.accounts[1].imap.username",
+		"mail.accounts[1].imap.mailbox_name",
+		"mail.send_limits[0].account_names[0]",
+		"monitoring.email_monitors[0].from_account",
+		"monitoring.email_monitors[0].notifications[0].mail",
+		"monitoring.email_monitors[0].address_families[0]",
+	}
+	assert.Equal(t, expectedUpdated, updated)
+
+	data, err := document.ToBytes()
+	require.Nil(t, err)
+	assert.Equal(t, NORMALIZED_TEST_YAML, string(data))
+
+	//a normalized document is unchanged
+	document, err = ReadConfigDocument(data, CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Equal(t, []string{}, document.UpdateNormalizedValues())
+}
+
+func TestConfigDocumentUpdateNormalizedValuesKeepsReferences(t *testing.T) {
+
+	t.Setenv("VARANUS_NORMALIZE_TEST_HOST", " SMTP.Example.COM ")
+	document, err := ReadConfigDocument([]byte(`interpolate: true
+mail:
+  accounts:
+    - name: " work"
+      smtp:
+        server_address: ${VARANUS_NORMALIZE_TEST_HOST}
+        port: 465
+`), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	assert.Equal(t, "smtp.example.com", document.Config.Mail.Accounts[0].SMTP.ServerAddress)
+
+	assert.Equal(t, []string{"mail.accounts[0].name"}, document.UpdateNormalizedValues())
+	data, err := document.ToBytes()
+	require.Nil(t, err)
+	assert.Contains(t, string(data), "server_address: ${VARANUS_NORMALIZE_TEST_HOST}\n")
+	assert.Contains(t, string(data), "- name: work\n")
+}
+
+func TestConfigDocumentUpdateNormalizedValuesMergeKeys(t *testing.T) {
+
+	document, err := ReadConfigDocument([]byte(`mail:
+  accounts:
+    - name: work
+      smtp: &smtp
+        sender_address: work@example.com
+        server_address: " SMTP.Example.COM"
+        use_tls: true
+        username: work
+        password: secret1
+    - name: home
+      smtp:
+        <<: *smtp
+        sender_address: home@example.com
+`), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+
+	//the merged fields are normalized in the anchored mapping, and the port isn't repeated
+	expectedUpdated := []string{
+		"mail.accounts[0].smtp.server_address",
+		"mail.accounts[0].smtp.port",
+	}
+	assert.Equal(t, expectedUpdated, document.UpdateNormalizedValues())
+	data, err := document.ToBytes()
+	require.Nil(t, err)
+	assert.Equal(t, `mail:
+  accounts:
+    - name: work
+      smtp: &smtp
+        sender_address: work@example.com
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+        username: work
+        password: secret1
+    - name: home
+      smtp:
+        <<: *smtp
+        sender_address: home@example.com
+`, string(data))
+}
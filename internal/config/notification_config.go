@@ -1,30 +1,32 @@
 package config
 
-import "varanus/internal/validation"
+import (
+	"strings"
+	"varanus/internal/validation"
+)
 
 type NotificationConfig struct {
-	Mail string `yaml:"mail"`
+	Mail string `yaml:"mail" doc:"The name of the account that the notification email is sent to" example:"home-account" validate:"required" message:"mail entry must not be empty"`
 	//TODO add more notification methods when we create more account types
 }
 
+// Normalize trims the account name
+func (c *NotificationConfig) Normalize() {
+	c.Mail = strings.TrimSpace(c.Mail)
+}
+
 func (c NotificationConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
 
-	vConfig := castInterfaceToVaranusConfig(root)
+	//check the rules of the validate tags
+	err := validation.CheckFieldRules(vet, c)
+	if err != nil {
+		return err
+	}
 
-	if len(c.Mail) == 0 {
-		vet.AddValidationError(
-			c,
-			"mail entry must not be empty",
-		)
-	} else { //else avoids a double validation error from an empty string
-		if vConfig.Mail.GetAccountByName(c.Mail) == nil {
-			vet.AddValidationError(
-				c,
-				"notification mail account named '%s' does not exist", c.Mail,
-			)
-		}
+	vConfig := castInterfaceToVaranusConfig(root)
 
-	}
+	//the notification is sent from the account itself
+	AccountRef{Description: "notification mail account", Name: c.Mail, Needs: ACCOUNT_CAPABILITY_SMTP}.Validate(vet, c, vConfig.Mail)
 
 	return nil
 }
@@ -27,6 +27,15 @@ func TestNotificationConfigValidation(t *testing.T) {
 			Mutator: func(c *NotificationConfig) { c.Mail = "nonexistent" },
 			Error:   "notification mail account named 'nonexistent' does not exist",
 		},
+		{
+			Mutator: func(c *NotificationConfig) { c.Mail = "tset3" },
+			Error:   "notification mail account named 'tset3' does not exist; did you mean 'test3' or 'test1'?",
+		},
+		{
+			//the notification is sent from the account, so it must be able to send
+			Mutator: func(c *NotificationConfig) { c.Mail = "test2" },
+			Error:   "notification mail account named 'test2' must have an SMTP configuration; did you mean 'test1' or 'test3'?",
+		},
 	}
 
 	baseConfig :=
@@ -68,9 +77,10 @@ func TestNotificationConfigValidation(t *testing.T) {
 					},
 					{
 						Name: "test3",
-						IMAP: &IMAPConfig{
+						SMTP: &SMTPConfig{
+							SenderAddress: "baz@foo.com",
 							ServerAddress: "bar.example.com",
-							Port:          993,
+							Port:          465,
 							Username:      "user",
 							Password:      secrets.CreateSealedItem("password"),
 						},
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"varanus/internal/secrets"
+	"varanus/internal/util"
+	"varanus/internal/validation"
+)
+
+// PROXY_SCHEME_SOCKS5 is a SOCKS5 proxy where the server addresses are resolved locally
+const PROXY_SCHEME_SOCKS5 = "socks5"
+
+// PROXY_SCHEME_SOCKS5H is a SOCKS5 proxy where the server addresses are resolved by the proxy
+const PROXY_SCHEME_SOCKS5H = "socks5h"
+
+// PROXY_SCHEME_HTTP is an HTTP proxy that supports the CONNECT method
+const PROXY_SCHEME_HTTP = "http"
+
+// SupportedProxySchemes lists the URL schemes accepted for a proxy url
+var SupportedProxySchemes = []string{PROXY_SCHEME_SOCKS5, PROXY_SCHEME_SOCKS5H, PROXY_SCHEME_HTTP}
+
+// ProxyConfig describes a proxy that outbound SMTP and IMAP connections are routed through.
+type ProxyConfig struct {
+	URL      string              `yaml:"url" doc:"The URL of the proxy, with a socks5, socks5h or http scheme" example:"socks5://proxy.example.com:1080"`
+	Username string              `yaml:"username,omitempty" doc:"The username to log in to the proxy" default:"no login" example:"proxyuser"`
+	Password *secrets.SealedItem `yaml:"password,omitempty" doc:"The password to log in to the proxy" default:"no login" example:"sealed(...)"`
+}
+
+// Normalize trims the url and username
+func (c *ProxyConfig) Normalize() {
+	c.URL = strings.TrimSpace(c.URL)
+	c.Username = strings.TrimSpace(c.Username)
+}
+
+// ParseURL returns the parsed proxy URL.
+func (c ProxyConfig) ParseURL() (*url.URL, error) {
+	return url.Parse(strings.TrimSpace(c.URL))
+}
+
+func (c ProxyConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
+
+	proxyUrl, err := c.ParseURL()
+	if err != nil {
+		vet.AddValidationError(
+			c,
+			"proxy url '%s' could not be parsed: %s", c.URL, err,
+		)
+		//the remaining url checks can't be done without a parsed url
+	} else {
+		if !slices.Contains(SupportedProxySchemes, proxyUrl.Scheme) {
+			vet.AddValidationError(
+				c,
+				"proxy url '%s' has an unsupported scheme '%s'; expected one of %s",
+				c.URL, proxyUrl.Scheme, strings.Join(SupportedProxySchemes, ", "),
+			)
+		}
+
+		if !util.IsUrlHost(proxyUrl.Hostname()) {
+			vet.AddValidationError(
+				c,
+				"proxy url '%s' does not have a valid hostname", c.URL,
+			)
+		}
+
+		if proxyUrl.Port() == "" {
+			vet.AddValidationError(
+				c,
+				"proxy url '%s' must include a port", c.URL,
+			)
+		}
+
+		if proxyUrl.User != nil {
+			vet.AddValidationError(
+				c,
+				"proxy url '%s' must not contain credentials; use the username and password fields so the password can be sealed",
+				proxyUrl.Redacted(),
+			)
+		}
+
+		if (proxyUrl.Path != "" && proxyUrl.Path != "/") || proxyUrl.RawQuery != "" || proxyUrl.Fragment != "" {
+			vet.AddValidationError(
+				c,
+				"proxy url '%s' must not contain a path, query, or fragment", c.URL,
+			)
+		}
+	}
+
+	if c.Password != nil && len(strings.TrimSpace(c.Username)) == 0 {
+		vet.AddValidationError(
+			c,
+			"proxy password is set but the username is empty",
+		)
+	}
+
+	//password will be validated on its own because it is also Validatable
+
+	return nil
+}
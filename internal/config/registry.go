@@ -0,0 +1,336 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"varanus/internal/walker"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Modules add their own config types by registering them, instead of adding fields to the config
+// types here.  There are two registries:
+//
+//   - module configs are sections under "modules:", chosen by their key, like "modules.dns"
+//   - monitor types are items of "monitoring.monitors", chosen by their "type:" field
+//
+// The registered types are decoded with the same strictness as the rest of the config, and since
+// the walker follows the interfaces they are stored in, they are validated, normalized, sealed
+// and diffed like the built-in types.  Types should be registered from an init function, before
+// any config is read.
+//
+// The registry only has the configs of the types, not a way to run them, so Compile leaves the
+// monitors of registered types out of the runtime config and the daemon doesn't run them.
+
+// MONITOR_TYPE_KEY is the field of a monitor that chooses its registered type
+const MONITOR_TYPE_KEY = "type"
+
+// ConfigGenerator returns a pointer to a new, empty config of a registered type
+type ConfigGenerator func() interface{}
+
+type registeredConfig struct {
+	description string //describes the type in the config reference
+	generator   ConfigGenerator
+}
+
+type configRegistry struct {
+	kind    string //for messages, like "monitor type"
+	entries map[string]registeredConfig
+}
+
+var moduleConfigRegistry = configRegistry{kind: "module config", entries: map[string]registeredConfig{}}
+var monitorTypeRegistry = configRegistry{kind: "monitor type", entries: map[string]registeredConfig{}}
+
+// RegisterModuleConfig registers the config of a module, which is read from "modules.<name>".  The
+// description is used in the config reference.
+func RegisterModuleConfig(name string, description string, generator ConfigGenerator) error {
+	return moduleConfigRegistry.register(name, description, generator)
+}
+
+// RegisterMonitorType registers a type of monitor, which is read from the items of
+// "monitoring.monitors" that have "type: <name>".  The config can't have its own "type" field.
+// The monitors of the type are checked with the config, but they are not run by the daemon.
+func RegisterMonitorType(name string, description string, generator ConfigGenerator) error {
+	err := monitorTypeRegistry.register(name, description, generator)
+	if err == nil && hasYamlField(reflect.TypeOf(generator()).Elem(), MONITOR_TYPE_KEY) {
+		delete(monitorTypeRegistry.entries, name)
+		err = fmt.Errorf("the config of monitor type '%s' can't have a '%s' field", name, MONITOR_TYPE_KEY)
+	}
+	return err
+}
+
+// RegisteredModuleConfigs returns the names of the registered module configs, sorted
+func RegisteredModuleConfigs() []string {
+	return moduleConfigRegistry.names()
+}
+
+// RegisteredMonitorTypes returns the names of the registered monitor types, sorted
+func RegisteredMonitorTypes() []string {
+	return monitorTypeRegistry.names()
+}
+
+func (r *configRegistry) register(name string, description string, generator ConfigGenerator) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("a %s must have a name", r.kind)
+	}
+	if _, found := r.entries[name]; found {
+		return fmt.Errorf("a %s named '%s' has already been registered", r.kind, name)
+	}
+	//the walker can only change the fields of a config through a pointer
+	configType := reflect.TypeOf(generator())
+	if configType == nil || configType.Kind() != reflect.Pointer || configType.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("the generator of %s '%s' must return a pointer to a struct, not %s", r.kind, name, configType)
+	}
+	r.entries[name] = registeredConfig{description: description, generator: generator}
+	return nil
+}
+
+func (r *configRegistry) names() []string {
+	names := []string{}
+	for name := range r.entries {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// configType returns the struct type of a registered config
+func (r *configRegistry) configType(name string) reflect.Type {
+	return reflect.TypeOf(r.entries[name].generator()).Elem()
+}
+
+// decode decodes node into a new config of the registered type
+func (r *configRegistry) decode(name string, keyNode *yaml.Node, node *yaml.Node) (interface{}, error) {
+	entry, found := r.entries[name]
+	if !found {
+		registered := "none are registered"
+		if len(r.entries) > 0 {
+			registered = "expected one of " + strings.Join(r.names(), ", ")
+		}
+		return nil, fmt.Errorf("line %d: no %s named '%s' is registered; %s", keyNode.Line, r.kind, name, registered)
+	}
+	config := entry.generator()
+	return config, decodeKnownFields(node, config)
+}
+
+//**************************************************************************************************
+//** ModuleConfigs
+//**************************************************************************************************
+
+// ModuleConfigs holds the configs of the modules by their registered names.  Each value is a
+// pointer to the registered type.
+type ModuleConfigs map[string]interface{}
+
+func (m *ModuleConfigs) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: the modules must be a mapping of module names to their configs", node.Line)
+	}
+	*m = ModuleConfigs{}
+	typeErrors := &yaml.TypeError{}
+	for keyIndex := 0; keyIndex+1 < len(node.Content); keyIndex += 2 {
+		keyNode := node.Content[keyIndex]
+		config, err := moduleConfigRegistry.decode(keyNode.Value, keyNode, node.Content[keyIndex+1])
+		if err != nil && !collectTypeErrors(typeErrors, err) {
+			return err
+		}
+		(*m)[keyNode.Value] = config
+	}
+	if len(typeErrors.Errors) > 0 {
+		return typeErrors
+	}
+	return nil
+}
+
+//**************************************************************************************************
+//** TypedMonitorConfig
+//**************************************************************************************************
+
+// TypedMonitorConfig is a monitor of a registered type.  In the config, the fields of Config are
+// next to the type, like "{type: dns, host: example.org}".
+type TypedMonitorConfig struct {
+	Type string `yaml:"type" doc:"The registered type of the monitor, which chooses the rest of its fields" example:"dns"`
+	//Config is a pointer to the registered type
+	Config interface{} `yaml:",inline"`
+}
+
+func (c *TypedMonitorConfig) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: a monitor must be a mapping with a '%s' field", node.Line, MONITOR_TYPE_KEY)
+	}
+	//the type can be merged in with "<<", like the other fields
+	fields := flattenMerges(node)
+	typeIndex := findMappingKey(fields, MONITOR_TYPE_KEY)
+	if typeIndex < 0 {
+		return fmt.Errorf("line %d: the monitor has no '%s' field", node.Line, MONITOR_TYPE_KEY)
+	}
+
+	//the rest of the mapping is the config of the registered type
+	typeKey := fields.Content[typeIndex]
+	c.Type = resolveNode(fields.Content[typeIndex+1]).Value
+	fields.Content = slices.Delete(fields.Content, typeIndex, typeIndex+2)
+	config, err := monitorTypeRegistry.decode(c.Type, typeKey, fields)
+	c.Config = config
+	return err
+}
+
+func (c TypedMonitorConfig) MarshalYAML() (interface{}, error) {
+	node := &yaml.Node{}
+	err := node.Encode(c.Config)
+	if err != nil {
+		return nil, err
+	}
+	if node.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("the config of monitor type '%s' is not a mapping", c.Type)
+	}
+	typeNodes := []*yaml.Node{
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: MONITOR_TYPE_KEY},
+		{Kind: yaml.ScalarNode, Tag: "!!str", Value: c.Type},
+	}
+	node.Content = append(typeNodes, node.Content...)
+	return node, nil
+}
+
+//**************************************************************************************************
+//** strict decoding
+//**************************************************************************************************
+
+// decodeKnownFields decodes node into out, and, like a decoder with KnownFields set, reports the
+// fields that out doesn't have.  Node.Decode alone would ignore them, since the setting of the
+// decoder isn't passed down to UnmarshalYAML.
+//
+// The errors are returned as a *yaml.TypeError so that the decoder keeps going and reports them
+// with its own.
+func decodeKnownFields(node *yaml.Node, out interface{}) error {
+	typeErrors := &yaml.TypeError{}
+	err := node.Decode(out)
+	if err != nil && !collectTypeErrors(typeErrors, err) {
+		return err
+	}
+	findUnknownFields(node, reflect.TypeOf(out), &typeErrors.Errors)
+	if len(typeErrors.Errors) > 0 {
+		return typeErrors
+	}
+	return nil
+}
+
+// collectTypeErrors adds the messages of err to typeErrors if it is a *yaml.TypeError
+func collectTypeErrors(typeErrors *yaml.TypeError, err error) bool {
+	typeError, isTypeError := err.(*yaml.TypeError)
+	if isTypeError {
+		typeErrors.Errors = append(typeErrors.Errors, typeError.Errors...)
+	}
+	return isTypeError
+}
+
+var yamlUnmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+
+// findUnknownFields adds an error, in the format of the decoder, for each key of a mapping in node
+// that isn't a field of the struct it is decoded into
+func findUnknownFields(node *yaml.Node, t reflect.Type, errors *[]string) {
+	node = resolveNode(node)
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(yamlUnmarshalerType) {
+		//a type with its own UnmarshalYAML checks its own fields
+		return
+	}
+
+	switch {
+	case t.Kind() == reflect.Struct && node.Kind == yaml.MappingNode:
+		for keyIndex := 0; keyIndex+1 < len(node.Content); keyIndex += 2 {
+			keyNode := node.Content[keyIndex]
+			if isMergeKey(keyNode) {
+				//the keys merged in with "<<" are fields of the same struct
+				for _, source := range mergeSources(node.Content[keyIndex+1]) {
+					findUnknownFields(source, t, errors)
+				}
+				continue
+			}
+			field, found := findYamlField(t, keyNode.Value)
+			if !found {
+				*errors = append(*errors, fmt.Sprintf("line %d: field %s not found in type %s", keyNode.Line, keyNode.Value, t))
+				continue
+			}
+			findUnknownFields(node.Content[keyIndex+1], field.Type, errors)
+		}
+	case (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && node.Kind == yaml.SequenceNode:
+		for _, item := range node.Content {
+			findUnknownFields(item, t.Elem(), errors)
+		}
+	case t.Kind() == reflect.Map && node.Kind == yaml.MappingNode:
+		for keyIndex := 1; keyIndex < len(node.Content); keyIndex += 2 {
+			findUnknownFields(node.Content[keyIndex], t.Elem(), errors)
+		}
+	}
+}
+
+// mergeSources returns the mappings merged in by the value of a "<<" key, which is a mapping or a
+// sequence of mappings
+func mergeSources(merge *yaml.Node) []*yaml.Node {
+	merge = resolveNode(merge)
+	if merge.Kind != yaml.SequenceNode {
+		return []*yaml.Node{merge}
+	}
+	sources := []*yaml.Node{}
+	for _, source := range merge.Content {
+		sources = append(sources, resolveNode(source))
+	}
+	return sources
+}
+
+// flattenMerges returns a copy of a mapping where the keys merged in with "<<" are entries of the
+// mapping itself.  Like the decoder, the keys of the mapping take precedence over merged ones, and
+// the first merged mapping over the later ones.
+func flattenMerges(mapping *yaml.Node) *yaml.Node {
+	flat := *mapping
+	flat.Content = []*yaml.Node{}
+	merged := []*yaml.Node{}
+	for keyIndex := 0; keyIndex+1 < len(mapping.Content); keyIndex += 2 {
+		if isMergeKey(mapping.Content[keyIndex]) {
+			for _, source := range mergeSources(mapping.Content[keyIndex+1]) {
+				if source.Kind == yaml.MappingNode {
+					merged = append(merged, flattenMerges(source))
+				}
+			}
+			continue
+		}
+		flat.Content = append(flat.Content, mapping.Content[keyIndex], mapping.Content[keyIndex+1])
+	}
+	for _, source := range merged {
+		for keyIndex := 0; keyIndex+1 < len(source.Content); keyIndex += 2 {
+			if findMappingKey(&flat, source.Content[keyIndex].Value) < 0 {
+				flat.Content = append(flat.Content, source.Content[keyIndex], source.Content[keyIndex+1])
+			}
+		}
+	}
+	return &flat
+}
+
+// findYamlField returns the field of the struct type t, or of a struct inlined in it, that is
+// decoded from key
+func findYamlField(t reflect.Type, key string) (reflect.StructField, bool) {
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		if !field.IsExported() {
+			continue
+		}
+		if walker.IsYamlInline(field.Tag) && field.Type.Kind() == reflect.Struct {
+			if inlined, found := findYamlField(field.Type, key); found {
+				return inlined, true
+			}
+			continue
+		}
+		name := walker.GetYamlNameFromTag(field.Tag)
+		if name == "" {
+			//like the decoder, fields without a yaml name use their lowercased name
+			name = strings.ToLower(field.Name)
+		}
+		if name == key {
+			return field, true
+		}
+	}
+	return reflect.StructField{}, false
+}
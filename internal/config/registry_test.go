@@ -0,0 +1,257 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"varanus/internal/secrets"
+	"varanus/internal/validation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// the config types of a module and a monitor type, registered for the tests
+type testResolverConfig struct {
+	Servers  []string           `yaml:"servers" doc:"The DNS servers" example:"[1.1.1.1]"`
+	Retries  int                `yaml:"retries,omitempty" doc:"How often a lookup is retried" default:"0" example:"2"`
+	Password secrets.SealedItem `yaml:"password" doc:"The password for the servers" example:"sealed(...)"`
+}
+
+type testPingMonitorConfig struct {
+	Host    string        `yaml:"host" doc:"The host that is pinged" example:"example.org"`
+	Timeout time.Duration `yaml:"timeout" doc:"How long to wait for a reply" example:"5s"`
+}
+
+func (c testPingMonitorConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
+	if c.Host == "" {
+		vet.AddValidationError(c, "the host can't be empty")
+	}
+	return nil
+}
+
+func (c *testPingMonitorConfig) Normalize() {
+	c.Host = strings.ToLower(strings.TrimSpace(c.Host))
+}
+
+func init() {
+	err := RegisterModuleConfig("test_resolver", "The settings of the test resolver module.",
+		func() interface{} { return &testResolverConfig{} })
+	if err == nil {
+		err = RegisterMonitorType("test_ping", "A test monitor that pings a host.",
+			func() interface{} { return &testPingMonitorConfig{} })
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+const REGISTRY_TEST_YAML = `mail:
+  accounts: []
+monitoring:
+  email_monitors: []
+  monitors:
+    - type: test_ping
+      host: " Example.ORG "
+      timeout: 5s
+    - host: ""
+      timeout: 1m
+      type: test_ping
+modules:
+  test_resolver:
+    servers: [1.1.1.1, 8.8.8.8]
+    password: secret
+`
+
+func TestRegisterConfigTypes(t *testing.T) {
+	generator := func() interface{} { return &testPingMonitorConfig{} }
+
+	err := RegisterMonitorType("test_ping", "", generator)
+	assert.ErrorContains(t, err, "a monitor type named 'test_ping' has already been registered")
+
+	err = RegisterModuleConfig(" ", "", generator)
+	assert.ErrorContains(t, err, "a module config must have a name")
+
+	err = RegisterModuleConfig("test_value", "", func() interface{} { return testResolverConfig{} })
+	assert.ErrorContains(t, err, "the generator of module config 'test_value' must return a pointer to a struct, not config.testResolverConfig")
+
+	err = RegisterMonitorType("test_typed", "", func() interface{} { return &TypedMonitorConfig{} })
+	assert.ErrorContains(t, err, "the config of monitor type 'test_typed' can't have a 'type' field")
+
+	assert.Equal(t, []string{"test_resolver"}, RegisteredModuleConfigs())
+	assert.Equal(t, []string{"test_ping"}, RegisteredMonitorTypes())
+}
+
+func TestReadRegisteredConfigs(t *testing.T) {
+	config, err := ReadConfig([]byte(REGISTRY_TEST_YAML))
+	require.Nil(t, err)
+
+	//the registered types are decoded and normalized
+	require.Len(t, config.MonitoringConfig.Monitors, 2)
+	assert.Equal(t, "test_ping", config.MonitoringConfig.Monitors[0].Type)
+	assert.Equal(t, &testPingMonitorConfig{Host: "example.org", Timeout: 5 * time.Second},
+		config.MonitoringConfig.Monitors[0].Config)
+	resolverConfig, isResolverConfig := config.Modules["test_resolver"].(*testResolverConfig)
+	require.True(t, isResolverConfig)
+	assert.Equal(t, []string{"1.1.1.1", "8.8.8.8"}, resolverConfig.Servers)
+
+	//and validated and sealed like the built-in types
+	result, err := validation.ValidateObject(config)
+	require.Nil(t, err)
+	require.Equal(t, 1, result.GetErrorCount())
+	assert.Equal(t, "monitoring.monitors[1]", result.GetErrorList()[0].Path)
+	assert.Equal(t, "the host can't be empty", result.GetErrorList()[0].Error)
+	sealCheckResult := secrets.CheckSealsOnObject(config, nil)
+	assert.Equal(t, 1, sealCheckResult.UnsealedCount)
+
+	//the type is written with the fields of the registered type
+	yamlText, err := config.ToYAML()
+	require.Nil(t, err)
+	assert.Contains(t, yamlText, "  monitors:\n    - type: test_ping\n      host: example.org\n      timeout: 5s\n")
+	readBack, err := ReadConfig([]byte(yamlText))
+	require.Nil(t, err)
+	assert.Equal(t, config.MonitoringConfig, readBack.MonitoringConfig)
+	assert.Equal(t, config.Modules, readBack.Modules)
+
+	jsonText, err := config.ToJSON()
+	require.Nil(t, err)
+	assert.Contains(t, jsonText, `"type": "test_ping"`)
+}
+
+func TestReadRegisteredConfigsMergeKeys(t *testing.T) {
+	data := `mail:
+  accounts: []
+monitoring:
+  email_monitors: []
+  monitors:
+    - &ping
+      type: test_ping
+      host: example.org
+      timeout: 5s
+    - <<: *ping
+      host: example.com
+    - <<: [{timeout: 1m}, *ping]
+modules:
+  test_resolver:
+    <<: {retries: 2}
+    servers: [1.1.1.1]
+`
+	config, err := ReadConfig([]byte(data))
+	require.Nil(t, err)
+
+	//the merged fields, including the type, are decoded like the monitor's own
+	require.Len(t, config.MonitoringConfig.Monitors, 3)
+	assert.Equal(t, "test_ping", config.MonitoringConfig.Monitors[1].Type)
+	assert.Equal(t, &testPingMonitorConfig{Host: "example.com", Timeout: 5 * time.Second},
+		config.MonitoringConfig.Monitors[1].Config)
+	assert.Equal(t, &testPingMonitorConfig{Host: "example.org", Timeout: time.Minute},
+		config.MonitoringConfig.Monitors[2].Config)
+	assert.Equal(t, 2, config.Modules["test_resolver"].(*testResolverConfig).Retries)
+
+	//the merged fields are still checked
+	_, err = ReadConfig([]byte(strings.Replace(data, "{retries: 2}", "{retries: 2, port: 53}", 1)))
+	assert.ErrorContains(t, err, "line 15: field port not found in type config.testResolverConfig")
+	assert.NotContains(t, err.Error(), "field <<")
+}
+
+func TestCompileLeavesOutRegisteredMonitors(t *testing.T) {
+	config, err := ReadConfig([]byte(REGISTRY_TEST_YAML))
+	require.Nil(t, err)
+	runtimeConfig, err := config.Compile(nil)
+	require.Nil(t, err)
+
+	assert.Empty(t, runtimeConfig.Monitors())
+	assert.Equal(t, []string{"test_ping", "test_ping"}, runtimeConfig.UnrunMonitorTypes())
+}
+
+func TestReadRegisteredConfigsErrors(t *testing.T) {
+	type registryErrorTestCase struct {
+		replace       string
+		with          string
+		errorContains []string
+	}
+	testCases := []registryErrorTestCase{
+		{"test_resolver:", "test_other:", []string{"line 13: no module config named 'test_other' is registered; expected one of test_resolver"}},
+		{"- type: test_ping", "- type: test_other", []string{"line 6: no monitor type named 'test_other' is registered; expected one of test_ping"}},
+		{"- type: test_ping", "- kind: test_ping", []string{"line 6: the monitor has no 'type' field"}},
+		{"monitors:\n", "monitors:\n    - test_ping\n", []string{"line 6: a monitor must be a mapping with a 'type' field"}},
+		{"modules:\n  test_resolver:\n", "modules: []\nother:\n  test_resolver:\n", []string{"the modules must be a mapping"}},
+		//the fields of the registered types are checked like the built-in ones, and all the errors are reported
+		{"timeout: 5s", "timeout: 5s\n      port: 10", []string{
+			"line 9: field port not found in type config.testPingMonitorConfig"}},
+		{"timeout: 1m", "timeout: soon", []string{
+			"line 10: cannot unmarshal !!str `soon` into time.Duration"}},
+		{"servers: [1.1.1.1, 8.8.8.8]", "servers: [1.1.1.1]\n    timeout: 1s", []string{
+			"line 15: field timeout not found in type config.testResolverConfig"}},
+		{"      type: test_ping\nmodules:", "      type: test_ping\n      extra: 1\n  extra: 2\nmodules:", []string{
+			"line 12: field extra not found in type config.testPingMonitorConfig",
+			"line 13: field extra not found in type config.MonitorConfig"}},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+		data := strings.Replace(REGISTRY_TEST_YAML, testCase.replace, testCase.with, 1)
+		require.NotEqual(t, REGISTRY_TEST_YAML, data)
+		_, err := ReadConfig([]byte(data))
+		require.NotNil(t, err)
+		for _, expected := range testCase.errorContains {
+			assert.ErrorContains(t, err, expected)
+		}
+	}
+}
+
+func TestRegisteredConfigsInIncludesAndDiffs(t *testing.T) {
+	document, err := ReadConfigDocument([]byte(REGISTRY_TEST_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+
+	//sealed items of the module configs are written back at their map key
+	document.Config.Modules["test_resolver"].(*testResolverConfig).Password = secrets.CreateUnsafeSealedItem("+bbbb==", true)
+	require.Nil(t, document.UpdateSealedItems())
+	output, err := document.ToBytes()
+	require.Nil(t, err)
+	assert.Contains(t, string(output), "    password: sealed(+bbbb==)\n")
+
+	//monitors are appended, and a module config can only be set once
+	other, err := ReadConfigDocument([]byte(REGISTRY_TEST_YAML), CONFIG_FORMAT_YAML)
+	require.Nil(t, err)
+	document.Filename, other.Filename = "first.yaml", "second.yaml"
+	_, err = mergeConfigDocuments([]*ConfigDocument{document, other})
+	assert.ErrorContains(t, err, "modules.test_resolver is set in both first.yaml and second.yaml")
+	other.Config.Modules = nil
+	set, err := mergeConfigDocuments([]*ConfigDocument{document, other})
+	require.Nil(t, err)
+	assert.Len(t, set.Config.MonitoringConfig.Monitors, 4)
+	assert.Same(t, document.Config.Modules["test_resolver"], set.Config.Modules["test_resolver"])
+
+	//the registered types are compared field by field
+	newConfig, err := ReadConfig([]byte(strings.NewReplacer(
+		"timeout: 5s", "timeout: 10s", "servers: [1.1.1.1, 8.8.8.8]", "servers: [1.1.1.1]").Replace(REGISTRY_TEST_YAML)))
+	require.Nil(t, err)
+	newConfig.Modules["test_other"] = &testResolverConfig{}
+	changes, err := DiffConfigs(other.Config, newConfig, nil)
+	require.Nil(t, err)
+	assert.Equal(t, []ConfigChange{
+		{Kind: CONFIG_CHANGE_CHANGED, Path: "monitoring.monitors[0].timeout", OldValue: "5s", NewValue: "10s"},
+		{Kind: CONFIG_CHANGE_ADDED, Path: "modules[test_other]"},
+		{Kind: CONFIG_CHANGE_ADDED, Path: "modules[test_resolver]"},
+	}, changes)
+	changes, err = DiffConfigs(document.Config, newConfig, nil)
+	require.Nil(t, err)
+	assert.Contains(t, changes, ConfigChange{Kind: CONFIG_CHANGE_CHANGED, Path: "modules[test_resolver].servers",
+		OldValue: "[1.1.1.1, 8.8.8.8]", NewValue: "[1.1.1.1]"})
+}
+
+func TestRegisteredConfigsInSchemaAndDocs(t *testing.T) {
+	_, defs := readSchema(t)
+	monitorDef := defs["TypedMonitorConfig"].(map[string]interface{})
+	typeProperty := monitorDef["properties"].(map[string]interface{})["type"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"test_ping"}, typeProperty["enum"])
+	then := monitorDef["allOf"].([]interface{})[0].(map[string]interface{})["then"].(map[string]interface{})
+	assert.Contains(t, then["properties"], "host")
+	assert.Equal(t, []interface{}{"host", "timeout"}, then["required"])
+
+	reference := GenerateConfigReference()
+	assert.Contains(t, reference, "| `modules` | mapping of module names to their configs | no |")
+	assert.Contains(t, reference, "## testResolverConfig\n\nThe settings of the test resolver module.\n\nUsed at `modules.test_resolver`.\n")
+	assert.Contains(t, reference, "## testPingMonitorConfig\n\nA test monitor that pings a host.  Its `type` is `test_ping`.\n\nUsed at `monitoring.monitors[]`.\n")
+}
@@ -0,0 +1,282 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"slices"
+	"time"
+)
+
+// RuntimeConfig is the read-only model of a valid config that the monitors and the mail worker
+// use.  It is compiled once, after validation, so that the account names used by the monitors,
+// notifications and send limits are resolved to their accounts once instead of on every use.
+//
+// The model can't be changed after it is compiled: its fields are unexported and the accessors
+// return copies.  It can be shared between goroutines, and a reloaded config is compiled into a
+// new model rather than changing the old one.
+//
+// The monitors of registered types, in monitoring.monitors, are not part of the model, since the
+// registry only knows their configs and not how to run them.  Only their types are kept, so that
+// the daemon can say which monitors it doesn't run.
+type RuntimeConfig struct {
+	accounts          []*RuntimeAccount
+	accountsByName    map[string]*RuntimeAccount
+	monitors          []*RuntimeMonitor
+	unrunMonitorTypes []string
+	sendLimitBuckets  []SendLimitBucket
+	authLockout       AuthLockoutConfig
+}
+
+// RuntimeAccount is a mail account of a RuntimeConfig
+type RuntimeAccount struct {
+	name          string
+	smtp          *SMTPConfig
+	imap          *IMAPConfig
+	proxy         *ProxyConfig //the account's proxy, or mail.proxy if it doesn't have one
+	addressFamily AddressFamily
+	localAddress  string
+	//indexes of the buckets of RuntimeConfig.sendLimitBuckets that the account is in
+	sendLimitBuckets []int
+}
+
+// RuntimeMonitor is an email monitor of a RuntimeConfig, with its accounts resolved
+type RuntimeMonitor struct {
+	name                 string
+	fromAccount          *RuntimeAccount
+	toAccount            *RuntimeAccount
+	testPeriod           time.Duration
+	notifications        []*RuntimeAccount
+	probeAddressFamilies []AddressFamily
+}
+
+// Compile returns the runtime model of the config.  The config should be validated first; Compile
+// only returns an error for the problems that keep it from building the model, like a monitor that
+// refers to an account that doesn't exist.
+//
+// The send limits that group accounts by server IP are resolved with resolver, or with
+// net.DefaultResolver if it is nil.
+func (c *VaranusConfig) Compile(resolver HostResolver) (*RuntimeConfig, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	runtimeConfig := &RuntimeConfig{
+		accounts:       make([]*RuntimeAccount, 0, len(c.Mail.Accounts)),
+		accountsByName: map[string]*RuntimeAccount{},
+		monitors:       make([]*RuntimeMonitor, 0, len(c.MonitoringConfig.EmailMonitors)),
+		authLockout:    c.Mail.GetAuthLockout(),
+	}
+
+	for _, account := range c.Mail.Accounts {
+		if _, found := runtimeConfig.accountsByName[account.Name]; found {
+			return nil, fmt.Errorf("duplicate account name '%s'", account.Name)
+		}
+		runtimeAccount := &RuntimeAccount{
+			name:          account.Name,
+			smtp:          cloneSMTPConfig(account.SMTP),
+			imap:          cloneIMAPConfig(account.IMAP),
+			proxy:         cloneProxyConfig(c.Mail.GetProxyForAccount(&account)),
+			addressFamily: account.AddressFamily,
+			localAddress:  account.LocalAddress,
+		}
+		runtimeConfig.accounts = append(runtimeConfig.accounts, runtimeAccount)
+		runtimeConfig.accountsByName[account.Name] = runtimeAccount
+	}
+
+	monitorNameCounts := map[string]int{}
+	for index, monitor := range c.MonitoringConfig.EmailMonitors {
+		runtimeMonitor := &RuntimeMonitor{
+			name:                 monitorName(monitor, monitorNameCounts),
+			fromAccount:          runtimeConfig.accountsByName[monitor.FromAccount],
+			toAccount:            runtimeConfig.accountsByName[monitor.ToAccount],
+			testPeriod:           monitor.TestPeriod,
+			notifications:        make([]*RuntimeAccount, 0, len(monitor.Notifications)),
+			probeAddressFamilies: slices.Clone(monitor.GetProbeAddressFamilies()),
+		}
+		if runtimeMonitor.fromAccount == nil || runtimeMonitor.fromAccount.smtp == nil {
+			return nil, fmt.Errorf("email monitor %d: from_account '%s' is not an account with an SMTP configuration",
+				index, monitor.FromAccount)
+		}
+		if runtimeMonitor.toAccount == nil || runtimeMonitor.toAccount.imap == nil {
+			return nil, fmt.Errorf("email monitor %d: to_account '%s' is not an account with an IMAP configuration",
+				index, monitor.ToAccount)
+		}
+		for _, notification := range monitor.Notifications {
+			notificationAccount := runtimeConfig.accountsByName[notification.Mail]
+			if notificationAccount == nil {
+				return nil, fmt.Errorf("email monitor %d: notification mail account '%s' does not exist",
+					index, notification.Mail)
+			}
+			if notificationAccount.smtp == nil {
+				return nil, fmt.Errorf("email monitor %d: notification mail account '%s' is not an account with an SMTP configuration",
+					index, notification.Mail)
+			}
+			runtimeMonitor.notifications = append(runtimeMonitor.notifications, notificationAccount)
+		}
+		runtimeConfig.monitors = append(runtimeConfig.monitors, runtimeMonitor)
+	}
+	for _, monitor := range c.MonitoringConfig.Monitors {
+		runtimeConfig.unrunMonitorTypes = append(runtimeConfig.unrunMonitorTypes, monitor.Type)
+	}
+
+	runtimeConfig.sendLimitBuckets = c.Mail.ResolveSendLimitBuckets(resolver)
+	for bucketIndex, bucket := range runtimeConfig.sendLimitBuckets {
+		for _, accountName := range bucket.AccountNames {
+			account := runtimeConfig.accountsByName[accountName]
+			if account == nil {
+				return nil, fmt.Errorf("send_limits account name '%s' does not exist", accountName)
+			}
+			account.sendLimitBuckets = append(account.sendLimitBuckets, bucketIndex)
+		}
+	}
+
+	return runtimeConfig, nil
+}
+
+// Accounts returns the accounts in config order
+func (rc *RuntimeConfig) Accounts() []*RuntimeAccount {
+	return slices.Clone(rc.accounts)
+}
+
+// AccountByName returns the account with the name, or nil if there is none
+func (rc *RuntimeConfig) AccountByName(name string) *RuntimeAccount {
+	return rc.accountsByName[name]
+}
+
+// Monitors returns the email monitors in config order
+func (rc *RuntimeConfig) Monitors() []*RuntimeMonitor {
+	return slices.Clone(rc.monitors)
+}
+
+// UnrunMonitorTypes returns the types of the monitors of registered types in config order.  They
+// are validated and sealed with the config, but they aren't compiled, so they are not run.
+func (rc *RuntimeConfig) UnrunMonitorTypes() []string {
+	return slices.Clone(rc.unrunMonitorTypes)
+}
+
+// SendLimitBuckets returns every send limit bucket, with the send limits that group by server IP
+// already split into their groups
+func (rc *RuntimeConfig) SendLimitBuckets() []SendLimitBucket {
+	buckets := make([]SendLimitBucket, 0, len(rc.sendLimitBuckets))
+	for _, bucket := range rc.sendLimitBuckets {
+		buckets = append(buckets, cloneSendLimitBucket(bucket))
+	}
+	return buckets
+}
+
+// SendLimitBucketsForAccount returns the send limit buckets that the account is in
+func (rc *RuntimeConfig) SendLimitBucketsForAccount(account *RuntimeAccount) []SendLimitBucket {
+	buckets := make([]SendLimitBucket, 0, len(account.sendLimitBuckets))
+	for _, bucketIndex := range account.sendLimitBuckets {
+		buckets = append(buckets, cloneSendLimitBucket(rc.sendLimitBuckets[bucketIndex]))
+	}
+	return buckets
+}
+
+// AuthLockout returns the auth_lockout settings, with the defaults filled in
+func (rc *RuntimeConfig) AuthLockout() AuthLockoutConfig {
+	return rc.authLockout
+}
+
+func (ra *RuntimeAccount) Name() string {
+	return ra.name
+}
+
+// SMTP returns a copy of the SMTP settings, or nil if the account can't send
+func (ra *RuntimeAccount) SMTP() *SMTPConfig {
+	return cloneSMTPConfig(ra.smtp)
+}
+
+// IMAP returns a copy of the IMAP settings, or nil if the account can't receive
+func (ra *RuntimeAccount) IMAP() *IMAPConfig {
+	return cloneIMAPConfig(ra.imap)
+}
+
+// Proxy returns a copy of the proxy that the connections of the account use, either its own or
+// mail.proxy, or nil if they are made directly
+func (ra *RuntimeAccount) Proxy() *ProxyConfig {
+	return cloneProxyConfig(ra.proxy)
+}
+
+func (ra *RuntimeAccount) AddressFamily() AddressFamily {
+	return ra.addressFamily
+}
+
+func (ra *RuntimeAccount) LocalAddress() string {
+	return ra.localAddress
+}
+
+// Name returns the name of the monitor, which is "from->to" like in a config diff, with "#2", "#3"
+// and so on added for the later monitors between the same accounts
+func (rm *RuntimeMonitor) Name() string {
+	return rm.name
+}
+
+// FromAccount returns the account that sends the test email
+func (rm *RuntimeMonitor) FromAccount() *RuntimeAccount {
+	return rm.fromAccount
+}
+
+// ToAccount returns the account that receives the test email
+func (rm *RuntimeMonitor) ToAccount() *RuntimeAccount {
+	return rm.toAccount
+}
+
+func (rm *RuntimeMonitor) TestPeriod() time.Duration {
+	return rm.testPeriod
+}
+
+// Notifications returns the accounts that are notified when the test fails
+func (rm *RuntimeMonitor) Notifications() []*RuntimeAccount {
+	return slices.Clone(rm.notifications)
+}
+
+// ProbeAddressFamilies returns the address family of each probe, see
+// EmailMonitorConfig.GetProbeAddressFamilies
+func (rm *RuntimeMonitor) ProbeAddressFamilies() []AddressFamily {
+	return slices.Clone(rm.probeAddressFamilies)
+}
+
+func monitorName(monitor EmailMonitorConfig, counts map[string]int) string {
+	name := monitor.FromAccount + "->" + monitor.ToAccount
+	counts[name]++
+	if counts[name] > 1 {
+		name = fmt.Sprintf("%s#%d", name, counts[name])
+	}
+	return name
+}
+
+func cloneSMTPConfig(smtp *SMTPConfig) *SMTPConfig {
+	if smtp == nil {
+		return nil
+	}
+	clone := *smtp
+	return &clone
+}
+
+func cloneIMAPConfig(imap *IMAPConfig) *IMAPConfig {
+	if imap == nil {
+		return nil
+	}
+	clone := *imap
+	return &clone
+}
+
+func cloneProxyConfig(proxy *ProxyConfig) *ProxyConfig {
+	if proxy == nil {
+		return nil
+	}
+	clone := *proxy
+	if proxy.Password != nil {
+		password := *proxy.Password
+		clone.Password = &password
+	}
+	return &clone
+}
+
+func cloneSendLimitBucket(bucket SendLimitBucket) SendLimitBucket {
+	bucket.AccountNames = slices.Clone(bucket.AccountNames)
+	bucket.ServerIPs = slices.Clone(bucket.ServerIPs)
+	bucket.ResolveErrors = slices.Clone(bucket.ResolveErrors)
+	return bucket
+}
@@ -0,0 +1,170 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const RUNTIME_TEST_YAML = `mail:
+  accounts:
+    - name: work
+      smtp:
+        sender_address: work@example.com
+        server_address: smtp.example.com
+        use_tls: true
+        username: work
+        password: secret1
+      proxy:
+        url: socks5://proxy.example.com:1080
+    - name: home
+      address_family: ipv6
+      imap:
+        recipient_address: home@example.org
+        server_address: imap.example.org
+        use_tls: true
+        username: home
+        password: secret2
+        mailbox_name: INBOX
+    - name: other
+      smtp:
+        sender_address: other@example.net
+        server_address: smtp.example.net
+        use_tls: true
+        username: other
+        password: secret3
+  send_limits:
+    - min_period: 10m
+      account_names: [work, other]
+    - min_period: 1h
+      group_by_server_ip: true
+  proxy:
+    url: http://proxy.example.com:3128
+monitoring:
+  email_monitors:
+    - from_account: work
+      to_account: home
+      test_period: 1h
+      address_families: [ipv4, ipv6]
+      notifications:
+        - mail: other
+        - mail: work
+`
+
+func TestCompile(t *testing.T) {
+
+	config, err := ReadConfig([]byte(RUNTIME_TEST_YAML))
+	require.Nil(t, err)
+	resolver := fakeResolver{
+		"smtp.example.com": {"192.0.2.1"},
+		"smtp.example.net": {"192.0.2.1"},
+	}
+
+	runtimeConfig, err := config.Compile(resolver)
+	require.Nil(t, err)
+
+	//accounts are indexed by name
+	accounts := runtimeConfig.Accounts()
+	require.Len(t, accounts, 3)
+	work := runtimeConfig.AccountByName("work")
+	home := runtimeConfig.AccountByName("home")
+	other := runtimeConfig.AccountByName("other")
+	assert.Same(t, accounts[0], work)
+	assert.Same(t, accounts[1], home)
+	assert.Same(t, accounts[2], other)
+	assert.Nil(t, runtimeConfig.AccountByName("nonexistent"))
+
+	assert.Equal(t, "work", work.Name())
+	assert.Equal(t, uint(DEFAULT_SMTP_TLS_PORT), work.SMTP().Port)
+	assert.Nil(t, work.IMAP())
+	assert.Nil(t, home.SMTP())
+	assert.Equal(t, "INBOX", home.IMAP().MailboxName)
+	assert.Equal(t, ADDRESS_FAMILY_IPV6, home.AddressFamily())
+
+	//the proxy of each account is resolved
+	assert.Equal(t, "socks5://proxy.example.com:1080", work.Proxy().URL)
+	assert.Equal(t, "http://proxy.example.com:3128", home.Proxy().URL)
+
+	//the monitors refer to their accounts
+	monitors := runtimeConfig.Monitors()
+	require.Len(t, monitors, 1)
+	assert.Equal(t, "work->home", monitors[0].Name())
+	assert.Same(t, work, monitors[0].FromAccount())
+	assert.Same(t, home, monitors[0].ToAccount())
+	assert.Equal(t, time.Hour, monitors[0].TestPeriod())
+	assert.Equal(t, []*RuntimeAccount{other, work}, monitors[0].Notifications())
+	assert.Equal(t, []AddressFamily{ADDRESS_FAMILY_IPV4, ADDRESS_FAMILY_IPV6}, monitors[0].ProbeAddressFamilies())
+
+	//the send limit groups are resolved once, and each account knows its buckets
+	expectedBuckets := []SendLimitBucket{
+		{MinPeriod: 10 * time.Minute, AccountNames: []string{"work", "other"}},
+		{MinPeriod: time.Hour, AccountNames: []string{"work", "other"}, ServerIPs: []string{"192.0.2.1"}},
+	}
+	assert.Equal(t, expectedBuckets, runtimeConfig.SendLimitBuckets())
+	assert.Equal(t, expectedBuckets, runtimeConfig.SendLimitBucketsForAccount(work))
+	assert.Equal(t, []SendLimitBucket{}, runtimeConfig.SendLimitBucketsForAccount(home))
+
+	assert.Equal(t, AuthLockoutConfig{FailureThreshold: DEFAULT_AUTH_FAILURE_THRESHOLD, CoolDown: DEFAULT_AUTH_COOL_DOWN},
+		runtimeConfig.AuthLockout())
+}
+
+func TestCompileIsReadOnly(t *testing.T) {
+
+	config, err := ReadConfig([]byte(RUNTIME_TEST_YAML))
+	require.Nil(t, err)
+	runtimeConfig, err := config.Compile(fakeResolver{})
+	require.Nil(t, err)
+
+	//changing the config after it is compiled doesn't change the model
+	config.Mail.Accounts[0].SMTP.ServerAddress = "changed.example.com"
+	config.Mail.Proxy.URL = "http://changed.example.com"
+	config.MonitoringConfig.EmailMonitors[0].AddressFamilies[0] = ADDRESS_FAMILY_ANY
+	work := runtimeConfig.AccountByName("work")
+	assert.Equal(t, "smtp.example.com", work.SMTP().ServerAddress)
+	assert.Equal(t, "http://proxy.example.com:3128", runtimeConfig.AccountByName("home").Proxy().URL)
+	assert.Equal(t, ADDRESS_FAMILY_IPV4, runtimeConfig.Monitors()[0].ProbeAddressFamilies()[0])
+
+	//and neither does changing what the accessors return
+	work.SMTP().ServerAddress = "changed.example.com"
+	work.Proxy().Username = "changed"
+	runtimeConfig.Monitors()[0].Notifications()[0] = work
+	runtimeConfig.SendLimitBuckets()[0].AccountNames[0] = "changed"
+	runtimeConfig.Accounts()[0] = nil
+	assert.Equal(t, "smtp.example.com", work.SMTP().ServerAddress)
+	assert.Equal(t, "", work.Proxy().Username)
+	assert.Equal(t, "other", runtimeConfig.Monitors()[0].Notifications()[0].Name())
+	assert.Equal(t, "work", runtimeConfig.SendLimitBuckets()[0].AccountNames[0])
+	assert.Same(t, work, runtimeConfig.Accounts()[0])
+}
+
+func TestCompileFailures(t *testing.T) {
+
+	type testCase struct {
+		old           string
+		new           string
+		errorContains string
+	}
+
+	testCases := []testCase{
+		{old: "from_account: work", new: "from_account: home", errorContains: "email monitor 0: from_account 'home' is not an account with an SMTP configuration"},
+		{old: "from_account: work", new: "from_account: nobody", errorContains: "email monitor 0: from_account 'nobody' is not an account with an SMTP configuration"},
+		{old: "to_account: home", new: "to_account: work", errorContains: "email monitor 0: to_account 'work' is not an account with an IMAP configuration"},
+		{old: "mail: other", new: "mail: nobody", errorContains: "email monitor 0: notification mail account 'nobody' does not exist"},
+		{old: "mail: other", new: "mail: home", errorContains: "email monitor 0: notification mail account 'home' is not an account with an SMTP configuration"},
+		{old: "account_names: [work, other]", new: "account_names: [work, nobody]", errorContains: "send_limits account name 'nobody' does not exist"},
+		{old: "name: other", new: "name: work", errorContains: "duplicate account name 'work'"},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		config, err := ReadConfig([]byte(strings.Replace(RUNTIME_TEST_YAML, testCase.old, testCase.new, 1)))
+		require.Nil(t, err)
+
+		_, err = config.Compile(fakeResolver{})
+		assert.ErrorContains(t, err, testCase.errorContains)
+	}
+}
@@ -0,0 +1,323 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+	"varanus/internal/secrets"
+	"varanus/internal/walker"
+)
+
+// The JSON Schema for the config is generated from the Go types, with the same field names as the
+// YAML decoder, so editors and CI can check configs before varanus reads them.  It uses these
+// rules:
+//
+//   - every struct is an object that only allows its own fields
+//   - a field is required if its yaml tag doesn't have omitempty and it is a string, number,
+//     duration or sealed item; booleans, lists and nested objects can always be left out
+//   - an object with an "extends" field, like an account, only requires its own fields when it
+//     extends a template, since the rest can be inherited
+//   - numbers, booleans, durations and enums also accept an interpolation reference like "${PORT}"
+//   - the registered module configs and monitor types, see registry.go, are checked by their key
+//     and their type field
+//
+// The schema can't check references between objects, like account names; "varanus config check"
+// does that.
+
+// JSON_SCHEMA_DIALECT is the JSON Schema draft the config schema uses
+const JSON_SCHEMA_DIALECT = "https://json-schema.org/draft/2020-12/schema"
+
+// JSON_SCHEMA_DURATION_PATTERN matches the durations accepted by time.ParseDuration, like "1h30m"
+const JSON_SCHEMA_DURATION_PATTERN = `^(0|[-+]?([0-9]*\.?[0-9]+(ns|us|µs|ms|s|m|h))+)$`
+
+// JSON_SCHEMA_INTERPOLATION_PATTERN matches values with an interpolation reference, like "${PORT}"
+const JSON_SCHEMA_INTERPOLATION_PATTERN = `\$\{[^}]*\}`
+
+// JSON_SCHEMA_EXTENDS_FIELD is the field of objects that can inherit from a template
+const JSON_SCHEMA_EXTENDS_FIELD = "extends"
+
+// the names of the shared definitions for types that aren't structs
+const (
+	schemaDefDuration      = "Duration"
+	schemaDefSealedItem    = "SealedItem"
+	schemaDefInterpolation = "InterpolationReference"
+	schemaPartialPrefix    = "Partial"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+var sealedItemType = reflect.TypeOf(secrets.SealedItem{})
+var forceConfigFailureType = reflect.TypeOf(ForceConfigFailure{})
+var moduleConfigsType = reflect.TypeOf(ModuleConfigs{})
+var typedMonitorConfigType = reflect.TypeOf(TypedMonitorConfig{})
+
+// schemaPartialTypes are always checked as if they inherit from a template, because templates
+// only have some of the fields
+var schemaPartialTypes = []reflect.Type{reflect.TypeOf(MailAccountTemplate{})}
+
+// schemaUninterpolatedFields are read before the values of the config are interpolated, so they
+// can't be interpolation references
+var schemaUninterpolatedFields = map[reflect.Type][]string{
+	reflect.TypeOf(VaranusConfig{}): {CONFIG_VERSION_FIELD, INTERPOLATE_FIELD},
+}
+
+// schemaEnums has the allowed values of the string types that are enums
+var schemaEnums = map[reflect.Type][]string{
+	reflect.TypeOf(AddressFamily("")): addressFamilyNames(),
+}
+
+// GenerateJSONSchema returns the JSON Schema for a VaranusConfig document as indented JSON
+func GenerateJSONSchema() ([]byte, error) {
+	generator := schemaGenerator{defs: map[string]interface{}{}}
+	rootRef := generator.schemaForType(reflect.TypeOf(VaranusConfig{}), false)
+
+	schema := map[string]interface{}{
+		"$schema":     JSON_SCHEMA_DIALECT,
+		"title":       "varanus config",
+		"description": "A config for the varanus email monitor",
+		"$ref":        rootRef["$ref"],
+		"$defs":       generator.defs,
+	}
+	data, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		//unreachable because the schema only has maps, slices and strings
+		return nil, fmt.Errorf("could not write the schema: %w", err)
+	}
+	return append(data, '\n'), nil
+}
+
+// WriteJSONSchemaToFile writes the JSON Schema for the config to filename.  If forceOverwrite is
+// False, then an error will occur if the file already exists.
+func WriteJSONSchemaToFile(filename string, forceOverwrite bool) error {
+	data, err := GenerateJSONSchema()
+	if err != nil {
+		return err
+	}
+	return writeBytesToFile(data, filename, forceOverwrite)
+}
+
+// schemaGenerator collects a definition for each struct type, and the shared scalar types, in defs
+type schemaGenerator struct {
+	defs map[string]interface{}
+}
+
+// schemaForType returns the schema for a value of type t.  If partial is set, none of the fields
+// of structs are required except TEMPLATE_OWN_FIELDS, for objects that inherit from a template.
+func (g *schemaGenerator) schemaForType(t reflect.Type, partial bool) map[string]interface{} {
+	switch {
+	case t == durationType:
+		g.defs[schemaDefDuration] = map[string]interface{}{
+			"type":        "string",
+			"pattern":     JSON_SCHEMA_DURATION_PATTERN,
+			"description": "A duration like 90s, 10m or 1h30m",
+		}
+		return g.interpolated(schemaRef(schemaDefDuration))
+	case t == sealedItemType:
+		sealedPattern := strings.TrimSuffix(strings.TrimPrefix(secrets.SealedValueRegex.String(), "^"), "$")
+		g.defs[schemaDefSealedItem] = map[string]interface{}{
+			"type":        "string",
+			"minLength":   1,
+			"description": "A plaintext value, or a value sealed by 'varanus config seal'",
+			//a value that looks sealed must be sealed correctly
+			"if":   map[string]interface{}{"pattern": `^sealed\(`},
+			"then": map[string]interface{}{"pattern": `^sealed\(` + sealedPattern + `\)$`},
+		}
+		return schemaRef(schemaDefSealedItem)
+	case t == forceConfigFailureType:
+		return map[string]interface{}{"type": "string", "description": "Only for testing"}
+	case t == moduleConfigsType:
+		return g.moduleConfigsSchema()
+	case t == typedMonitorConfigType:
+		return g.typedMonitorSchema()
+	}
+	if enum, isEnum := schemaEnums[t]; isEnum {
+		return g.interpolated(map[string]interface{}{"type": "string", "enum": enum})
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schemaForType(t.Elem(), partial)
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.schemaForType(t.Elem(), partial)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schemaForType(t.Elem(), partial)}
+	case reflect.Struct:
+		return g.schemaForStruct(t, partial)
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return g.interpolated(map[string]interface{}{"type": "boolean"})
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return g.interpolated(map[string]interface{}{"type": "integer"})
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return g.interpolated(map[string]interface{}{"type": "integer", "minimum": 0})
+	case reflect.Float32, reflect.Float64:
+		return g.interpolated(map[string]interface{}{"type": "number"})
+	}
+	//any other type is left unchecked
+	return map[string]interface{}{}
+}
+
+// schemaForStruct adds the definition for a struct type and returns a reference to it.  An object
+// that can extend a template is checked completely only if it doesn't.
+func (g *schemaGenerator) schemaForStruct(t reflect.Type, partial bool) map[string]interface{} {
+	name := t.Name()
+	if slices.Contains(schemaPartialTypes, t) {
+		partial = true
+	} else if partial {
+		name = schemaPartialPrefix + name
+	}
+	if _, found := g.defs[name]; !found {
+		//added before the fields, so types that contain themselves end
+		g.defs[name] = map[string]interface{}{}
+		g.defs[name] = g.structDefinition(t, partial)
+	}
+
+	if partial || !hasYamlField(t, JSON_SCHEMA_EXTENDS_FIELD) {
+		return schemaRef(name)
+	}
+	inherited := g.schemaForStruct(t, true)
+	return map[string]interface{}{
+		"if":   map[string]interface{}{"required": []string{JSON_SCHEMA_EXTENDS_FIELD}},
+		"then": inherited,
+		"else": schemaRef(name),
+	}
+}
+
+func (g *schemaGenerator) structDefinition(t reflect.Type, partial bool) map[string]interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	for index := 0; index < t.NumField(); index++ {
+		field := t.Field(index)
+		key := walker.GetYamlNameFromTag(field.Tag)
+		if !field.IsExported() || key == "" || key == "-" {
+			continue
+		}
+		property := g.schemaForType(field.Type, partial)
+		if slices.Contains(schemaUninterpolatedFields[t], key) {
+			property = withoutInterpolation(property)
+		}
+		if doc := field.Tag.Get(DOC_TAG); doc != "" {
+			property["description"] = doc
+		}
+		properties[key] = property
+
+		if isRequiredField(field) && (!partial || slices.Contains(TEMPLATE_OWN_FIELDS, key)) {
+			required = append(required, key)
+		}
+	}
+
+	definition := map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+	if len(required) > 0 {
+		definition["required"] = required
+	}
+	return definition
+}
+
+// moduleConfigsSchema returns an object with a property for each registered module config
+func (g *schemaGenerator) moduleConfigsSchema() map[string]interface{} {
+	properties := map[string]interface{}{}
+	for _, name := range RegisteredModuleConfigs() {
+		properties[name] = g.schemaForType(moduleConfigRegistry.configType(name), false)
+	}
+	return map[string]interface{}{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
+
+// typedMonitorSchema adds the definition of a monitor of a registered type, which has the fields
+// of the type named by its type field, and returns a reference to it
+func (g *schemaGenerator) typedMonitorSchema() map[string]interface{} {
+	name := typedMonitorConfigType.Name()
+	if _, found := g.defs[name]; found {
+		return schemaRef(name)
+	}
+
+	typeNames := RegisteredMonitorTypes()
+	typeProperty := map[string]interface{}{"type": "string"}
+	if len(typeNames) > 0 {
+		typeProperty["enum"] = typeNames
+	}
+	if field, found := typedMonitorConfigType.FieldByName("Type"); found {
+		typeProperty["description"] = field.Tag.Get(DOC_TAG)
+	}
+
+	typeSchemas := []interface{}{}
+	for _, typeName := range typeNames {
+		definition := g.structDefinition(monitorTypeRegistry.configType(typeName), false)
+		definition["properties"].(map[string]interface{})[MONITOR_TYPE_KEY] = map[string]interface{}{"const": typeName}
+		typeSchemas = append(typeSchemas, map[string]interface{}{
+			"if":   map[string]interface{}{"properties": map[string]interface{}{MONITOR_TYPE_KEY: map[string]interface{}{"const": typeName}}},
+			"then": definition,
+		})
+	}
+
+	definition := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{MONITOR_TYPE_KEY: typeProperty},
+		"required":   []string{MONITOR_TYPE_KEY},
+	}
+	if len(typeSchemas) > 0 {
+		definition["allOf"] = typeSchemas
+	}
+	g.defs[name] = definition
+	return schemaRef(name)
+}
+
+// withoutInterpolation returns the schema that an interpolated schema allows besides a reference
+func withoutInterpolation(schema map[string]interface{}) map[string]interface{} {
+	anyOf, isInterpolated := schema["anyOf"].([]interface{})
+	if !isInterpolated || len(anyOf) != 2 {
+		return schema
+	}
+	return anyOf[0].(map[string]interface{})
+}
+
+// interpolated allows a value to be an interpolation reference instead
+func (g *schemaGenerator) interpolated(schema map[string]interface{}) map[string]interface{} {
+	g.defs[schemaDefInterpolation] = map[string]interface{}{
+		"type":        "string",
+		"pattern":     JSON_SCHEMA_INTERPOLATION_PATTERN,
+		"description": "A reference like ${NAME}, used when the config sets interpolate: true",
+	}
+	return map[string]interface{}{"anyOf": []interface{}{schema, schemaRef(schemaDefInterpolation)}}
+}
+
+// isRequiredField returns true if field must be in an object, see the rules above
+func isRequiredField(field reflect.StructField) bool {
+	if slices.Contains(strings.Split(field.Tag.Get("yaml"), ",")[1:], "omitempty") {
+		return false
+	}
+	if field.Type == durationType || field.Type == sealedItemType {
+		return true
+	}
+	switch field.Type.Kind() {
+	case reflect.String, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// hasYamlField returns true if the struct type t has a field with the given yaml name
+func hasYamlField(t reflect.Type, key string) bool {
+	for index := 0; index < t.NumField(); index++ {
+		if walker.GetYamlNameFromTag(t.Field(index).Tag) == key {
+			return true
+		}
+	}
+	return false
+}
+
+func schemaRef(name string) map[string]interface{} {
+	return map[string]interface{}{"$ref": "#/$defs/" + name}
+}
@@ -0,0 +1,175 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+	"varanus/internal/util"
+	"varanus/internal/walker"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readSchema returns the generated schema and its definitions
+func readSchema(t *testing.T) (map[string]interface{}, map[string]interface{}) {
+	data, err := GenerateJSONSchema()
+	require.Nil(t, err)
+	schema := map[string]interface{}{}
+	err = json.Unmarshal(data, &schema)
+	require.Nil(t, err)
+	return schema, schema["$defs"].(map[string]interface{})
+}
+
+func TestGenerateJSONSchema(t *testing.T) {
+	schema, defs := readSchema(t)
+
+	assert.Equal(t, JSON_SCHEMA_DIALECT, schema["$schema"])
+	assert.Equal(t, "#/$defs/VaranusConfig", schema["$ref"])
+
+	type testCase struct {
+		def      string
+		required []interface{}
+	}
+
+	testCases := []testCase{
+		{def: "VaranusConfig", required: nil},
+		//the port has a default, so it is optional
+		{def: "SMTPConfig", required: []interface{}{"sender_address", "server_address", "username", "password"}},
+		{def: "EmailMonitorConfig", required: []interface{}{"from_account", "to_account", "test_period"}},
+		{def: "SendLimitConfig", required: []interface{}{"min_period"}},
+		{def: "ProxyConfig", required: []interface{}{"url"}},
+		//objects that inherit from templates only need their own fields
+		{def: "MailAccountConfig", required: []interface{}{"name"}},
+		{def: "PartialMailAccountConfig", required: []interface{}{"name"}},
+		{def: "PartialSMTPConfig", required: nil},
+		{def: "MailAccountTemplate", required: []interface{}{"name"}},
+	}
+
+	for _, testCase := range testCases {
+		def, found := defs[testCase.def].(map[string]interface{})
+		require.True(t, found, "for %s", testCase.def)
+		assert.Equal(t, "object", def["type"], "for %s", testCase.def)
+		assert.Equal(t, false, def["additionalProperties"], "for %s", testCase.def)
+		if testCase.required == nil {
+			assert.NotContains(t, def, "required", "for %s", testCase.def)
+		} else {
+			assert.Equal(t, testCase.required, def["required"], "for %s", testCase.def)
+		}
+	}
+
+	//accounts are only checked completely if they don't extend a template
+	mailProperties := defs["MailConfig"].(map[string]interface{})["properties"].(map[string]interface{})
+	accountItems := mailProperties["accounts"].(map[string]interface{})["items"]
+	assert.Equal(t, map[string]interface{}{
+		"if":   map[string]interface{}{"required": []interface{}{"extends"}},
+		"then": map[string]interface{}{"$ref": "#/$defs/PartialMailAccountConfig"},
+		"else": map[string]interface{}{"$ref": "#/$defs/MailAccountConfig"},
+	}, accountItems)
+
+	//enums accept their values or an interpolation reference
+	accountProperties := defs["MailAccountConfig"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"anyOf": []interface{}{
+			map[string]interface{}{"type": "string", "enum": []interface{}{"any", "ipv4", "ipv6"}},
+			map[string]interface{}{"$ref": "#/$defs/InterpolationReference"},
+		},
+		"description": "The IP address family used to connect to the servers, or to the proxy if there is one.  An http or socks5h proxy picks the family it uses to reach the servers",
+	}, accountProperties["address_family"])
+
+	//the fields read before interpolation don't accept a reference
+	rootProperties := defs["VaranusConfig"].(map[string]interface{})["properties"].(map[string]interface{})
+	for _, key := range []string{CONFIG_VERSION_FIELD, INTERPOLATE_FIELD} {
+		assert.NotContains(t, rootProperties[key], "anyOf", "for %s", key)
+	}
+	assert.Equal(t, "integer", rootProperties[CONFIG_VERSION_FIELD].(map[string]interface{})["type"])
+	assert.Equal(t, "boolean", rootProperties[INTERPOLATE_FIELD].(map[string]interface{})["type"])
+}
+
+func TestJSONSchemaHasEveryField(t *testing.T) {
+	_, defs := readSchema(t)
+
+	//every yaml field of every struct in the config is a property of its definition
+	checked := map[reflect.Type]bool{}
+	var checkType func(t reflect.Type)
+	checkType = func(structType reflect.Type) {
+		for structType.Kind() == reflect.Pointer || structType.Kind() == reflect.Slice {
+			structType = structType.Elem()
+		}
+		if structType.Kind() != reflect.Struct || checked[structType] ||
+			structType == sealedItemType || structType == forceConfigFailureType {
+			return
+		}
+		checked[structType] = true
+
+		def, found := defs[structType.Name()].(map[string]interface{})
+		require.True(t, found, "for %s", structType.Name())
+		properties := def["properties"].(map[string]interface{})
+		fieldCount := 0
+		for index := 0; index < structType.NumField(); index++ {
+			key := walker.GetYamlNameFromTag(structType.Field(index).Tag)
+			if key == "" {
+				continue
+			}
+			fieldCount++
+			assert.Contains(t, properties, key, "for %s", structType.Name())
+			checkType(structType.Field(index).Type)
+		}
+		assert.Len(t, properties, fieldCount, "for %s", structType.Name())
+	}
+	checkType(reflect.TypeOf(VaranusConfig{}))
+	assert.Len(t, checked, 13)
+}
+
+func TestJSONSchemaPatterns(t *testing.T) {
+	_, defs := readSchema(t)
+
+	sealedItem := defs["SealedItem"].(map[string]interface{})
+	sealedPattern := regexp.MustCompile(sealedItem["then"].(map[string]interface{})["pattern"].(string))
+	looksSealedPattern := regexp.MustCompile(sealedItem["if"].(map[string]interface{})["pattern"].(string))
+	durationPattern := regexp.MustCompile(defs["Duration"].(map[string]interface{})["pattern"].(string))
+	interpolationPattern := regexp.MustCompile(defs["InterpolationReference"].(map[string]interface{})["pattern"].(string))
+
+	type testCase struct {
+		pattern *regexp.Regexp
+		value   string
+		matches bool
+	}
+
+	testCases := []testCase{
+		{pattern: sealedPattern, value: "sealed(AAAA+/==)", matches: true},
+		{pattern: sealedPattern, value: "sealed(not sealed)", matches: false},
+		{pattern: sealedPattern, value: "sealed()", matches: false},
+		{pattern: looksSealedPattern, value: "sealed(", matches: true},
+		{pattern: looksSealedPattern, value: "it's a secret", matches: false},
+		{pattern: durationPattern, value: "1h30m", matches: true},
+		{pattern: durationPattern, value: "90s", matches: true},
+		{pattern: durationPattern, value: "1.5h", matches: true},
+		{pattern: durationPattern, value: "0", matches: true},
+		{pattern: durationPattern, value: "1 hour", matches: false},
+		{pattern: durationPattern, value: "10", matches: false},
+		{pattern: interpolationPattern, value: "${SMTP_PORT}", matches: true},
+		{pattern: interpolationPattern, value: "465", matches: false},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.matches, testCase.pattern.MatchString(testCase.value),
+			"for %s and %s", testCase.pattern, testCase.value)
+	}
+}
+
+func TestWriteJSONSchemaToFile(t *testing.T) {
+	tempFile := util.CreateTempFileAndDir("test_output", "schema.*.json")
+	tempFile.Close()
+
+	err := WriteJSONSchemaToFile(tempFile.Name(), false)
+	assert.ErrorContains(t, err, "could not open file")
+
+	err = WriteJSONSchemaToFile(tempFile.Name(), true)
+	require.Nil(t, err)
+	data, err := os.ReadFile(tempFile.Name())
+	require.Nil(t, err)
+	assert.True(t, json.Valid(data))
+}
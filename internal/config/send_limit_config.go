@@ -1,40 +1,46 @@
 package config
 
 import (
+	"strings"
 	"time"
 	"varanus/internal/validation"
 )
 
 type SendLimitConfig struct {
-	MinPeriod    time.Duration `yaml:"min_period"`
-	AccountNames []string      `yaml:"account_names"`
+	MinPeriod    time.Duration `yaml:"min_period" doc:"The shortest time between two emails sent by the accounts" example:"10m" validate:"positive" message:"send limit min_period must be non-negative, not '%d'"`
+	AccountNames []string      `yaml:"account_names" doc:"The accounts that share the limit" validate:"items,required" message:"send_limits account names must not be empty or whitespace"`
+	//if set, the accounts (or every SMTP account if account_names is empty) are split into groups
+	//whose SMTP servers resolve to overlapping IPs, and each group gets its own min_period bucket
+	GroupByServerIP bool `yaml:"group_by_server_ip,omitempty" doc:"If true, the accounts, or every SMTP account if there are none, are grouped by servers with overlapping IP addresses, and each group has its own limit" default:"false" example:"true"`
+}
+
+// Normalize trims the account names
+func (c *SendLimitConfig) Normalize() {
+	for index := range c.AccountNames {
+		c.AccountNames[index] = strings.TrimSpace(c.AccountNames[index])
+	}
 }
 
 func (c SendLimitConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
 
-	if len(c.AccountNames) == 0 {
-		vet.AddValidationError(
-			c,
-			"send limits account name list must not be empty",
-		)
+	//check the rules of the validate tags
+	err := validation.CheckFieldRules(vet, c)
+	if err != nil {
+		return err
 	}
 
-	if c.MinPeriod.Nanoseconds() <= 0 {
+	if len(c.AccountNames) == 0 && !c.GroupByServerIP {
 		vet.AddValidationError(
 			c,
-			"send limit min_period must be non-negative, not '%d'", c.MinPeriod,
+			"send limits account name list must not be empty",
 		)
 	}
 
-	//make sure the account name exists
+	//make sure the accounts exist and can send, since the limits are on the emails they send
 	rootConfig := castInterfaceToVaranusConfig(root)
 	for _, sendLimitAccountName := range c.AccountNames {
-		account := rootConfig.Mail.GetAccountByName(sendLimitAccountName)
-		if account == nil {
-			vet.AddValidationError(
-				c,
-				"send_limits account name '%s' that does not exist", sendLimitAccountName)
-		} // else valid account found
+		AccountRef{Description: "send_limits account", Name: sendLimitAccountName, Needs: ACCOUNT_CAPABILITY_SMTP}.
+			Validate(vet, c, rootConfig.Mail)
 	}
 	return nil
 }
@@ -29,6 +29,19 @@ func TestSendLimitValidation(t *testing.T) {
 			Mutator: func(c *SendLimitConfig) { c.MinPeriod = 0 },
 			Error:   "send limit min_period must be non-negative, not '0'",
 		},
+		{
+			Mutator: func(c *SendLimitConfig) { c.AccountNames = []string{"test1", " "} },
+			Error:   "send_limits account names must not be empty or whitespace",
+		},
+		{
+			Mutator: func(c *SendLimitConfig) { c.AccountNames = []string{"test1", "test"} },
+			Error:   "send_limits account named 'test' does not exist; did you mean 'test1' or 'test2'?",
+		},
+		{
+			//the limits are on sending, so only the accounts with an SMTP configuration are suggested
+			Mutator: func(c *SendLimitConfig) { c.AccountNames = []string{"test1", "test3"} },
+			Error:   "send_limits account named 'test3' must have an SMTP configuration; did you mean 'test1' or 'test2'?",
+		},
 	}
 
 	baseConfig :=
@@ -37,9 +50,15 @@ func TestSendLimitValidation(t *testing.T) {
 				Accounts: []MailAccountConfig{
 					{
 						Name: "test1",
+						SMTP: &SMTPConfig{},
 					},
 					{
 						Name: "test2",
+						SMTP: &SMTPConfig{},
+					},
+					{
+						Name: "test3",
+						IMAP: &IMAPConfig{},
 					},
 				},
 				SendLimits: []SendLimitConfig{
@@ -51,6 +70,17 @@ func TestSendLimitValidation(t *testing.T) {
 			},
 		}
 
+	{
+		//grouping by server IP doesn't need account names
+		config := util.DeepCopy(baseConfig).(VaranusConfig) //make a copy of the config
+		config.Mail.SendLimits[0].AccountNames = nil
+		config.Mail.SendLimits[0].GroupByServerIP = true
+		validationResult := validation.ValidationResult{}
+		err := config.Mail.SendLimits[0].Validate(&validationResult, config)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, validationResult.GetErrorCount())
+	}
+
 	{
 		//nominal case test should have no errors
 		config := util.DeepCopy(baseConfig).(VaranusConfig) //make a copy of the config
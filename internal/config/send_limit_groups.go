@@ -0,0 +1,162 @@
+package config
+
+import (
+	"context"
+	"net"
+	"slices"
+	"strings"
+	"time"
+	"varanus/internal/util"
+)
+
+// RESOLVE_TIMEOUT bounds how long resolving the SMTP server addresses for send limit groups may take
+const RESOLVE_TIMEOUT = 10 * time.Second
+
+// HostResolver looks up the IP addresses of a host.  net.DefaultResolver implements it, and tests
+// can substitute a fake so they don't depend on DNS.
+type HostResolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// SendLimitBucket is a set of accounts that share a send limit.  Only one message may be sent from
+// any of the accounts in each MinPeriod.
+type SendLimitBucket struct {
+	MinPeriod     time.Duration
+	AccountNames  []string
+	ServerIPs     []string //for automatic groups, the IPs the SMTP servers resolved to
+	ResolveErrors []string //for automatic groups, servers that could only be grouped by name
+}
+
+// ResolveSendLimitBuckets returns the buckets for every send limit.  A send limit with explicit
+// account names and without group_by_server_ip is a single bucket.  A send limit with
+// group_by_server_ip is split into one bucket for each group of accounts whose SMTP server addresses
+// resolve to overlapping sets of IPs.  Servers that fail to resolve are still grouped with accounts
+// that use the same server_address, and the failure is recorded in the bucket.
+func (c MailConfig) ResolveSendLimitBuckets(resolver HostResolver) []SendLimitBucket {
+	buckets := []SendLimitBucket{}
+	for _, sendLimit := range c.SendLimits {
+		if !sendLimit.GroupByServerIP {
+			buckets = append(buckets, SendLimitBucket{
+				MinPeriod:    sendLimit.MinPeriod,
+				AccountNames: slices.Clone(sendLimit.AccountNames),
+			})
+			continue
+		}
+		buckets = append(buckets, c.groupAccountsByServerIP(sendLimit, resolver)...)
+	}
+	return buckets
+}
+
+// coversAccount returns true if the send limit applies to the account.  A send limit with
+// group_by_server_ip only covers SMTP accounts, and all of them if it has no account names.
+func (s SendLimitConfig) coversAccount(account MailAccountConfig) bool {
+	if !s.GroupByServerIP {
+		return slices.Contains(s.AccountNames, account.Name)
+	}
+	return account.SMTP != nil && (len(s.AccountNames) == 0 || slices.Contains(s.AccountNames, account.Name))
+}
+
+// groupAccountsByServerIP splits the SMTP accounts covered by sendLimit into buckets using a
+// union-find over the resolved IPs
+func (c MailConfig) groupAccountsByServerIP(sendLimit SendLimitConfig, resolver HostResolver) []SendLimitBucket {
+	//collect the accounts covered by the send limit, in config order
+	accounts := []MailAccountConfig{}
+	for _, account := range c.Accounts {
+		if sendLimit.coversAccount(account) {
+			accounts = append(accounts, account)
+		}
+	}
+
+	parents := make([]int, len(accounts))
+	for index := range parents {
+		parents[index] = index
+	}
+	var find func(index int) int
+	find = func(index int) int {
+		if parents[index] != index {
+			parents[index] = find(parents[index])
+		}
+		return parents[index]
+	}
+	union := func(a int, b int) {
+		rootA, rootB := find(a), find(b)
+		//the earlier account is the root so the groups come out in config order
+		if rootA < rootB {
+			parents[rootB] = rootA
+		} else if rootB < rootA {
+			parents[rootA] = rootB
+		}
+	}
+
+	//accounts that share an IP or a server name are in the same group
+	firstAccountByKey := map[string]int{}
+	addresses := make([][]string, len(accounts))
+	resolveErrors := make([]string, len(accounts))
+	for index, account := range accounts {
+		host := strings.ToLower(util.UnbracketHost(strings.TrimSpace(account.SMTP.ServerAddress)))
+		addresses[index], resolveErrors[index] = resolveServerAddress(host, resolver)
+
+		keys := []string{"name:" + host}
+		for _, address := range addresses[index] {
+			keys = append(keys, "ip:"+address)
+		}
+		for _, key := range keys {
+			if first, found := firstAccountByKey[key]; found {
+				union(first, index)
+			} else {
+				firstAccountByKey[key] = index
+			}
+		}
+	}
+
+	bucketByRoot := map[int]*SendLimitBucket{}
+	roots := []int{}
+	for index, account := range accounts {
+		root := find(index)
+		bucket := bucketByRoot[root]
+		if bucket == nil {
+			bucket = &SendLimitBucket{MinPeriod: sendLimit.MinPeriod, ServerIPs: []string{}}
+			bucketByRoot[root] = bucket
+			roots = append(roots, root)
+		}
+		bucket.AccountNames = append(bucket.AccountNames, account.Name)
+		for _, address := range addresses[index] {
+			if !slices.Contains(bucket.ServerIPs, address) {
+				bucket.ServerIPs = append(bucket.ServerIPs, address)
+			}
+		}
+		if resolveErrors[index] != "" {
+			bucket.ResolveErrors = append(bucket.ResolveErrors, resolveErrors[index])
+		}
+	}
+
+	buckets := make([]SendLimitBucket, 0, len(roots))
+	for _, root := range roots {
+		slices.Sort(bucketByRoot[root].ServerIPs)
+		buckets = append(buckets, *bucketByRoot[root])
+	}
+	return buckets
+}
+
+// resolveServerAddress returns the normalized IPs of host, or a description of why it couldn't be
+// resolved
+func resolveServerAddress(host string, resolver HostResolver) ([]string, string) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), RESOLVE_TIMEOUT)
+	defer cancel()
+	resolved, err := resolver.LookupHost(ctx, host)
+	if err != nil {
+		return nil, err.Error()
+	}
+
+	addresses := []string{}
+	for _, address := range resolved {
+		if ip := net.ParseIP(address); ip != nil {
+			addresses = append(addresses, ip.String())
+		}
+	}
+	return addresses, ""
+}
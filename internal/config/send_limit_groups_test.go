@@ -0,0 +1,74 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver resolves hosts from a map, and fails for any other host
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addresses, found := r[host]
+	if !found {
+		return nil, fmt.Errorf("lookup %s: no such host", host)
+	}
+	return addresses, nil
+}
+
+func TestResolveSendLimitBuckets(t *testing.T) {
+
+	resolver := fakeResolver{
+		"smtp1.example.com": {"192.0.2.1", "192.0.2.2"},
+		"smtp2.example.com": {"192.0.2.2", "2001:db8::0:1"},
+		"smtp3.example.com": {"2001:db8::1"},
+		"smtp4.example.com": {"198.51.100.1"},
+	}
+
+	smtpAccount := func(name string, serverAddress string) MailAccountConfig {
+		return MailAccountConfig{Name: name, SMTP: &SMTPConfig{ServerAddress: serverAddress}}
+	}
+
+	config := MailConfig{
+		Accounts: []MailAccountConfig{
+			smtpAccount("a", "smtp1.example.com"),
+			smtpAccount("b", "smtp4.example.com"),
+			smtpAccount("c", "smtp3.example.com"), //shares 2001:db8::1 with d through smtp2
+			smtpAccount("d", "SMTP2.example.com"), //shares 192.0.2.2 with a
+			smtpAccount("e", "[2001:db8::1]"),
+			smtpAccount("f", "unknown.example.com"),
+			smtpAccount("g", "unknown.example.com"),
+			{Name: "imap only", IMAP: &IMAPConfig{}},
+		},
+		SendLimits: []SendLimitConfig{
+			{MinPeriod: time.Minute, AccountNames: []string{"a", "b"}},
+			{MinPeriod: time.Hour, GroupByServerIP: true},
+			{MinPeriod: time.Second, AccountNames: []string{"a", "b"}, GroupByServerIP: true},
+		},
+	}
+
+	buckets := config.ResolveSendLimitBuckets(resolver)
+
+	expected := []SendLimitBucket{
+		{MinPeriod: time.Minute, AccountNames: []string{"a", "b"}},
+		{
+			MinPeriod:    time.Hour,
+			AccountNames: []string{"a", "c", "d", "e"},
+			ServerIPs:    []string{"192.0.2.1", "192.0.2.2", "2001:db8::1"},
+		},
+		{MinPeriod: time.Hour, AccountNames: []string{"b"}, ServerIPs: []string{"198.51.100.1"}},
+		{
+			MinPeriod:     time.Hour,
+			AccountNames:  []string{"f", "g"},
+			ServerIPs:     []string{},
+			ResolveErrors: []string{"lookup unknown.example.com: no such host", "lookup unknown.example.com: no such host"},
+		},
+		{MinPeriod: time.Second, AccountNames: []string{"a"}, ServerIPs: []string{"192.0.2.1", "192.0.2.2"}},
+		{MinPeriod: time.Second, AccountNames: []string{"b"}, ServerIPs: []string{"198.51.100.1"}},
+	}
+	assert.Equal(t, expected, buckets)
+}
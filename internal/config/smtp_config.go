@@ -1,55 +1,54 @@
 package config
 
 import (
-	"net/mail"
 	"strings"
 	"varanus/internal/secrets"
-	"varanus/internal/util"
 	"varanus/internal/validation"
 )
 
+// the ports used for SMTP if an account doesn't set one
+const (
+	DEFAULT_SMTP_PORT     = 25
+	DEFAULT_SMTP_TLS_PORT = 465
+)
+
 type SMTPConfig struct {
-	SenderAddress string             `yaml:"sender_address"`
-	ServerAddress string             `yaml:"server_address"`
-	Port          uint               `yaml:"port"`
-	UseTLS        bool               `yaml:"use_tls"`
-	Username      string             `yaml:"username"`
-	Password      secrets.SealedItem `yaml:"password"`
+	SenderAddress string             `yaml:"sender_address" doc:"The email address the test emails are sent from" example:"monitor@example.com" validate:"email"`
+	ServerAddress string             `yaml:"server_address" doc:"The hostname or IP address of the SMTP server" example:"smtp.example.com" validate:"hostname"`
+	Port          uint               `yaml:"port,omitempty" doc:"The port of the SMTP server" default:"465 with use_tls, otherwise 25" example:"465" validate:"port"`
+	UseTLS        bool               `yaml:"use_tls" doc:"If true, the connection to the server is encrypted with TLS" default:"false" example:"true"`
+	Username      string             `yaml:"username" doc:"The username to log in to the SMTP server" example:"monitor@example.com" validate:"required"`
+	Password      secrets.SealedItem `yaml:"password" doc:"The password to log in to the SMTP server" example:"sealed(...)"`
 }
 
-func (c SMTPConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
-
-	//validate fields
+// Normalize trims the addresses and username, and lowercases the server address.  The default
+// port is filled in by the account.
+func (c *SMTPConfig) Normalize() {
 	c.SenderAddress = strings.TrimSpace(c.SenderAddress)
+	c.ServerAddress = strings.ToLower(strings.TrimSpace(c.ServerAddress))
+	c.Username = strings.TrimSpace(c.Username)
+}
 
-	_, addressError := mail.ParseAddress(c.SenderAddress)
-	if addressError != nil {
-		vet.AddValidationError(
-			c,
-			"sender_address '%s' is not a valid email: %s", c.SenderAddress, addressError,
-		)
+// defaultPort returns the port to use if none is set
+func (c SMTPConfig) defaultPort() uint {
+	if c.UseTLS {
+		return DEFAULT_SMTP_TLS_PORT
 	}
+	return DEFAULT_SMTP_PORT
+}
 
-	c.ServerAddress = strings.TrimSpace(c.ServerAddress)
-	if !util.IsUrlHost(c.ServerAddress) {
-		vet.AddValidationError(
-			c,
-			"server_address '%s' is not a valid hostname", c.ServerAddress,
-		)
-	}
+func (c SMTPConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
 
-	if c.Port == 0 {
-		vet.AddValidationError(
-			c,
-			"port value is required and cannot be 0",
-		)
+	//check the rules of the validate tags
+	err := validation.CheckFieldRules(vet, c)
+	if err != nil {
+		return err
 	}
 
-	c.Username = strings.TrimSpace(c.Username)
-	if len(c.Username) == 0 {
-		vet.AddValidationError(
+	if !c.UseTLS {
+		vet.AddValidationWarning(
 			c,
-			"username must not be empty or whitespace",
+			"use_tls is false, so the password is sent to '%s' without encryption", c.ServerAddress,
 		)
 	}
 
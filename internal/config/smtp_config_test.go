@@ -76,12 +76,23 @@ func TestSmtpConfigValidation(t *testing.T) {
 		validationResult, err := validation.ValidateObject(config)
 		assert.Nil(t, err)
 		assert.Equal(t, 0, validationResult.GetErrorCount())
+
+		//without TLS, the password is sent in the clear, which is only a warning
+		require.Equal(t, 1, validationResult.GetWarningCount())
+		assert.Equal(t, "use_tls is false, so the password is sent to 'mail.example.com' without encryption",
+			validationResult.GetWarningList()[0].Error)
+
+		config.UseTLS = true
+		validationResult, err = validation.ValidateObject(config)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, validationResult.GetWarningCount())
 	}
 	// test loop
 	for index, testCase := range testCases {
 		//do validation
 		config := util.DeepCopy(baseConfig).(SMTPConfig) //make a copy of the config
 		testCase.Mutator(&config)                        //modify the config
+		require.Nil(t, validation.NormalizeObject(&config))
 		validationResult, err := validation.ValidateObject(config)
 		//checks
 		assert.Nil(t, err)
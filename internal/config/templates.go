@@ -0,0 +1,177 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"varanus/internal/walker"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MailAccountTemplate has the settings shared by the accounts that extend it, like the server
+// address, port, and TLS settings of a provider.  It has the same fields as an account, but none
+// are required because it is not validated itself; the accounts are validated after they inherit
+// from it.  A template can extend another template.
+type MailAccountTemplate MailAccountConfig
+
+// TEMPLATE_OWN_FIELDS are the fields that are never inherited from a template
+var TEMPLATE_OWN_FIELDS = []string{"name", "extends"}
+
+// TEMPLATE_SECTION_FIELDS are the sections that decide whether an account can send or receive, so
+// an account only inherits them from its template if it declares them itself, even if only as
+// "smtp: {}".  A template inherits every section of the template it extends.
+var TEMPLATE_SECTION_FIELDS = []string{"smtp", "imap"}
+
+// nodeFinder returns the node that the object at a path was decoded from, or nil if there is none
+type nodeFinder func(path string) *yaml.Node
+
+// resolveTemplates returns a copy of the config where every account that extends a template has
+// inherited each template field that the account doesn't set itself, and without the templates.
+// Nested objects like smtp are inherited field by field, and the sections in
+// TEMPLATE_SECTION_FIELDS only if the account has them.
+//
+// findNode gives the nodes the config was decoded from, to tell fields that are set to their zero
+// value, like "use_tls: false", from fields that are left out.  Templates that can't be resolved
+// are added to the unresolved references of the copy.
+func (c VaranusConfig) resolveTemplates(findNode nodeFinder) VaranusConfig {
+	resolved := c
+	resolved.Mail.Templates = nil
+	if len(c.Mail.Templates) == 0 {
+		return resolved
+	}
+
+	resolver := templateResolver{
+		templates: c.Mail.Templates,
+		byName:    map[string]int{},
+		resolved:  map[string]*MailAccountTemplate{},
+		findNode:  findNode,
+	}
+	for index, template := range c.Mail.Templates {
+		if _, found := resolver.byName[template.Name]; found {
+			resolved.unresolvedReferences = append(resolved.unresolvedReferences, UnresolvedReference{
+				Path:      fmt.Sprintf("mail.templates[%d]", index),
+				Reference: fmt.Sprintf("template '%s'", template.Name),
+				Reason:    fmt.Errorf("another template has the same name"),
+			})
+			continue
+		}
+		resolver.byName[template.Name] = index
+	}
+
+	resolved.Mail.Accounts = make([]MailAccountConfig, 0, len(c.Mail.Accounts))
+	for index, account := range c.Mail.Accounts {
+		if account.Extends != "" {
+			path := fmt.Sprintf("mail.accounts[%d]", index)
+			template, err := resolver.resolve(account.Extends, []string{})
+			if err != nil {
+				resolved.unresolvedReferences = append(resolved.unresolvedReferences, UnresolvedReference{
+					Path:      path,
+					Reference: fmt.Sprintf("template '%s'", account.Extends),
+					Reason:    err,
+				})
+			} else {
+				inheritFields(reflect.ValueOf(&account).Elem(), reflect.ValueOf(MailAccountConfig(*template)), findNode(path), true)
+			}
+			account.Extends = ""
+		}
+		resolved.Mail.Accounts = append(resolved.Mail.Accounts, account)
+	}
+
+	return resolved
+}
+
+type templateResolver struct {
+	templates []MailAccountTemplate
+	byName    map[string]int
+	//the templates that have already inherited from the templates they extend
+	resolved map[string]*MailAccountTemplate
+	findNode nodeFinder
+}
+
+// resolve returns the template with the given name after it has inherited from the templates it
+// extends.  chain is the templates that extend this one, to detect cycles.
+func (r *templateResolver) resolve(name string, chain []string) (*MailAccountTemplate, error) {
+	if template, found := r.resolved[name]; found {
+		return template, nil
+	}
+	chain = append(chain, name)
+	index, found := r.byName[name]
+	if !found {
+		return nil, fmt.Errorf("there is no template with that name")
+	}
+	if slices.Contains(chain[:len(chain)-1], name) {
+		return nil, fmt.Errorf("the templates extend each other in a cycle: %s", strings.Join(chain, " -> "))
+	}
+
+	template := r.templates[index]
+	if template.Extends != "" {
+		parent, err := r.resolve(template.Extends, chain)
+		if err != nil {
+			return nil, err
+		}
+		inheritFields(reflect.ValueOf(&template).Elem(), reflect.ValueOf(*parent),
+			r.findNode(fmt.Sprintf("mail.templates[%d]", index)), false)
+	}
+	r.resolved[name] = &template
+	return &template, nil
+}
+
+// inheritFields sets each field of target that isn't in node to the same field of source.  Fields
+// that are pointers to structs and are set in both are inherited field by field, into a copy so
+// that the objects target shares with other configs are not changed.  A nil node means that none
+// of the fields are set.
+//
+// If declaredSectionsOnly is set, the fields in TEMPLATE_SECTION_FIELDS are only inherited if they
+// are in node, so that an account without an smtp section doesn't become able to send.
+func inheritFields(target reflect.Value, source reflect.Value, node *yaml.Node, declaredSectionsOnly bool) {
+	for index := 0; index < target.NumField(); index++ {
+		field := target.Type().Field(index)
+		key := walker.GetYamlNameFromTag(field.Tag)
+		if !field.IsExported() || key == "" || key == "-" || slices.Contains(TEMPLATE_OWN_FIELDS, key) {
+			continue
+		}
+		targetField := target.Field(index)
+		sourceField := source.Field(index)
+		isSection := declaredSectionsOnly && slices.Contains(TEMPLATE_SECTION_FIELDS, key)
+
+		child := mappingValue(node, key)
+		if child == nil {
+			if !isSection {
+				targetField.Set(sourceField)
+			}
+			continue
+		}
+		if field.Type.Kind() != reflect.Pointer || field.Type.Elem().Kind() != reflect.Struct || sourceField.IsNil() {
+			continue
+		}
+		if targetField.IsNil() && !isSection {
+			//set to null, which removes what the template has
+			continue
+		}
+		fieldCopy := reflect.New(field.Type.Elem())
+		if !targetField.IsNil() {
+			fieldCopy.Elem().Set(targetField.Elem())
+		}
+		inheritFields(fieldCopy.Elem(), sourceField.Elem(), child, false)
+		targetField.Set(fieldCopy)
+	}
+}
+
+// mappingValue returns the value for key in a mapping node, or nil if node is nil, isn't a
+// mapping, or doesn't have key.  A key merged in with "<<" counts as one the mapping has.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	node = resolveNode(node)
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	_, value := findMappingEntry(node, key)
+	if value == nil {
+		return nil
+	}
+	return resolveNode(value)
+}
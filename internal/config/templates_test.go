@@ -0,0 +1,179 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const TEMPLATE_TEST_PROVIDER_YAML = `mail:
+  templates:
+    - name: provider
+      smtp:
+        server_address: smtp.example.com
+        port: 465
+        use_tls: true
+      local_address: 10.0.0.1
+`
+
+func TestResolveTemplates(t *testing.T) {
+
+	type testCase struct {
+		//the config files, keyed by name, that are merged from a directory
+		files map[string]string
+		//called with the resolved accounts
+		checkAccounts func(t *testing.T, accounts []MailAccountConfig)
+		//the paths and reasons of the unresolved references expected, in order
+		unresolvedPaths   []string
+		unresolvedReasons []string
+	}
+
+	testCases := []testCase{
+		//fields are inherited unless the account sets them, even to false
+		{
+			files: map[string]string{"10-config.yaml": TEMPLATE_TEST_PROVIDER_YAML + `  accounts:
+    - name: test1
+      extends: provider
+      smtp:
+        sender_address: example@example.com
+        use_tls: false
+    - name: test2
+      extends: provider
+      smtp: {}
+      local_address: 10.0.0.2
+    - name: test3
+      extends: provider
+      imap:
+        server_address: imap.example.com
+    - name: test4
+      extends: provider
+      smtp:
+`},
+			checkAccounts: func(t *testing.T, accounts []MailAccountConfig) {
+				require.Len(t, accounts, 4)
+				assert.Equal(t, "", accounts[0].Extends)
+				assert.Equal(t, "example@example.com", accounts[0].SMTP.SenderAddress)
+				assert.Equal(t, "smtp.example.com", accounts[0].SMTP.ServerAddress)
+				assert.Equal(t, uint(465), accounts[0].SMTP.Port)
+				assert.Equal(t, false, accounts[0].SMTP.UseTLS)
+				assert.Equal(t, "10.0.0.1", accounts[0].LocalAddress)
+				assert.Equal(t, "test2", accounts[1].Name)
+				assert.Equal(t, "smtp.example.com", accounts[1].SMTP.ServerAddress)
+				assert.Equal(t, true, accounts[1].SMTP.UseTLS)
+				assert.Equal(t, "10.0.0.2", accounts[1].LocalAddress)
+				//accounts that inherit the same object don't share it
+				assert.NotSame(t, accounts[0].SMTP, accounts[1].SMTP)
+				//the smtp section is only inherited by the accounts that have one, even if empty
+				assert.Nil(t, accounts[2].SMTP)
+				assert.Equal(t, "imap.example.com", accounts[2].IMAP.ServerAddress)
+				require.NotNil(t, accounts[3].SMTP)
+				assert.Equal(t, "smtp.example.com", accounts[3].SMTP.ServerAddress)
+				assert.NotSame(t, accounts[1].SMTP, accounts[3].SMTP)
+			},
+		},
+		//templates can extend templates, and can be in another fragment
+		{
+			files: map[string]string{
+				"10-templates.yaml": TEMPLATE_TEST_PROVIDER_YAML + `    - name: team
+      extends: provider
+      smtp:
+        port: 587
+`,
+				"20-accounts.yaml": `mail:
+  accounts:
+    - name: test1
+      extends: team
+      smtp:
+        username: joeuser@example.com
+`},
+			checkAccounts: func(t *testing.T, accounts []MailAccountConfig) {
+				require.Len(t, accounts, 1)
+				assert.Equal(t, "smtp.example.com", accounts[0].SMTP.ServerAddress)
+				assert.Equal(t, uint(587), accounts[0].SMTP.Port)
+				assert.Equal(t, true, accounts[0].SMTP.UseTLS)
+				assert.Equal(t, "joeuser@example.com", accounts[0].SMTP.Username)
+				assert.Equal(t, "10.0.0.1", accounts[0].LocalAddress)
+			},
+		},
+		//an unknown template
+		{
+			files: map[string]string{"10-config.yaml": TEMPLATE_TEST_PROVIDER_YAML + `  accounts:
+    - name: test1
+      extends: providr
+`},
+			checkAccounts: func(t *testing.T, accounts []MailAccountConfig) {
+				require.Len(t, accounts, 1)
+				assert.Equal(t, "", accounts[0].Extends)
+				assert.Nil(t, accounts[0].SMTP)
+			},
+			unresolvedPaths:   []string{"mail.accounts[0]"},
+			unresolvedReasons: []string{"there is no template with that name"},
+		},
+		//templates that extend each other in a cycle
+		{
+			files: map[string]string{"10-config.yaml": `mail:
+  templates:
+    - name: a
+      extends: b
+    - name: b
+      extends: a
+  accounts:
+    - name: test1
+      extends: a
+`},
+			unresolvedPaths:   []string{"mail.accounts[0]"},
+			unresolvedReasons: []string{"the templates extend each other in a cycle: a -> b -> a"},
+		},
+		//two templates with the same name
+		{
+			files: map[string]string{
+				"10-config.yaml": TEMPLATE_TEST_PROVIDER_YAML,
+				"20-config.yaml": TEMPLATE_TEST_PROVIDER_YAML,
+			},
+			unresolvedPaths:   []string{"mail.templates[1]"},
+			unresolvedReasons: []string{"another template has the same name"},
+		},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+
+		dir := writeConfigFiles(t, testCase.files)
+		set, err := ReadConfigSetFromDir(dir)
+		require.Nil(t, err)
+
+		//the templates are only in the merged config
+		assert.Empty(t, set.Config.Mail.Templates)
+		assert.NotEmpty(t, set.MergedConfig.Mail.Templates)
+
+		if testCase.checkAccounts != nil {
+			testCase.checkAccounts(t, set.Config.Mail.Accounts)
+		}
+
+		unresolved := set.Config.GetUnresolvedReferences()
+		require.Len(t, unresolved, len(testCase.unresolvedPaths))
+		for referenceIndex, reference := range unresolved {
+			assert.Equal(t, testCase.unresolvedPaths[referenceIndex], reference.Path)
+			assert.ErrorContains(t, reference.Reason, testCase.unresolvedReasons[referenceIndex])
+		}
+	}
+}
+
+func TestResolveTemplatesKeepsDocuments(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{"10-config.yaml": TEMPLATE_TEST_PROVIDER_YAML + `  accounts:
+    - name: test1
+      extends: provider
+      smtp:
+        username: joeuser@example.com
+`})
+	set, err := ReadConfigSetFromDir(dir)
+	require.Nil(t, err)
+
+	//the document is left as written, so it can be sealed and written back
+	account := set.Documents[0].Config.Mail.Accounts[0]
+	assert.Equal(t, "provider", account.Extends)
+	assert.Equal(t, "", account.SMTP.ServerAddress)
+	assert.Equal(t, "", account.LocalAddress)
+	assert.Equal(t, "smtp.example.com", set.Config.Mail.Accounts[0].SMTP.ServerAddress)
+}
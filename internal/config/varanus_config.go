@@ -2,6 +2,7 @@ package config
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"varanus/internal/validation"
@@ -14,9 +15,31 @@ import (
 //**************************************************************************************************
 
 type VaranusConfig struct {
-	Mail             MailConfig          `yaml:"mail"`
-	MonitoringConfig MonitorConfig       `yaml:"monitoring"`
-	ForceFailure     *ForceConfigFailure `yaml:"force_failure,omitempty"`
+	//Version is the version of the config format, see migration.go
+	Version int `yaml:"version,omitempty" doc:"The version of the config format; older configs are upgraded when they are read, see config migrate" default:"1" example:"1"`
+	//Interpolate turns on ${...} references in the config values, see interpolation.go
+	Interpolate bool `yaml:"interpolate,omitempty" doc:"If true, ${NAME}, ${NAME:-default} and ${file:/path} references in values are replaced when the config is read" default:"false" example:"true"`
+	//Include lists files, or glob patterns, to merge into this config, relative to its directory;
+	//see config_set.go
+	Include          []string            `yaml:"include,omitempty" doc:"Files or glob patterns, relative to this file, whose accounts, send limits and monitors are merged into this config" example:"accounts/*.yaml"`
+	Mail             MailConfig          `yaml:"mail" doc:"The mail accounts and how they are used"`
+	MonitoringConfig MonitorConfig       `yaml:"monitoring" doc:"The monitors that test the accounts"`
+	Modules          ModuleConfigs       `yaml:"modules,omitempty" doc:"The configs of the modules, by the name they are registered with"`
+	ForceFailure     *ForceConfigFailure `yaml:"force_failure,omitempty" doc:"Forces errors in config handling; only for testing varanus"`
+
+	//references that could not be resolved when the config was read, reported by Validate
+	unresolvedReferences []UnresolvedReference
+	//the file each account came from, if the config was merged from several files
+	accountSources []string
+	//the documents that were upgraded from an older version when they were read
+	migratedSources []migratedSource
+}
+
+// migratedSource is a document that was upgraded from an older version when it was read
+type migratedSource struct {
+	//filename is empty if the config wasn't read from a file
+	filename string
+	version  int
 }
 
 // ToYAML marshalls the config to a YAML format and returns it as a string.
@@ -24,30 +47,76 @@ func (c *VaranusConfig) ToYAML() (string, error) {
 	return objectToYaml(c)
 }
 
-// WriteConfigToFile marshals the VaranusConfig to a YAML format and writes it to filename.  If
-// forceOverwrite is False, then an error will occur if the file already exists.
+// ToJSON marshalls the config to a JSON format and returns it as a string.
+func (c *VaranusConfig) ToJSON() (string, error) {
+	return objectToJson(c)
+}
+
+// WriteConfigToFile marshals the VaranusConfig to the format implied by the filename extension and
+// writes it to filename.  If forceOverwrite is False, then an error will occur if the file already
+// exists.
 func (c *VaranusConfig) WriteConfigToFile(filename string, forceOverwrite bool) error {
-	return writeObjectToFile(c, filename, forceOverwrite)
+	return writeObjectToFile(c, filename, ConfigFormatFromFilename(filename), forceOverwrite)
+}
+
+// WriteConfigToFileWithFormat is like WriteConfigToFile, but writes in the given format regardless
+// of the filename extension.
+func (c *VaranusConfig) WriteConfigToFileWithFormat(filename string, format ConfigFormat, forceOverwrite bool) error {
+	return writeObjectToFile(c, filename, format, forceOverwrite)
 }
 
 func (c VaranusConfig) Validate(vet validation.ValidationErrorTracker, root interface{}) error {
-	//no top-level validation to do
+	//unresolved references are reported at the value they were in, if the tracker allows it
+	pathVet, hasPaths := vet.(validation.PathIssueTracker)
+	for _, reference := range c.unresolvedReferences {
+		if hasPaths {
+			pathVet.AddIssueAtPath(validation.SEVERITY_ERROR, reference.Path, c,
+				"could not resolve %s: %s", reference.Reference, reference.Reason)
+		} else {
+			vet.AddValidationError(c,
+				"could not resolve %s at %s: %s", reference.Reference, reference.Path, reference.Reason)
+		}
+	}
+
+	//older documents still work, but should be upgraded before the migrations are removed
+	for _, source := range c.migratedSources {
+		inFile := ""
+		if source.filename != "" {
+			inFile = " in " + source.filename
+		}
+		vet.AddValidationWarning(c,
+			"the config%s is version %d and was upgraded to version %d when it was read; run 'varanus config migrate' to upgrade the file",
+			inFile, source.version, CurrentConfigVersion())
+	}
 	return nil
 }
 
+// GetUnresolvedReferences returns the interpolation references that could not be resolved when
+// the config was read.
+func (c VaranusConfig) GetUnresolvedReferences() []UnresolvedReference {
+	return c.unresolvedReferences
+}
+
 //**************************************************************************************************
 //** Top level methods for loading and saving configs
 //**************************************************************************************************
 
-// ReadConfigFromFile creates a VaranusConfig object from the file at filename.
+// ReadConfigFromFile creates a VaranusConfig object from the file at filename, in the format implied
+// by the filename extension.
 func ReadConfigFromFile(filename string) (*VaranusConfig, error) {
+	return ReadConfigFromFileWithFormat(filename, ConfigFormatFromFilename(filename))
+}
+
+// ReadConfigFromFileWithFormat creates a VaranusConfig object from the file at filename, which must
+// be in the given format.
+func ReadConfigFromFileWithFormat(filename string, format ConfigFormat) (*VaranusConfig, error) {
 
 	ydata, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("file read error for config file %s: %s", filename, err)
 	}
 
-	config, err := ReadConfig(ydata)
+	config, err := ReadConfigWithFormat(ydata, format)
 	if err != nil {
 		return nil, fmt.Errorf("error with the contents of %s: %s", filename, err)
 	}
@@ -56,18 +125,83 @@ func ReadConfigFromFile(filename string) (*VaranusConfig, error) {
 }
 
 func ReadConfig(yamlData []byte) (*VaranusConfig, error) {
+	return ReadConfigWithFormat(yamlData, CONFIG_FORMAT_YAML)
+}
+
+// ReadConfigWithFormat creates a VaranusConfig object from data in the given format.  Unknown fields
+// are rejected in both formats.  Configs from an older version are upgraded first, as described in
+// migration.go.  If the config sets "interpolate: true", the references in its values are
+// replaced as described in interpolation.go.
+func ReadConfigWithFormat(data []byte, format ConfigFormat) (*VaranusConfig, error) {
+
+	if format == CONFIG_FORMAT_JSON {
+		//JSON is decoded with the YAML decoder, since JSON is valid YAML, so that the YAML field
+		//names and KnownFields apply.  Make sure it really is JSON first.
+		if !json.Valid(data) {
+			var ignored interface{}
+			err := json.Unmarshal(data, &ignored)
+			return nil, fmt.Errorf("unmarshal error: invalid JSON: %w", err)
+		}
+	}
+
+	data, applied, err := migrateConfigData(data)
+	if err != nil {
+		return nil, err
+	}
+	config, err := decodeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	//the values are normalized before anything validates or uses them
+	err = validation.NormalizeObject(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) > 0 {
+		config.migratedSources = []migratedSource{{version: applied[0].FromVersion}}
+	}
+	return config, nil
+}
+
+// decodeConfig decodes a config from YAML data, interpolating it if it sets "interpolate: true"
+func decodeConfig(data []byte) (*VaranusConfig, error) {
 
 	config := &VaranusConfig{}
 
 	//use a decoder so we can set KnownFields
-	decoder := yaml.NewDecoder(bytes.NewBuffer(yamlData))
+	decoder := yaml.NewDecoder(bytes.NewBuffer(data))
 	decoder.KnownFields(true)
 	err := decoder.Decode(config)
 
+	if !config.Interpolate {
+		if err != nil {
+			return nil, fmt.Errorf("unmarshal error: %w", err)
+		}
+		return config, nil
+	}
+
+	//the values are decoded again after interpolation, so only the unknown fields are errors here
+	err = unknownFieldErrors(err)
 	if err != nil {
 		return nil, fmt.Errorf("unmarshal error: %w", err)
 	}
 
+	//the node tree is interpolated and decoded so that errors keep the line numbers of data
+	var root yaml.Node
+	err = yaml.Unmarshal(data, &root)
+	if err != nil {
+		//unreachable because the same data was already decoded
+		return nil, fmt.Errorf("unmarshal error: %w", err)
+	}
+	unresolvedReferences := interpolateNode(&root, "")
+
+	config = &VaranusConfig{}
+	err = root.Decode(config)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshal error after interpolation: %w", err)
+	}
+	config.unresolvedReferences = unresolvedReferences
+
 	return config, nil
 }
 
@@ -87,8 +221,25 @@ func objectToYaml(object interface{}) (string, error) {
 	return yamlData.String(), nil
 }
 
-func writeObjectToFile(object interface{}, filename string, forceOverwrite bool) error {
-	//write the config file back out
+func writeObjectToFile(object interface{}, filename string, format ConfigFormat, forceOverwrite bool) error {
+	//convert to the output format
+	var data string
+	var err error
+	if format == CONFIG_FORMAT_JSON {
+		data, err = objectToJson(object)
+	} else {
+		data, err = objectToYaml(object)
+	}
+	if err != nil {
+		return fmt.Errorf("marshaling error: %w", err)
+	}
+
+	return writeBytesToFile([]byte(data), filename, forceOverwrite)
+}
+
+// writeBytesToFile writes data to filename.  If forceOverwrite is False, then an error will occur
+// if the file already exists.
+func writeBytesToFile(data []byte, filename string, forceOverwrite bool) error {
 	var flags int
 	if forceOverwrite {
 		//okay to overwrite an existing file
@@ -97,19 +248,14 @@ func writeObjectToFile(object interface{}, filename string, forceOverwrite bool)
 		flags = os.O_RDWR | os.O_CREATE | os.O_EXCL
 	}
 
-	//convert to yaml
-	yaml, err := objectToYaml(object)
-	if err != nil {
-		return fmt.Errorf("marshaling error: %w", err)
-	}
-
 	f, err := os.OpenFile(filename, flags, 0600)
 	if err != nil {
 		return fmt.Errorf("could not open file %s: %w", filename, err)
 	}
+	defer f.Close()
 
 	//write out file
-	_, err = f.WriteString(yaml)
+	_, err = f.Write(data)
 	if err != nil {
 		//not tested because difficult to induce the write failure
 		return fmt.Errorf("error writing to file %s: %w", filename, err)
@@ -137,3 +283,15 @@ func castInterfaceToVaranusConfig(config interface{}) VaranusConfig {
 	}
 	panic(fmt.Errorf("could not cast %#v to VaranusConfig or *VaranusConfig", config))
 }
+
+// getAccountSources returns the file each account came from if root is a config merged from
+// several files, or nil otherwise
+func getAccountSources(root interface{}) []string {
+	switch rootConfig := root.(type) {
+	case VaranusConfig:
+		return rootConfig.accountSources
+	case *VaranusConfig:
+		return rootConfig.accountSources
+	}
+	return nil
+}
@@ -136,7 +136,7 @@ func TestEndToEndWithValidationErrors(t *testing.T) {
 	assert.Nil(t, err)
 
 	err = validationResult.AsError()
-	assert.ErrorContains(t, err, "send_limits account name 'test1' that does not exist")
+	assert.ErrorContains(t, err, "send_limits account named 'test1' does not exist")
 	assert.ErrorContains(t, err, "account names must not be empty or whitespace")
 
 	//write back the invalidated config anyway
@@ -224,7 +224,7 @@ func TestConfigWriteFailures(t *testing.T) {
 	assert.ErrorContains(t, err, "intentional marshal failure")
 
 	//try to write it to a file
-	err = writeObjectToFile(object, "test.output", false)
+	err = writeObjectToFile(object, "test.output", CONFIG_FORMAT_YAML, false)
 	assert.ErrorContains(t, err, "intentional marshal failure")
 
 	//try to write a valid config to an invalid filename
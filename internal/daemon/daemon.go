@@ -0,0 +1,322 @@
+package daemon
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+	"varanus/internal/config"
+
+	"github.com/rs/zerolog/log"
+)
+
+// the number of probe results kept for each monitor
+const MAX_PROBE_HISTORY = 20
+
+// ConfigLoader reads, validates, seal-checks and compiles the config.  The error says why the
+// config was rejected.
+type ConfigLoader func() (*config.RuntimeConfig, error)
+
+// Prober runs the probes of the monitors of one runtime config, and sends the notifications of a
+// monitor when its probe fails
+type Prober interface {
+	Probe(ctx context.Context, monitor *config.RuntimeMonitor) error
+	Notify(monitor *config.RuntimeMonitor, probeErr error) error
+}
+
+// ProberFactory makes the prober for a runtime config.  A new prober is made for each reloaded
+// config, while the probes that are still running finish with the prober of the old config.
+type ProberFactory func(runtimeConfig *config.RuntimeConfig) Prober
+
+// ProbeResult is one probe run by a monitor.  Err is nil if the probe succeeded.
+type ProbeResult struct {
+	Started time.Time
+	Err     error
+}
+
+// ReloadChanges lists the monitors, by name, that were changed by a reload
+type ReloadChanges struct {
+	Added       []string
+	Removed     []string
+	Rescheduled []string //the monitors whose test_period changed
+}
+
+// Daemon runs the monitors of a config on their test periods, and reloads the config on request.
+// A reloaded config is swapped in only if it is loaded without an error, and the monitors are
+// diffed by name: new monitors are added, missing ones are removed, and the others keep their
+// history and schedule, which is only changed if their test_period changed.  The name of a monitor
+// is "from->to", with "#2", "#3" and so on added to the monitors after the first with the same
+// accounts, so reordering those monitors swaps their histories.
+//
+// The notifications of a monitor are sent when a probe fails after the last one succeeded, or
+// when its first probe fails, rather than for every probe that fails.
+type Daemon struct {
+	loader     ConfigLoader
+	makeProber ProberFactory
+	reloads    chan struct{}
+	results    chan probeCompletion
+	now        func() time.Time
+
+	//guards the fields below, which are swapped together by a reload
+	mutex    sync.Mutex
+	config   *config.RuntimeConfig
+	prober   Prober
+	monitors map[string]*monitorState
+}
+
+type monitorState struct {
+	monitor *config.RuntimeMonitor
+	running bool
+	lastRun time.Time //when the last probe started, or zero if it hasn't run
+	nextRun time.Time
+	history []ProbeResult
+}
+
+type probeCompletion struct {
+	name   string
+	state  *monitorState //the state when the probe started, so a removed monitor's result is dropped
+	result ProbeResult
+}
+
+// MakeDaemon returns a daemon for the config returned by loader.  An error is returned if the
+// config can't be loaded.
+func MakeDaemon(loader ConfigLoader, makeProber ProberFactory) (*Daemon, error) {
+	d := &Daemon{
+		loader:     loader,
+		makeProber: makeProber,
+		reloads:    make(chan struct{}, 1),
+		results:    make(chan probeCompletion),
+		now:        time.Now,
+		monitors:   map[string]*monitorState{},
+	}
+	runtimeConfig, err := loader()
+	if err != nil {
+		return nil, err
+	}
+	d.applyConfig(runtimeConfig)
+	return d, nil
+}
+
+// Config returns the runtime config that the monitors use
+func (d *Daemon) Config() *config.RuntimeConfig {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.config
+}
+
+// MonitorNames returns the names of the monitors in config order
+func (d *Daemon) MonitorNames() []string {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	names := []string{}
+	for _, monitor := range d.config.Monitors() {
+		names = append(names, monitor.Name())
+	}
+	return names
+}
+
+// History returns the results of the last probes of the monitor, oldest first
+func (d *Daemon) History(name string) []ProbeResult {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	state := d.monitors[name]
+	if state == nil {
+		return nil
+	}
+	return slices.Clone(state.history)
+}
+
+// Reload asks Run to reload the config.  It doesn't wait for the reload, and a request that is
+// made while another is waiting is merged with it.
+func (d *Daemon) Reload() {
+	select {
+	case d.reloads <- struct{}{}:
+	default:
+	}
+}
+
+// Run runs the monitors until ctx is done, then waits for the probes that are running to return
+func (d *Daemon) Run(ctx context.Context) error {
+	var inFlight sync.WaitGroup
+	defer inFlight.Wait()
+
+	for ctx.Err() == nil {
+		d.startDueProbes(ctx, &inFlight)
+
+		//with no monitors waiting, the timer never fires
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		if wait, found := d.untilNextProbe(); found {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+		case <-d.reloads:
+			//a rejected reload is logged, and the old config keeps running
+			_, _ = d.reload()
+		case completion := <-d.results:
+			if notify := d.recordProbe(completion); notify != nil {
+				d.sendNotifications(completion.name, notify, &inFlight)
+			}
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+	return nil
+}
+
+// reload loads the config and swaps it in, or logs why it was rejected and keeps the old one
+func (d *Daemon) reload() (ReloadChanges, error) {
+	runtimeConfig, err := d.loader()
+	if err != nil {
+		log.Error().Err(err).Msg("The config was not reloaded, so the monitors keep running with the old config")
+		return ReloadChanges{}, err
+	}
+	changes := d.applyConfig(runtimeConfig)
+	log.Info().
+		Strs("added", changes.Added).
+		Strs("removed", changes.Removed).
+		Strs("rescheduled", changes.Rescheduled).
+		Msg("The config was reloaded")
+	return changes, nil
+}
+
+// applyConfig swaps in the runtime config and diffs its monitors with the old ones by name
+func (d *Daemon) applyConfig(runtimeConfig *config.RuntimeConfig) ReloadChanges {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now()
+	changes := ReloadChanges{Added: []string{}, Removed: []string{}, Rescheduled: []string{}}
+	monitors := map[string]*monitorState{}
+	for _, monitor := range runtimeConfig.Monitors() {
+		name := monitor.Name()
+		state := d.monitors[name]
+		if state == nil {
+			//new monitors are probed right away
+			state = &monitorState{nextRun: now}
+			changes.Added = append(changes.Added, name)
+		} else if state.monitor.TestPeriod() != monitor.TestPeriod() {
+			//the next probe is timed from the last one, so a shorter period may run it right away
+			if !state.lastRun.IsZero() {
+				state.nextRun = state.lastRun.Add(monitor.TestPeriod())
+			}
+			changes.Rescheduled = append(changes.Rescheduled, name)
+		}
+		state.monitor = monitor
+		monitors[name] = state
+	}
+	for name := range d.monitors {
+		if monitors[name] == nil {
+			changes.Removed = append(changes.Removed, name)
+		}
+	}
+	slices.Sort(changes.Removed)
+
+	d.config = runtimeConfig
+	d.prober = d.makeProber(runtimeConfig)
+	d.monitors = monitors
+	return changes
+}
+
+// startDueProbes starts the probes of the monitors that are due and aren't already running
+func (d *Daemon) startDueProbes(ctx context.Context, inFlight *sync.WaitGroup) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	now := d.now()
+	for name, state := range d.monitors {
+		if state.running || state.nextRun.After(now) {
+			continue
+		}
+		state.running = true
+		state.lastRun = now
+		monitor := state.monitor
+		prober := d.prober
+
+		inFlight.Add(1)
+		go func(name string, state *monitorState) {
+			defer inFlight.Done()
+			err := prober.Probe(ctx, monitor)
+			completion := probeCompletion{name: name, state: state, result: ProbeResult{Started: now, Err: err}}
+			select {
+			case d.results <- completion:
+			case <-ctx.Done():
+			}
+		}(name, state)
+	}
+}
+
+// untilNextProbe returns how long until the next monitor is due, or false if none are waiting
+func (d *Daemon) untilNextProbe() (time.Duration, bool) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var next time.Time
+	for _, state := range d.monitors {
+		if state.running {
+			continue
+		}
+		if next.IsZero() || state.nextRun.Before(next) {
+			next = state.nextRun
+		}
+	}
+	if next.IsZero() {
+		return 0, false
+	}
+	return max(next.Sub(d.now()), 0), true
+}
+
+// recordProbe adds the result to the history of the monitor and schedules its next probe.  It
+// returns a function that sends the notifications of the monitor if they are due, or nil.
+func (d *Daemon) recordProbe(completion probeCompletion) func() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	state := d.monitors[completion.name]
+	if state != completion.state {
+		log.Debug().Str("monitor", completion.name).Msg("Dropped the result of a probe of a monitor that was removed")
+		return nil
+	}
+	failedBefore := len(state.history) > 0 && state.history[len(state.history)-1].Err != nil
+	state.running = false
+	state.nextRun = completion.result.Started.Add(state.monitor.TestPeriod())
+	state.history = append(state.history, completion.result)
+	if len(state.history) > MAX_PROBE_HISTORY {
+		state.history = slices.Delete(state.history, 0, len(state.history)-MAX_PROBE_HISTORY)
+	}
+
+	if completion.result.Err == nil {
+		log.Info().Str("monitor", completion.name).Msg("The probe succeeded")
+		return nil
+	}
+	log.Warn().Err(completion.result.Err).Str("monitor", completion.name).Msg("The probe failed")
+	if failedBefore {
+		//the notifications were sent when the monitor started failing
+		return nil
+	}
+	prober := d.prober
+	monitor := state.monitor
+	return func() error {
+		return prober.Notify(monitor, completion.result.Err)
+	}
+}
+
+// sendNotifications sends the notifications of a monitor without blocking the probes, and logs
+// whether they were sent
+func (d *Daemon) sendNotifications(name string, notify func() error, inFlight *sync.WaitGroup) {
+	inFlight.Add(1)
+	go func() {
+		defer inFlight.Done()
+		err := notify()
+		if err != nil {
+			log.Error().Err(err).Str("monitor", name).Msg("The notifications about the failed probe could not be sent")
+			return
+		}
+		log.Info().Str("monitor", name).Msg("The notifications about the failed probe were sent")
+	}()
+}
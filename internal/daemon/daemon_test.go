@@ -0,0 +1,292 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"varanus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const DAEMON_TEST_ACCOUNTS = `mail:
+  accounts:
+    - name: work
+      smtp:
+        sender_address: work@example.com
+        server_address: smtp.example.com
+        use_tls: true
+        username: work
+        password: secret1
+    - name: home
+      imap:
+        recipient_address: home@example.org
+        server_address: imap.example.org
+        use_tls: true
+        username: home
+        password: secret2
+        mailbox_name: INBOX
+    - name: other
+      imap:
+        recipient_address: other@example.net
+        server_address: imap.example.net
+        use_tls: true
+        username: other
+        password: secret3
+        mailbox_name: INBOX
+monitoring:
+  email_monitors:
+`
+
+// compileMonitors compiles a config with the test accounts and a monitor for each "from->to period"
+func compileMonitors(t *testing.T, monitors ...string) *config.RuntimeConfig {
+	var sb strings.Builder
+	sb.WriteString(DAEMON_TEST_ACCOUNTS)
+	for _, monitor := range monitors {
+		var from, to, period string
+		_, err := fmt.Sscanf(strings.Replace(monitor, "->", " ", 1), "%s %s %s", &from, &to, &period)
+		require.Nil(t, err)
+		fmt.Fprintf(&sb, "    - from_account: %s\n      to_account: %s\n      test_period: %s\n", from, to, period)
+	}
+	parsedConfig, err := config.ReadConfig([]byte(sb.String()))
+	require.Nil(t, err)
+	runtimeConfig, err := parsedConfig.Compile(nil)
+	require.Nil(t, err)
+	return runtimeConfig
+}
+
+// fakeLoader returns the configs that are queued, or the error if it is set
+type fakeLoader struct {
+	mutex   sync.Mutex
+	configs []*config.RuntimeConfig
+	err     error
+}
+
+func (l *fakeLoader) load() (*config.RuntimeConfig, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.err != nil {
+		return nil, l.err
+	}
+	runtimeConfig := l.configs[0]
+	if len(l.configs) > 1 {
+		l.configs = l.configs[1:]
+	}
+	return runtimeConfig, nil
+}
+
+// fakeProber records the monitors it probes and notifies, and fails the probes of the monitors in
+// failures
+type fakeProber struct {
+	mutex         sync.Mutex
+	config        *config.RuntimeConfig
+	probes        []string
+	notifications []string
+	failures      map[string]bool
+}
+
+func (p *fakeProber) Probe(ctx context.Context, monitor *config.RuntimeMonitor) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.probes = append(p.probes, monitor.Name())
+	if p.failures[monitor.Name()] {
+		return fmt.Errorf("probe of %s failed", monitor.Name())
+	}
+	return nil
+}
+
+func (p *fakeProber) Notify(monitor *config.RuntimeMonitor, probeErr error) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.notifications = append(p.notifications, monitor.Name())
+	return nil
+}
+
+func (p *fakeProber) notificationCount() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.notifications)
+}
+
+func (p *fakeProber) count(name string) int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	count := 0
+	for _, probe := range p.probes {
+		if probe == name {
+			count++
+		}
+	}
+	return count
+}
+
+func TestReloadDiffsMonitorsByName(t *testing.T) {
+
+	reloadedConfig := compileMonitors(t, "work->home 1h", "work->other 30m", "work->home 5m")
+	loader := &fakeLoader{configs: []*config.RuntimeConfig{
+		compileMonitors(t, "work->home 1h", "work->other 1h", "work->home 2h"),
+		reloadedConfig,
+		compileMonitors(t, "work->other 30m"),
+	}}
+	probers := []*fakeProber{}
+	makeProber := func(runtimeConfig *config.RuntimeConfig) Prober {
+		prober := &fakeProber{config: runtimeConfig}
+		probers = append(probers, prober)
+		return prober
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	daemon, err := MakeDaemon(loader.load, makeProber)
+	require.Nil(t, err)
+	daemon.now = func() time.Time { return start }
+	assert.Equal(t, []string{"work->home", "work->other", "work->home#2"}, daemon.MonitorNames())
+
+	//run the first probes by hand
+	for name, state := range daemon.monitors {
+		state.lastRun = start
+		daemon.recordProbe(probeCompletion{name: name, state: state, result: ProbeResult{Started: start}})
+	}
+	history := daemon.History("work->home")
+	require.Len(t, history, 1)
+
+	changes, err := daemon.reload()
+	require.Nil(t, err)
+	assert.Equal(t, []string{}, changes.Added)
+	assert.Equal(t, []string{}, changes.Removed)
+	assert.Equal(t, []string{"work->other", "work->home#2"}, changes.Rescheduled)
+	assert.Same(t, probers[1], daemon.prober)
+
+	//the unchanged monitor keeps its history and schedule
+	assert.Equal(t, history, daemon.History("work->home"))
+	assert.Equal(t, start.Add(time.Hour), daemon.monitors["work->home"].nextRun)
+	//the rescheduled ones keep their history and are timed from their last probe
+	assert.Len(t, daemon.History("work->other"), 1)
+	assert.Equal(t, start.Add(30*time.Minute), daemon.monitors["work->other"].nextRun)
+	assert.Equal(t, start.Add(5*time.Minute), daemon.monitors["work->home#2"].nextRun)
+	//the monitors use the new config
+	assert.Equal(t, 5*time.Minute, daemon.monitors["work->home#2"].monitor.TestPeriod())
+	assert.Same(t, reloadedConfig, daemon.Config())
+
+	//a probe that was started before its monitor was removed is dropped
+	removedState := daemon.monitors["work->home"]
+	changes, err = daemon.reload()
+	require.Nil(t, err)
+	assert.Equal(t, []string{}, changes.Added)
+	assert.Equal(t, []string{"work->home", "work->home#2"}, changes.Removed)
+	assert.Equal(t, []string{}, changes.Rescheduled)
+	assert.Equal(t, []string{"work->other"}, daemon.MonitorNames())
+	daemon.recordProbe(probeCompletion{name: "work->home", state: removedState, result: ProbeResult{Started: start}})
+	assert.Nil(t, daemon.History("work->home"))
+
+	//a monitor that is added back starts over
+	loader.configs = []*config.RuntimeConfig{compileMonitors(t, "work->other 30m", "work->home 1h")}
+	changes, err = daemon.reload()
+	require.Nil(t, err)
+	assert.Equal(t, []string{"work->home"}, changes.Added)
+	assert.Len(t, daemon.History("work->home"), 0)
+	assert.Equal(t, start, daemon.monitors["work->home"].nextRun)
+}
+
+func TestRejectedReloadKeepsTheOldConfig(t *testing.T) {
+
+	loader := &fakeLoader{configs: []*config.RuntimeConfig{compileMonitors(t, "work->home 1h")}}
+	prober := &fakeProber{}
+	daemon, err := MakeDaemon(loader.load, func(*config.RuntimeConfig) Prober { return prober })
+	require.Nil(t, err)
+	oldConfig := daemon.Config()
+	oldState := daemon.monitors["work->home"]
+
+	loader.err = fmt.Errorf("the config has 2 validation errors")
+	changes, err := daemon.reload()
+	assert.ErrorContains(t, err, "the config has 2 validation errors")
+	assert.Equal(t, ReloadChanges{}, changes)
+	assert.Same(t, oldConfig, daemon.Config())
+	assert.Same(t, oldState, daemon.monitors["work->home"])
+
+	//a config that can't be loaded at startup is an error
+	_, err = MakeDaemon(loader.load, func(*config.RuntimeConfig) Prober { return prober })
+	assert.ErrorContains(t, err, "the config has 2 validation errors")
+}
+
+func TestRunProbesAndReloads(t *testing.T) {
+
+	loader := &fakeLoader{configs: []*config.RuntimeConfig{
+		compileMonitors(t, "work->home 10ms"),
+		compileMonitors(t, "work->home 10ms", "work->other 10ms"),
+	}}
+	probers := make(chan *fakeProber, 2)
+	makeProber := func(runtimeConfig *config.RuntimeConfig) Prober {
+		prober := &fakeProber{config: runtimeConfig, failures: map[string]bool{"work->other": true}}
+		probers <- prober
+		return prober
+	}
+	daemon, err := MakeDaemon(loader.load, makeProber)
+	require.Nil(t, err)
+	firstProber := <-probers
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- daemon.Run(ctx)
+	}()
+
+	assert.Eventually(t, func() bool { return firstProber.count("work->home") >= 2 },
+		time.Second, time.Millisecond)
+
+	daemon.Reload()
+	secondProber := <-probers
+	assert.Eventually(t, func() bool { return secondProber.count("work->other") >= 2 },
+		time.Second, time.Millisecond)
+
+	cancel()
+	require.Nil(t, <-done)
+
+	//the monitor that kept failing was notified about once
+	assert.Equal(t, 0, firstProber.notificationCount())
+	assert.Equal(t, []string{"work->other"}, secondProber.notifications)
+
+	//the monitor that was kept has the results from before and after the reload
+	history := daemon.History("work->home")
+	assert.GreaterOrEqual(t, len(history), 2)
+	assert.LessOrEqual(t, len(history), MAX_PROBE_HISTORY)
+	for _, result := range daemon.History("work->other") {
+		assert.ErrorContains(t, result.Err, "probe of work->other failed")
+	}
+}
+
+func TestRecordProbeNotifiesWhenMonitorStartsFailing(t *testing.T) {
+
+	loader := &fakeLoader{configs: []*config.RuntimeConfig{compileMonitors(t, "work->home 1h")}}
+	prober := &fakeProber{}
+	daemon, err := MakeDaemon(loader.load, func(*config.RuntimeConfig) Prober { return prober })
+	require.Nil(t, err)
+	state := daemon.monitors["work->home"]
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type testCase struct {
+		err    error
+		notify bool
+	}
+
+	testCases := []testCase{
+		//the first probe fails
+		{err: fmt.Errorf("first failure"), notify: true},
+		{err: fmt.Errorf("still failing"), notify: false},
+		{err: nil, notify: false},
+		//it fails again after a success
+		{err: fmt.Errorf("failing again"), notify: true},
+	}
+
+	for index, testCase := range testCases {
+		notify := daemon.recordProbe(probeCompletion{name: "work->home", state: state, result: ProbeResult{Started: start, Err: testCase.err}})
+		assert.Equal(t, testCase.notify, notify != nil, "for testcase %d", index)
+		if notify != nil {
+			require.Nil(t, notify())
+		}
+	}
+	assert.Equal(t, []string{"work->home", "work->home"}, prober.notifications)
+}
@@ -0,0 +1,131 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+	"varanus/internal/config"
+	"varanus/internal/mail"
+	"varanus/internal/secrets"
+)
+
+// how long a probe waits after sending the test email before it looks for it with IMAP
+const DEFAULT_PROBE_READ_DELAY = time.Minute
+
+// MakeEmailProberFactory returns a factory for probers that send a test email from the from_account
+// of a monitor and read it with its to_account, once for each of its probe address families.
+// Each reloaded config gets new mail workers, which also resets their auth lockouts, but the send
+// history is shared by every config so that a reload doesn't reset the send limits.
+func MakeEmailProberFactory(unsealer secrets.SecretUnsealer, readDelay time.Duration) ProberFactory {
+	sendHistory := mail.MakeSendHistory()
+	return func(runtimeConfig *config.RuntimeConfig) Prober {
+		//the notifications are sent with the address_family of each account
+		families := []config.AddressFamily{""}
+		for _, monitor := range runtimeConfig.Monitors() {
+			for _, family := range monitor.ProbeAddressFamilies() {
+				if !slices.Contains(families, family) {
+					families = append(families, family)
+				}
+			}
+		}
+		return &emailProber{
+			readDelay: readDelay,
+			workers:   mail.MakeMailWorkersForAddressFamilies(runtimeConfig, families, unsealer, sendHistory),
+		}
+	}
+}
+
+type emailProber struct {
+	readDelay time.Duration
+	//one worker per address family, shared by the monitors so they share the auth lockouts
+	workers map[config.AddressFamily]mail.MailWorker
+}
+
+// Probe runs a test for every probe address family of the monitor, even after one fails, so that
+// a failure over one family doesn't hide the result of the others.  The errors are joined.  The
+// send limits are reserved once for the whole probe, so the test emails of the families after the
+// first aren't held back by the first one.
+func (p *emailProber) Probe(ctx context.Context, monitor *config.RuntimeMonitor) error {
+	err := p.workers[""].ReserveSend(monitor.FromAccount().Name())
+	if err != nil {
+		return fmt.Errorf("could not send the test email: %w", err)
+	}
+
+	errs := []error{}
+	for _, family := range monitor.ProbeAddressFamilies() {
+		err := p.probe(ctx, monitor, family)
+		if err == nil {
+			continue
+		}
+		//the workers for a family name it in their errors
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			//the daemon is stopping, so the other families aren't probed
+			break
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *emailProber) probe(ctx context.Context, monitor *config.RuntimeMonitor, family config.AddressFamily) error {
+	worker := p.workers[family]
+	subject := fmt.Sprintf("varanus probe %s %s", monitor.Name(), time.Now().UTC().Format(time.RFC3339Nano))
+	if family != "" {
+		subject = fmt.Sprintf("%s %s", subject, family)
+	}
+
+	err := worker.SendMessage(monitor.FromAccount().Name(), mail.MailMessage{
+		Recipient: monitor.ToAccount().IMAP().RecipientAddress,
+		Subject:   subject,
+		Body:      fmt.Sprintf("This is a test email sent by the varanus monitor %s.", monitor.Name()),
+		//reserved by Probe
+		SkipSendLimits: true,
+	})
+	if err != nil {
+		return fmt.Errorf("could not send the test email: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(p.readDelay):
+	}
+
+	_, err = worker.ReadMessage(monitor.ToAccount().Name(), subject)
+	if err != nil {
+		return fmt.Errorf("could not read the test email: %w", err)
+	}
+	return nil
+}
+
+// Notify sends an email about the failed probe to each notification account of the monitor, from
+// the account itself, since the notification account is the one that is expected to still work.
+// It is sent to the recipient_address of the account, or its sender_address if it has no imap
+// section.  Every account is tried, and the errors are joined.
+//
+// The notifications skip the send limits, since they are only sent once, when the monitor starts
+// failing, and the test email that was just sent would otherwise hold back a notification account
+// that shares a send limit with the from_account.
+func (p *emailProber) Notify(monitor *config.RuntimeMonitor, probeErr error) error {
+	worker := p.workers[""]
+	errs := []error{}
+	//the notification accounts have an smtp section, since Compile checks it
+	for _, account := range monitor.Notifications() {
+		recipient := account.SMTP().SenderAddress
+		if account.IMAP() != nil {
+			recipient = account.IMAP().RecipientAddress
+		}
+		err := worker.SendMessage(account.Name(), mail.MailMessage{
+			Recipient:      recipient,
+			Subject:        fmt.Sprintf("varanus monitor %s failed", monitor.Name()),
+			Body:           fmt.Sprintf("The probe of the varanus monitor %s failed: %s", monitor.Name(), probeErr),
+			SkipSendLimits: true,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("could not send the notification to '%s': %w", account.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,141 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+	"varanus/internal/config"
+	"varanus/internal/mail"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// EMAIL_PROBER_TEST_CONFIG has a monitor whose smtp server can't be reached over either family
+const EMAIL_PROBER_TEST_CONFIG = `mail:
+  accounts:
+    - name: sender
+      smtp:
+        sender_address: sender@example.com
+        server_address: 127.0.0.1
+        port: 1
+        username: sender
+        password: secret1
+    - name: receiver
+      imap:
+        recipient_address: receiver@example.com
+        server_address: 127.0.0.1
+        port: 1
+        username: receiver
+        password: secret2
+        mailbox_name: INBOX
+monitoring:
+  email_monitors:
+    - from_account: sender
+      to_account: receiver
+      test_period: 1h
+      address_families: [ipv4, ipv6]
+`
+
+func TestEmailProberProbesEveryFamily(t *testing.T) {
+	parsedConfig, err := config.ReadConfig([]byte(EMAIL_PROBER_TEST_CONFIG))
+	require.Nil(t, err)
+	runtimeConfig, err := parsedConfig.Compile(nil)
+	require.Nil(t, err)
+	monitor := runtimeConfig.Monitors()[0]
+
+	prober := MakeEmailProberFactory(nil, time.Millisecond)(runtimeConfig)
+	err = prober.Probe(context.Background(), monitor)
+	require.NotNil(t, err)
+
+	//the ipv6 probe runs even though the ipv4 probe failed, and both failures are reported
+	families := []config.AddressFamily{}
+	for _, familyErr := range err.(interface{ Unwrap() []error }).Unwrap() {
+		var addressFamilyError mail.AddressFamilyError
+		require.ErrorAs(t, familyErr, &addressFamilyError)
+		families = append(families, addressFamilyError.Family)
+		assert.ErrorContains(t, familyErr, "could not send the test email")
+	}
+	assert.Equal(t, []config.AddressFamily{config.ADDRESS_FAMILY_IPV4, config.ADDRESS_FAMILY_IPV6}, families)
+}
+
+func TestEmailProberReservesSendLimitsOncePerProbe(t *testing.T) {
+	parsedConfig, err := config.ReadConfig([]byte(strings.Replace(EMAIL_PROBER_TEST_CONFIG, "monitoring:",
+		"  send_limits:\n    - min_period: 1h\n      account_names: [sender]\nmonitoring:", 1)))
+	require.Nil(t, err)
+	runtimeConfig, err := parsedConfig.Compile(nil)
+	require.Nil(t, err)
+	monitor := runtimeConfig.Monitors()[0]
+
+	//both families try to send, rather than the second waiting for the first
+	prober := MakeEmailProberFactory(nil, time.Millisecond)(runtimeConfig)
+	err = prober.Probe(context.Background(), monitor)
+	require.NotNil(t, err)
+	familyErrs := err.(interface{ Unwrap() []error }).Unwrap()
+	require.Len(t, familyErrs, 2)
+	for _, familyErr := range familyErrs {
+		var waitError mail.WaitError
+		assert.False(t, errors.As(familyErr, &waitError), "for %s", familyErr)
+		assert.ErrorContains(t, familyErr, "failed to dial SMTP server")
+	}
+}
+
+func TestEmailProberNotify(t *testing.T) {
+	parsedConfig, err := config.ReadConfig([]byte(EMAIL_PROBER_TEST_CONFIG + `      notifications:
+        - mail: sender
+`))
+	require.Nil(t, err)
+	runtimeConfig, err := parsedConfig.Compile(nil)
+	require.Nil(t, err)
+	monitor := runtimeConfig.Monitors()[0]
+
+	//the notification is sent from the account itself
+	prober := MakeEmailProberFactory(nil, time.Millisecond)(runtimeConfig)
+	err = prober.Notify(monitor, errors.New("the test email did not arrive"))
+	assert.ErrorContains(t, err, "could not send the notification to 'sender'")
+}
+
+func TestEmailProberNotifySkipsSendLimits(t *testing.T) {
+	//the notification account shares a send limit with the from_account of the monitor
+	parsedConfig, err := config.ReadConfig([]byte(strings.Replace(EMAIL_PROBER_TEST_CONFIG, "monitoring:",
+		"  send_limits:\n    - min_period: 1h\n      account_names: [sender]\nmonitoring:", 1) + `      notifications:
+        - mail: sender
+`))
+	require.Nil(t, err)
+	runtimeConfig, err := parsedConfig.Compile(nil)
+	require.Nil(t, err)
+	monitor := runtimeConfig.Monitors()[0]
+
+	prober := MakeEmailProberFactory(nil, time.Millisecond)(runtimeConfig)
+	probeErr := prober.Probe(context.Background(), monitor)
+	require.NotNil(t, probeErr)
+
+	//the notification is tried right after the probe instead of waiting for the send limit
+	err = prober.Notify(monitor, probeErr)
+	require.NotNil(t, err)
+	var waitError mail.WaitError
+	assert.False(t, errors.As(err, &waitError), "for %s", err)
+	assert.ErrorContains(t, err, "failed to dial SMTP server")
+}
+
+func TestEmailProberKeepsSendLimitsAcrossReloads(t *testing.T) {
+	parsedConfig, err := config.ReadConfig([]byte(strings.Replace(EMAIL_PROBER_TEST_CONFIG, "monitoring:",
+		"  send_limits:\n    - min_period: 1h\n      account_names: [sender]\nmonitoring:", 1)))
+	require.Nil(t, err)
+	runtimeConfig, err := parsedConfig.Compile(nil)
+	require.Nil(t, err)
+	monitor := runtimeConfig.Monitors()[0]
+
+	//the failed send still counts toward the send limit
+	makeProber := MakeEmailProberFactory(nil, time.Millisecond)
+	err = makeProber(runtimeConfig).Probe(context.Background(), monitor)
+	require.NotNil(t, err)
+
+	//the prober of the reloaded config keeps to it, so it doesn't try to send
+	err = makeProber(runtimeConfig).Probe(context.Background(), monitor)
+	var waitError mail.WaitError
+	require.ErrorAs(t, err, &waitError)
+	assert.Greater(t, waitError.GetWaitTime(), 59*time.Minute)
+}
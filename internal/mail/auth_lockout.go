@@ -0,0 +1,98 @@
+package mail
+
+import (
+	"errors"
+	"sync"
+	"time"
+	"varanus/internal/config"
+)
+
+const (
+	AUTH_PROTOCOL_SMTP = "smtp"
+	AUTH_PROTOCOL_IMAP = "imap"
+)
+
+// authFailureKey identifies a set of credentials.  SMTP and IMAP are tracked separately because an
+// account may use different credentials for each.
+type authFailureKey struct {
+	accountName string
+	protocol    string
+}
+
+type authFailureState struct {
+	consecutiveFailures uint
+	suspendedUntil      time.Time
+}
+
+// authFailureTracker counts consecutive rejected logins so that the worker stops trying credentials
+// that no longer work before the provider locks the mailbox.  The zero value is ready to use.
+type authFailureTracker struct {
+	mutex  sync.Mutex
+	now    func() time.Time //injectable clock for tests; time.Now if nil
+	states map[authFailureKey]*authFailureState
+}
+
+func (t *authFailureTracker) getNow() time.Time {
+	if t.now == nil {
+		return time.Now()
+	}
+	return t.now()
+}
+
+// checkSuspended returns a WaitError if logins with the credentials are suspended, or nil if a
+// login may be attempted.
+func (t *authFailureTracker) checkSuspended(key authFailureKey) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	state := t.states[key]
+	if state == nil {
+		return nil
+	}
+	remaining := state.suspendedUntil.Sub(t.getNow())
+	if remaining > 0 {
+		return WaitError{remaining}
+	}
+	return nil
+}
+
+// recordResult updates the failure count after a login attempt.  Only permanent authentication
+// failures are counted; any other result, including success, resets the count.  If the failure
+// reaches the threshold, logins are suspended for the cool-down and a CredentialsRejectedError
+// wrapping loginErr is returned.  Otherwise loginErr is returned unchanged.
+func (t *authFailureTracker) recordResult(key authFailureKey, lockout config.AuthLockoutConfig, loginErr error) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var mailError MailError
+	isRejected := errors.As(loginErr, &mailError) && mailError.Stage == MAIL_STAGE_AUTH && !mailError.Temporary
+	if !isRejected {
+		delete(t.states, key)
+		return loginErr
+	}
+
+	if t.states == nil {
+		t.states = map[authFailureKey]*authFailureState{}
+	}
+	state := t.states[key]
+	if state == nil {
+		state = &authFailureState{}
+		t.states[key] = state
+	}
+	state.consecutiveFailures += 1
+
+	if state.consecutiveFailures < lockout.FailureThreshold {
+		return loginErr
+	}
+
+	//suspend logins.  The count isn't reset, so the first attempt after the cool-down that fails
+	//suspends logins again.
+	state.suspendedUntil = t.getNow().Add(lockout.CoolDown)
+	return CredentialsRejectedError{
+		AccountName: key.accountName,
+		Protocol:    key.protocol,
+		Failures:    state.consecutiveFailures,
+		CoolDown:    lockout.CoolDown,
+		Err:         loginErr,
+	}
+}
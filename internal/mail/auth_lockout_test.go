@@ -0,0 +1,116 @@
+package mail
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"varanus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthFailureTracker(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := authFailureTracker{now: func() time.Time { return now }}
+	lockout := config.AuthLockoutConfig{FailureThreshold: 3, CoolDown: time.Hour}
+	key := authFailureKey{"account1", AUTH_PROTOCOL_SMTP}
+	otherKey := authFailureKey{"account1", AUTH_PROTOCOL_IMAP}
+
+	rejected := MailError{Stage: MAIL_STAGE_AUTH, Temporary: false, Err: errors.New("535 bad credentials")}
+	unavailable := MailError{Stage: MAIL_STAGE_AUTH, Temporary: true, Err: errors.New("454 try later")}
+
+	//failures below the threshold are returned unchanged
+	assert.Nil(t, tracker.checkSuspended(key))
+	assert.Equal(t, rejected, tracker.recordResult(key, lockout, rejected))
+	assert.Equal(t, rejected, tracker.recordResult(key, lockout, rejected))
+
+	//a temporary failure resets the count
+	assert.Equal(t, unavailable, tracker.recordResult(key, lockout, unavailable))
+	assert.Equal(t, rejected, tracker.recordResult(key, lockout, rejected))
+	assert.Equal(t, rejected, tracker.recordResult(key, lockout, rejected))
+
+	//reaching the threshold raises one CredentialsRejectedError
+	err := tracker.recordResult(key, lockout, rejected)
+	var rejectedError CredentialsRejectedError
+	require.ErrorAs(t, err, &rejectedError)
+	assert.Equal(t, "account1", rejectedError.AccountName)
+	assert.Equal(t, AUTH_PROTOCOL_SMTP, rejectedError.Protocol)
+	assert.Equal(t, uint(3), rejectedError.Failures)
+	assert.ErrorIs(t, err, rejected)
+	assert.Equal(t,
+		"credentials rejected: smtp login for account 'account1' failed 3 times in a row, so logins are suspended for 1h0m0s: 535 bad credentials",
+		err.Error())
+
+	//logins are suspended during the cool-down, but only for these credentials
+	now = now.Add(20 * time.Minute)
+	assert.Equal(t, WaitError{40 * time.Minute}, tracker.checkSuspended(key))
+	assert.Nil(t, tracker.checkSuspended(otherKey))
+
+	//after the cool-down one attempt is allowed, and another rejection suspends logins again
+	now = now.Add(40 * time.Minute)
+	assert.Nil(t, tracker.checkSuspended(key))
+	require.ErrorAs(t, tracker.recordResult(key, lockout, rejected), &rejectedError)
+	assert.Equal(t, uint(4), rejectedError.Failures)
+	assert.Equal(t, WaitError{time.Hour}, tracker.checkSuspended(key))
+
+	//a successful login after the cool-down clears the state
+	now = now.Add(time.Hour)
+	assert.Nil(t, tracker.recordResult(key, lockout, nil))
+	assert.Equal(t, rejected, tracker.recordResult(key, lockout, rejected))
+	assert.Nil(t, tracker.checkSuspended(key))
+}
+
+func TestSendMessageAuthLockout(t *testing.T) {
+	mailConfig := makeFakeSmtpMailConfig(startFakeSmtpServer(t, map[string]string{"AUTH": "535 5.7.8 authentication failed"}))
+	mailConfig.AuthLockout = &config.AuthLockoutConfig{FailureThreshold: 2, CoolDown: time.Hour}
+	worker := MakeMailWorker(compileMailConfig(t, mailConfig), nil)
+
+	message := MailMessage{
+		Recipient: "recipient@example.com",
+		Subject:   "test subject",
+		Body:      "This is the message body.",
+	}
+
+	var mailError MailError
+	require.ErrorAs(t, worker.SendMessage("account1", message), &mailError)
+	assert.Equal(t, MAIL_STAGE_AUTH, mailError.Stage)
+
+	var rejectedError CredentialsRejectedError
+	require.ErrorAs(t, worker.SendMessage("account1", message), &rejectedError)
+	assert.Equal(t, uint(2), rejectedError.Failures)
+
+	//no more logins are attempted
+	var waitError WaitError
+	require.ErrorAs(t, worker.SendMessage("account1", message), &waitError)
+	assert.Greater(t, waitError.GetWaitTime(), 59*time.Minute)
+
+	//a new worker, as made after a config reload, tries again
+	require.ErrorAs(t, MakeMailWorker(compileMailConfig(t, mailConfig), nil).SendMessage("account1", message), &mailError)
+	assert.Equal(t, MAIL_STAGE_AUTH, mailError.Stage)
+}
+
+func TestAuthLockoutSharedByAddressFamilies(t *testing.T) {
+	mailConfig := makeFakeSmtpMailConfig(startFakeSmtpServer(t, map[string]string{"AUTH": "535 5.7.8 authentication failed"}))
+	mailConfig.AuthLockout = &config.AuthLockoutConfig{FailureThreshold: 2, CoolDown: time.Hour}
+	families := []config.AddressFamily{"", config.ADDRESS_FAMILY_IPV4}
+	workers := MakeMailWorkersForAddressFamilies(compileMailConfig(t, mailConfig), families, nil, MakeSendHistory())
+
+	message := MailMessage{
+		Recipient: "recipient@example.com",
+		Subject:   "test subject",
+		Body:      "This is the message body.",
+	}
+
+	var mailError MailError
+	require.ErrorAs(t, workers[""].SendMessage("account1", message), &mailError)
+	assert.Equal(t, MAIL_STAGE_AUTH, mailError.Stage)
+
+	//the failure with the first family counts towards the lockout of the other
+	var rejectedError CredentialsRejectedError
+	require.ErrorAs(t, workers[config.ADDRESS_FAMILY_IPV4].SendMessage("account1", message), &rejectedError)
+	assert.Equal(t, uint(2), rejectedError.Failures)
+
+	var waitError WaitError
+	require.ErrorAs(t, workers[""].SendMessage("account1", message), &waitError)
+}
@@ -0,0 +1,386 @@
+package mail
+
+import (
+	"bufio"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+	"varanus/internal/config"
+	"varanus/internal/secrets"
+	"varanus/internal/util"
+)
+
+// DIAL_TIMEOUT bounds how long a connection to a mail server (or its proxy) may take to open
+const DIAL_TIMEOUT = 30 * time.Second
+
+// dialer opens network connections.  It matches the Dial method of net.Dialer so that direct and
+// proxied connections can be used interchangeably.
+type dialer interface {
+	Dial(network string, address string) (net.Conn, error)
+}
+
+// dialOptions controls the first hop of a connection, which is the mail server itself or the proxy.
+// Through a proxy, the address family also selects the address of the mail server when it is
+// resolved locally for a socks5 proxy.  An http or socks5h proxy resolves the mail server itself,
+// so it picks the family of the hop from the proxy to the server.
+type dialOptions struct {
+	addressFamily config.AddressFamily
+	localAddress  string //optional source IP
+}
+
+// familyDialer restricts every connection it opens to an address family, regardless of the network
+// requested by the caller.
+type familyDialer struct {
+	net.Dialer
+	network string
+}
+
+func (d *familyDialer) Dial(network string, address string) (net.Conn, error) {
+	return d.Dialer.Dial(d.network, address)
+}
+
+// makeDirectDialer returns a dialer that connects using the address family and local address in
+// options.
+func makeDirectDialer(options dialOptions) (*familyDialer, error) {
+	direct := &familyDialer{
+		Dialer:  net.Dialer{Timeout: DIAL_TIMEOUT},
+		network: options.addressFamily.Network(),
+	}
+	if options.localAddress != "" {
+		localIP := net.ParseIP(util.UnbracketHost(options.localAddress))
+		if localIP == nil {
+			return nil, fmt.Errorf("local address '%s' is not a valid IP address", options.localAddress)
+		}
+		direct.LocalAddr = &net.TCPAddr{IP: localIP}
+	}
+	return direct, nil
+}
+
+// makeDialer returns a dialer that connects directly if proxyConfig is nil, or through the proxy
+// otherwise.  The proxy password is unsealed here so that it is read as close to use as possible.
+func makeDialer(proxyConfig *config.ProxyConfig, options dialOptions, unsealer secrets.SecretUnsealer) (dialer, error) {
+	direct, err := makeDirectDialer(options)
+	if err != nil {
+		return nil, err
+	}
+
+	if proxyConfig == nil {
+		return direct, nil
+	}
+
+	proxyUrl, err := proxyConfig.ParseURL()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse proxy url '%s': %w", proxyConfig.URL, err)
+	}
+
+	password := ""
+	if proxyConfig.Password != nil {
+		password, err = proxyConfig.Password.ReadSecret(unsealer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unseal proxy password secret: %w", err)
+		}
+	}
+
+	switch proxyUrl.Scheme {
+	case config.PROXY_SCHEME_SOCKS5, config.PROXY_SCHEME_SOCKS5H:
+		return &socks5Dialer{
+			proxyAddress:  proxyUrl.Host,
+			username:      proxyConfig.Username,
+			password:      password,
+			remoteResolve: proxyUrl.Scheme == config.PROXY_SCHEME_SOCKS5H,
+			addressFamily: options.addressFamily,
+			forward:       direct,
+		}, nil
+	case config.PROXY_SCHEME_HTTP:
+		return &httpConnectDialer{
+			proxyAddress: proxyUrl.Host,
+			username:     proxyConfig.Username,
+			password:     password,
+			forward:      direct,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme '%s'", proxyUrl.Scheme)
+	}
+}
+
+// dialMailServer opens a connection to the mail server at address using d.  If useTLS is set, the
+// connection is wrapped in a TLS client that verifies the certificate against the server host.
+//
+// The TLS handshake is done here instead of on first use so that certificate and handshake failures
+// are reported as MAIL_STAGE_TLS errors instead of failures of whichever command came first.
+func dialMailServer(d dialer, address string, useTLS bool) (net.Conn, error) {
+	conn, err := d.Dial("tcp", address)
+	if err != nil {
+		return nil, newMailError(MAIL_STAGE_DIAL, err)
+	}
+	if !useTLS {
+		return conn, nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		conn.Close()
+		return nil, newMailError(MAIL_STAGE_DIAL, err)
+	}
+	tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+	tlsConn.SetDeadline(time.Now().Add(DIAL_TIMEOUT))
+	if err := tlsConn.Handshake(); err != nil {
+		conn.Close()
+		return nil, newMailError(MAIL_STAGE_TLS, fmt.Errorf("TLS handshake with '%s' failed: %w", address, err))
+	}
+	tlsConn.SetDeadline(time.Time{})
+	return tlsConn, nil
+}
+
+//--------------------------------------------------------------------------------------------------
+// SOCKS5 (RFC 1928) with username/password authentication (RFC 1929)
+//--------------------------------------------------------------------------------------------------
+
+const (
+	socks5Version           = 0x05
+	socks5AuthNone          = 0x00
+	socks5AuthUserPass      = 0x02
+	socks5AuthNoAcceptable  = 0xff
+	socks5UserPassVersion   = 0x01
+	socks5CommandConnect    = 0x01
+	socks5AddressTypeIPv4   = 0x01
+	socks5AddressTypeDomain = 0x03
+	socks5AddressTypeIPv6   = 0x04
+	socks5ReplySucceeded    = 0x00
+	socks5MaxFieldLength    = 255
+)
+
+type socks5Dialer struct {
+	proxyAddress  string
+	username      string
+	password      string
+	remoteResolve bool                 // if set, send the hostname to the proxy instead of resolving it locally
+	addressFamily config.AddressFamily // the family of the target address when it is resolved locally
+	forward       dialer
+}
+
+func (d *socks5Dialer) Dial(network string, address string) (net.Conn, error) {
+	conn, err := d.forward.Dial(network, d.proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial SOCKS5 proxy '%s': %w", d.proxyAddress, err)
+	}
+	conn.SetDeadline(time.Now().Add(DIAL_TIMEOUT))
+
+	err = d.handshake(conn, address)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("SOCKS5 proxy '%s' could not connect to '%s': %w", d.proxyAddress, address, err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+func (d *socks5Dialer) handshake(conn net.Conn, address string) error {
+	//negotiate the authentication method
+	method := byte(socks5AuthNone)
+	if d.username != "" {
+		method = socks5AuthUserPass
+	}
+	if _, err := conn.Write([]byte{socks5Version, 1, method}); err != nil {
+		return err
+	}
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return fmt.Errorf("failed to read the method selection: %w", err)
+	}
+	if reply[0] != socks5Version {
+		return fmt.Errorf("unexpected protocol version %d", reply[0])
+	}
+	if reply[1] == socks5AuthNoAcceptable || reply[1] != method {
+		return fmt.Errorf("the proxy did not accept the authentication method")
+	}
+
+	if method == socks5AuthUserPass {
+		if len(d.username) > socks5MaxFieldLength || len(d.password) > socks5MaxFieldLength {
+			return fmt.Errorf("the proxy username and password must be at most %d bytes", socks5MaxFieldLength)
+		}
+		request := []byte{socks5UserPassVersion, byte(len(d.username))}
+		request = append(request, d.username...)
+		request = append(request, byte(len(d.password)))
+		request = append(request, d.password...)
+		if _, err := conn.Write(request); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return fmt.Errorf("failed to read the authentication status: %w", err)
+		}
+		if reply[1] != 0 {
+			return fmt.Errorf("the proxy rejected the username and password")
+		}
+	}
+
+	//request the connection to the target
+	request, err := d.makeConnectRequest(address)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return fmt.Errorf("failed to read the connect reply: %w", err)
+	}
+	if header[1] != socks5ReplySucceeded {
+		return fmt.Errorf("the proxy refused the connection with reply code %d", header[1])
+	}
+
+	//discard the bound address, which we don't need
+	var boundLength int
+	switch header[3] {
+	case socks5AddressTypeIPv4:
+		boundLength = net.IPv4len
+	case socks5AddressTypeIPv6:
+		boundLength = net.IPv6len
+	case socks5AddressTypeDomain:
+		lengthByte := make([]byte, 1)
+		if _, err := io.ReadFull(conn, lengthByte); err != nil {
+			return fmt.Errorf("failed to read the bound address: %w", err)
+		}
+		boundLength = int(lengthByte[0])
+	default:
+		return fmt.Errorf("unexpected bound address type %d", header[3])
+	}
+	if _, err := io.ReadFull(conn, make([]byte, boundLength+2)); err != nil {
+		return fmt.Errorf("failed to read the bound address: %w", err)
+	}
+
+	return nil
+}
+
+func (d *socks5Dialer) makeConnectRequest(address string) ([]byte, error) {
+	host, portString, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portString, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in '%s': %w", address, err)
+	}
+
+	request := []byte{socks5Version, socks5CommandConnect, 0}
+
+	ip := net.ParseIP(host)
+	if ip == nil && !d.remoteResolve {
+		ip, err = lookupIPForFamily(host, d.addressFamily)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ip == nil {
+		if len(host) > socks5MaxFieldLength {
+			return nil, fmt.Errorf("the hostname '%s' is too long", host)
+		}
+		request = append(request, socks5AddressTypeDomain, byte(len(host)))
+		request = append(request, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		request = append(request, socks5AddressTypeIPv4)
+		request = append(request, ip4...)
+	} else {
+		request = append(request, socks5AddressTypeIPv6)
+		request = append(request, ip.To16()...)
+	}
+
+	return binary.BigEndian.AppendUint16(request, uint16(port)), nil
+}
+
+// lookupIPForFamily resolves host to the first address of the given family
+func lookupIPForFamily(host string, family config.AddressFamily) (net.IP, error) {
+	addresses, err := net.LookupIP(host)
+	if err != nil {
+		return nil, err
+	}
+	for _, address := range addresses {
+		isIPv4 := address.To4() != nil
+		switch family {
+		case config.ADDRESS_FAMILY_IPV4:
+			if isIPv4 {
+				return address, nil
+			}
+		case config.ADDRESS_FAMILY_IPV6:
+			if !isIPv4 {
+				return address, nil
+			}
+		default:
+			return address, nil
+		}
+	}
+	return nil, fmt.Errorf("the hostname '%s' has no %s address", host, family)
+}
+
+//--------------------------------------------------------------------------------------------------
+// HTTP CONNECT
+//--------------------------------------------------------------------------------------------------
+
+type httpConnectDialer struct {
+	proxyAddress string
+	username     string
+	password     string
+	forward      dialer
+}
+
+func (d *httpConnectDialer) Dial(network string, address string) (net.Conn, error) {
+	conn, err := d.forward.Dial(network, d.proxyAddress)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial HTTP proxy '%s': %w", d.proxyAddress, err)
+	}
+	conn.SetDeadline(time.Now().Add(DIAL_TIMEOUT))
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: address},
+		Host:   address,
+		Header: http.Header{},
+	}
+	if d.username != "" {
+		credentials := base64.StdEncoding.EncodeToString([]byte(d.username + ":" + d.password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to HTTP proxy '%s': %w", d.proxyAddress, err)
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, request)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from HTTP proxy '%s': %w", d.proxyAddress, err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("HTTP proxy '%s' could not connect to '%s': %s", d.proxyAddress, address, response.Status)
+	}
+
+	conn.SetDeadline(time.Time{})
+
+	//the mail server may send its greeting before we write anything, so hand over any bytes that
+	//were buffered while reading the response
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+// bufferedConn reads through the buffered reader that consumed the proxy response
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
@@ -1,8 +1,16 @@
 package mail
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
 	"fmt"
+	"io"
+	"net"
 	"time"
+	"varanus/internal/config"
+
+	"github.com/emersion/go-smtp"
 )
 
 type WaitError struct {
@@ -16,3 +24,138 @@ func (we WaitError) Error() string {
 func (we WaitError) GetWaitTime() time.Duration {
 	return we.waitTime
 }
+
+// AddressFamilyError is returned by a mail worker that is restricted to an address family, so that
+// a failure can be attributed to the family it occurred on.
+type AddressFamilyError struct {
+	Family config.AddressFamily
+	Err    error
+}
+
+func (afe AddressFamilyError) Error() string {
+	return fmt.Sprintf("%s: %s", afe.Family, afe.Err)
+}
+
+func (afe AddressFamilyError) Unwrap() error {
+	return afe.Err
+}
+
+// MailErrorStage names the step of sending or reading a message that failed
+type MailErrorStage string
+
+const (
+	MAIL_STAGE_DIAL      MailErrorStage = "dial"
+	MAIL_STAGE_TLS       MailErrorStage = "tls"
+	MAIL_STAGE_AUTH      MailErrorStage = "auth"
+	MAIL_STAGE_MAIL_FROM MailErrorStage = "mail-from"
+	MAIL_STAGE_RCPT      MailErrorStage = "rcpt"
+	MAIL_STAGE_DATA      MailErrorStage = "data"
+	MAIL_STAGE_SELECT    MailErrorStage = "select"
+	MAIL_STAGE_SEARCH    MailErrorStage = "search"
+	MAIL_STAGE_FETCH     MailErrorStage = "fetch"
+)
+
+// MailError is returned (possibly wrapped, so use errors.As) when talking to a mail server fails.
+// Temporary is set for failures that may succeed if retried, such as an SMTP 4xx reply or a network
+// timeout.  Code and EnhancedCode hold the SMTP reply code and the RFC 3463 enhanced status code
+// (such as "5.7.8") when the server sent them, and are empty otherwise.
+type MailError struct {
+	Stage        MailErrorStage
+	Temporary    bool
+	Code         int
+	EnhancedCode string
+	Err          error
+}
+
+func (me MailError) Error() string {
+	return me.Err.Error()
+}
+
+func (me MailError) Unwrap() error {
+	return me.Err
+}
+
+// ErrMessageNotFound matches, with errors.Is, the error returned by ReadMessage when no message has
+// the expected subject.  The message may just not have arrived yet, so the MailError is temporary.
+var ErrMessageNotFound = errors.New("message not found")
+
+type messageNotFoundError struct {
+	subject string
+}
+
+func (e messageNotFoundError) Error() string {
+	return fmt.Sprintf("no message matching the subject '%s' was found", e.subject)
+}
+
+func (e messageNotFoundError) Is(target error) bool {
+	return target == ErrMessageNotFound
+}
+
+// newMailError classifies err, which happened at stage, into a MailError.  If err already contains a
+// MailError, its stage is kept since it is more specific (e.g. a tls failure found while dialing).
+func newMailError(stage MailErrorStage, err error) MailError {
+	mailError := MailError{Stage: stage, Err: err}
+
+	var innerMailError MailError
+	if errors.As(err, &innerMailError) {
+		mailError.Stage = innerMailError.Stage
+		mailError.Temporary = innerMailError.Temporary
+		mailError.Code = innerMailError.Code
+		mailError.EnhancedCode = innerMailError.EnhancedCode
+		return mailError
+	}
+
+	var smtpError *smtp.SMTPError
+	var dnsError *net.DNSError
+	var netError net.Error
+	var verificationError *tls.CertificateVerificationError
+	var unknownAuthorityError x509.UnknownAuthorityError
+	var hostnameError x509.HostnameError
+	var certificateInvalidError x509.CertificateInvalidError
+
+	switch {
+	case errors.As(err, &smtpError):
+		mailError.Code = smtpError.Code
+		mailError.Temporary = smtpError.Temporary()
+		if smtpError.EnhancedCode != smtp.NoEnhancedCode && smtpError.EnhancedCode != smtp.EnhancedCodeNotSet {
+			mailError.EnhancedCode = fmt.Sprintf("%d.%d.%d",
+				smtpError.EnhancedCode[0], smtpError.EnhancedCode[1], smtpError.EnhancedCode[2])
+		}
+	case errors.As(err, &dnsError):
+		//a name that doesn't exist won't start existing on a retry, but a failed lookup might
+		mailError.Temporary = dnsError.IsTemporary || dnsError.IsTimeout
+	case errors.As(err, &verificationError), errors.As(err, &unknownAuthorityError),
+		errors.As(err, &hostnameError), errors.As(err, &certificateInvalidError):
+		mailError.Temporary = false
+	case errors.As(err, &netError), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		mailError.Temporary = true
+	case errors.Is(err, ErrMessageNotFound):
+		mailError.Temporary = true
+	default:
+		//without more information, assume the server may be reachable later, but that a server
+		//which answered and refused will refuse again
+		mailError.Temporary = stage == MAIL_STAGE_DIAL || stage == MAIL_STAGE_TLS
+	}
+
+	return mailError
+}
+
+// CredentialsRejectedError is returned once when an account's credentials have been rejected
+// enough times in a row that logins are suspended.  Until the cool-down has passed, attempts to use
+// the account return a WaitError instead of trying to log in again.
+type CredentialsRejectedError struct {
+	AccountName string
+	Protocol    string
+	Failures    uint
+	CoolDown    time.Duration
+	Err         error
+}
+
+func (cre CredentialsRejectedError) Error() string {
+	return fmt.Sprintf("credentials rejected: %s login for account '%s' failed %d times in a row, so logins are suspended for %s: %s",
+		cre.Protocol, cre.AccountName, cre.Failures, cre.CoolDown, cre.Err)
+}
+
+func (cre CredentialsRejectedError) Unwrap() error {
+	return cre.Err
+}
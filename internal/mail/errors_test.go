@@ -1,10 +1,22 @@
 package mail
 
 import (
+	"bufio"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
+	"varanus/internal/config"
+	"varanus/internal/secrets"
 
+	"github.com/emersion/go-smtp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestWaitError(t *testing.T) {
@@ -12,3 +24,258 @@ func TestWaitError(t *testing.T) {
 	assert.Equal(t, "wait for 10s", err.Error())
 	assert.Equal(t, time.Second*10, err.GetWaitTime())
 }
+
+func TestAddressFamilyError(t *testing.T) {
+	inner := fmt.Errorf("connection refused")
+	err := error(AddressFamilyError{Family: config.ADDRESS_FAMILY_IPV6, Err: inner})
+	assert.Equal(t, "ipv6: connection refused", err.Error())
+	assert.ErrorIs(t, err, inner)
+
+	var familyError AddressFamilyError
+	require.True(t, errors.As(err, &familyError))
+	assert.Equal(t, config.ADDRESS_FAMILY_IPV6, familyError.Family)
+}
+
+func TestNewMailError(t *testing.T) {
+	type TestCase struct {
+		name         string
+		stage        MailErrorStage
+		err          error
+		stageOut     MailErrorStage
+		temporary    bool
+		code         int
+		enhancedCode string
+	}
+
+	testCases := []TestCase{
+		{
+			name:         "greylisted",
+			stage:        MAIL_STAGE_RCPT,
+			err:          fmt.Errorf("wrapped: %w", &smtp.SMTPError{Code: 450, EnhancedCode: smtp.EnhancedCode{4, 2, 0}, Message: "greylisted"}),
+			stageOut:     MAIL_STAGE_RCPT,
+			temporary:    true,
+			code:         450,
+			enhancedCode: "4.2.0",
+		},
+		{
+			name:         "rejected",
+			stage:        MAIL_STAGE_AUTH,
+			err:          &smtp.SMTPError{Code: 535, EnhancedCode: smtp.EnhancedCode{5, 7, 8}, Message: "bad credentials"},
+			stageOut:     MAIL_STAGE_AUTH,
+			temporary:    false,
+			code:         535,
+			enhancedCode: "5.7.8",
+		},
+		{
+			name:     "no enhanced code",
+			stage:    MAIL_STAGE_DATA,
+			err:      &smtp.SMTPError{Code: 554, EnhancedCode: smtp.NoEnhancedCode, Message: "no"},
+			stageOut: MAIL_STAGE_DATA,
+			code:     554,
+		},
+		{
+			name:     "unknown host",
+			stage:    MAIL_STAGE_DIAL,
+			err:      &net.OpError{Op: "dial", Err: &net.DNSError{Err: "no such host", Name: "nowhere.invalid", IsNotFound: true}},
+			stageOut: MAIL_STAGE_DIAL,
+		},
+		{
+			name:      "dns timeout",
+			stage:     MAIL_STAGE_DIAL,
+			err:       &net.DNSError{Err: "timeout", Name: "example.com", IsTimeout: true},
+			stageOut:  MAIL_STAGE_DIAL,
+			temporary: true,
+		},
+		{
+			name:      "connection refused",
+			stage:     MAIL_STAGE_DIAL,
+			err:       &net.OpError{Op: "dial", Err: errors.New("connection refused")},
+			stageOut:  MAIL_STAGE_DIAL,
+			temporary: true,
+		},
+		{
+			name:     "untrusted certificate",
+			stage:    MAIL_STAGE_TLS,
+			err:      x509.UnknownAuthorityError{},
+			stageOut: MAIL_STAGE_TLS,
+		},
+		{
+			name:      "connection dropped",
+			stage:     MAIL_STAGE_SELECT,
+			err:       fmt.Errorf("reading: %w", io.ErrUnexpectedEOF),
+			stageOut:  MAIL_STAGE_SELECT,
+			temporary: true,
+		},
+		{
+			name:     "imap refusal",
+			stage:    MAIL_STAGE_SELECT,
+			err:      errors.New("Mailbox doesn't exist"),
+			stageOut: MAIL_STAGE_SELECT,
+		},
+		{
+			name:      "not found yet",
+			stage:     MAIL_STAGE_SEARCH,
+			err:       messageNotFoundError{"subject"},
+			stageOut:  MAIL_STAGE_SEARCH,
+			temporary: true,
+		},
+		{
+			name:      "inner stage is kept",
+			stage:     MAIL_STAGE_DIAL,
+			err:       fmt.Errorf("dialing: %w", MailError{Stage: MAIL_STAGE_TLS, Temporary: true, Err: io.EOF}),
+			stageOut:  MAIL_STAGE_TLS,
+			temporary: true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		mailError := newMailError(testCase.stage, testCase.err)
+		assert.Equal(t, testCase.stageOut, mailError.Stage, testCase.name)
+		assert.Equal(t, testCase.temporary, mailError.Temporary, testCase.name)
+		assert.Equal(t, testCase.code, mailError.Code, testCase.name)
+		assert.Equal(t, testCase.enhancedCode, mailError.EnhancedCode, testCase.name)
+		assert.Equal(t, testCase.err.Error(), mailError.Error(), testCase.name)
+		assert.ErrorIs(t, mailError, testCase.err, testCase.name)
+	}
+
+	assert.ErrorIs(t, newMailError(MAIL_STAGE_SEARCH, messageNotFoundError{"subject"}), ErrMessageNotFound)
+	assert.Equal(t, "no message matching the subject 'subject' was found", messageNotFoundError{"subject"}.Error())
+}
+
+// startFakeSmtpServer starts an SMTP server that accepts everything except the commands listed in
+// replies, which are answered with the given reply instead.
+func startFakeSmtpServer(t *testing.T, replies map[string]string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	reply := func(conn net.Conn, command string, defaultReply string) {
+		if override, found := replies[command]; found {
+			fmt.Fprint(conn, override+"\r\n")
+		} else {
+			fmt.Fprint(conn, defaultReply+"\r\n")
+		}
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				fmt.Fprint(conn, "220 fake ESMTP\r\n")
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					command := strings.ToUpper(strings.Fields(line + " x")[0])
+					switch command {
+					case "EHLO":
+						fmt.Fprint(conn, "250-fake\r\n250 AUTH PLAIN\r\n")
+					case "AUTH":
+						reply(conn, command, "235 2.7.0 authenticated")
+					case "DATA":
+						fmt.Fprint(conn, "354 go ahead\r\n")
+						for line != ".\r\n" && err == nil {
+							line, err = reader.ReadString('\n')
+						}
+						reply(conn, command, "250 2.0.0 queued")
+					case "QUIT":
+						reply(conn, command, "221 2.0.0 bye")
+						return
+					default:
+						reply(conn, command, "250 2.0.0 ok")
+					}
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+// makeFakeSmtpMailConfig returns a mail config with an account named account1 that sends through the
+// fake SMTP server at address
+func makeFakeSmtpMailConfig(address string) config.MailConfig {
+	host, port, _ := net.SplitHostPort(address)
+	portNumber, _ := strconv.ParseUint(port, 10, 16)
+	return config.MailConfig{
+		Accounts: []config.MailAccountConfig{
+			{
+				Name: "account1",
+				SMTP: &config.SMTPConfig{
+					SenderAddress: "sender@example.com",
+					ServerAddress: host,
+					Port:          uint(portNumber),
+					Username:      "sender@example.com",
+					Password:      secrets.CreateSealedItem("some password"),
+				},
+			},
+		},
+	}
+}
+
+// compileMailConfig returns the runtime model of a config with the mail settings
+func compileMailConfig(t *testing.T, mailConfig config.MailConfig) *config.RuntimeConfig {
+	runtimeConfig, err := (&config.VaranusConfig{Mail: mailConfig}).Compile(nil)
+	require.Nil(t, err)
+	return runtimeConfig
+}
+
+func TestSendMessageErrorStages(t *testing.T) {
+	type TestCase struct {
+		replies      map[string]string
+		stage        MailErrorStage
+		temporary    bool
+		code         int
+		enhancedCode string
+	}
+
+	testCases := []TestCase{
+		{map[string]string{"AUTH": "535 5.7.8 authentication failed"}, MAIL_STAGE_AUTH, false, 535, "5.7.8"},
+		{map[string]string{"MAIL": "451 4.3.0 try again later"}, MAIL_STAGE_MAIL_FROM, true, 451, "4.3.0"},
+		{map[string]string{"RCPT": "450 4.2.0 greylisted"}, MAIL_STAGE_RCPT, true, 450, "4.2.0"},
+		{map[string]string{"RCPT": "550 5.1.1 no such user"}, MAIL_STAGE_RCPT, false, 550, "5.1.1"},
+		{map[string]string{"DATA": "554 5.7.1 rejected as spam"}, MAIL_STAGE_DATA, false, 554, "5.7.1"},
+	}
+
+	for index, testCase := range testCases {
+		mailConfig := makeFakeSmtpMailConfig(startFakeSmtpServer(t, testCase.replies))
+		err := MakeMailWorker(compileMailConfig(t, mailConfig), nil).SendMessage("account1", MailMessage{
+			Recipient: "recipient@example.com",
+			Subject:   "test subject",
+			Body:      "This is the message body.",
+		})
+		var mailError MailError
+		require.ErrorAs(t, err, &mailError, "for test %d", index)
+		assert.Equal(t, testCase.stage, mailError.Stage, "for test %d", index)
+		assert.Equal(t, testCase.temporary, mailError.Temporary, "for test %d", index)
+		assert.Equal(t, testCase.code, mailError.Code, "for test %d", index)
+		assert.Equal(t, testCase.enhancedCode, mailError.EnhancedCode, "for test %d", index)
+	}
+
+	{
+		//the fake server accepts everything
+		mailConfig := makeFakeSmtpMailConfig(startFakeSmtpServer(t, nil))
+		err := MakeMailWorker(compileMailConfig(t, mailConfig), nil).SendMessage("account1", MailMessage{
+			Recipient: "recipient@example.com",
+			Subject:   "test subject",
+			Body:      "This is the message body.",
+		})
+		assert.Nil(t, err)
+	}
+	{
+		//the message was accepted before QUIT failed, so the send succeeded
+		mailConfig := makeFakeSmtpMailConfig(startFakeSmtpServer(t, map[string]string{"QUIT": "421 4.4.2 timeout"}))
+		err := MakeMailWorker(compileMailConfig(t, mailConfig), nil).SendMessage("account1", MailMessage{
+			Recipient: "recipient@example.com",
+			Subject:   "test subject",
+			Body:      "This is the message body.",
+		})
+		assert.Nil(t, err)
+	}
+}
@@ -5,9 +5,15 @@ type MailMessage struct {
 	Recipient string
 	Subject   string
 	Body      string
+	//set if the send limits were already reserved for the message with ReserveSend, or don't apply
+	//to it, like a notification that must not be held back
+	SkipSendLimits bool
 }
 
 type MailWorker interface {
+	//ReserveSend records a send from the account under its send limits, for messages that are then
+	//sent with SkipSendLimits set.  It returns a WaitError if the account must wait.
+	ReserveSend(accountName string) error
 	SendMessage(accountName string, message MailMessage) error
 	ReadMessage(accountName string, expectedSubject string) (MailMessage, error)
 }
@@ -3,10 +3,12 @@ package mail
 import (
 	"fmt"
 	"io"
+	"net"
 	"strings"
 	"time"
 	"varanus/internal/config"
 	"varanus/internal/secrets"
+	"varanus/internal/util"
 
 	"github.com/emersion/go-imap"
 	"github.com/emersion/go-sasl"
@@ -24,26 +26,100 @@ func init() {
 	zerolog.SetGlobalLevel(zerolog.InfoLevel)
 }
 
-func MakeMailWorker(config config.MailConfig, unsealer secrets.SecretUnsealer) MailWorker {
+// MakeMailWorker returns a mail worker for the accounts of the compiled config
+func MakeMailWorker(runtimeConfig *config.RuntimeConfig, unsealer secrets.SecretUnsealer) MailWorker {
 	return &mailWorkerImpl{
-		config:   config,
-		unsealer: unsealer,
+		config:       runtimeConfig,
+		unsealer:     unsealer,
+		authFailures: &authFailureTracker{},
+		sendHistory:  MakeSendHistory(),
 	}
 }
 
+// MakeMailWorkersForAddressFamilies returns a mail worker for each of the families, which connects
+// to every mail server using that family instead of the address_family of each account.  An empty
+// family gets a worker that uses the address_family of each account.  Errors returned by a worker
+// for a family are wrapped in an AddressFamilyError so that a probe can report which family failed.
+//
+// The workers share the auth lockouts, since an account logs in with the same credentials whichever
+// family it connects with, and sendHistory, since the send limits apply to every family.  The send
+// history can be shared with the workers of other configs, so that a reload doesn't reset it.
+func MakeMailWorkersForAddressFamilies(runtimeConfig *config.RuntimeConfig, families []config.AddressFamily,
+	unsealer secrets.SecretUnsealer, sendHistory *SendHistory) map[config.AddressFamily]MailWorker {
+	authFailures := &authFailureTracker{}
+	workers := map[config.AddressFamily]MailWorker{}
+	for _, family := range families {
+		workers[family] = &mailWorkerImpl{
+			config:        runtimeConfig,
+			unsealer:      unsealer,
+			addressFamily: family,
+			authFailures:  authFailures,
+			sendHistory:   sendHistory,
+		}
+	}
+	return workers
+}
+
 type mailWorkerImpl struct {
-	config   config.MailConfig
-	unsealer secrets.SecretUnsealer
+	config        *config.RuntimeConfig
+	unsealer      secrets.SecretUnsealer
+	addressFamily config.AddressFamily //if set, overrides the address family of every account
+	authFailures  *authFailureTracker  //reset when new workers are made for a reloaded config
+	sendHistory   *SendHistory
+}
+
+// getDialOptions returns the options used for the first hop of connections from the account
+func (mw *mailWorkerImpl) getDialOptions(account *config.RuntimeAccount) dialOptions {
+	options := dialOptions{
+		addressFamily: account.AddressFamily(),
+		localAddress:  account.LocalAddress(),
+	}
+	if mw.addressFamily != "" {
+		options.addressFamily = mw.addressFamily
+	}
+	return options
+}
+
+// wrapAddressFamilyError wraps err in an AddressFamilyError if the worker is restricted to an
+// address family.
+func (mw *mailWorkerImpl) wrapAddressFamilyError(err error) error {
+	if err == nil || mw.addressFamily == "" {
+		return err
+	}
+	return AddressFamilyError{Family: mw.addressFamily, Err: err}
 }
 
 // CanSend takes the account name and returns True if a message can be sent from the account,
-// or false if not.  If false is returned, the bool return value contains the wait time.
+// or false if not.  If false is returned, the bool return value contains the wait time.  A message
+// can't be sent until the min_period of every send limit bucket of the account has passed since
+// the last message from any account in the bucket.
 func (mw *mailWorkerImpl) CanSend(accountName string) (bool, time.Duration) {
-	//TODO implement the send limits here
-	return true, time.Duration(0)
+	account := mw.config.AccountByName(accountName)
+	if account == nil {
+		return true, time.Duration(0)
+	}
+	wait := mw.sendHistory.checkSend(mw.config.SendLimitBucketsForAccount(account))
+	return wait == 0, wait
+}
+
+func (mw *mailWorkerImpl) ReserveSend(accountName string) error {
+	account := mw.config.AccountByName(accountName)
+	if account == nil {
+		return fmt.Errorf("no account named '%s' was found", accountName)
+	}
+	sendWait := mw.sendHistory.reserveSend(accountName, mw.config.SendLimitBucketsForAccount(account))
+	if sendWait > 0 {
+		log.Trace().Dur("sendWait", sendWait).Msg("Must wait to send message")
+		return WaitError{sendWait}
+	}
+	return nil
 }
 
 func (mw *mailWorkerImpl) SendMessage(accountName string, message MailMessage) error {
+	return mw.wrapAddressFamilyError(mw.sendMessage(accountName, message))
+}
+
+func (mw *mailWorkerImpl) sendMessage(accountName string, message MailMessage) error {
 	//validate the message
 	if len(message.Sender) > 0 {
 		return fmt.Errorf("invalid message with non-empty Sender field.  Sender is set by the account")
@@ -58,70 +134,91 @@ func (mw *mailWorkerImpl) SendMessage(accountName string, message MailMessage) e
 		return fmt.Errorf("invalid message with empty Body field.  Body is required")
 	}
 
-	account := mw.config.GetAccountByName(accountName)
+	account := mw.config.AccountByName(accountName)
 	if account == nil {
 		return fmt.Errorf("no account named '%s' was found", accountName)
 	}
-	if account.SMTP == nil {
+	smtpConfig := account.SMTP()
+	if smtpConfig == nil {
 		return fmt.Errorf("the account named '%s' has no SMTP config", accountName)
 	}
 
-	canSend, sendWait := mw.CanSend(accountName)
-	if !canSend {
-		log.Trace().Dur("sendWait", sendWait).Msg("Must wait to send message")
-		return WaitError{sendWait}
+	//don't try credentials that were rejected until the cool-down has passed
+	authKey := authFailureKey{accountName, AUTH_PROTOCOL_SMTP}
+	if err := mw.authFailures.checkSuspended(authKey); err != nil {
+		log.Trace().Err(err).Msg("SMTP logins are suspended")
+		return err
+	}
+
+	//the attempt counts as a send even if it fails, since the server may have received the message
+	if !message.SkipSendLimits {
+		if err := mw.ReserveSend(accountName); err != nil {
+			return err
+		}
 	}
 
 	log.Trace().Str("accountName", accountName).Msg("Ready to send a message")
 
-	unsealedPassword, err := account.SMTP.Password.ReadSecret(mw.unsealer)
+	unsealedPassword, err := smtpConfig.Password.ReadSecret(mw.unsealer)
 	if err != nil {
 		log.Trace().Err(err).Msg("Failed to unseal password secret")
 		return fmt.Errorf("failed to unseal password secret: %w", err)
 	}
 
-	auth := sasl.NewPlainClient("", account.SMTP.Username, unsealedPassword)
+	auth := sasl.NewPlainClient("", smtpConfig.Username, unsealedPassword)
 
 	msgBody := fmt.Sprintf("To: %s\r\n", message.Recipient) +
 		fmt.Sprintf("Subject: %s\r\n", message.Subject) +
 		"\r\n" +
 		fmt.Sprintf("%s\r\n", message.Body)
 
-	mailServerAddress := fmt.Sprintf("%s:%d", account.SMTP.ServerAddress, account.SMTP.Port)
+	smtpHost := util.UnbracketHost(smtpConfig.ServerAddress)
+	mailServerAddress := net.JoinHostPort(smtpHost, fmt.Sprint(smtpConfig.Port))
 
 	log.Trace().Str("mailServerAddress", mailServerAddress).Msg("Sending to")
 
-	// Connect to the remote SMTP server.
+	// Connect to the remote SMTP server, through the proxy if there is one.
 	var smtpClient *smtp.Client
 	{
-		var err error
-		if account.SMTP.UseTLS {
-			smtpClient, err = smtp.DialTLS(mailServerAddress, nil)
-		} else {
-			smtpClient, err = smtp.Dial(mailServerAddress)
+		dialer, err := makeDialer(account.Proxy(), mw.getDialOptions(account), mw.unsealer)
+		if err != nil {
+			log.Trace().Err(err).Msg("Failed to set up the dialer")
+			return fmt.Errorf("failed to set up the connection to SMTP server '%s': %w", mailServerAddress, err)
+		}
+		conn, err := dialMailServer(dialer, mailServerAddress, smtpConfig.UseTLS)
+		if err == nil {
+			smtpClient, err = smtp.NewClient(conn, smtpHost)
+			if err != nil {
+				conn.Close()
+			}
 		}
 		if err != nil {
 			log.Trace().Err(err).Str("mailServerAddress", mailServerAddress).Msgf("Failed to dial SMTP server")
-			return fmt.Errorf("failed to dial SMTP server '%s': %w", mailServerAddress, err)
+			return newMailError(MAIL_STAGE_DIAL, fmt.Errorf("failed to dial SMTP server '%s': %w", mailServerAddress, err))
 		}
 	}
 	//authenticate
-	if err := smtpClient.Auth(auth); err != nil {
-		//no test coverage for failures that require inducing an error in the SMTP server
-		log.Trace().Err(err).Msgf("Failed to authenticate")
-		return fmt.Errorf("failed to authenticate: %w", err)
+	{
+		err := smtpClient.Auth(auth)
+		if err != nil {
+			log.Trace().Err(err).Msgf("Failed to authenticate")
+			err = newMailError(MAIL_STAGE_AUTH, fmt.Errorf("failed to authenticate: %w", err))
+		}
+		if err := mw.authFailures.recordResult(authKey, mw.config.AuthLockout(), err); err != nil {
+			return err
+		}
 	}
 
 	// Set the sender and recipient first
-	if err := smtpClient.Mail(account.SMTP.SenderAddress, nil); err != nil {
+	if err := smtpClient.Mail(smtpConfig.SenderAddress, nil); err != nil {
 		//no test coverage for failures that require inducing an error in the SMTP server
-		log.Trace().Err(err).Str("senderAddress", account.SMTP.SenderAddress).Msgf("Failed to set sender address")
-		return fmt.Errorf("failed to set sender address '%s': %w", account.SMTP.SenderAddress, err)
+		log.Trace().Err(err).Str("senderAddress", smtpConfig.SenderAddress).Msgf("Failed to set sender address")
+		return newMailError(MAIL_STAGE_MAIL_FROM, fmt.Errorf("failed to set sender address '%s': %w", smtpConfig.SenderAddress, err))
 	}
 	if err := smtpClient.Rcpt(message.Recipient, nil); err != nil {
 		//no test coverage for failures that require inducing an error in the SMTP server
 		log.Trace().Err(err).Str("recipientAddress", message.Recipient).Msgf("Failed to set recipient address")
-		return fmt.Errorf("failed to set recipient address '%s': %w", message.Recipient, err)
+		return newMailError(MAIL_STAGE_RCPT, fmt.Errorf("failed to set recipient address '%s': %w", message.Recipient, err))
 	}
 	// Send the email body.
 	{
@@ -129,25 +226,25 @@ func (mw *mailWorkerImpl) SendMessage(accountName string, message MailMessage) e
 		if err != nil {
 			//no test coverage for failures that require inducing an error in the SMTP server
 			log.Trace().Err(err).Str("email body", message.Body).Msgf("Failed to set email body")
-			return fmt.Errorf("failed to set email body '%s': %w", message.Body, err)
+			return newMailError(MAIL_STAGE_DATA, fmt.Errorf("failed to set email body '%s': %w", message.Body, err))
 		}
 		if _, err = fmt.Fprint(wc, msgBody); err != nil {
 			//no test coverage for failures that require inducing an error in the SMTP server
 			log.Trace().Err(err).Str("email body", message.Body).Msgf("Failed to write body to message")
-			return fmt.Errorf("failed to write body to message '%s': %w", message.Body, err)
+			return newMailError(MAIL_STAGE_DATA, fmt.Errorf("failed to write body to message '%s': %w", message.Body, err))
 		}
 		if err := wc.Close(); err != nil {
 			//no test coverage for failures that require inducing an error in the SMTP server
 			log.Trace().Err(err).Msgf("Failed to close email body")
-			return fmt.Errorf("failed to close email body: %w", err)
+			return newMailError(MAIL_STAGE_DATA, fmt.Errorf("failed to close email body: %w", err))
 		}
 	}
 
-	// Send the QUIT command and close the connection.
+	// Send the QUIT command and close the connection.  The server accepted the message when the
+	// body was closed, so a failed QUIT is logged but doesn't fail the send.
 	if err := smtpClient.Quit(); err != nil {
-		//no test coverage for failures that require inducing an error in the SMTP server
-		log.Trace().Err(err).Msgf("Failed to quit client")
-		return fmt.Errorf("failed to quit client: %w", err)
+		log.Warn().Err(err).Str("mailServerAddress", mailServerAddress).Msg("Failed to quit client after the message was accepted")
+		smtpClient.Close()
 	}
 
 	//success
@@ -155,33 +252,50 @@ func (mw *mailWorkerImpl) SendMessage(accountName string, message MailMessage) e
 }
 
 func (mw *mailWorkerImpl) ReadMessage(accountName string, expectedSubject string) (MailMessage, error) {
+	message, err := mw.readMessage(accountName, expectedSubject)
+	return message, mw.wrapAddressFamilyError(err)
+}
+
+func (mw *mailWorkerImpl) readMessage(accountName string, expectedSubject string) (MailMessage, error) {
 	//get the account
-	account := mw.config.GetAccountByName(accountName)
+	account := mw.config.AccountByName(accountName)
 	if account == nil {
 		return MailMessage{}, fmt.Errorf("no account named '%s' was found", accountName)
 	}
-	if account.IMAP == nil {
+	imapConfig := account.IMAP()
+	if imapConfig == nil {
 		return MailMessage{}, fmt.Errorf("the account named '%s' has no IMAP config", accountName)
 	}
 
-	unsealedPassword, err := account.IMAP.Password.ReadSecret(mw.unsealer)
+	//don't try credentials that were rejected until the cool-down has passed
+	authKey := authFailureKey{accountName, AUTH_PROTOCOL_IMAP}
+	if err := mw.authFailures.checkSuspended(authKey); err != nil {
+		return MailMessage{}, err
+	}
+
+	unsealedPassword, err := imapConfig.Password.ReadSecret(mw.unsealer)
 	if err != nil {
 		return MailMessage{}, fmt.Errorf("failed to unseal password secret: %w", err)
 	}
 
 	// Connect to server
-	mailServerAddress := fmt.Sprintf("%s:%d", account.IMAP.ServerAddress, account.IMAP.Port)
+	mailServerAddress := net.JoinHostPort(util.UnbracketHost(imapConfig.ServerAddress), fmt.Sprint(imapConfig.Port))
 
 	var imapClient *client.Client
 	{
-		var err error
-		if account.IMAP.UseTLS {
-			imapClient, err = client.DialTLS(mailServerAddress, nil)
-		} else {
-			imapClient, err = client.Dial(mailServerAddress)
+		dialer, err := makeDialer(account.Proxy(), mw.getDialOptions(account), mw.unsealer)
+		if err != nil {
+			return MailMessage{}, fmt.Errorf("failed to set up the connection to IMAP server %s: %w", mailServerAddress, err)
+		}
+		conn, err := dialMailServer(dialer, mailServerAddress, imapConfig.UseTLS)
+		if err == nil {
+			imapClient, err = client.New(conn)
+			if err != nil {
+				conn.Close()
+			}
 		}
 		if err != nil {
-			return MailMessage{}, fmt.Errorf("failed to dial IMAP server %s: %w", mailServerAddress, err)
+			return MailMessage{}, newMailError(MAIL_STAGE_DIAL, fmt.Errorf("failed to dial IMAP server %s: %w", mailServerAddress, err))
 		}
 	}
 
@@ -189,15 +303,21 @@ func (mw *mailWorkerImpl) ReadMessage(accountName string, expectedSubject string
 	defer imapClient.Logout()
 
 	// Login
-	if err := imapClient.Login(account.IMAP.Username, unsealedPassword); err != nil {
-		return MailMessage{}, fmt.Errorf("failed to login to IMAP server %s: %w",
-			mailServerAddress, err)
+	{
+		err := imapClient.Login(imapConfig.Username, unsealedPassword)
+		if err != nil {
+			err = newMailError(MAIL_STAGE_AUTH, fmt.Errorf("failed to login to IMAP server %s: %w",
+				mailServerAddress, err))
+		}
+		if err := mw.authFailures.recordResult(authKey, mw.config.AuthLockout(), err); err != nil {
+			return MailMessage{}, err
+		}
 	}
 
-	mbox, err := imapClient.Select(account.IMAP.MailboxName, true)
+	mbox, err := imapClient.Select(imapConfig.MailboxName, true)
 	if err != nil {
-		return MailMessage{}, fmt.Errorf("failed to select the mailbox %s: %w",
-			account.IMAP.MailboxName, err)
+		return MailMessage{}, newMailError(MAIL_STAGE_SELECT, fmt.Errorf("failed to select the mailbox %s: %w",
+			imapConfig.MailboxName, err))
 	}
 
 	const CHUNK_SIZE = 5
@@ -243,7 +363,7 @@ func (mw *mailWorkerImpl) ReadMessage(accountName string, expectedSubject string
 		}
 
 		if err := <-done; err != nil {
-			return MailMessage{}, fmt.Errorf("failed while fetching messages: %w", err)
+			return MailMessage{}, newMailError(MAIL_STAGE_FETCH, fmt.Errorf("failed while fetching messages: %w", err))
 		}
 
 		if messageCount > 10 {
@@ -252,7 +372,7 @@ func (mw *mailWorkerImpl) ReadMessage(accountName string, expectedSubject string
 	}
 
 	if foundMessage == nil {
-		return MailMessage{}, fmt.Errorf("no message matching the subject '%s' was found", expectedSubject)
+		return MailMessage{}, newMailError(MAIL_STAGE_SEARCH, messageNotFoundError{expectedSubject})
 	}
 
 	return *foundMessage, nil
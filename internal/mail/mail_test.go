@@ -51,7 +51,9 @@ func TestMailWorker(t *testing.T) {
 
 	subjectLine := "test message " + time.Now().Format(time.DateTime)
 
-	worker := mailWorkerImpl{testConfig.Mail, unsealer}
+	runtimeConfig, err := testConfig.Compile(nil)
+	require.Nil(t, err)
+	worker := mailWorkerImpl{config: runtimeConfig, unsealer: unsealer}
 
 	err = worker.SendMessage("314pies_account", MailMessage{
 		Recipient: "mailtest2@314pies.com",
@@ -110,13 +112,15 @@ func TestMailWorkerLocal(t *testing.T) {
 			require.Equal(t, 0, result.GetErrorCount())
 		}
 
-		worker := MakeMailWorker(config.Mail, nil)
+		runtimeConfig, err := config.Compile(nil)
+		require.Nil(t, err)
+		worker := MakeMailWorker(runtimeConfig, nil)
 
 		fmt.Fprintln(os.Stderr, "Sending mail")
 
 		subjectLine := "test message 1" + time.Now().Format(time.DateTime)
 
-		err := worker.SendMessage("account1", MailMessage{
+		err = worker.SendMessage("account1", MailMessage{
 			Recipient: "mailtest2@314pies.com",
 			Subject:   subjectLine,
 			Body:      "This is the message body.",
@@ -191,6 +195,7 @@ func TestMailWorkerLocal(t *testing.T) {
 }
 
 func TestMailWorkerInvalidSend(t *testing.T) {
+	ipv6Only := []config.AddressFamily{config.ADDRESS_FAMILY_IPV6}
 	config := config.VaranusConfig{
 		Mail: config.MailConfig{
 			Accounts: []config.MailAccountConfig{
@@ -250,7 +255,9 @@ func TestMailWorkerInvalidSend(t *testing.T) {
 		require.Equal(t, 0, result.GetErrorCount())
 	}
 
-	worker := MakeMailWorker(config.Mail, nil)
+	runtimeConfig, err := config.Compile(nil)
+	require.Nil(t, err)
+	worker := MakeMailWorker(runtimeConfig, nil)
 
 	{
 		err := worker.SendMessage("account1", MailMessage{
@@ -320,5 +327,22 @@ func TestMailWorkerInvalidSend(t *testing.T) {
 		})
 		assert.ErrorContains(t, err, "failed to dial SMTP server")
 	}
+	{
+		//a worker restricted to one address family reports the family that failed
+		familyWorker := MakeMailWorkersForAddressFamilies(runtimeConfig, ipv6Only, nil, MakeSendHistory())[ipv6Only[0]]
+		err := familyWorker.SendMessage("account1", MailMessage{
+			Recipient: "mailtest2@314pies.com",
+			Subject:   "test subject",
+			Body:      "This is the message body.",
+		})
+		var familyError AddressFamilyError
+		require.ErrorAs(t, err, &familyError)
+		assert.Equal(t, "ipv6", string(familyError.Family))
+		assert.ErrorContains(t, err, "failed to dial SMTP server 'localhost:2525'")
+
+		_, err = familyWorker.ReadMessage("account2", "test subject")
+		require.ErrorAs(t, err, &familyError)
+		assert.ErrorContains(t, err, "ipv6: failed to dial IMAP server localhost:2543")
+	}
 
 }
@@ -0,0 +1,71 @@
+package mail
+
+import (
+	"sync"
+	"time"
+	"varanus/internal/config"
+)
+
+// SendHistory records when each account last tried to send a message, so that the workers can keep
+// to the send limits.  It is kept by account name rather than by bucket so that it can be shared by
+// the workers made for a reloaded config, whose buckets may be different.  The zero value is ready
+// to use.
+type SendHistory struct {
+	mutex    sync.Mutex
+	now      func() time.Time //injectable clock for tests; time.Now if nil
+	lastSent map[string]time.Time
+}
+
+// MakeSendHistory returns an empty send history
+func MakeSendHistory() *SendHistory {
+	return &SendHistory{}
+}
+
+func (h *SendHistory) getNow() time.Time {
+	if h.now == nil {
+		return time.Now()
+	}
+	return h.now()
+}
+
+// waitTime returns how long to wait before a message can be sent from an account that is in the
+// buckets, which is zero if it can be sent now.  The mutex must be held.
+func (h *SendHistory) waitTime(buckets []config.SendLimitBucket) time.Duration {
+	now := h.getNow()
+	wait := time.Duration(0)
+	for _, bucket := range buckets {
+		for _, accountName := range bucket.AccountNames {
+			lastSent, found := h.lastSent[accountName]
+			if found {
+				wait = max(wait, lastSent.Add(bucket.MinPeriod).Sub(now))
+			}
+		}
+	}
+	return wait
+}
+
+// checkSend returns how long to wait before a message can be sent from an account that is in the
+// buckets, without recording anything
+func (h *SendHistory) checkSend(buckets []config.SendLimitBucket) time.Duration {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.waitTime(buckets)
+}
+
+// reserveSend records a send from the account, which is in the buckets, and returns zero if a
+// message can be sent now.  Otherwise nothing is recorded and it returns how long to wait.  The
+// check and the record are done together so that two messages sent at once can't both pass.
+func (h *SendHistory) reserveSend(accountName string, buckets []config.SendLimitBucket) time.Duration {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	wait := h.waitTime(buckets)
+	if wait > 0 {
+		return wait
+	}
+	if h.lastSent == nil {
+		h.lastSent = map[string]time.Time{}
+	}
+	h.lastSent[accountName] = h.getNow()
+	return 0
+}
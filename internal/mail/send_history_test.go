@@ -0,0 +1,86 @@
+package mail
+
+import (
+	"testing"
+	"time"
+	"varanus/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendHistory(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	history := &SendHistory{now: func() time.Time { return now }}
+
+	shared := config.SendLimitBucket{MinPeriod: 10 * time.Minute, AccountNames: []string{"a", "b"}}
+	slow := config.SendLimitBucket{MinPeriod: time.Hour, AccountNames: []string{"b"}}
+
+	//nothing was sent yet
+	assert.Equal(t, time.Duration(0), history.checkSend([]config.SendLimitBucket{shared}))
+	assert.Equal(t, time.Duration(0), history.reserveSend("a", []config.SendLimitBucket{shared}))
+
+	//a send from a blocks every account in the bucket
+	now = now.Add(4 * time.Minute)
+	assert.Equal(t, 6*time.Minute, history.checkSend([]config.SendLimitBucket{shared}))
+	assert.Equal(t, 6*time.Minute, history.reserveSend("b", []config.SendLimitBucket{shared, slow}))
+
+	//an account without buckets isn't limited
+	assert.Equal(t, time.Duration(0), history.reserveSend("c", nil))
+
+	//the refused send wasn't recorded, so b can send once the shared bucket allows it
+	now = now.Add(6 * time.Minute)
+	assert.Equal(t, time.Duration(0), history.reserveSend("b", []config.SendLimitBucket{shared, slow}))
+
+	//and then waits for the longest of its buckets
+	now = now.Add(20 * time.Minute)
+	assert.Equal(t, 40*time.Minute, history.checkSend([]config.SendLimitBucket{shared, slow}))
+	assert.Equal(t, time.Duration(0), history.checkSend([]config.SendLimitBucket{shared}))
+}
+
+func TestSendMessageSendLimits(t *testing.T) {
+	mailConfig := makeFakeSmtpMailConfig(startFakeSmtpServer(t, nil))
+	mailConfig.SendLimits = []config.SendLimitConfig{{MinPeriod: time.Hour, AccountNames: []string{"account1"}}}
+	worker := MakeMailWorker(compileMailConfig(t, mailConfig), nil)
+
+	message := MailMessage{
+		Recipient: "recipient@example.com",
+		Subject:   "test subject",
+		Body:      "This is the message body.",
+	}
+
+	canSend, _ := worker.(*mailWorkerImpl).CanSend("account1")
+	assert.True(t, canSend)
+	require.Nil(t, worker.SendMessage("account1", message))
+
+	canSend, wait := worker.(*mailWorkerImpl).CanSend("account1")
+	assert.False(t, canSend)
+	assert.Greater(t, wait, 59*time.Minute)
+
+	var waitError WaitError
+	require.ErrorAs(t, worker.SendMessage("account1", message), &waitError)
+	assert.Greater(t, waitError.GetWaitTime(), 59*time.Minute)
+}
+
+func TestReserveSend(t *testing.T) {
+	mailConfig := makeFakeSmtpMailConfig(startFakeSmtpServer(t, nil))
+	mailConfig.SendLimits = []config.SendLimitConfig{{MinPeriod: time.Hour, AccountNames: []string{"account1"}}}
+	worker := MakeMailWorker(compileMailConfig(t, mailConfig), nil)
+
+	message := MailMessage{
+		Recipient:      "recipient@example.com",
+		Subject:        "test subject",
+		Body:           "This is the message body.",
+		SkipSendLimits: true,
+	}
+
+	//the messages sent with a reservation don't count or wait for the send limits themselves
+	require.Nil(t, worker.ReserveSend("account1"))
+	require.Nil(t, worker.SendMessage("account1", message))
+	require.Nil(t, worker.SendMessage("account1", message))
+
+	var waitError WaitError
+	require.ErrorAs(t, worker.ReserveSend("account1"), &waitError)
+	assert.Greater(t, waitError.GetWaitTime(), 59*time.Minute)
+	assert.ErrorContains(t, worker.ReserveSend("account2"), "no account named 'account2' was found")
+}
@@ -68,6 +68,9 @@ func (si SealedItem) Validate(vet validation.ValidationErrorTracker, root interf
 	if err != nil {
 		vet.AddValidationError(si, err.Error())
 	}
+	if !si.isSealed && si.value != "" {
+		vet.AddValidationWarning(si, "the value is not sealed, so the secret is stored as plaintext")
+	}
 	return nil
 }
 
@@ -5,6 +5,7 @@ import (
 	"varanus/internal/validation"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v3"
 )
 
@@ -110,6 +111,20 @@ func TestSealedItemValidationErrors(t *testing.T) {
 
 }
 
+func TestSealedItemPlaintextWarning(t *testing.T) {
+
+	validationResult, err := validation.ValidateObject(CreateSealedItem("it's a secret"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, validationResult.GetErrorCount())
+	require.Equal(t, 1, validationResult.GetWarningCount())
+	assert.Equal(t, "the value is not sealed, so the secret is stored as plaintext", validationResult.GetWarningList()[0].Error)
+
+	validationResult, err = validation.ValidateObject(CreateSealedItem("sealed(+aaaa==)"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, validationResult.GetErrorCount())
+	assert.Equal(t, 0, validationResult.GetWarningCount())
+}
+
 func TestSealedItemUnmarshaling(t *testing.T) {
 	//sealed yaml
 	{
@@ -453,6 +453,37 @@ func TestMixedSealUnseal(t *testing.T) {
 
 }
 
+func TestSealUnsetItems(t *testing.T) {
+
+	sealer := secretSealerImpl{}
+	unsealer := secretUnsealerImpl{}
+	err := sealer.LoadPublicKeyFromFile(TEST_FILE_PREFIX + PUBLIC_KEY_4096_FILENAME)
+	assert.Nil(t, err)
+	err = unsealer.LoadPrivateKeyFromFile(TEST_FILE_PREFIX+PRIVATE_KEY_4096_FILENAME, "")
+	assert.Nil(t, err)
+
+	//the empty item was left out, so it is neither sealed nor counted
+	mssh := MockMultiSecretHolder{
+		SI1: CreateSealedItem("it's a secret."),
+	}
+
+	sealResult := sealer.SealObject(&mssh)
+	assert.Equal(t, 1, sealResult.NumberSealed)
+	assert.Equal(t, 1, sealResult.TotalSealedCount)
+	assert.Equal(t, 0, sealResult.TotalUnsealedCount)
+	assert.False(t, mssh.SI2.IsValueSealed())
+	assert.Equal(t, "", mssh.SI2.GetValue())
+
+	check := unsealer.CheckSeals(mssh)
+	assert.Equal(t, 1, check.SealedCount)
+	assert.Equal(t, 0, check.UnsealedCount)
+
+	unsealResult := unsealer.UnsealObject(&mssh)
+	assert.Equal(t, 1, unsealResult.NumberUnsealed)
+	assert.Equal(t, 1, unsealResult.TotalUnsealedCount)
+
+}
+
 type mockFailingSealer struct {
 }
 
@@ -7,6 +7,13 @@ import (
 	"varanus/internal/walker"
 )
 
+// isUnsetItem returns true for an item with an empty unsealed value, which was left out of the
+// object it is in, like the password of a template that doesn't set one.  It is not counted, and
+// there is nothing to seal.
+func isUnsetItem(si SealableReader) bool {
+	return !si.IsValueSealed() && si.GetValue() == ""
+}
+
 type SealCheckResult struct {
 	UnsealedCount int
 	SealedCount   int
@@ -33,6 +40,9 @@ func CheckSealsOnObject(objectToSeal interface{}, unsealer SecretUnsealer) SealC
 	sealedItemWorker := func(needle interface{}, path string) error {
 		si := needle.(SealableReader)
 
+		if isUnsetItem(si) {
+			return nil
+		}
 		if !si.IsValueSealed() {
 			result.UnsealedCount += 1
 		} else {
@@ -78,12 +88,25 @@ func (r SealResult) HumanReadable() string {
 	return sb.String()
 }
 
+// Add returns the combined result of sealing two objects, e.g. the fragments of a config
+func (r SealResult) Add(other SealResult) SealResult {
+	return SealResult{
+		TotalUnsealedCount: r.TotalUnsealedCount + other.TotalUnsealedCount,
+		TotalSealedCount:   r.TotalSealedCount + other.TotalSealedCount,
+		NumberSealed:       r.NumberSealed + other.NumberSealed,
+		SealErrors:         append(append([]error{}, r.SealErrors...), other.SealErrors...),
+	}
+}
+
 func SealObject(objectToSeal interface{}, sealer SecretSealer) SealResult {
 	result := SealResult{}
 
 	sealedItemWorker := func(needle interface{}, path string) error {
 		si := needle.(SealableWriter)
 
+		if isUnsetItem(si) {
+			return nil
+		}
 		//already sealed
 		if si.IsValueSealed() {
 			result.TotalSealedCount += 1
@@ -138,12 +161,25 @@ func (r UnsealResult) HumanReadable() string {
 	return sb.String()
 }
 
+// Add returns the combined result of unsealing two objects, e.g. the fragments of a config
+func (r UnsealResult) Add(other UnsealResult) UnsealResult {
+	return UnsealResult{
+		TotalUnsealedCount: r.TotalUnsealedCount + other.TotalUnsealedCount,
+		TotalSealedCount:   r.TotalSealedCount + other.TotalSealedCount,
+		NumberUnsealed:     r.NumberUnsealed + other.NumberUnsealed,
+		UnsealErrors:       append(append([]error{}, r.UnsealErrors...), other.UnsealErrors...),
+	}
+}
+
 func UnsealObject(objectToSeal interface{}, unsealer SecretUnsealer) UnsealResult {
 	result := UnsealResult{}
 
 	sealedItemWorker := func(needle interface{}, path string) error {
 		si := needle.(SealableWriter)
 
+		if isUnsetItem(si) {
+			return nil
+		}
 		//already unsealed
 		if !si.IsValueSealed() {
 			result.TotalUnsealedCount += 1
@@ -41,6 +41,21 @@ After the unseal operation, of 3 total items, 2 are sealed and 1 are unsealed.
 	assert.Equal(t, expectedOutput, output)
 }
 
+func TestAddResults(t *testing.T) {
+	first := SealResult{1, 2, 3, []error{fmt.Errorf("an error")}}
+	second := SealResult{10, 20, 30, []error{fmt.Errorf("another error")}}
+	assert.Equal(t,
+		SealResult{11, 22, 33, []error{fmt.Errorf("an error"), fmt.Errorf("another error")}},
+		first.Add(second))
+	assert.Len(t, first.SealErrors, 1)
+
+	firstUnseal := UnsealResult{1, 2, 3, nil}
+	secondUnseal := UnsealResult{10, 20, 30, []error{fmt.Errorf("an error")}}
+	assert.Equal(t,
+		UnsealResult{11, 22, 33, []error{fmt.Errorf("an error")}},
+		firstUnseal.Add(secondUnseal))
+}
+
 func TestEnsureSealedItemIsSealable(t *testing.T) {
 	readerFun := func(s SealableReader) {
 	}
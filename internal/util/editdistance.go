@@ -0,0 +1,90 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MAX_SUGGESTIONS is the most names that SuggestNames returns
+const MAX_SUGGESTIONS = 3
+
+// EditDistance returns the number of single character insertions, deletions, substitutions and
+// swaps of neighbouring characters that turn a into b.  Swaps are counted as one edit because they
+// are a common typo, like "tset" for "test".
+func EditDistance(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+
+	//distances[i][j] is the distance between the first i runes of a and the first j runes of b
+	distances := make([][]int, len(ar)+1)
+	for i := range distances {
+		distances[i] = make([]int, len(br)+1)
+		distances[i][0] = i
+	}
+	for j := range distances[0] {
+		distances[0][j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		for j := 1; j <= len(br); j++ {
+			substitution := 1
+			if ar[i-1] == br[j-1] {
+				substitution = 0
+			}
+			distances[i][j] = min(
+				distances[i-1][j]+1,
+				distances[i][j-1]+1,
+				distances[i-1][j-1]+substitution,
+			)
+			if i > 1 && j > 1 && ar[i-1] == br[j-2] && ar[i-2] == br[j-1] {
+				distances[i][j] = min(distances[i][j], distances[i-2][j-2]+1)
+			}
+		}
+	}
+	return distances[len(ar)][len(br)]
+}
+
+// SuggestNames returns the candidates that are close enough to name to be what was meant, closest
+// first, up to MAX_SUGGESTIONS.  A candidate is close enough if it is at most a third of the
+// length of name away from it, but two edits are always allowed.  Case is ignored.
+func SuggestNames(name string, candidates []string) []string {
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	maxDistance := max(2, len([]rune(name))/3)
+	suggestions := []suggestion{}
+	for _, candidate := range candidates {
+		distance := EditDistance(strings.ToLower(name), strings.ToLower(candidate))
+		if candidate != name && distance <= maxDistance && distance < len([]rune(candidate)) {
+			suggestions = append(suggestions, suggestion{name: candidate, distance: distance})
+		}
+	}
+	//ties keep the order of the candidates
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	names := []string{}
+	for index := 0; index < len(suggestions) && index < MAX_SUGGESTIONS; index++ {
+		names = append(names, suggestions[index].name)
+	}
+	return names
+}
+
+// DescribeSuggestions returns "; did you mean 'a'?", or "; did you mean 'a', 'b' or 'c'?", to be
+// added to a message about a name that wasn't found.  It returns an empty string if there are no
+// suggestions.
+func DescribeSuggestions(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	quoted := []string{}
+	for _, suggestion := range suggestions {
+		quoted = append(quoted, fmt.Sprintf("'%s'", suggestion))
+	}
+	if len(quoted) == 1 {
+		return fmt.Sprintf("; did you mean %s?", quoted[0])
+	}
+	return fmt.Sprintf("; did you mean %s or %s?", strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+}
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditDistance(t *testing.T) {
+	type distanceTestCase struct {
+		a        string
+		b        string
+		distance int
+	}
+	testCases := []distanceTestCase{
+		{"", "", 0},
+		{"test1", "test1", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"test1", "test2", 1},
+		{"tset1", "test1", 1},
+		{"test", "tests", 1},
+		{"work-account", "work_acount", 2},
+		{"kitten", "sitting", 3},
+		{"héllo", "hello", 1},
+	}
+
+	for _, testCase := range testCases {
+		assert.Equal(t, testCase.distance, EditDistance(testCase.a, testCase.b), "for %s and %s", testCase.a, testCase.b)
+		assert.Equal(t, testCase.distance, EditDistance(testCase.b, testCase.a), "for %s and %s", testCase.b, testCase.a)
+	}
+}
+
+func TestSuggestNames(t *testing.T) {
+	candidates := []string{"test1", "test2", "home", "work-account", "test10", "Test3"}
+
+	assert.Equal(t, []string{"test1", "test2", "test10"}, SuggestNames("tset1", candidates))
+	assert.Equal(t, []string{"work-account"}, SuggestNames("work_acount", candidates))
+	assert.Equal(t, []string{"home"}, SuggestNames("hom", candidates))
+	assert.Equal(t, []string{}, SuggestNames("office", candidates))
+	assert.Equal(t, []string{}, SuggestNames("x", []string{"y", "z"}))
+	assert.Equal(t, []string{}, SuggestNames("test1", []string{"test1"}))
+
+	assert.Equal(t, "", DescribeSuggestions([]string{}))
+	assert.Equal(t, "; did you mean 'test1'?", DescribeSuggestions([]string{"test1"}))
+	assert.Equal(t, "; did you mean 'test1', 'test2' or 'Test3'?", DescribeSuggestions([]string{"test1", "test2", "Test3"}))
+}
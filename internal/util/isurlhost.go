@@ -1,8 +1,10 @@
 package util
 
 import (
+	"net"
 	"net/url"
 	"regexp"
+	"strings"
 )
 
 //=================================================================================================
@@ -11,8 +13,13 @@ import (
 // source: https://stackoverflow.com/questions/106179/regular-expression-to-match-dns-hostname-or-ip-address
 var HostnameRe = regexp.MustCompile(`^([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]{0,61}[a-zA-Z0-9])(\.([a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9\-]{0,61}[a-zA-Z0-9]))*$`)
 
-// IsURLHost returns true if the candidate string is a valid hostname
+// IsURLHost returns true if the candidate string is a valid hostname or IP address literal.  IPv6
+// literals may be bracketed, e.g. "[2001:db8::1]".
 func IsUrlHost(candidate string) bool {
+	if IsIPLiteral(candidate) {
+		return true
+	}
+
 	//we don't want the candidate to have to have a scheme, so we add our own
 	candidateWithScheme := "varanus://" + candidate
 
@@ -33,3 +40,24 @@ func IsUrlHost(candidate string) bool {
 
 	return err == nil && u.Scheme == "varanus" && u.Host == candidate
 }
+
+// IsIPLiteral returns true if the candidate string is an IPv4 or IPv6 address.  IPv6 addresses may
+// be bracketed.
+func IsIPLiteral(candidate string) bool {
+	unbracketed := UnbracketHost(candidate)
+	ip := net.ParseIP(unbracketed)
+	if ip == nil {
+		return false
+	}
+	//only IPv6 literals are bracketed
+	return unbracketed == candidate || ip.To4() == nil
+}
+
+// UnbracketHost removes the brackets around an IPv6 literal such as "[::1]" so that the host can be
+// used with net.JoinHostPort.  Other values are returned unchanged.
+func UnbracketHost(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
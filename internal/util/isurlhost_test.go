@@ -18,6 +18,15 @@ func TestIsUrlHost(t *testing.T) {
 		"foo,bar.com":           false,
 		"foo__bar.com":          false,
 		".leading.dot":          false,
+		"192.0.2.10":            true,
+		"2001:db8::1":           true,
+		"[2001:db8::1]":         true,
+		"[::1]":                 true,
+		"[192.0.2.10]":          false,
+		"[2001:db8::1":          false,
+		"2001:db8::1]":          false,
+		"[not.an.ip]":           false,
+		"[2001:db8::1]:25":      false,
 	}
 
 	for candidate, expectedResult := range testCases {
@@ -28,3 +37,21 @@ func TestIsUrlHost(t *testing.T) {
 	}
 
 }
+
+func TestUnbracketHost(t *testing.T) {
+	testCases := map[string]string{
+		"[2001:db8::1]":    "2001:db8::1",
+		"2001:db8::1":      "2001:db8::1",
+		"mail.example.com": "mail.example.com",
+		"[::1":             "[::1",
+		"":                 "",
+	}
+
+	for candidate, expectedResult := range testCases {
+		assert.Equal(t, expectedResult, UnbracketHost(candidate), "for candidate '%s'", candidate)
+	}
+
+	assert.True(t, IsIPLiteral("[::1]"))
+	assert.True(t, IsIPLiteral("127.0.0.1"))
+	assert.False(t, IsIPLiteral("localhost"))
+}
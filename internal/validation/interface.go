@@ -1,5 +1,7 @@
 package validation
 
+import "fmt"
+
 // Validatable is the interface that objects that are to be validated with a ValidationProcess
 // should implement this interface.
 type Validatable interface {
@@ -25,7 +27,50 @@ type Validatable interface {
 // ValidationErrorTracker provides the required interface that Validateable objects can access to
 // log their validation errors.
 //
+// Errors make the object invalid.  Warnings and info are only reported; warnings are for likely
+// mistakes or risks, like a plaintext password, and info is for advice.
+//
 // The ValidationError error struct is the primary implementation of this.
 type ValidationErrorTracker interface {
 	AddValidationError(object interface{}, message string, messageArgs ...interface{})
+	AddValidationWarning(object interface{}, message string, messageArgs ...interface{})
+	AddValidationInfo(object interface{}, message string, messageArgs ...interface{})
+}
+
+// PathIssueTracker accepts issues at an explicit path.  It is for checks that look at the whole
+// object at once, like a lint pass, rather than one Validatable at a time.
+//
+// ValidationResult is the primary implementation of this.
+type PathIssueTracker interface {
+	AddIssueAtPath(severity Severity, path string, object interface{}, message string, messageArgs ...interface{})
+}
+
+// SourceLocation is a position in the source file that a validated object was read from.  Line
+// and Column start at 1.
+type SourceLocation struct {
+	Filename string
+	Line     int
+	Column   int
+}
+
+// String returns the location as "file:line:col"
+func (sl SourceLocation) String() string {
+	return fmt.Sprintf("%s:%d:%d", sl.Filename, sl.Line, sl.Column)
+}
+
+// SourceLocator maps the path of a validated object (as produced by the walker, e.g.
+// "mail.accounts[1].smtp") back to where it was defined in the source.
+//
+// LocatePath returns false if the path can't be located.
+type SourceLocator interface {
+	LocatePath(path string) (SourceLocation, bool)
+}
+
+// Normalizable is the interface for objects that can put their values in a canonical form before
+// they are validated, like trimming whitespace or filling in defaults.
+type Normalizable interface {
+	// Normalize changes the object in place, so it is implemented with a pointer receiver.  Like
+	// Validate, it only needs to normalize its own fields; the objects lower down in the
+	// hierarchy that implement Normalizable are normalized on their own.
+	Normalize()
 }
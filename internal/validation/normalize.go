@@ -0,0 +1,22 @@
+package validation
+
+import (
+	"reflect"
+	"varanus/internal/walker"
+)
+
+// NormalizeObject walks the target object and calls Normalize on every element, including the
+// top level element, that implements Normalizable.  Parents are normalized before their children.
+//
+// The target must be a pointer, since Normalize changes the objects in place.  An error is only
+// returned if the object can't be walked.
+func NormalizeObject(root interface{}) error {
+
+	normalizationWorker := func(needle interface{}, path string) error {
+		needle.(Normalizable).Normalize()
+		return nil
+	}
+
+	normalizableType := reflect.TypeOf((*Normalizable)(nil)).Elem()
+	return walker.WalkObjectMutable(root, normalizableType, normalizationWorker)
+}
@@ -0,0 +1,45 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type mockNormalizable struct {
+	Value string
+}
+
+func (mn *mockNormalizable) Normalize() {
+	mn.Value = strings.TrimSpace(mn.Value)
+}
+
+type mockNormalizationTarget struct {
+	mockNormalizable
+	Single  mockNormalizable
+	Pointer *mockNormalizable
+	Missing *mockNormalizable
+	List    []mockNormalizable
+}
+
+func TestNormalizeObject(t *testing.T) {
+
+	target := mockNormalizationTarget{
+		mockNormalizable: mockNormalizable{Value: " top "},
+		Single:           mockNormalizable{Value: " single"},
+		Pointer:          &mockNormalizable{Value: "pointer "},
+		List:             []mockNormalizable{{Value: " item 0 "}, {Value: "item 1\n"}},
+	}
+
+	err := NormalizeObject(&target)
+	assert.Nil(t, err)
+
+	expected := mockNormalizationTarget{
+		mockNormalizable: mockNormalizable{Value: "top"},
+		Single:           mockNormalizable{Value: "single"},
+		Pointer:          &mockNormalizable{Value: "pointer"},
+		List:             []mockNormalizable{{Value: "item 0"}, {Value: "item 1"}},
+	}
+	assert.Equal(t, expected, target)
+}
@@ -0,0 +1,255 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"slices"
+	"strings"
+	"varanus/internal/util"
+	"varanus/internal/walker"
+)
+
+// Common checks on a single field are declared with a validate tag instead of being written out in
+// a Validate method, e.g.
+//
+//	Port uint `yaml:"port" validate:"port"`
+//
+// A tag can list several rules, separated by commas.  The errors are added for the struct, like the
+// errors of its Validate method, with the yaml name of the field in the message.  A field can have
+// its own message in a message tag instead, which is a format string that is given the value of
+// the field, e.g.
+//
+//	TestPeriod time.Duration `yaml:"test_period" validate:"positive" message:"test_period must be a positive value, not '%d'"`
+//
+// ValidateObject checks the rules of the structs that don't have a Validate method.  A Validate
+// method checks the rules of its struct by calling CheckFieldRules first, so that calling it
+// directly checks the struct completely.  That leaves Validate with the checks that involve several
+// fields.
+
+// VALIDATE_TAG is the struct tag that lists the rules of a field
+const VALIDATE_TAG = "validate"
+
+// MESSAGE_TAG is the struct tag with the message of a field that breaks one of its rules
+const MESSAGE_TAG = "message"
+
+// the rules that can be used in a validate tag
+const (
+	// RULE_REQUIRED requires a string that is not empty or whitespace, or another value that is
+	// not empty or zero
+	RULE_REQUIRED = "required"
+	// RULE_PORT requires an integer port number from 1 to 65535
+	RULE_PORT = "port"
+	// RULE_EMAIL requires a string that is an email address
+	RULE_EMAIL = "email"
+	// RULE_HOSTNAME requires a string that is a hostname or IP address
+	RULE_HOSTNAME = "hostname"
+	// RULE_POSITIVE requires a number or duration that is greater than zero
+	RULE_POSITIVE = "positive"
+	// RULE_ITEMS applies the rules after it to each item of a slice, instead of to the slice, like
+	// "items,required" for a list of names that must not be blank
+	RULE_ITEMS = "items"
+)
+
+// MAX_PORT is the largest port number accepted by RULE_PORT
+const MAX_PORT = 65535
+
+// fieldRuleCheck returns the message for a field that breaks the rule, or "" if it doesn't.  name
+// is the yaml name of the field.
+type fieldRuleCheck func(name string, value reflect.Value) string
+
+type fieldRule struct {
+	kinds []reflect.Kind //the kinds of field the rule can be used on
+	check fieldRuleCheck
+}
+
+var intKinds = []reflect.Kind{
+	reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+	reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+}
+var numberKinds = append([]reflect.Kind{reflect.Float32, reflect.Float64}, intKinds...)
+
+var fieldRules = map[string]fieldRule{
+	RULE_REQUIRED: {
+		kinds: append([]reflect.Kind{reflect.String, reflect.Slice, reflect.Map, reflect.Pointer}, numberKinds...),
+		check: checkRequired,
+	},
+	RULE_PORT: {
+		kinds: intKinds,
+		check: checkPort,
+	},
+	RULE_EMAIL: {
+		kinds: []reflect.Kind{reflect.String},
+		check: checkEmail,
+	},
+	RULE_HOSTNAME: {
+		kinds: []reflect.Kind{reflect.String},
+		check: checkHostname,
+	},
+	RULE_POSITIVE: {
+		kinds: numberKinds,
+		check: checkPositive,
+	},
+}
+
+func checkRequired(name string, value reflect.Value) string {
+	if value.Kind() == reflect.String {
+		if len(strings.TrimSpace(value.String())) == 0 {
+			return fmt.Sprintf("%s must not be empty or whitespace", name)
+		}
+		return ""
+	}
+	if value.IsZero() || ((value.Kind() == reflect.Slice || value.Kind() == reflect.Map) && value.Len() == 0) {
+		return fmt.Sprintf("%s must not be empty", name)
+	}
+	return ""
+}
+
+func checkPort(name string, value reflect.Value) string {
+	if value.IsZero() {
+		return fmt.Sprintf("%s value is required and cannot be 0", name)
+	}
+	if value.CanInt() && (value.Int() < 0 || value.Int() > MAX_PORT) ||
+		value.CanUint() && value.Uint() > MAX_PORT {
+		return fmt.Sprintf("%s %v is not a valid port; it must be from 1 to %d", name, value, MAX_PORT)
+	}
+	return ""
+}
+
+func checkEmail(name string, value reflect.Value) string {
+	_, err := mail.ParseAddress(value.String())
+	if err != nil {
+		return fmt.Sprintf("%s '%s' is not a valid email: %s", name, value.String(), err)
+	}
+	return ""
+}
+
+func checkHostname(name string, value reflect.Value) string {
+	if !util.IsUrlHost(value.String()) {
+		return fmt.Sprintf("%s '%s' is not a valid hostname", name, value.String())
+	}
+	return ""
+}
+
+func checkPositive(name string, value reflect.Value) string {
+	isPositive := (value.CanInt() && value.Int() > 0) || (value.CanUint() && value.Uint() > 0) ||
+		(value.CanFloat() && value.Float() > 0)
+	if !isPositive {
+		//the value is formatted by its own type, so a duration is shown like "1m0s"
+		return fmt.Sprintf("%s must be positive, not '%v'", name, value.Interface())
+	}
+	return ""
+}
+
+// hasFieldRules returns true if t is a struct with a validate tag on one of its fields
+func hasFieldRules(t reflect.Type) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for index := 0; index < t.NumField(); index++ {
+		if _, found := t.Field(index).Tag.Lookup(VALIDATE_TAG); found {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckFieldRules checks the rules in the validate tags of the fields of the struct object.  Only
+// its own fields are checked; like any other struct field, an embedded struct is checked when the
+// walk reaches it, which it only does if the embedded type is exported.  A field with a message tag
+// gets one error with that message, even if it breaks several rules, and so does each of its items.
+//
+// An error is returned, and the checks stop, if a tag has a rule that doesn't exist or can't be
+// used on its field, since that is a mistake in the code rather than in the object.
+func CheckFieldRules(vet ValidationErrorTracker, object interface{}) error {
+	value := reflect.ValueOf(object)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if !hasFieldRules(value.Type()) {
+		return nil
+	}
+
+	structObject := value.Interface()
+	structType := value.Type()
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+		tag, found := field.Tag.Lookup(VALIDATE_TAG)
+		if !found {
+			continue
+		}
+		if !field.IsExported() {
+			return fmt.Errorf("field %s of %s has a validate tag but is not exported", field.Name, structType)
+		}
+		name := walker.GetYamlNameFromTag(field.Tag)
+		if name == "" {
+			name = field.Name
+		}
+		ruleNames := strings.Split(tag, ",")
+		for ruleIndex := range ruleNames {
+			ruleNames[ruleIndex] = strings.TrimSpace(ruleNames[ruleIndex])
+		}
+		fieldValue := value.Field(index)
+
+		//the rules after "items" are checked on each item instead of the field
+		itemRuleNames := []string{}
+		if itemsIndex := slices.Index(ruleNames, RULE_ITEMS); itemsIndex >= 0 {
+			if fieldValue.Kind() != reflect.Slice && fieldValue.Kind() != reflect.Array {
+				return fmt.Errorf("the validate rule '%s' can't be used on field %s of %s, which is a %s",
+					RULE_ITEMS, field.Name, structType, fieldValue.Kind())
+			}
+			itemRuleNames = ruleNames[itemsIndex+1:]
+			ruleNames = ruleNames[:itemsIndex]
+		}
+
+		err := checkValueRules(vet, structObject, field, ruleNames, name, fieldValue)
+		if err != nil {
+			return err
+		}
+		for itemIndex := 0; len(itemRuleNames) > 0 && itemIndex < fieldValue.Len(); itemIndex++ {
+			itemName := fmt.Sprintf("%s[%d]", name, itemIndex)
+			err = checkValueRules(vet, structObject, field, itemRuleNames, itemName, fieldValue.Index(itemIndex))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkValueRules checks the rules on the value of a field, or of one of its items, and adds an
+// error for object if it breaks them.  name is used in the messages of the rules, unless the field
+// has its own message.
+func checkValueRules(vet ValidationErrorTracker, object interface{}, field reflect.StructField, ruleNames []string,
+	name string, value reflect.Value) error {
+
+	customMessage, hasCustomMessage := field.Tag.Lookup(MESSAGE_TAG)
+	for _, ruleName := range ruleNames {
+		rule, found := fieldRules[ruleName]
+		if !found {
+			return fmt.Errorf("field %s of %T has an unknown validate rule '%s'", field.Name, object, ruleName)
+		}
+		if !slices.Contains(rule.kinds, value.Kind()) {
+			return fmt.Errorf("the validate rule '%s' can't be used on field %s of %T, which is a %s",
+				ruleName, field.Name, object, value.Kind())
+		}
+		message := rule.check(name, value)
+		if message == "" {
+			continue
+		}
+		if !hasCustomMessage {
+			vet.AddValidationError(object, "%s", message)
+			continue
+		}
+		if strings.Contains(customMessage, "%") {
+			vet.AddValidationError(object, customMessage, value.Interface())
+		} else {
+			vet.AddValidationError(object, customMessage)
+		}
+		break
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package validation
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockServer struct {
+	Address  string        `yaml:"address" validate:"hostname"`
+	Port     uint          `yaml:"port" validate:"port"`
+	Admin    string        `yaml:"admin" validate:"required,email"`
+	Timeout  time.Duration `yaml:"timeout" validate:"positive"`
+	Retries  int           `validate:"positive"`
+	Aliases  []string      `yaml:"aliases" validate:"required"`
+	Optional string        `yaml:"optional"`
+}
+
+// mockCheckedServer has rules on its fields and a Validate method for the checks that involve
+// several of them
+type mockCheckedServer struct {
+	Primary mockServer  `yaml:"primary"`
+	Backup  *mockServer `yaml:"backup"`
+}
+
+func (s mockCheckedServer) Validate(vet ValidationErrorTracker, root interface{}) error {
+	if s.Backup != nil && s.Backup.Address == s.Primary.Address {
+		vet.AddValidationError(s, "the backup can't be the same server")
+	}
+	return nil
+}
+
+// mockMessageServer has its own messages for its fields, and checks its rules in its Validate method
+type mockMessageServer struct {
+	Admin   string        `yaml:"admin" validate:"required,email" message:"the admin must be an email address"`
+	Timeout time.Duration `yaml:"timeout" validate:"positive" message:"the timeout must be more than '%s'"`
+}
+
+func (s mockMessageServer) Validate(vet ValidationErrorTracker, root interface{}) error {
+	return CheckFieldRules(vet, s)
+}
+
+// mockServerGroup has rules on the items of its lists
+type mockServerGroup struct {
+	Members []string `yaml:"members" validate:"required,items,required"`
+	Admins  []string `yaml:"admins" validate:"items,email" message:"admins must be email addresses, not '%s'"`
+}
+
+type mockBadItemsRule struct {
+	Name string `validate:"items,required"`
+}
+
+type mockBadRule struct {
+	Name string `validate:"required,uppercase"`
+}
+
+type mockBadRuleKind struct {
+	Enabled bool `validate:"positive"`
+}
+
+func TestFieldRules(t *testing.T) {
+
+	type rulesTestCase struct {
+		mutator func(s *mockCheckedServer)
+		errors  []string
+		paths   []string
+	}
+
+	testCases := []rulesTestCase{
+		{mutator: func(s *mockCheckedServer) {}, errors: []string{}, paths: []string{}},
+		{
+			mutator: func(s *mockCheckedServer) { s.Primary.Address = "not/a/host"; s.Backup.Address = "not/a/host" },
+			errors: []string{
+				"the backup can't be the same server",
+				"address 'not/a/host' is not a valid hostname",
+				"address 'not/a/host' is not a valid hostname",
+			},
+			paths: []string{"", "primary", "backup"},
+		},
+		{
+			mutator: func(s *mockCheckedServer) { s.Primary.Port = 0; s.Backup.Port = 70000 },
+			errors: []string{
+				"port value is required and cannot be 0",
+				"port 70000 is not a valid port; it must be from 1 to 65535",
+			},
+			paths: []string{"primary", "backup"},
+		},
+		{
+			mutator: func(s *mockCheckedServer) { s.Primary.Admin = "  " },
+			errors: []string{
+				"admin must not be empty or whitespace",
+				"admin '  ' is not a valid email: mail: no address",
+			},
+			paths: []string{"primary", "primary"},
+		},
+		{
+			mutator: func(s *mockCheckedServer) {
+				s.Primary.Timeout = -time.Minute
+				s.Primary.Retries = 0
+				s.Primary.Aliases = []string{}
+			},
+			errors: []string{
+				"timeout must be positive, not '-1m0s'",
+				"Retries must be positive, not '0'",
+				"aliases must not be empty",
+			},
+			paths: []string{"primary", "primary", "primary"},
+		},
+	}
+
+	for index, testCase := range testCases {
+		t.Logf("running testcase %d", index)
+		server := mockServer{
+			Address: "mail.example.com",
+			Port:    25,
+			Admin:   "admin@example.com",
+			Timeout: time.Minute,
+			Retries: 3,
+			Aliases: []string{"mail"},
+		}
+		backup := server
+		backup.Address = "backup.example.com"
+		target := mockCheckedServer{Primary: server, Backup: &backup}
+		testCase.mutator(&target)
+
+		result, err := ValidateObject(target)
+		require.Nil(t, err)
+		//the top level and both servers
+		assert.Equal(t, 3, result.GetValidationCount())
+		errors := []string{}
+		paths := []string{}
+		for _, validationError := range result.GetErrorList() {
+			errors = append(errors, validationError.Error)
+			paths = append(paths, validationError.Path)
+		}
+		assert.Equal(t, testCase.errors, errors)
+		assert.Equal(t, testCase.paths, paths)
+	}
+}
+
+func TestFieldRulesMessages(t *testing.T) {
+	server := mockMessageServer{Admin: " ", Timeout: -time.Second}
+
+	//a field with a message gets one error even if it breaks several rules
+	result, err := ValidateObject(server)
+	require.Nil(t, err)
+	assert.Equal(t, 1, result.GetValidationCount())
+	require.Equal(t, 2, result.GetErrorCount())
+	assert.Equal(t, "the admin must be an email address", result.GetErrorList()[0].Error)
+	assert.Equal(t, "the timeout must be more than '-1s'", result.GetErrorList()[1].Error)
+
+	//the Validate method checks the rules when it is called directly too
+	direct := ValidationResult{}
+	err = server.Validate(&direct, nil)
+	require.Nil(t, err)
+	assert.Equal(t, result.GetErrorList(), direct.GetErrorList())
+}
+
+func TestFieldRulesItems(t *testing.T) {
+	group := mockServerGroup{Members: []string{"mail", " ", ""}, Admins: []string{"admin@example.com", "admin"}}
+
+	result, err := ValidateObject(group)
+	require.Nil(t, err)
+	errors := []string{}
+	for _, validationError := range result.GetErrorList() {
+		errors = append(errors, validationError.Error)
+	}
+	assert.Equal(t, []string{
+		"members[1] must not be empty or whitespace",
+		"members[2] must not be empty or whitespace",
+		"admins must be email addresses, not 'admin'",
+	}, errors)
+
+	//the rules before "items" are still checked on the list
+	result, err = ValidateObject(mockServerGroup{})
+	require.Nil(t, err)
+	require.Equal(t, 1, result.GetErrorCount())
+	assert.Equal(t, "members must not be empty", result.GetErrorList()[0].Error)
+}
+
+func TestFieldRulesMistakes(t *testing.T) {
+	_, err := ValidateObject(mockBadRule{Name: "a"})
+	assert.ErrorContains(t, err, "field Name of validation.mockBadRule has an unknown validate rule 'uppercase'")
+
+	_, err = ValidateObject(&mockBadRuleKind{})
+	assert.ErrorContains(t, err, "the validate rule 'positive' can't be used on field Enabled of validation.mockBadRuleKind, which is a bool")
+
+	_, err = ValidateObject(mockBadItemsRule{})
+	assert.ErrorContains(t, err, "the validate rule 'items' can't be used on field Name of validation.mockBadItemsRule, which is a string")
+}
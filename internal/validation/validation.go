@@ -5,62 +5,152 @@ import (
 	"reflect"
 	"strings"
 	"varanus/internal/walker"
-
-	"github.com/kr/pretty"
 )
 
+// Severity is how serious a validation issue is.  Only SEVERITY_ERROR makes the validated object
+// invalid.
+type Severity string
+
+// SEVERITY_ERROR is an issue that makes the object invalid
+const SEVERITY_ERROR Severity = "error"
+
+// SEVERITY_WARNING is an issue that is likely a mistake or a risk but still allows the object to
+// be used
+const SEVERITY_WARNING Severity = "warning"
+
+// SEVERITY_INFO is advice about the object that is not a problem
+const SEVERITY_INFO Severity = "info"
+
 // SingleValidationError captures the context for a single validation error discovered during
 // validation.
 //
-// It is the primary type created by the AddValidationError of ValidationResult
+// It is the primary type created by the AddValidationError of ValidationResult.  Warnings and
+// info added with AddValidationWarning and AddValidationInfo use it too, with a different Severity.
 type SingleValidationError struct {
 	// Error describes the validation error
 	Error string
 	// Object provides the context of the object where the error occured.
 	Object interface{}
+	// Path is the location in the validated hierarchy of the object whose validation reported
+	// the error, e.g. "mail.accounts[1].smtp".  It is empty for the root object.
+	Path string
+	// Location is where Path was defined in the source, if it is known.  See AddSourceLocations.
+	Location *SourceLocation
+	// Severity is SEVERITY_ERROR for validation errors
+	Severity Severity
+}
+
+// ROOT_PATH_NAME is shown in place of the empty path of the root object
+const ROOT_PATH_NAME = "(root)"
+
+// HumanReadable returns the error as "file:line:col: path: message", leaving out the location if
+// it is not known.  Warnings and info have the severity before the message, e.g.
+// "path: warning: message".
+func (sve SingleValidationError) HumanReadable() string {
+	path := sve.Path
+	if path == "" {
+		path = ROOT_PATH_NAME
+	}
+	message := sve.Error
+	if sve.Severity != SEVERITY_ERROR {
+		message = fmt.Sprintf("%s: %s", sve.Severity, sve.Error)
+	}
+	if sve.Location == nil {
+		return fmt.Sprintf("%s: %s", path, message)
+	}
+	return fmt.Sprintf("%s: %s: %s", sve.Location, path, message)
 }
 
 // ValidationResult is a collection of SingleValidationErrors accumulated during a call to
 // ValidateObject.
 type ValidationResult struct {
-	errorList       []SingleValidationError //accumulates the validation errors for ValidationErrorTracker
+	errorList       []SingleValidationError //accumulates the issues of every severity for ValidationErrorTracker
 	validationCount int                     //number of validation callbacks we hit
+	currentPath     string                  //path of the object being validated
 }
 
-// GetErrorList provides a copy of validation errors it holds.
+// GetErrorList provides a copy of validation errors it holds.  Warnings and info are not included.
 func (vr ValidationResult) GetErrorList() []SingleValidationError {
+	return vr.getListForSeverity(SEVERITY_ERROR)
+}
+
+// GetWarningList provides a copy of the validation warnings it holds.
+func (vr ValidationResult) GetWarningList() []SingleValidationError {
+	return vr.getListForSeverity(SEVERITY_WARNING)
+}
+
+// GetInfoList provides a copy of the validation info it holds.
+func (vr ValidationResult) GetInfoList() []SingleValidationError {
+	return vr.getListForSeverity(SEVERITY_INFO)
+}
+
+// GetIssueList provides a copy of the errors, warnings and info it holds, in the order they were
+// added.
+func (vr ValidationResult) GetIssueList() []SingleValidationError {
 	listCopy := make([]SingleValidationError, len(vr.errorList))
 	copy(listCopy, vr.errorList)
 	return listCopy
 }
 
-// HumanReadable returns a human-readable formatted list of the validation errors suitable for
-// printing to the terminal.
+func (vr ValidationResult) getListForSeverity(severity Severity) []SingleValidationError {
+	list := []SingleValidationError{}
+	for _, validationError := range vr.errorList {
+		if validationError.Severity == severity {
+			list = append(list, validationError)
+		}
+	}
+	return list
+}
+
+// HumanReadable returns a human-readable formatted list of the validation errors, followed by any
+// warnings and info, suitable for printing to the terminal.
 func (vr ValidationResult) HumanReadable() string {
 	var sb strings.Builder
 
-	if len(vr.errorList) > 0 {
+	errorList := vr.GetErrorList()
+	if len(errorList) > 0 {
+		writeSection(&sb, fmt.Sprintf("%d Validation Errors", len(errorList)), errorList)
+	} else {
+		sb.WriteString("No validation errors\n")
+	}
 
-		sb.WriteString("*****************************************************\n")
-		sb.WriteString(fmt.Sprintf("%d Validation Errors\n", len(vr.errorList)))
+	warningList := vr.GetWarningList()
+	if len(warningList) > 0 {
+		writeSection(&sb, fmt.Sprintf("%d Validation Warnings", len(warningList)), warningList)
+	}
 
-		for _, validationError := range vr.errorList {
-			sb.WriteString(fmt.Sprintf("Error %s on object:\n%# v\n",
-				validationError.Error, pretty.Formatter(validationError.Object)))
-			sb.WriteString("--------------------\n")
-		}
+	infoList := vr.GetInfoList()
+	if len(infoList) > 0 {
+		writeSection(&sb, fmt.Sprintf("%d Validation Info", len(infoList)), infoList)
+	}
+	return sb.String()
+}
 
-		sb.WriteString("*****************************************************\n")
+func writeSection(sb *strings.Builder, title string, list []SingleValidationError) {
+	sb.WriteString("*****************************************************\n")
+	sb.WriteString(title + "\n")
 
-	} else {
-		sb.WriteString("No validation errors\n")
+	for _, validationError := range list {
+		sb.WriteString(validationError.HumanReadable())
+		sb.WriteString("\n")
 	}
-	return sb.String()
+
+	sb.WriteString("*****************************************************\n")
 }
 
 // GetErrorCount returns the number of validation errors in the result
 func (vr ValidationResult) GetErrorCount() int {
-	return len(vr.errorList)
+	return len(vr.GetErrorList())
+}
+
+// GetWarningCount returns the number of validation warnings in the result
+func (vr ValidationResult) GetWarningCount() int {
+	return len(vr.GetWarningList())
+}
+
+// GetInfoCount returns the number of validation info in the result
+func (vr ValidationResult) GetInfoCount() int {
+	return len(vr.GetInfoList())
 }
 
 // GetValidationCount returns the number of validation callbacks that were executed
@@ -69,17 +159,18 @@ func (vr ValidationResult) GetValidationCount() int {
 }
 
 // AsError returns an error object containing all validation errors.  Returns nil if there are
-// no validation errors
+// no validation errors.  Warnings and info are not included.
 //
 // The error value returned here is not very user-friendly.  Consider using GetErrorList() or
 // HumanReadable if users are meant to parse the error list.
 func (vr ValidationResult) AsError() error {
-	if len(vr.errorList) == 0 {
+	errorList := vr.GetErrorList()
+	if len(errorList) == 0 {
 		return nil
 	}
 
 	var sb strings.Builder
-	for _, validationError := range vr.errorList {
+	for _, validationError := range errorList {
 		sb.Write([]byte(fmt.Sprintf("Error: %s on object %#v\n", validationError.Error, validationError.Object)))
 	}
 	return fmt.Errorf(sb.String())
@@ -95,14 +186,63 @@ func (vr ValidationResult) AsError() error {
 //
 // Implmentsf ValidationErrorTracker
 func (vr *ValidationResult) AddValidationError(object interface{}, message string, messageArgs ...interface{}) {
+	vr.addIssue(SEVERITY_ERROR, object, message, messageArgs...)
+}
+
+// AddValidationWarning adds a warning, which is reported like an error but does not make the
+// object invalid.  The parameters are the same as AddValidationError.
+//
+// Implements ValidationErrorTracker
+func (vr *ValidationResult) AddValidationWarning(object interface{}, message string, messageArgs ...interface{}) {
+	vr.addIssue(SEVERITY_WARNING, object, message, messageArgs...)
+}
+
+// AddValidationInfo adds advice that is not a problem.  The parameters are the same as
+// AddValidationError.
+//
+// Implements ValidationErrorTracker
+func (vr *ValidationResult) AddValidationInfo(object interface{}, message string, messageArgs ...interface{}) {
+	vr.addIssue(SEVERITY_INFO, object, message, messageArgs...)
+}
+
+// AddIssueAtPath adds an issue for the object at path, which is given explicitly instead of coming
+// from the object being validated.  The remaining parameters are the same as AddValidationError.
+//
+// Implements PathIssueTracker
+func (vr *ValidationResult) AddIssueAtPath(
+	severity Severity,
+	path string,
+	object interface{},
+	message string,
+	messageArgs ...interface{},
+) {
 	vr.errorList = append(vr.errorList, SingleValidationError{
-		Error:  fmt.Sprintf(message, messageArgs...),
-		Object: object,
+		Error:    fmt.Sprintf(message, messageArgs...),
+		Object:   object,
+		Path:     path,
+		Severity: severity,
 	})
 }
 
+func (vr *ValidationResult) addIssue(severity Severity, object interface{}, message string, messageArgs ...interface{}) {
+	vr.AddIssueAtPath(severity, vr.currentPath, object, message, messageArgs...)
+}
+
+// AddSourceLocations sets the Location of each validation error whose path can be found by the
+// locator.
+func (vr *ValidationResult) AddSourceLocations(locator SourceLocator) {
+	for index := range vr.errorList {
+		location, found := locator.LocatePath(vr.errorList[index].Path)
+		if found {
+			vr.errorList[index].Location = &location
+		}
+	}
+}
+
 // Validate walks the target object looking for elements (including the top level element) that
-// implement the validatable interface.
+// implement the validatable interface, or that are structs with validate tags (see rules.go).  The
+// rules of the tags are checked here for the structs that aren't validatable, and by the Validate
+// method for those that are.
 //
 // If validation errors are found, they will be accumulated in the ValidationResult.
 //
@@ -112,13 +252,25 @@ func (vr *ValidationResult) AddValidationError(object interface{}, message strin
 // This function only returns an error if a failure keeps the validation from completing or
 // the validation result is invalid due to an error.
 func ValidateObject(root interface{}) (ValidationResult, error) {
+	return ValidateObjectWithRoot(root, root)
+}
+
+// ValidateObjectWithRoot validates target like ValidateObject, but passes root to the Validate
+// methods instead of target.  It validates one part of a larger object, like a single item of a
+// config whose checks look up names elsewhere in the config.  The paths are relative to target.
+func ValidateObjectWithRoot(target interface{}, root interface{}) (ValidationResult, error) {
 
 	result := ValidationResult{}
 
 	validationWorker := func(needle interface{}, path string) error {
-		validationTarget := needle.(Validatable)
-
 		result.validationCount += 1
+		result.currentPath = path
+
+		//a struct without a Validate method only has the rules of its validate tags
+		validationTarget, isValidatable := needle.(Validatable)
+		if !isValidatable {
+			return CheckFieldRules(&result, needle)
+		}
 
 		//validate
 		err := validationTarget.Validate(&result, root)
@@ -132,9 +284,13 @@ func ValidateObject(root interface{}) (ValidationResult, error) {
 	}
 
 	validatableType := reflect.TypeOf((*Validatable)(nil)).Elem()
-	err := walker.WalkObjectImmutable(root, validatableType, validationWorker)
+	isValidationTarget := func(currentType reflect.Type) bool {
+		return currentType.Implements(validatableType) || hasFieldRules(currentType)
+	}
+	err := walker.WalkObjectImmutableMatching(target, isValidationTarget, validationWorker)
 	if err != nil {
 		return ValidationResult{}, err
 	}
+	result.currentPath = ""
 	return result, nil
 }
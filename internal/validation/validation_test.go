@@ -2,9 +2,11 @@ package validation
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type mockValidatable struct {
@@ -200,6 +202,16 @@ func TestValidationProcessWithErrors(t *testing.T) {
 		validationTarget.Middle2.Bottom2.mockValidatable,
 	}
 
+	expectedPaths := []string{
+		"",
+		"Middle1",
+		"Middle1.Bottom1",
+		"Middle1.Bottom2",
+		"Middle2",
+		"Middle2.Bottom1",
+		"Middle2.Bottom2",
+	}
+
 	validationResult, err := ValidateObject(validationTarget)
 	assert.Nil(t, err)
 	assert.Equal(t, 7, validationResult.GetErrorCount())
@@ -210,6 +222,8 @@ func TestValidationProcessWithErrors(t *testing.T) {
 	for index := 0; index < len(expectedErrors); index++ {
 		assert.Equal(t, expectedErrors[index], errorList[index].Error, "for index %d", index)
 		assert.Equal(t, expectedObjects[index], errorList[index].Object, "for index %d", index)
+		assert.Equal(t, expectedPaths[index], errorList[index].Path, "for index %d", index)
+		assert.Nil(t, errorList[index].Location, "for index %d", index)
 		assert.Contains(t, humanReadableResult, expectedErrors[index], "for index %d", index)
 		assert.Contains(t, errorResult, expectedErrors[index], "for index %d", index)
 	}
@@ -248,3 +262,116 @@ func TestValidatableWithValidationFailures(t *testing.T) {
 	assert.Nil(t, validationResult.AsError())
 
 }
+
+// mockLocator locates every path except those starting with "Middle2"
+type mockLocator struct{}
+
+func (ml mockLocator) LocatePath(path string) (SourceLocation, bool) {
+	if strings.HasPrefix(path, "Middle2") {
+		return SourceLocation{}, false
+	}
+	return SourceLocation{"config.yaml", len(path) + 1, 3}, true
+}
+
+func TestValidationSourceLocations(t *testing.T) {
+
+	validationTarget := mockValidationTargetTop{
+		mockValidatable: mockValidatable{"top validation error", "", false, ""},
+		Middle1: mockValidationTargetMiddle{
+			Bottom2: MockValidationTargetBottom{
+				mockValidatable: mockValidatable{"bottom validation error 12", "", false, ""},
+			},
+		},
+		Middle2: mockValidationTargetMiddle{
+			mockValidatable: mockValidatable{"middle validation error 2", "", false, ""},
+		},
+	}
+
+	validationResult, err := ValidateObject(validationTarget)
+	assert.Nil(t, err)
+	validationResult.AddSourceLocations(mockLocator{})
+
+	errorList := validationResult.GetErrorList()
+	assert.Equal(t, &SourceLocation{"config.yaml", 1, 3}, errorList[0].Location)
+	assert.Equal(t, &SourceLocation{"config.yaml", 16, 3}, errorList[1].Location)
+	assert.Nil(t, errorList[2].Location)
+
+	expectedHumanReadableString := `*****************************************************
+3 Validation Errors
+config.yaml:1:3: (root): top validation error
+config.yaml:16:3: Middle1.Bottom2: bottom validation error 12
+Middle2: middle validation error 2
+*****************************************************
+`
+	assert.Equal(t, expectedHumanReadableString, validationResult.HumanReadable())
+}
+
+// severityValidatable adds one issue of each severity
+type severityValidatable struct{}
+
+func (sv severityValidatable) Validate(vet ValidationErrorTracker, root interface{}) error {
+	vet.AddValidationInfo(sv, "info %d", 1)
+	vet.AddValidationWarning(sv, "warning %d", 2)
+	vet.AddValidationError(sv, "error %d", 3)
+	return nil
+}
+
+func TestValidationSeverities(t *testing.T) {
+
+	validationTarget := struct {
+		Item severityValidatable
+	}{}
+
+	validationResult, err := ValidateObject(validationTarget)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, validationResult.GetErrorCount())
+	assert.Equal(t, 1, validationResult.GetWarningCount())
+	assert.Equal(t, 1, validationResult.GetInfoCount())
+
+	errorList := validationResult.GetErrorList()
+	assert.Equal(t, "error 3", errorList[0].Error)
+	assert.Equal(t, SEVERITY_ERROR, errorList[0].Severity)
+	warningList := validationResult.GetWarningList()
+	assert.Equal(t, "warning 2", warningList[0].Error)
+	assert.Equal(t, SEVERITY_WARNING, warningList[0].Severity)
+	infoList := validationResult.GetInfoList()
+	assert.Equal(t, "info 1", infoList[0].Error)
+	assert.Equal(t, SEVERITY_INFO, infoList[0].Severity)
+
+	//the issue list keeps the order they were added in
+	issueList := validationResult.GetIssueList()
+	require.Len(t, issueList, 3)
+	assert.Equal(t, []Severity{SEVERITY_INFO, SEVERITY_WARNING, SEVERITY_ERROR},
+		[]Severity{issueList[0].Severity, issueList[1].Severity, issueList[2].Severity})
+
+	//only the errors make up the error
+	assert.ErrorContains(t, validationResult.AsError(), "error 3")
+	assert.NotContains(t, validationResult.AsError().Error(), "warning 2")
+
+	expectedHumanReadableString := `*****************************************************
+1 Validation Errors
+Item: error 3
+*****************************************************
+*****************************************************
+1 Validation Warnings
+Item: warning: warning 2
+*****************************************************
+*****************************************************
+1 Validation Info
+Item: info: info 1
+*****************************************************
+`
+	assert.Equal(t, expectedHumanReadableString, validationResult.HumanReadable())
+
+	//warnings alone don't make the object invalid
+	warningResult := ValidationResult{}
+	warningResult.AddValidationWarning(validationTarget, "just a warning")
+	assert.Equal(t, 0, warningResult.GetErrorCount())
+	assert.Nil(t, warningResult.AsError())
+	assert.Equal(t, `No validation errors
+*****************************************************
+1 Validation Warnings
+(root): warning: just a warning
+*****************************************************
+`, warningResult.HumanReadable())
+}
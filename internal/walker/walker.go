@@ -3,6 +3,7 @@ package walker
 import (
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 )
 
@@ -12,7 +13,9 @@ func SetVerbose(newval bool) {
 	verbose = newval
 }
 
-func getYamlNameFromTag(tag reflect.StructTag) string {
+// GetYamlNameFromTag returns the field name from the yaml tag of a struct field, or an empty
+// string if there is no yaml tag.
+func GetYamlNameFromTag(tag reflect.StructTag) string {
 	yamlTag := tag.Get("yaml")
 	if yamlTag == "" {
 		return ""
@@ -21,8 +24,19 @@ func getYamlNameFromTag(tag reflect.StructTag) string {
 	return tokens[0]
 }
 
+// IsYamlInline returns true if the yaml tag of a struct field has the inline flag, so its fields
+// are read as if they were fields of the struct that contains it.
+func IsYamlInline(tag reflect.StructTag) bool {
+	tokens := strings.Split(tag.Get("yaml"), ",")
+	return slices.Contains(tokens[1:], "inline")
+}
+
 func getFieldPathName(field reflect.StructField, currentType reflect.Type, currentValue reflect.Value) string {
-	yamlString := getYamlNameFromTag(field.Tag)
+	if IsYamlInline(field.Tag) {
+		//inlined fields don't add to the path, like they don't add to the yaml
+		return ""
+	}
+	yamlString := GetYamlNameFromTag(field.Tag)
 	if yamlString != "" {
 		return yamlString
 	}
@@ -32,7 +46,9 @@ func getFieldPathName(field reflect.StructField, currentType reflect.Type, curre
 }
 
 func addFieldToPath(path string, field string) string {
-	if path == "" {
+	if field == "" {
+		return path
+	} else if path == "" {
 		return field
 	} else {
 		return path + "." + field
@@ -80,6 +96,18 @@ func WalkObjectImmutable(
 	return walkObjectImplementation(haystack, needle, callback, false)
 }
 
+// WalkObjectImmutableMatching is like WalkObjectImmutable, but the needles are the objects whose
+// type is accepted by matches, instead of the objects of a single type.  This allows one walk to
+// find objects that are needles for different reasons, like implementing an interface or having a
+// struct tag.
+func WalkObjectImmutableMatching(
+	haystack interface{},
+	matches func(reflect.Type) bool,
+	callback func(interface{}, string) error,
+) error {
+	return walkObjectWithMatcher(haystack, matches, true, callback, false)
+}
+
 func isNeedleType(currentType reflect.Type, currentValue reflect.Value, needleType reflect.Type, isMutable bool) bool {
 	if currentType == needleType {
 		return true
@@ -105,6 +133,22 @@ func walkObjectImplementation(
 	callback func(interface{}, string) error,
 	isMutable bool,
 ) error {
+	matches := func(currentType reflect.Type) bool {
+		return isNeedleType(currentType, reflect.Value{}, needleType, isMutable)
+	}
+	return walkObjectWithMatcher(haystack, matches, needleType.Kind() == reflect.Interface, callback, isMutable)
+}
+
+// walkObjectWithMatcher walks the haystack and calls the callback for each object whose type is
+// accepted by matches.  If needleIsInterface is set, mutable walks pass the needle itself to the
+// callback instead of a pointer to it.
+func walkObjectWithMatcher(
+	haystack interface{},
+	matches func(reflect.Type) bool,
+	needleIsInterface bool,
+	callback func(interface{}, string) error,
+	isMutable bool,
+) error {
 
 	//declare recursive function
 	var process func(reflect.Value, string) error
@@ -117,7 +161,8 @@ func walkObjectImplementation(
 			fmt.Printf("path: %s type: %s value %s\n", path, currentType, currentValue)
 		}
 
-		if isNeedleType(currentType, currentValue, needleType, isMutable) {
+		isNeedle := matches(currentType)
+		if isNeedle {
 			// found the object type we were looking for
 			var err error
 
@@ -133,10 +178,10 @@ func walkObjectImplementation(
 				if !currentValue.CanSet() && currentType.Kind() != reflect.Pointer {
 					return fmt.Errorf(
 						"at path=%s, value=%s, found a value of type %s, but it is not settable",
-						path, currentValue, needleType,
+						path, currentValue, currentType,
 					)
 				}
-				if needleType.Kind() == reflect.Interface {
+				if needleIsInterface {
 					//if the needle type is already an interface, don't get the pointer
 					err = callback(currentValue.Interface(), path)
 				} else {
@@ -177,6 +222,15 @@ func walkObjectImplementation(
 			}
 
 		}
+		if currentType.Kind() == reflect.Interface {
+			//walk the value held by the interface, like a registered config type, unless the
+			//interface was the needle.  For mutable walks, it must hold a pointer for its fields
+			//to be changed.
+			if isNeedle || currentValue.IsNil() {
+				return nil
+			}
+			return process(currentValue.Elem(), path)
+		}
 		if currentType.Kind() == reflect.Struct {
 			for _, field := range reflect.VisibleFields(currentType) {
 				//skip exported fields
@@ -203,10 +257,13 @@ func walkObjectImplementation(
 		}
 		if currentType.Kind() == reflect.Slice {
 			for index := 0; index < currentValue.Len(); index++ {
-				err := process(
-					currentValue.Index(index),
-					fmt.Sprintf("%s[%d]", path, index),
-				)
+				itemValue := currentValue.Index(index)
+				if isMutable && itemValue.CanAddr() {
+					//like struct fields, pass the pointer to slice items so that needles whose
+					//methods have pointer receivers are found
+					itemValue = itemValue.Addr()
+				}
+				err := process(itemValue, fmt.Sprintf("%s[%d]", path, index))
 				if err != nil {
 					return err
 				}
@@ -796,6 +796,110 @@ func TestWalkerWithNullNeedlePointers(t *testing.T) {
 	assert.Equal(t, 1, callbackCount)
 }
 
+type NeedleList struct {
+	Items []NeedleObject
+}
+
+func TestWalkerMutableSliceItemsWithPointerReceivers(t *testing.T) {
+	object := NeedleList{
+		Items: []NeedleObject{{}, {}},
+	}
+
+	pathSequence := []string{}
+	testCallback := func(needle interface{}, path string) error {
+		_, isPointer := needle.(*NeedleObject)
+		assert.True(t, isPointer)
+		pathSequence = append(pathSequence, path)
+		return nil
+	}
+
+	needleType := reflect.TypeOf((*NeedleInterface)(nil)).Elem()
+
+	//the mutable walk finds the items through their pointers
+	err := WalkObjectMutable(&object, needleType, testCallback)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Items[0]", "Items[1]"}, pathSequence)
+
+	//the immutable walk only has copies, which don't implement the interface
+	pathSequence = []string{}
+	err = WalkObjectImmutable(&object, needleType, testCallback)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{}, pathSequence)
+}
+
+type AnyContainer struct {
+	Name  string      `yaml:"name"`
+	Value interface{} `yaml:"value"`
+	Empty interface{} `yaml:"empty"`
+	Extra interface{} `yaml:",inline"`
+}
+
+func TestWalkerFollowsInterfaceValues(t *testing.T) {
+	object := AnyContainer{
+		Value: &NeedleList{Items: []NeedleObject{{}}},
+		Extra: &NeedleObject{},
+	}
+
+	pathSequence := []string{}
+	testCallback := func(needle interface{}, path string) error {
+		_, isPointer := needle.(*NeedleObject)
+		assert.True(t, isPointer)
+		pathSequence = append(pathSequence, path)
+		return nil
+	}
+
+	needleType := reflect.TypeOf((*NeedleInterface)(nil)).Elem()
+
+	//the values held by the interfaces are walked, and the inlined one doesn't add to the path
+	err := WalkObjectMutable(&object, needleType, testCallback)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"value.Items[0]", ""}, pathSequence)
+
+	pathSequence = []string{}
+	err = WalkObjectImmutable(object, reflect.TypeOf(NeedleObject{}), func(needle interface{}, path string) error {
+		pathSequence = append(pathSequence, path)
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"value.Items[0]", ""}, pathSequence)
+}
+
+func TestWalkObjectImmutableMatching(t *testing.T) {
+	object := loadTestObject(t, yamlStr)
+
+	//match the structs that have an int_val field of any type
+	matches := func(currentType reflect.Type) bool {
+		if currentType.Kind() != reflect.Struct {
+			return false
+		}
+		_, found := currentType.FieldByName("IntVal")
+		return found
+	}
+	pathSequence := []string{}
+	err := WalkObjectImmutableMatching(object, matches, func(needle interface{}, path string) error {
+		assert.True(t, matches(reflect.TypeOf(needle)))
+		pathSequence = append(pathSequence, path)
+		return nil
+	})
+	assert.Nil(t, err)
+	slices.Sort(pathSequence)
+	assert.Equal(t, []string{
+		"field_a",
+		"field_b.a_list[0]",
+		"field_b.a_list[1]",
+		"field_b.a_map[bar]",
+		"field_b.a_map[foo]",
+		"field_c.field_d",
+		"field_c.field_e",
+	}, pathSequence)
+}
+
+func TestIsYamlInline(t *testing.T) {
+	fields := reflect.TypeOf(AnyContainer{})
+	assert.False(t, IsYamlInline(fields.Field(0).Tag))
+	assert.True(t, IsYamlInline(fields.Field(3).Tag))
+}
+
 type SimpleItem struct {
 }
 
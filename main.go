@@ -17,11 +17,11 @@ func main() {
 	if err != nil {
 		//don't need to print the error because Cobra already prints it, just set the return type
 		//based on what kind of error
-		_, ok := err.(app.ApplicationError)
+		appErr, ok := err.(app.ApplicationError)
 		if ok {
-			os.Exit(2)
+			os.Exit(appErr.ExitCode())
 		} else {
-			os.Exit(1)
+			os.Exit(app.EXIT_CODE_USAGE)
 		}
 	}
 